@@ -0,0 +1,32 @@
+// --answer-file: the final answer (the finish tool's summary when the model reported one) is
+// written to a file instead of stdout, for generating documents from scripts. The file is
+// replaced atomically, and an answer that is one fenced code block as a whole loses the
+// fence. stdout gets a one-line confirmation; with --quiet that is all it gets. With --then
+// the file is rewritten after each step, so it holds the latest answer even if a later step
+// dies.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// quiet is --quiet: no progress lines, notices or run summary, only the result and errors.
+var quiet bool
+
+// writeAnswer replaces path with text, unfenced.
+func writeAnswer(path, text string) (int, error) {
+	data := []byte(ensureNewline(unfence(text)))
+	return len(data), replaceFile(path, data)
+}
+
+// replaceFile writes data to path atomically, through a temporary file in the same directory.
+func replaceFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*"); if err != nil { return err }
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil { tmp.Close(); return err }
+	if err := tmp.Close(); err != nil { return err }
+	if err := os.Chmod(tmp.Name(), 0644); err != nil { return err }
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,100 @@
+// Structured API errors, so callers can react to specific error types instead of grepping text.
+// The common ones also get a line of advice (which variable to check, what to do about a
+// request that's too big), shown above the raw error and carried in --output json as "hint"
+// next to "error_type".
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type APIError struct {
+	Status        int
+	Type, Message string
+	Body          []byte
+	Truncated     int64 // Body was cut at this many bytes
+}
+
+const maxErrorShown = 4096 // of the body, in the message
+
+func (e *APIError) Error() string {
+	body := string(e.Body); if len(body) > maxErrorShown { body = strings.ToValidUTF8(body[:maxErrorShown], "") + "…" }
+	s := fmt.Sprintf("API error %d: %s", e.Status, body)
+	if e.Truncated > 0 { s += fmt.Sprintf(" [body truncated at %d bytes]", e.Truncated) }
+	return s
+}
+
+func newAPIError(status int, body []byte) *APIError {
+	var v struct{ Error struct{ Type, Message string; Metadata struct{ Raw json.RawMessage `json:"raw"`; Provider string `json:"provider_name"` } } }
+	json.Unmarshal(body, &v)
+	e := &APIError{Status: status, Type: v.Error.Type, Message: v.Error.Message, Body: body}
+	unwrapError(e, v.Error.Metadata.Raw, v.Error.Metadata.Provider)
+	return e
+}
+
+var tooLong = regexp.MustCompile(`(\d+) tokens > (\d+)`)
+
+// contextExceeded reports whether err means the request no longer fits the context window.
+// Proxies that rewrite error bodies lose the type, hence the substring fallback.
+func contextExceeded(err error) bool {
+	if err == nil { return false }
+	msg := err.Error()
+	var ae *APIError
+	if errors.As(err, &ae) && ae.Type != "" {
+		if ae.Type != "invalid_request_error" { return false }
+		msg = ae.Message
+	}
+	msg = strings.ToLower(msg)
+	return strings.Contains(msg, "prompt is too long") || strings.Contains(msg, "context window") || strings.Contains(msg, "context_length_exceeded")
+}
+
+// tokensOver extracts how far over the limit a "N tokens > M maximum" error was.
+func tokensOver(err error) (int, bool) {
+	m := tooLong.FindStringSubmatch(err.Error()); if m == nil { return 0, false }
+	got, _ := strconv.Atoi(m[1]); limit, _ := strconv.Atoi(m[2]); return got - limit, true
+}
+
+// apiHints are the advice shown above an API error, first match winning. An entry matches
+// on the error type, or the status when a proxy dropped the type, and on a message
+// substring when it has one. In hints, {model}, {session} and {keys} are filled in.
+var apiHints = []struct {
+	status   int
+	typ      string
+	contains string // lowercase
+	hint     string
+}{
+	{401, "authentication_error", "", "The API key was rejected. Check {keys}, or the key stored with nano auth set; nano doctor shows which one is in use."},
+	{403, "permission_error", "", "The key isn't allowed to use {model} or this feature. Check the key's workspace, or pick another model with --model."},
+	{404, "not_found_error", "", "The model {model} wasn't found. Check MODEL or --model; nano doctor checks that the model is available, and ANTHROPIC_BASE_URL if you go through a proxy."},
+	{400, "invalid_request_error", "max_tokens", "{model} can't produce as many output tokens as nano asks for. Lower --max-output-tokens or --max-output-tokens-tools, or use a model with a larger output limit."},
+	{400, "invalid_request_error", "prompt is too long", "The conversation no longer fits {model}'s context window. Run /compact in a session, read narrower parts of files, or start a new session."},
+	{413, "request_too_large", "", "The request is too big to send. Run /compact in a session or read narrower parts of files; config max_request_bytes is nano's own limit."},
+	{429, "rate_limit_error", "", "Rate limited. Wait a minute, then continue with nano --resume {session}."},
+	{529, "overloaded_error", "", "The API is overloaded; nano made this call once and doesn't retry it. Wait a minute and continue with nano --resume {session}, or use another model with --model."},
+	{500, "api_error", "", "The API had an internal error, usually a passing one. Continue with nano --resume {session}."},
+}
+
+// errorHint is the type and advice for err, both empty when it isn't an API error nano
+// knows; the type falls back to the one its status implies.
+func (a *Agent) errorHint(err error) (typ, hint string) {
+	var e *APIError; if !errors.As(err, &e) { return "", "" }
+	for _, h := range apiHints {
+		if e.Type != h.typ && (e.Type != "" || e.Status != h.status) || !strings.Contains(strings.ToLower(e.Message+string(e.Body)), h.contains) { continue }
+		ks := keyEnvs(); keys := strings.Join(ks[:len(ks)-1], ", ") + " or " + ks[len(ks)-1]
+		return h.typ, strings.NewReplacer("{model}", a.Model, "{session}", a.Session, "{keys}", keys).Replace(h.hint)
+	}
+	return e.Type, ""
+}
+
+// printError shows err on stderr, with its hint above it.
+func printError(hint string, err any) {
+	if hint != "" { fmt.Fprintln(os.Stderr, "✗ "+hint) }
+	fmt.Fprintln(os.Stderr, "Error:", err)
+}
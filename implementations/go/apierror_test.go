@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// failingAPI answers every request with status and body.
+func failingAPI(t *testing.T, status int, body string) *fakeAPI {
+	f := &fakeAPI{}
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock(); f.reqs = append(f.reqs, nil); f.mu.Unlock()
+		w.WriteHeader(status); io.WriteString(w, body)
+	}))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func apiErrorBody(typ, msg string) string { return fmt.Sprintf(`{"type":"error","error":{"type":%q,"message":%q}}`, typ, msg) }
+
+var hintCases = []struct {
+	name, body string
+	status     int
+	typ, hint  string // hint: a phrase the advice must contain; "" for none
+}{
+	{"401", apiErrorBody("authentication_error", "invalid x-api-key"), 401, "authentication_error", "The API key was rejected. Check ANTHROPIC_API_KEY"},
+	{"404", apiErrorBody("not_found_error", "model: claude-nope"), 404, "not_found_error", "The model claude-sonnet-4-20250514 wasn't found."},
+	{"400 max_tokens", apiErrorBody("invalid_request_error", "max_tokens: 64000 > 32000, which is the maximum allowed"), 400, "invalid_request_error", "Lower --max-output-tokens"},
+	{"400 other", apiErrorBody("invalid_request_error", "messages: roles must alternate"), 400, "invalid_request_error", ""},
+	{"413", apiErrorBody("request_too_large", "Request exceeds the maximum allowed number of bytes"), 413, "request_too_large", "The request is too big to send."},
+	{"413 from a proxy", "<html><body>413 Request Entity Too Large</body></html>", 413, "request_too_large", "The request is too big to send."},
+	{"529", apiErrorBody("overloaded_error", "Overloaded"), 529, "overloaded_error", "The API is overloaded; nano made this call once"},
+}
+
+func TestAPIErrorHints(t *testing.T) {
+	for _, c := range hintCases {
+		a := testAgent(t, failingAPI(t, c.status, c.body))
+		_, err := a.Run("hello"); if err == nil { t.Fatalf("%s: no error", c.name) }
+		typ, hint := a.errorHint(err)
+		if typ != c.typ { t.Errorf("%s: type %q, want %q", c.name, typ, c.typ) }
+		if c.hint == "" && hint != "" || !strings.Contains(hint, c.hint) { t.Errorf("%s: hint %q, want one containing %q", c.name, hint, c.hint) }
+		if strings.ContainsAny(hint, "{}") { t.Errorf("%s: placeholder left in %q", c.name, hint) }
+		if c.name == "529" && !strings.Contains(hint, "nano --resume "+a.Session) { t.Errorf("529: the hint doesn't name the session: %q", hint) }
+	}
+}
+
+func TestAPIErrorHintsInTheJSONReport(t *testing.T) {
+	for _, c := range hintCases {
+		f := failingAPI(t, c.status, c.body)
+		stdout, stderr, code := runNano(t, f, t.TempDir(), "--output", "json", "hello")
+		if code == 0 { t.Errorf("%s: exit 0", c.name) }
+		var rep struct{ Status, Error, Hint string; ErrorType string `json:"error_type"` }
+		if err := json.Unmarshal([]byte(stdout), &rep); err != nil { t.Fatalf("%s: %v in %q (stderr %s)", c.name, err, stdout, stderr); continue }
+		if rep.Status != "failed" || rep.ErrorType != c.typ || !strings.Contains(rep.Hint, c.hint) || c.hint == "" && rep.Hint != "" { t.Errorf("%s: %+v", c.name, rep) }
+	}
+}
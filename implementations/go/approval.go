@@ -0,0 +1,56 @@
+// Approval of mutating tool calls, the CLI's Approver (see hooks.go). With --yes everything runs; otherwise a terminal user is
+// asked before each write or command, and can save the answer for the project (see
+// permissions.go). Without a terminal there is nobody to ask, so calls
+// proceed as they always have and the audit log records them as non-interactive, except under
+// --ci, where they are declined.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var autoApprove bool
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// terminalApprover is the CLI's Approver. By is "--yes", "interactive", "non-interactive" or
+// a saved rule.
+func (a *Agent) terminalApprover(_ context.Context, c ToolCall) (Decision, error) {
+	if c.Suspicious != "" { return a.approveSuspicious(c), nil }
+	if r, ok := permissionFor(c.Name, c.Detail); ok && (r.Decision == "deny" || !autoApprove) { return Decision{r.Decision == "allow", r.approval()}, nil }
+	switch {
+	case autoApprove: return Decision{true, "--yes"}, nil
+	case a.ci != nil: return Decision{false, "ci (declined)"}, nil
+	case !isTTY(os.Stdin): return Decision{true, "non-interactive"}, nil
+	}
+	fmt.Fprintf(os.Stderr, "Allow %s %s? [y]es / [N]o / [a]lways / ne[v]er: ", c.Name, c.Detail)
+	switch strings.ToLower(strings.TrimSpace(readAnswer())) {
+	case "y", "yes": return Decision{true, "interactive"}, nil
+	case "a", "always": rememberDecision(c.Name, c.Detail, "allow"); return Decision{true, "interactive (always)"}, nil
+	case "v", "never": rememberDecision(c.Name, c.Detail, "deny"); return Decision{false, "interactive (never)"}, nil
+	}
+	return Decision{false, "interactive (denied)"}, nil
+}
+
+// describeCall is the one-line summary of a call shown in prompts: the command, URL or path.
+func describeCall(in Input) string {
+	if c := in.Str("command"); c != "" { return c }
+	if u := in.Str("url"); u != "" { return strings.TrimSpace(strings.ToUpper(in.Str("method")) + " " + u) }
+	if p := in.Str("pattern"); p != "" { return fmt.Sprintf("%q → %q", p, in.Str("replacement")) }
+	return relPath(in.Str("path"))
+}
+
+// approveSuspicious asks about the first mutating call after a possible prompt injection,
+// overriding --yes and saved rules; with no terminal the call is declined.
+func (a *Agent) approveSuspicious(c ToolCall) Decision {
+	fmt.Fprintln(os.Stderr, "⚠ approval required: "+c.Suspicious)
+	if !isTTY(os.Stdin) { return Decision{false, "declined after possible prompt injection (no terminal)"} }
+	fmt.Fprintf(os.Stderr, "Allow %s %s anyway? [y/N] ", c.Name, c.Detail)
+	if a := strings.ToLower(strings.TrimSpace(readAnswer())); a == "y" || a == "yes" { return Decision{true, "interactive (after possible prompt injection)"} }
+	return Decision{false, "interactive (denied after possible prompt injection)"}
+}
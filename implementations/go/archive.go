@@ -0,0 +1,86 @@
+// The archive tool: list or extract .zip, .tar and .tar.gz without shelling out. Extraction
+// only ever creates regular files and directories inside the destination; entries that would
+// land elsewhere (zip-slip), links and devices are skipped and reported.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxListed, maxExtracted = 300, 1 << 30
+
+type archiveEntry struct {
+	name string
+	size int64
+	mode fs.FileMode
+	open func() (io.ReadCloser, error)
+}
+
+func archive(in Input) (string, error) {
+	path, err := resolvePath(in.Str("path")); if err != nil { return "", err }
+	var dest string
+	if in.Str("action") == "extract" {
+		if in.Str("destination") == "" { return "", errors.New("extract needs a destination") }
+		if dest, err = resolvePath(in.Str("destination")); err != nil { return "", err }
+		if err := os.MkdirAll(dest, 0755); err != nil { return "", err }
+		if dest, err = filepath.Abs(dest); err != nil { return "", err }; dest = realPath(dest)
+	} else if in.Str("action") != "list" { return "", fmt.Errorf("unknown action %q (want list or extract)", in.Str("action")) }
+	var lines []string; var total int64; count, skipped := 0, 0
+	err = walkArchive(path, func(e archiveEntry) error {
+		count++
+		if dest == "" {
+			if count <= maxListed { lines = append(lines, fmt.Sprintf("%10d  %s", e.size, e.name)) }
+			total += e.size; return nil
+		}
+		target := filepath.Join(dest, e.name)
+		if !e.mode.IsRegular() && !e.mode.IsDir() || !within(realPath(target), dest) || filepath.IsAbs(e.name) {
+			skipped++; lines = append(lines, "skipped "+e.name); return nil
+		}
+		if e.mode.IsDir() { return os.MkdirAll(target, 0755) }
+		if total += e.size; total > maxExtracted { return fmt.Errorf("archive expands past %d bytes; stopping", maxExtracted) }
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil { return err }
+		r, err := e.open(); if err != nil { return err }; defer r.Close()
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, e.mode.Perm()|0600); if err != nil { return err }
+		n, err := io.Copy(f, io.LimitReader(r, e.size+1))
+		if cerr := f.Close(); err == nil { err = cerr }
+		if err == nil && n > e.size { err = fmt.Errorf("%s is larger than its header claims", e.name) }
+		return err
+	})
+	if err != nil { return strings.Join(lines, "\n"), err }
+	if dest == "" {
+		if count > maxListed { lines = append(lines, fmt.Sprintf("... and %d more entries", count-maxListed)) }
+		return strings.Join(append(lines, fmt.Sprintf("%d entries, %d bytes uncompressed", count, total)), "\n"), nil
+	}
+	return strings.Join(append(lines, fmt.Sprintf("extracted %d of %d entries (%d bytes) to %s", count-skipped, count, total, in.Str("destination"))), "\n"), nil
+}
+
+// walkArchive calls fn for each entry, picking the format from the file name.
+func walkArchive(path string, fn func(archiveEntry) error) error {
+	name := strings.ToLower(path)
+	if strings.HasSuffix(name, ".zip") {
+		z, err := zip.OpenReader(path); if err != nil { return err }; defer z.Close()
+		for _, f := range z.File { if err := fn(archiveEntry{f.Name, int64(f.UncompressedSize64), f.Mode(), f.Open}); err != nil { return err } }
+		return nil
+	}
+	if !strings.HasSuffix(name, ".tar") && !strings.HasSuffix(name, ".tar.gz") && !strings.HasSuffix(name, ".tgz") { return fmt.Errorf("unsupported archive %s (want .zip, .tar, .tar.gz or .tgz)", filepath.Base(path)) }
+	f, err := os.Open(path); if err != nil { return err }; defer f.Close()
+	var r io.Reader = f
+	if !strings.HasSuffix(name, ".tar") { gz, err := gzip.NewReader(f); if err != nil { return err }; defer gz.Close(); r = gz }
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF { return nil } else if err != nil { return err }
+		body := io.NopCloser(tr)
+		if err := fn(archiveEntry{h.Name, h.Size, h.FileInfo().Mode(), func() (io.ReadCloser, error) { return body, nil }}); err != nil { return err }
+	}
+}
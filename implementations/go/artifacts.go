@@ -0,0 +1,108 @@
+// Run artifacts: what is too big for a tool result, or worth keeping after the run, goes to
+// .nano/runs/<session>/ in the project. That is the whole output of a command cut at the tool
+// output limit, the summary written when the history is compacted, and each verification
+// round's review with the diff it looked at. The tool result or notice names the file, so the
+// model can read the rest and a person can look later. `nano artifacts [session]` lists them;
+// only the newest --keep-runs run directories are kept.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var keepRuns = 20
+
+func runsDir(root string) string { return filepath.Join(root, ".nano", "runs") }
+
+// saveArtifact writes content to the run's directory and returns its path for messages.
+func (a *Agent) saveArtifact(name, content string) (string, error) {
+	dir := filepath.Join(runsDir(workDir()), a.Session)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0755); err != nil { return "", err }
+		os.WriteFile(filepath.Join(runsDir(workDir()), ".gitignore"), []byte("*\n"), 0644)
+		pruneRuns(workDir(), a.Session)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%03d-%s", len(entries)+1, name))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil { return "", err }
+	return relPath(path), nil
+}
+
+// spill cuts a result at the tool output limit, keeping the whole of it as an artifact.
+func (a *Agent) spill(tool, out string) string {
+	path, err := a.saveArtifact(tool+".txt", out)
+	if err != nil { slog.Warn("could not save the full tool output", "tool", tool, "err", err); return clip(out) }
+	return out[:maxToolOutput] + fmt.Sprintf("\n[... truncated %d bytes; the full output is in %s]", len(out)-maxToolOutput, path)
+}
+
+// pruneRuns removes the oldest run directories past keepRuns (0 keeps them all).
+func pruneRuns(root, current string) {
+	if keepRuns <= 0 { return }
+	runs := listRuns(root); n := 0
+	for _, r := range runs {
+		if r.id == current { continue }
+		if n++; n < keepRuns { continue } // the current run takes one of the places
+		if err := os.RemoveAll(filepath.Join(runsDir(root), r.id)); err != nil { slog.Warn("could not prune a run directory", "run", r.id, "err", err) }
+	}
+}
+
+type runEntry struct {
+	id    string
+	mod   time.Time
+	files int
+	size  int64
+}
+
+// listRuns returns the project's run directories, newest first.
+func listRuns(root string) []runEntry {
+	entries, _ := os.ReadDir(runsDir(root))
+	var runs []runEntry
+	for _, e := range entries {
+		if !e.IsDir() { continue }
+		fi, err := e.Info(); if err != nil { continue }
+		r := runEntry{id: e.Name(), mod: fi.ModTime()}
+		files, _ := os.ReadDir(filepath.Join(runsDir(root), e.Name()))
+		for _, f := range files { if info, err := f.Info(); err == nil { r.files++; r.size += info.Size() } }
+		runs = append(runs, r)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].mod.After(runs[j].mod) })
+	return runs
+}
+
+func artifactsMain(fs *flag.FlagSet) func(args []string) int {
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano artifacts [session]\n\nLists the runs with artifacts in this project's .nano/runs, or one session's files."); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			runs := listRuns(workDir())
+			if len(runs) == 0 { fmt.Println("no run artifacts in", relPath(runsDir(workDir()))); return 0 }
+			for _, r := range runs { fmt.Printf("%-24s %s  %3d file(s) %8s\n", r.id, r.mod.Format("2006-01-02 15:04"), r.files, humanBytes(r.size)) }
+			return 0
+		}
+		id := fs.Arg(0); root := workDir()
+		if s, err := loadSession(id); err == nil { root = s.Dir }
+		dir := filepath.Join(runsDir(root), id)
+		files, err := os.ReadDir(dir)
+		if os.IsNotExist(err) { fmt.Fprintf(os.Stderr, "Error: no artifacts for %s in %s\n", id, runsDir(root)); return 1 } else if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		for _, f := range files {
+			info, err := f.Info(); if err != nil { continue }
+			fmt.Printf("%8s  %s\n", humanBytes(info.Size()), relPath(filepath.Join(dir, f.Name())))
+		}
+		return 0
+	}
+}
+
+func humanBytes(n int64) string {
+	switch {
+	case n >= 1<<20: return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10: return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -0,0 +1,73 @@
+// Audit trail of side effects, independent of the transcript: one JSON line per mutating tool
+// call, written (and synced) before the call runs, then another with its outcome. The file
+// lives at ~/.local/share/nano/audit.log unless --audit-log or NANO_AUDIT_LOG says otherwise,
+// is created 0600 and only ever opened for append.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+var auditPath = defaultAuditPath()
+
+func defaultAuditPath() string {
+	if p := os.Getenv("NANO_AUDIT_LOG"); p != "" { return p }
+	home, _ := os.UserHomeDir(); return filepath.Join(home, ".local", "share", "nano", "audit.log")
+}
+
+type auditRecord struct {
+	Time     string `json:"time"`
+	Phase    string `json:"phase"`
+	Session  string `json:"session"`
+	User     string `json:"user"`
+	Cwd      string `json:"cwd"`
+	Tool     string `json:"tool"`
+	Command  string `json:"command,omitempty"`
+	Path     string `json:"path,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Bytes    *int   `json:"bytes,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Approval string `json:"approval"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (a *Agent) auditEntry(phase, tool string, in Input, approval string) auditRecord {
+	cwd, _ := os.Getwd(); if sandboxRoot != "" { cwd = sandboxRoot }
+	r := auditRecord{Time: time.Now().UTC().Format(time.RFC3339Nano), Phase: phase, Session: a.Session, User: username(), Cwd: cwd, Tool: tool, Command: in.Str("command"), Path: in.Str("path"), URL: in.Str("url"), Approval: approval}
+	if c, ok := in["content"].(string); ok { n := len(c); r.Bytes = &n }
+	return r
+}
+
+func (a *Agent) auditResult(tool string, in Input, approval string, err error) auditRecord {
+	r := a.auditEntry("result", tool, in, approval); r.Bytes = nil
+	if err != nil { r.Error = err.Error() }
+	if tool == "bash" {
+		code := 0; var ee *exec.ExitError
+		if errors.As(err, &ee) { code = ee.ExitCode() } else if err != nil { code = -1 }
+		r.ExitCode = &code
+	} else if p, perr := resolvePath(r.Path); err == nil && perr == nil && r.Path != "" {
+		if fi, statErr := os.Stat(p); statErr == nil { n := int(fi.Size()); r.Bytes = &n }
+	}
+	return r
+}
+
+func audit(r auditRecord) error {
+	if err := os.MkdirAll(filepath.Dir(auditPath), 0700); err != nil { return err }
+	f, err := os.OpenFile(auditPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); if err != nil { return err }
+	line, _ := json.Marshal(r)
+	if _, err := f.Write(append(line, '\n')); err != nil { f.Close(); return err }
+	if err := f.Sync(); err != nil { f.Close(); return err }
+	return f.Close()
+}
+
+func username() string {
+	if u, err := user.Current(); err == nil { return u.Username }
+	return os.Getenv("USER")
+}
@@ -0,0 +1,147 @@
+// Where the API key comes from, first found wins: --api-key, the stdout of $NANO_API_KEY_CMD
+// (pass, the 1Password CLI, `security find-generic-password`, ...), the system keychain
+// (macOS Keychain through security(1), libsecret through secret-tool(1) elsewhere), then
+// $ANTHROPIC_API_KEY and $ANTHROPIC_AUTH_TOKEN (after the gateway's own variable, such as
+// $OPENROUTER_API_KEY, with a PROVIDER preset). The winning source is logged at debug level,
+// never the key. `nano auth set|get|delete` manages the keychain entry.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// apiKeyFlag is --api-key; like any argument it shows in process listings, so it's for one-offs.
+var apiKeyFlag string
+
+const keyService, keyAccount = "nano", "anthropic"
+
+const keyCmdTimeout = 30 * time.Second // long enough to unlock a password manager
+
+// resolveKey returns the API key and a description of where it came from; an empty key with
+// no error means none was found.
+func resolveKey() (key, source string, err error) {
+	defer func() { if key != "" { logSecrets = append(logSecrets, key); slog.Debug("api key", "source", source) } }()
+	if apiKeyFlag != "" { return apiKeyFlag, "--api-key", nil }
+	if cmd := os.Getenv("NANO_API_KEY_CMD"); cmd != "" {
+		key, err := keyCommand(cmd); if err != nil { return "", "", err }
+		return key, "NANO_API_KEY_CMD", nil
+	}
+	if key, err := keychainGet(); err == nil && key != "" { return key, "keychain", nil } else if err != nil { slog.Debug("keychain lookup failed", "err", err) }
+	for _, k := range keyEnvs() { if v := os.Getenv(k); v != "" { return v, k, nil } }
+	return "", "", nil
+}
+
+// keyCommand runs cmd through the shell and returns its trimmed stdout; a failure carries the
+// command's stderr.
+func keyCommand(cmd string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyCmdTimeout); defer cancel()
+	c := exec.CommandContext(ctx, "sh", "-c", cmd); var stderr bytes.Buffer; c.Stderr = &stderr
+	if isTTY(os.Stdin) { c.Stdin = os.Stdin } // so it can ask for a passphrase
+	out, err := c.Output()
+	if msg := strings.TrimSpace(stderr.String()); err != nil && msg != "" { return "", fmt.Errorf("NANO_API_KEY_CMD failed: %v: %s", err, truncate(msg, 500)) } else if err != nil { return "", fmt.Errorf("NANO_API_KEY_CMD failed: %v", err) }
+	key := strings.TrimSpace(string(out)); if key == "" { return "", errors.New("NANO_API_KEY_CMD printed nothing") }
+	if strings.ContainsAny(key, "\n\r") { return "", errors.New("NANO_API_KEY_CMD printed more than one line; it should print only the key") }
+	return key, nil
+}
+
+// keychain runs the platform's keychain tool; errNoKeychain when there is none.
+func keychain(stdin string, args ...string) (string, error) {
+	var name string
+	switch runtime.GOOS {
+	case "darwin": name = "security"
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly": name = "secret-tool"
+	default: return "", errNoKeychain
+	}
+	if _, err := exec.LookPath(name); err != nil { return "", fmt.Errorf("%w (%s not found)", errNoKeychain, name) }
+	c := exec.Command(name, args...); c.Stdin = strings.NewReader(stdin); var stderr bytes.Buffer; c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil { if msg := strings.TrimSpace(stderr.String()); msg != "" { err = fmt.Errorf("%s: %v: %s", name, err, msg) }; return "", err }
+	return strings.TrimSpace(string(out)), nil
+}
+
+var errNoKey = errors.New("Set ANTHROPIC_API_KEY or ANTHROPIC_AUTH_TOKEN, or store a key with nano auth set")
+
+var errNoKeychain = errors.New("no system keychain available")
+
+func keychainGet() (string, error) {
+	if runtime.GOOS == "darwin" { return keychain("", "find-generic-password", "-s", keyService, "-a", keyAccount, "-w") }
+	return keychain("", "lookup", "service", keyService, "account", keyAccount)
+}
+
+// keychainSet stores key, passing it on stdin rather than the command line.
+func keychainSet(key string) error {
+	if runtime.GOOS == "darwin" {
+		_, err := keychain(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", keyService, keyAccount, shellQuote(key)), "-i"); return err
+	}
+	_, err := keychain(key, "store", "--label=nano API key", "service", keyService, "account", keyAccount); return err
+}
+
+func keychainDelete() error {
+	if runtime.GOOS == "darwin" { _, err := keychain("", "delete-generic-password", "-s", keyService, "-a", keyAccount); return err }
+	_, err := keychain("", "clear", "service", keyService, "account", keyAccount); return err
+}
+
+func shellQuote(s string) string { return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'" }
+
+// maskKey shows enough of a key to tell which one it is.
+func maskKey(key string) string {
+	if len(key) < 12 { return fmt.Sprintf("(%d chars)", len(key)) }
+	return key[:7] + "…" + key[len(key)-4:]
+}
+
+// authMain implements `nano auth set|get|delete`.
+func authMain(fs *flag.FlagSet) func(args []string) int {
+	show := fs.Bool("show", false, "get: print the key itself rather than a masked form")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano auth set | get [--show] | delete\n\nset reads the key from the terminal (not echoed) or standard input and stores it in the system keychain."); fs.PrintDefaults() }
+	return func(args []string) int {
+		sub := ""; if len(args) > 0 && !strings.HasPrefix(args[0], "-") { sub, args = args[0], args[1:] }
+		fs.Parse(args)
+		var err error
+		switch sub {
+		case "set":
+			key, rerr := readSecret("API key: "); if rerr != nil { err = rerr; break }
+			if key = strings.TrimSpace(key); key == "" { err = errors.New("no key given"); break }
+			if err = keychainSet(key); err == nil { fmt.Printf("stored %s in the keychain\n", maskKey(key)) }
+		case "get":
+			key, gerr := keychainGet()
+			if gerr != nil { err = fmt.Errorf("reading the keychain: %w", gerr); break } else if key == "" { err = errors.New("no key in the keychain"); break }
+			if *show { fmt.Println(key) } else { fmt.Println(maskKey(key)) }
+			if _, src, _ := resolveKey(); src != "keychain" && src != "" { fmt.Fprintf(os.Stderr, "note: %s takes precedence over the keychain\n", src) }
+		case "delete":
+			if err = keychainDelete(); err == nil { fmt.Println("removed the key from the keychain") }
+		default:
+			fs.Usage(); return 2
+		}
+		if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		return 0
+	}
+}
+
+// readSecret reads one line without echoing it when stdin is a terminal.
+func readSecret(prompt string) (string, error) {
+	if !isTTY(os.Stdin) { line, err := stdin.ReadString('\n'); if err != nil && line == "" { return "", err }; return line, nil }
+	fmt.Fprint(os.Stderr, prompt)
+	restore, err := makeRaw(os.Stdin); if err != nil { return "", err }
+	defer func() { restore(); fmt.Fprintln(os.Stderr) }()
+	var buf []byte; b := make([]byte, 1)
+	for {
+		if _, err := os.Stdin.Read(b); err != nil { return "", err }
+		switch b[0] {
+		case '\r', '\n': return string(buf), nil
+		case 3, 4: return "", errors.New("cancelled")
+		case 127, 8: if len(buf) > 0 { buf = buf[:len(buf)-1] }
+		default: buf = append(buf, b[0])
+		}
+	}
+}
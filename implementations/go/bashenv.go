@@ -0,0 +1,73 @@
+// The environment bash commands run in. By default it is minimal: an allowlist of harmless
+// variables (PATH, HOME, locale, TERM and a few more) plus the names listed in config
+// "bash_env", so tokens and cloud credentials in the user's shell never reach a command the
+// model picked, or an `env` dump in its output. --inherit-env passes everything through, as
+// before. Either way bash output is run through scrubSecrets before the model sees it.
+//
+// Neither includes the .nano/env variables, which go only to the commands the user defines
+// (see projectenv.go).
+//
+// On top of either, every bash command, verify_command and fix-mode command gets nano's run
+// metadata, a stable interface for wrapper scripts (a deploy script refusing to run under
+// NANO=1, a logger tying entries to the transcript):
+//
+//	NANO=1
+//	NANO_SESSION_ID  the session, as nano --resume takes it
+//	NANO_TURN        API calls so far in the session
+//	NANO_MODEL       the model in use
+//	NANO_WORKSPACE   the workspace root (the sandbox, else the working directory)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+var inheritEnv bool
+
+// bashEnvAllow are the variables every command gets; a trailing * matches a prefix.
+var bashEnvAllow = []string{"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LANGUAGE", "LC_*", "TERM", "TZ", "TMPDIR"}
+
+const bashDescription = "Run a shell command. The environment is minimal (PATH, HOME, locale, TERM and any variables the user configured): tokens and credentials from the user's shell are not available, so don't assume a command can authenticate anywhere unless its configuration is in the workspace."
+
+const bashInheritDescription = "Run a shell command in the user's full environment."
+
+// bashEnv is the environment for a bash command, nil meaning nano's own.
+func bashEnv() []string {
+	if inheritEnv { return nil }
+	allow := append(append([]string{}, bashEnvAllow...), cfg.BashEnv...)
+	out := []string{} // not nil, which would inherit everything
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		for _, a := range allow {
+			if name == a || strings.HasSuffix(a, "*") && strings.HasPrefix(name, strings.TrimSuffix(a, "*")) { out = append(out, kv); break }
+		}
+	}
+	return out
+}
+
+// runMeta supplies the agent's side of the metadata; newAgent sets it.
+var runMeta func() (session, model string, turn int)
+
+// withRunEnv adds the metadata variables to env (nil meaning nano's own), overriding any
+// already there.
+func withRunEnv(env []string) []string {
+	if env == nil { env = os.Environ() }
+	env = append(env, "NANO=1", "NANO_WORKSPACE="+workDir())
+	if runMeta != nil { s, m, t := runMeta(); env = append(env, "NANO_SESSION_ID="+s, "NANO_MODEL="+m, fmt.Sprintf("NANO_TURN=%d", t)) }
+	return env
+}
+
+// scrubOutput redacts secrets from command output and notes how many there were.
+func scrubOutput(s string) string {
+	s, n := scrubSecrets(s); if n > 0 { s += fmt.Sprintf("\n[%d secret(s) redacted from the output]", n) }
+	return s
+}
+
+// useInheritedEnv switches bash to the full environment and says so in its description.
+func useInheritedEnv() {
+	inheritEnv = true
+	for i := range registry { if registry[i].Name == "bash" { registry[i].Description = bashInheritDescription } }
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBashGetsRunMetadataInBothModes(t *testing.T) {
+	saved := inheritEnv; t.Cleanup(func() { inheritEnv = saved })
+	t.Setenv("NANO_TEST_PASSTHROUGH", "from-the-shell"); t.Setenv("NANO_MODEL", "stale")
+	for _, inherit := range []bool{false, true} {
+		inheritEnv = inherit
+		f := newFakeAPI(t, toolReply("t1", "bash", `{"command":"env"}`), textReply("done"))
+		a := testAgent(t, f)
+		if _, err := a.Run("show the environment"); err != nil { t.Fatal(err) }
+		out, _ := toolResult(t, f.request(t, 1), "t1")["content"].(string)
+		vars := map[string]string{}
+		for _, line := range strings.Split(out, "\n") { if k, v, ok := strings.Cut(line, "="); ok { vars[k] = v } }
+		wd, _ := os.Getwd()
+		for k, want := range map[string]string{"NANO": "1", "NANO_SESSION_ID": a.Session, "NANO_TURN": "1", "NANO_MODEL": a.Model, "NANO_WORKSPACE": wd} {
+			if vars[k] != want { t.Errorf("inherit=%v: %s=%q, want %q", inherit, k, vars[k], want) }
+		}
+		if got := vars["NANO_TEST_PASSTHROUGH"]; inherit != (got == "from-the-shell") { t.Errorf("inherit=%v: the shell's variable came through as %q", inherit, got) }
+		if _, ok := vars["PATH"]; !ok { t.Errorf("inherit=%v: no PATH", inherit) }
+	}
+}
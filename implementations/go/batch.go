@@ -0,0 +1,62 @@
+// Message Batches mode (--batch): each API call is submitted as a one-request batch and polled
+// until it ends, at half the price of a live call. The batch ID is kept under the user cache
+// dir, keyed by the request body, so rerunning an interrupted command resumes polling the
+// batch it already paid for instead of submitting a new one.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var batchPoll, batchPollMax = 5 * time.Second, time.Minute
+
+func batchFile(body []byte) string {
+	dir, _ := os.UserCacheDir(); sum := sha256.Sum256(body)
+	return filepath.Join(dir, "nano", "batches", hex.EncodeToString(sum[:8]))
+}
+
+func (a *Agent) postBatch(body []byte) ([]byte, error) {
+	state := batchFile(body)
+	var batch struct{ ID string `json:"id"`; Status string `json:"processing_status"` }
+	if data, err := os.ReadFile(state); err == nil {
+		batch.ID = strings.TrimSpace(string(data)); a.notify("⏳ resuming batch " + batch.ID)
+	} else {
+		req, _ := json.Marshal(map[string]any{"requests": []map[string]any{{"custom_id": "nano-" + a.Session, "params": json.RawMessage(body)}}})
+		raw, err := a.do("POST", a.URL+"/batches", req); if err != nil { return nil, err }
+		if err := json.Unmarshal(raw, &batch); err != nil || batch.ID == "" { return nil, fmt.Errorf("unexpected batch response: %s", raw) }
+		os.MkdirAll(filepath.Dir(state), 0700)
+		if err := os.WriteFile(state, []byte(batch.ID), 0600); err != nil { slog.Warn("couldn't save batch id; an interrupted run will resubmit", "err", err) }
+		a.notify("⏳ submitted batch " + batch.ID)
+		time.Sleep(batchPoll)
+	}
+	for delay := batchPoll; ; delay = delay * 3 / 2 {
+		if delay > batchPollMax { delay = batchPollMax }
+		raw, err := a.do("GET", a.URL+"/batches/"+batch.ID, nil)
+		if ae := (*APIError)(nil); errors.As(err, &ae) && ae.Status == 404 { os.Remove(state) }
+		if err != nil { return nil, err }
+		if err := json.Unmarshal(raw, &batch); err != nil { return nil, fmt.Errorf("unexpected batch response: %s", raw) }
+		slog.Info("batch poll", "id", batch.ID, "status", batch.Status)
+		if batch.Status == "ended" { break }
+		time.Sleep(delay)
+	}
+	raw, err := a.do("GET", a.URL+"/batches/"+batch.ID+"/results", nil); if err != nil { return nil, err }
+	os.Remove(state)
+	var line struct{ Result struct{ Type string; Message, Error json.RawMessage } }
+	if err := json.Unmarshal([]byte(strings.SplitN(strings.TrimSpace(string(raw)), "\n", 2)[0]), &line); err != nil { return nil, fmt.Errorf("unexpected batch results: %s", raw) }
+	switch line.Result.Type {
+	case "succeeded": return line.Result.Message, nil
+	case "errored":
+		e := newAPIError(500, line.Result.Error); if e.Type == "invalid_request_error" { e.Status = 400 }; return nil, e
+	}
+	return nil, errors.New("batch " + batch.ID + " " + line.Result.Type)
+}
@@ -0,0 +1,33 @@
+// Calls on the same file within one batch. A turn's tool calls run one after another in the
+// order the model wrote them, so a read after a write in the same batch sees the new content
+// (the write leaves the read cache marked unseen) and two edits to a file never overlap. What
+// ordering alone can't prevent is an edit planned against the state an earlier call in the
+// batch was to produce: when a call that changes a file fails (an edit_file whose old_string
+// wasn't found, a declined write_file), later changes to the same file in that batch are
+// skipped, with a result saying which call they depended on. Paths are compared canonically
+// (see paths.go), so ./a.go and a symlink to it are the same file.
+
+package main
+
+import "fmt"
+
+// batchPaths maps a file whose change failed this batch to the call that failed.
+type batchPaths map[string]string
+
+// target is the file a mutating call changes, if it names one.
+func (a *Agent) target(b Block, in Input) (string, bool) {
+	t, ok := a.lookup(b.Name); p := in.Str("path")
+	if !ok || p == "" || t.readOnlyCall(in) { return "", false }
+	return canonPath(p), true
+}
+
+// blocked is the result for a call skipped because of an earlier failure, or "".
+func (bp batchPaths) blocked(path string, ok bool) string {
+	prev, failed := bp[path]; if !ok || !failed { return "" }
+	return fmt.Sprintf("Error: not run: the earlier %s in this batch failed, and this call changes the same file (%s), so it may have depended on that change. Check the file's current state and call again.", prev, relPath(path))
+}
+
+// record notes a failed change; later calls on the path are then blocked.
+func (bp batchPaths) record(path string, ok bool, name string, n int, isErr bool) {
+	if ok && isErr { if _, seen := bp[path]; !seen { bp[path] = fmt.Sprintf("%s call (call %d)", name, n) } }
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBatchSkipsEditsAfterAFailedChangeToTheSameFile(t *testing.T) {
+	f := newFakeAPI(t, reply("tool_use",
+		toolBlock("t1", "edit_file", `{"path":"a.go","old_string":"missing","new_string":"x"}`),
+		toolBlock("t2", "edit_file", `{"path":"./a.go","old_string":"one","new_string":"two"}`),
+		toolBlock("t3", "edit_file", `{"path":"link.go","old_string":"one","new_string":"three"}`),
+		toolBlock("t4", "edit_file", `{"path":"b.go","old_string":"one","new_string":"two"}`)), textReply("done"))
+	a := testAgent(t, f)
+	writeTestFile(t, "a.go", "one\n"); writeTestFile(t, "b.go", "one\n")
+	if err := os.Symlink("a.go", "link.go"); err != nil { t.Fatal(err) }
+	if _, err := a.Run("edit"); err != nil { t.Fatal(err) }
+	req := f.request(t, 1)
+	if r := toolResult(t, req, "t1"); r["is_error"] != true { t.Error("t1 should fail: old_string not found") }
+	for _, id := range []string{"t2", "t3"} { // ./a.go and the symlink are a.go too
+		r := toolResult(t, req, id); content, _ := r["content"].(string)
+		if r["is_error"] != true || !strings.Contains(content, "the earlier edit_file call (call 1) in this batch failed") { t.Errorf("%s: %v %q, want it skipped for depending on call 1", id, r["is_error"], content) }
+	}
+	if r := toolResult(t, req, "t4"); r["is_error"] == true { t.Errorf("t4 on another file should run: %v", r["content"]) }
+	if readTestFile(t, "a.go") != "one\n" || readTestFile(t, "b.go") != "two\n" { t.Errorf("a.go %q, b.go %q", readTestFile(t, "a.go"), readTestFile(t, "b.go")) }
+}
+
+func TestBatchRunsSameFileCallsInOrder(t *testing.T) {
+	f := newFakeAPI(t, reply("tool_use",
+		toolBlock("t1", "write_file", `{"path":"a.txt","content":"first\n"}`),
+		toolBlock("t2", "read_file", `{"path":"a.txt"}`),
+		toolBlock("t3", "edit_file", `{"path":"a.txt","old_string":"first","new_string":"second"}`),
+		toolBlock("t4", "read_file", `{"path":"a.txt"}`)), textReply("done"))
+	a := testAgent(t, f)
+	writeTestFile(t, "a.txt", "zero\n")
+	if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+	req := f.request(t, 1)
+	for id, want := range map[string]string{"t2": "first\n", "t4": "second\n"} {
+		if got, _ := toolResult(t, req, id)["content"].(string); got != want { t.Errorf("%s read %q, want %q: a read sees the writes before it in the batch", id, got, want) }
+	}
+}
+
+func TestFailedReadDoesNotBlockLaterChanges(t *testing.T) {
+	f := newFakeAPI(t, reply("tool_use",
+		toolBlock("t1", "read_file", `{"path":"new.txt"}`),
+		toolBlock("t2", "write_file", `{"path":"new.txt","content":"made\n"}`)), textReply("done"))
+	a := testAgent(t, f)
+	if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+	if r := toolResult(t, f.request(t, 1), "t2"); r["is_error"] == true { t.Errorf("a failed read changes nothing, so the write should run: %v", r["content"]) }
+}
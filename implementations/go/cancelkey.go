@@ -0,0 +1,60 @@
+// Cancelling a running command. While bash runs with a person at the terminal, pressing x
+// kills the command's process group and the model gets what it printed so far plus "command
+// cancelled by user after Ns", so it can adapt instead of the whole run being aborted with
+// Ctrl-C. In interactive mode the running turn's typeahead takes the key (only at the start
+// of a line, so typing a queued message that begins with x still works); otherwise the
+// terminal is put in polling raw mode for the command's duration and restored afterwards,
+// with Ctrl-C passed on as the interrupt it would have been and other keys kept for whatever
+// reads stdin next (an approval prompt typed ahead).
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const cancelHintAfter = 2 * time.Second
+
+// watchCancelKey calls cancel when the user presses x. The returned stop ends the listening,
+// restores the terminal and reports whether the key was pressed.
+func watchCancelKey(cancel func()) (stop func() bool) {
+	var mu sync.Mutex; pressed := false
+	fire := func() { mu.Lock(); pressed = true; mu.Unlock(); fmt.Fprintln(ui, "\r⏹ cancelling the command"); cancel() }
+	wasPressed := func() bool { mu.Lock(); defer mu.Unlock(); return pressed }
+	if q := active; q != nil {
+		q.mu.Lock(); q.cancel = fire; q.mu.Unlock()
+		hint := time.AfterFunc(cancelHintAfter, func() { fmt.Fprintln(ui, "  … press x to cancel this command") })
+		return func() bool { hint.Stop(); q.mu.Lock(); q.cancel = nil; q.mu.Unlock(); return wasPressed() }
+	}
+	if keys != nil || !isTTY(os.Stdin) || !isTTY(os.Stderr) { return func() bool { return false } }
+	restore, err := makePolling(os.Stdin); if err != nil { return func() bool { return false } }
+	var once sync.Once; reset := func() { once.Do(restore) }
+	done, exited := make(chan struct{}), make(chan struct{}); var typed []byte
+	hint := time.AfterFunc(cancelHintAfter, func() { fmt.Fprintln(ui, "  … press x to cancel this command") })
+	go func() {
+		defer close(exited)
+		buf := make([]byte, 1)
+		for {
+			select { case <-done: return; default: }
+			if n, _ := os.Stdin.Read(buf); n == 0 { continue } // a read times out after 100ms
+			switch buf[0] {
+			case 'x', 'X': fire(); return
+			case 3: // Ctrl-C: what the terminal would have sent without raw mode
+				reset(); p, _ := os.FindProcess(os.Getpid()); p.Signal(os.Interrupt); return
+			case '\r': typed = append(typed, '\n') // Enter, as the terminal would have translated it
+			default: typed = append(typed, buf[0])
+			}
+		}
+	}()
+	return func() bool {
+		hint.Stop(); close(done); <-exited; reset()
+		if len(typed) > 0 { stdin = bufio.NewReader(io.MultiReader(bytes.NewReader(typed), stdin)) }
+		return wasPressed()
+	}
+}
@@ -0,0 +1,97 @@
+// --changes-file: a JSON account of what a run did to the workspace, for tools that act on
+// it (a bot opening a pull request) instead of scraping the summary. It is written at exit
+// from the same tracking as the summary and --show-diff-on-exit: every file a tool wrote,
+// compared from its content before the first write to what is on disk at the end, so later
+// edits by bash or an editor count. A tracked file deleted while another was created with its
+// exact former content is reported as renamed. Files changed only by bash commands aren't
+// tracked. --changes-no-diff leaves the diffs out.
+//
+// The schema is versioned: a consumer checks "schema" and can rely on version 1 gaining
+// fields but not losing or changing them.
+//
+//	{"schema": "nano-changes/1", "session": "…", "prompt": "…", "status": "completed",
+//	 "exit_code": 0, "cost_usd": 0.0123 (null when a price is unknown),
+//	 "files": [{"path": "src/a.go" (workspace-relative, / separated),
+//	            "change": "created" | "modified" | "deleted" | "renamed",
+//	            "from": "old/path.go" (renamed only),
+//	            "before_sha256": "…" (not for created), "after_sha256": "…" (not for deleted),
+//	            "added": 3, "removed": 1 (lines),
+//	            "diff": "…" (nano's line diff: ' ', '-' and '+' lines, '…' for skipped ones)}]}
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const changesSchema = "nano-changes/1"
+
+type changeSet struct {
+	Schema   string       `json:"schema"`
+	Session  string       `json:"session"`
+	Prompt   string       `json:"prompt"`
+	Status   string       `json:"status"`
+	ExitCode int          `json:"exit_code"`
+	CostUSD  *float64     `json:"cost_usd"`
+	Files    []fileChange `json:"files"`
+}
+
+type fileChange struct {
+	Path    string `json:"path"`
+	Change  string `json:"change"`
+	From    string `json:"from,omitempty"`
+	Before  string `json:"before_sha256,omitempty"`
+	After   string `json:"after_sha256,omitempty"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Diff    string `json:"diff,omitempty"`
+}
+
+// changeSet collects the run's file changes as they are on disk now.
+func (a *Agent) changeSet(prompt, status string, code int, diffs bool) changeSet {
+	cs := changeSet{Schema: changesSchema, Session: a.Session, Prompt: prompt, Status: status, ExitCode: code, Files: []fileChange{}}
+	if c, ok := a.runCost(); ok { cs.CostUSD = &c }
+	for _, p := range sortedKeys(a.originals) {
+		was := a.originals[p]; now, err := os.ReadFile(p); exists := err == nil
+		if !was.existed && !exists || was.existed && exists && string(now) == string(was.data) { continue }
+		fc := fileChange{Path: filepath.ToSlash(relPath(p)), Change: "modified"}
+		if was.existed { fc.Before = sha256Hex(was.data) } else { fc.Change = "created" }
+		if exists { fc.After = sha256Hex(now) } else { fc.Change = "deleted" }
+		d := lineDiff(string(was.data), string(now))
+		for _, l := range strings.Split(d, "\n") { if strings.HasPrefix(l, "+") { fc.Added++ } else if strings.HasPrefix(l, "-") { fc.Removed++ } }
+		if diffs { fc.Diff = d }
+		cs.Files = append(cs.Files, fc)
+	}
+	return cs.pairRenames()
+}
+
+// pairRenames turns a deletion and a creation with the same content into one rename.
+func (cs changeSet) pairRenames() changeSet {
+	var out []fileChange; used := map[int]bool{}
+	for i, f := range cs.Files {
+		if f.Change != "created" { continue }
+		for j, g := range cs.Files {
+			if g.Change != "deleted" || used[j] || g.Before != f.After { continue }
+			used[i], used[j] = true, true
+			f.Change, f.From, f.Before, f.Added, f.Removed, f.Diff = "renamed", g.Path, g.Before, 0, 0, ""
+			out = append(out, f); break
+		}
+	}
+	for i, f := range cs.Files { if !used[i] { out = append(out, f) } }
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	cs.Files = out; if cs.Files == nil { cs.Files = []fileChange{} }
+	return cs
+}
+
+func (cs changeSet) write(path string) error {
+	data, err := json.MarshalIndent(cs, "", "  "); if err != nil { return err }
+	return replaceFile(path, append(data, '\n'))
+}
+
+func sha256Hex(data []byte) string { sum := sha256.Sum256(data); return hex.EncodeToString(sum[:]) }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestChangeSetRoundTrip(t *testing.T) {
+	f := newFakeAPI(t,
+		reply("tool_use",
+			toolBlock("t1", "write_file", `{"path":"new.go","content":"package main\n"}`),
+			toolBlock("t2", "write_file", `{"path":"edit.txt","content":"one\nTWO\n"}`),
+			toolBlock("t3", "write_file", `{"path":"moved.txt","content":"keep me\n"}`),
+			toolBlock("t4", "write_file", `{"path":"old.txt","content":"scratch\n"}`),
+			toolBlock("t5", "bash", `{"command":"rm old.txt"}`)),
+		textReply("done"))
+	a := testAgent(t, f)
+	writeTestFile(t, "edit.txt", "one\ntwo\n"); writeTestFile(t, "old.txt", "keep me\n")
+	if _, err := a.Run("reorganize"); err != nil { t.Fatal(err) }
+	cs := a.changeSet("reorganize", "completed", 0, true)
+	if err := cs.write("changes.json"); err != nil { t.Fatal(err) }
+	data, err := os.ReadFile("changes.json"); if err != nil { t.Fatal(err) }
+	var back changeSet; if err := json.Unmarshal(data, &back); err != nil { t.Fatal(err) }
+	if !reflect.DeepEqual(back, cs) { t.Errorf("round trip changed it:\n got %+v\nwant %+v", back, cs) }
+	if back.Schema != "nano-changes/1" { t.Errorf("schema %q", back.Schema) }
+	changes := map[string]fileChange{}; for _, fc := range back.Files { changes[fc.Path] = fc }
+	if len(changes) != 3 || changes["new.go"].Change != "created" || changes["edit.txt"].Change != "modified" || changes["moved.txt"].Change != "renamed" || changes["moved.txt"].From != "old.txt" {
+		t.Fatalf("files %+v", back.Files)
+	}
+	if e := changes["edit.txt"]; e.Added != 1 || e.Removed != 1 || e.Before != sha256Hex([]byte("one\ntwo\n")) || e.After != sha256Hex([]byte("one\nTWO\n")) || e.Diff == "" { t.Errorf("edit.txt %+v", e) }
+	if c := changes["new.go"]; c.Before != "" || c.After == "" { t.Errorf("new.go %+v", c) }
+	// the documented field names, and "from" only on renames
+	var raw struct{ Files []map[string]any }
+	json.Unmarshal(data, &raw)
+	var top map[string]any; json.Unmarshal(data, &top)
+	for _, k := range []string{"schema", "session", "prompt", "status", "exit_code", "cost_usd", "files"} { if _, ok := top[k]; !ok { t.Errorf("no %q", k) } }
+	for _, fc := range raw.Files {
+		for _, k := range []string{"path", "change", "added", "removed"} { if _, ok := fc[k]; !ok { t.Errorf("%v: no %q", fc["path"], k) } }
+		if _, ok := fc["from"]; ok != (fc["change"] == "renamed") { t.Errorf("%v: from present = %v", fc["path"], ok) }
+	}
+}
+
+func TestChangeSetWithoutDiffsOrChanges(t *testing.T) {
+	a := testAgent(t, newFakeAPI(t))
+	data, _ := json.Marshal(a.changeSet("nothing", "completed", 0, false))
+	var top map[string]any; json.Unmarshal(data, &top)
+	if files, ok := top["files"].([]any); !ok || len(files) != 0 { t.Errorf("files %v, want an empty list, not null", top["files"]) }
+}
@@ -0,0 +1,130 @@
+// CI mode (--ci), aimed at GitHub Actions. Each tool call's output is folded into a
+// ::group:: in the log instead of a truncated line, compiler and linter diagnostics in command
+// output ("path:line[:col]: message" for a file in the tree) become ::error annotations, and
+// when $GITHUB_STEP_SUMMARY is set a Markdown summary of the run (prompt, outcome, files changed
+// with line counts, commands run, cost) is appended to it on every exit path. Nobody can answer
+// an approval prompt in CI, so writes and commands are declined unless --yes is given. Exit
+// codes are unchanged.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type ciRun struct {
+	prompt    string
+	commands  []ciCommand
+	annotated map[string]bool
+}
+
+type ciCommand struct {
+	command string
+	failed  bool
+}
+
+func newCIRun(prompt string) *ciRun { return &ciRun{prompt: prompt, annotated: map[string]bool{}} }
+
+const (
+	ciGroupOutput    = 20000 // bytes of tool output shown in a group
+	ciMaxAnnotations = 50
+)
+
+// diagnosticLine matches "path:line: message" and "path:line:col: message", the format of
+// gcc, go vet, eslint --format unix, flake8, rustc's short output and most linters.
+var diagnosticLine = regexp.MustCompile(`^\s*([^\s:][^:]*):(\d+)(?::(\d+))?:\s*(.+)$`)
+
+// showToolStart and showToolResult print a tool call's progress: one line each normally, a
+// collapsible group with the full output in CI mode.
+func (a *Agent) showToolStart(e Event) {
+	if a.ci == nil { fmt.Fprintln(ui, "⚡", e.Name); return }
+	fmt.Fprintf(ui, "::group::⚡ %s %s\n", e.Name, ciEscape(truncate(e.Detail, 120)))
+}
+
+func (a *Agent) showToolResult(e Event) {
+	r := e.Text
+	if a.ci == nil { fmt.Fprintln(ui, r[:min(len(r), 100)]); return }
+	out := r; if len(out) > ciGroupOutput { out = out[:ciGroupOutput] + fmt.Sprintf("\n… %d more bytes", len(r)-ciGroupOutput) }
+	fmt.Fprintln(ui, strings.TrimRight(out, "\n")); fmt.Fprintln(ui, "::endgroup::")
+	if e.Name != "bash" || strings.HasPrefix(r, "Error: the user declined") || strings.HasPrefix(r, "Error: not run") { return }
+	a.ci.commands = append(a.ci.commands, ciCommand{e.Detail, e.IsError})
+	a.ci.annotate(r)
+}
+
+// annotate turns the diagnostics in a command's output into error annotations, once each.
+func (c *ciRun) annotate(out string) {
+	for _, line := range strings.Split(out, "\n") {
+		m := diagnosticLine.FindStringSubmatch(line); if m == nil || len(c.annotated) >= ciMaxAnnotations { continue }
+		file := filepath.ToSlash(filepath.Clean(m[1]))
+		if fi, err := os.Stat(file); err != nil || fi.IsDir() || filepath.IsAbs(file) || strings.HasPrefix(file, "../") { continue }
+		key := file + ":" + m[2] + ":" + m[4]; if c.annotated[key] { continue }
+		c.annotated[key] = true
+		loc := "file=" + ciProperty(file) + ",line=" + m[2]; if m[3] != "" { loc += ",col=" + m[3] }
+		fmt.Fprintf(ui, "::error %s::%s\n", loc, ciEscape(m[4]))
+	}
+}
+
+// writeSummary appends the run's Markdown summary to $GITHUB_STEP_SUMMARY, if set.
+func (a *Agent) writeSummary(code int) {
+	path := os.Getenv("GITHUB_STEP_SUMMARY"); if a.ci == nil || path == "" { return }
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "### nano: %s\n\n", exitOutcome(code))
+	fmt.Fprintf(&sb, "**Prompt**\n\n%s\n\n", fence("", truncate(a.ci.prompt, 2000)))
+	if files := a.diffStats(); len(files) > 0 {
+		sb.WriteString("| File | Added | Removed |\n|---|---:|---:|\n")
+		for _, f := range files { fmt.Fprintf(&sb, "| `%s` | +%d | −%d |\n", f.path, f.added, f.removed) }
+		sb.WriteString("\n")
+	} else {
+		sb.WriteString("No files changed.\n\n")
+	}
+	if len(a.ci.commands) > 0 {
+		sb.WriteString("**Commands run**\n\n")
+		for _, c := range a.ci.commands { mark := "✓"; if c.failed { mark = "✗" }; fmt.Fprintf(&sb, "- %s `%s`\n", mark, strings.ReplaceAll(truncate(c.command, 200), "`", "'")) }
+		sb.WriteString("\n")
+	}
+	cost := "cost unknown"; if c, ok := a.runCost(); ok { cost = fmt.Sprintf("$%.4f", c) }
+	fmt.Fprintf(&sb, "%s · %d turn(s) · %d input / %d output tokens · model %s · session `%s`\n\n", cost, a.Turns, a.Usage.InputTokens, a.Usage.OutputTokens, a.Model, a.Session)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); if err != nil { fmt.Fprintln(os.Stderr, "warning: job summary:", err); return }
+	defer f.Close()
+	if _, err := f.WriteString(sb.String()); err != nil { fmt.Fprintln(os.Stderr, "warning: job summary:", err) }
+}
+
+type fileStat struct {
+	path           string
+	added, removed int
+}
+
+// diffStats counts the lines added and removed in each file written this run.
+func (a *Agent) diffStats() []fileStat {
+	var out []fileStat
+	for _, p := range sortedKeys(a.originals) {
+		was := a.originals[p]; now, _ := os.ReadFile(p)
+		if string(now) == string(was.data) { continue }
+		st := fileStat{path: relPath(p)}
+		for _, l := range strings.Split(lineDiff(string(was.data), string(now)), "\n") {
+			if strings.HasPrefix(l, "+") { st.added++ } else if strings.HasPrefix(l, "-") { st.removed++ }
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+func exitOutcome(code int) string {
+	switch {
+	case code == 0: return "✅ completed"
+	case code == exitRefused: return "⛔ refused"
+	case code == exitPartial: return "◐ partially completed"
+	case code == exitDeadline: return "⏱ deadline exceeded"
+	case code > 128: return "⏹ stopped"
+	}
+	return fmt.Sprintf("❌ failed (exit %d)", code)
+}
+
+// ciEscape and ciProperty encode workflow command messages and properties.
+func ciEscape(s string) string { return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s) }
+
+func ciProperty(s string) string { return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s) }
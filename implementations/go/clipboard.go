@@ -0,0 +1,50 @@
+// The clipboard tool, via whichever clipboard utility the platform has: pbpaste/pbcopy on
+// macOS, wl-paste/wl-copy or xclip/xsel on Linux, PowerShell on Windows.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const maxClipboard = 100 << 10
+
+type clipCmd struct{ read, write []string }
+
+func clipboardCommands() []clipCmd {
+	switch runtime.GOOS {
+	case "darwin": return []clipCmd{{[]string{"pbpaste"}, []string{"pbcopy"}}}
+	case "windows": return []clipCmd{{[]string{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"}, []string{"powershell", "-NoProfile", "-Command", "$input | Set-Clipboard"}}}
+	}
+	var cmds []clipCmd
+	if os.Getenv("WAYLAND_DISPLAY") != "" { cmds = append(cmds, clipCmd{[]string{"wl-paste", "--no-newline"}, []string{"wl-copy"}}) }
+	if os.Getenv("DISPLAY") != "" {
+		cmds = append(cmds, clipCmd{[]string{"xclip", "-selection", "clipboard", "-o"}, []string{"xclip", "-selection", "clipboard", "-i"}}, clipCmd{[]string{"xsel", "--clipboard", "--output"}, []string{"xsel", "--clipboard", "--input"}})
+	}
+	return cmds
+}
+
+func clipboard(in Input) (string, error) {
+	var c *clipCmd
+	for _, cc := range clipboardCommands() { if _, err := exec.LookPath(cc.read[0]); err == nil { c = &cc; break } }
+	if c == nil { return "", errors.New("no clipboard available: needs pbcopy (macOS), wl-clipboard or xclip/xsel with a display (Linux), or PowerShell (Windows)") }
+	switch in.Str("action") {
+	case "read":
+		out, err := exec.Command(c.read[0], c.read[1:]...).Output(); if err != nil { return "", fmt.Errorf("%s: %w", c.read[0], err) }
+		s := string(out); note := ""
+		if len(s) > maxClipboard { note = fmt.Sprintf("\n[clipboard truncated: %d of %d bytes shown]", maxClipboard, len(s)); s = s[:maxClipboard] }
+		s, n := scrubSecrets(s); if n > 0 { note += fmt.Sprintf("\n[%d secret(s) redacted]", n) }
+		if s == "" { return "(clipboard is empty)", nil }
+		return s + note, nil
+	case "write":
+		cmd := exec.Command(c.write[0], c.write[1:]...); cmd.Stdin = strings.NewReader(in.Str("content"))
+		if out, err := cmd.CombinedOutput(); err != nil { return "", fmt.Errorf("%s: %v: %s", c.write[0], err, truncate(string(out), 200)) }
+		return fmt.Sprintf("copied %d bytes to the clipboard", len(in.Str("content"))), nil
+	}
+	return "", fmt.Errorf("unknown action %q (want read or write)", in.Str("action"))
+}
@@ -0,0 +1,113 @@
+// Emergency compaction for when a request overflows the context window even after the context
+// manager's trimming (contextmgr.go): the oldest turns are replaced by a model-written summary
+// and bulky tool results in the remainder are stubbed out, in the history itself.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const maxCompactions = 2
+
+const summaryPrompt = "The conversation below is being compacted to fit the context window. Summarize it for your own later use: the user's requests, what was done, files touched, decisions, and anything still outstanding. Be concise but keep specifics (paths, names, errors).\n\n"
+
+// request calls the API, compacting the history and retrying when it no longer fits.
+func (a *Agent) request() (*Response, error) {
+	if err := stopped(); err != nil { return nil, err }
+	if os.Getenv("NANO_ACCURATE_TOKENS") == "1" {
+		if _, over := a.contextView(); over {
+			slog.Info("history over budget even trimmed; compacting before sending", "tokens", a.trimmed.tokens, "budget", a.trimmed.budget)
+			if err := a.compact(0); err != nil { slog.Warn("compaction failed", "err", err) }
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		stop := watchNudgeKey(); res, err := a.call(); stop()
+		if !contextExceeded(err) { return res, err }
+		if attempt < maxCompactions {
+			a.notify("⚠ context window exceeded; compacting history")
+			cerr := a.compact(attempt); if cerr == nil { continue }
+			slog.Warn("compaction failed", "err", cerr)
+		}
+		if n, ok := tokensOver(err); ok { return nil, fmt.Errorf("conversation is %d tokens over the context window even after compacting %d times; start a new session or narrow the task: %w", n, attempt, err) }
+		return nil, fmt.Errorf("conversation exceeds the context window even after compacting %d times: %w", attempt, err)
+	}
+}
+
+// compact summarizes the older half of the history and stubs tool results in the rest; each
+// further level keeps less. Pinned seed messages at the start are left as they are.
+func (a *Agent) compact(level int) error {
+	before := len(a.Messages)
+	limit := 2000 >> (2 * level) // 2000, then 500 bytes per tool result
+	// Cut right before an assistant message so tool_use/tool_result pairs stay together.
+	cut := -1
+	for i := max(len(a.Messages)/2, a.pinned+1); i < len(a.Messages)-1; i++ { if a.Messages[i].Role == "assistant" { cut = i; break } }
+	if cut > 0 {
+		var sb strings.Builder
+		for _, m := range a.Messages[a.pinned:cut] { transcribe(&sb, m) }
+		summary, err := a.summarize(sb.String())
+		if err != nil { slog.Warn("could not summarize history; dropping the oldest turns", "err", err); summary = "(earlier turns were dropped without a summary)" }
+		forgetReadsIn(a.Messages[a.pinned:cut], nil)
+		if path, err := a.saveArtifact("compaction-summary.md", summary); err == nil { a.notify("📝 compaction summary saved to " + path) }
+		kept := append(append([]Message{}, a.Messages[:a.pinned]...), Message{Role: "user", Content: "Summary of the earlier conversation (compacted to fit the context window):\n\n" + summary + a.instructionsSummary()})
+		a.Messages = append(kept, a.Messages[cut:]...)
+		a.shiftExchanges(a.pinned, cut)
+	}
+	var stubbed []string
+	for i := a.pinned; i < len(a.Messages); i++ { stubbed = append(stubbed, stubResults(a.Messages[i].Content, limit)...) }
+	forgetReadsIn(a.Messages, stubbed)
+	if cut <= 0 && len(stubbed) == 0 { return errors.New("nothing left to compact") }
+	slog.Info("compacted history", "messages_before", before, "messages_after", len(a.Messages), "stubbed_results", len(stubbed))
+	return nil
+}
+
+func (a *Agent) summarize(transcript string) (string, error) {
+	body, _ := json.Marshal(map[string]any{"model": a.Model, "max_tokens": 2048, "messages": []Message{{Role: "user", Content: summaryPrompt + transcript}}})
+	raw, err := a.post(body); if err != nil { return "", fmt.Errorf("summarizing history: %w", err) }
+	var res Response; if err := json.Unmarshal(raw, &res); err != nil { return "", fmt.Errorf("summarizing history: %w", err) }
+	a.addUsage(phaseCompaction, res.Usage)
+	var texts []string; for _, b := range res.Content { if b.Type == "text" { texts = append(texts, b.Text) } }
+	return strings.Join(texts, ""), nil
+}
+
+// transcribe renders one message as plain text, truncating bulky parts.
+func transcribe(sb *strings.Builder, m Message) {
+	switch c := m.Content.(type) {
+	case string: fmt.Fprintf(sb, "%s: %s\n\n", m.Role, truncate(c, 4000))
+	case []Block:
+		for _, b := range c {
+			if b.Type == "text" { fmt.Fprintf(sb, "%s: %s\n\n", m.Role, truncate(b.Text, 4000)) }
+			if b.Type == "tool_use" { fmt.Fprintf(sb, "%s called %s %s\n\n", m.Role, b.Name, truncate(string(b.Input), 500)) }
+		}
+	default:
+		for _, r := range toolResults(c) { fmt.Fprintf(sb, "tool result: %s\n\n", truncate(resultText(r["content"]), 1000)) }
+	}
+}
+
+// stubResults shortens tool_result contents longer than limit, returning the tool_use IDs of
+// those it changed.
+func stubResults(content any, limit int) []string {
+	var ids []string
+	for _, r := range toolResults(content) {
+		id, _ := r["tool_use_id"].(string)
+		if bl, ok := r["content"].([]Block); ok { r["content"] = blocksText(bl) + "\n[images elided to fit the context window]"; ids = append(ids, id); continue }
+		s, ok := r["content"].(string); if !ok || len(s) <= limit { continue }
+		r["content"] = s[:limit] + fmt.Sprintf("\n[... %d bytes elided to fit the context window]", len(s)-limit); ids = append(ids, id)
+	}
+	return ids
+}
+
+func resultText(c any) string { if bl, ok := c.([]Block); ok { return blocksText(bl) }; return fmt.Sprint(c) }
+
+func toolResults(content any) []map[string]any {
+	switch c := content.(type) {
+	case []map[string]any: return c
+	case []any: var out []map[string]any; for _, v := range c { if m, ok := v.(map[string]any); ok && m["type"] == "tool_result" { out = append(out, m) } }; return out
+	}
+	return nil
+}
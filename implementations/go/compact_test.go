@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRereadAfterCompactionSendsContents(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "read_file", `{"path":"a.txt"}`), textReply("read a"), toolReply("t2", "read_file", `{"path":"b.txt"}`), textReply("read b"))
+	a := testAgent(t, f)
+	body := strings.Repeat("0123456789\n", 60) // over the 500 bytes a result keeps at level 1
+	writeTestFile(t, "a.txt", body); writeTestFile(t, "b.txt", body)
+	if _, err := a.Run("read a"); err != nil { t.Fatal(err) }
+	if _, err := a.Run("read b"); err != nil { t.Fatal(err) }
+	in, _ := decodeInput([]byte(`{"path":"b.txt"}`))
+	if out, _ := readFile(in); !strings.HasPrefix(out, "unchanged since your last read") { t.Fatalf("before compacting, a re-read should be answered from the cache; got %.60q", out) }
+	if err := a.compact(1); err != nil { t.Fatal(err) }
+	for _, p := range []string{"a.txt", "b.txt"} { // a.txt was summarized away, b.txt's result stubbed
+		in, _ := decodeInput([]byte(`{"path":"` + p + `"}`))
+		if out, err := readFile(in); err != nil || out != body { t.Errorf("%s after compacting: %.60q, %v; want the full contents", p, out, err) }
+	}
+}
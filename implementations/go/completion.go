@@ -0,0 +1,243 @@
+// Shell completion. `nano completion bash|zsh|fish` prints a short script that hands the words
+// being completed to `nano __complete`, which answers from the real flag sets and the
+// subcommand table, so completions can't drift from the flags. Values are dynamic where that
+// helps: --model from the alias table, --resume and session arguments from the saved sessions
+// (titles as descriptions), --tools from the registry, --persona and -t from config and disk.
+// A ":file" reply tells the script to fall back to file names.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+type command struct {
+	name, summary string
+	declare       func(fs *flag.FlagSet) func(args []string) int // declares the flags on fs, returning the command itself
+}
+
+// commands are the subcommands main dispatches on os.Args[1]; an empty summary hides one from
+// completion.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"tools", "list the tools and whether they're enabled", toolsMain},
+		{"fix", "run a command and let the agent fix it until it passes", fixMain},
+		{"watch", "re-run a prompt whenever matching files change", watchMain},
+		{"eval", "run an eval suite", evalMain},
+		{"foreach", "run a prompt in every matching directory", foreachMain},
+		{"serve", "serve runs over HTTP, each in a workspace of its own", serveMain},
+		{"tokens", "count the input tokens of a prompt", tokensMain},
+		{"prompts", "list prompt templates", promptsMain},
+		{"sessions", "list, search, prune or remove saved sessions", sessionsMain},
+		{"fork", "branch a saved session", forkMain},
+		{"diff-sessions", "compare the files two sessions wrote", diffSessionsMain},
+		{"export", "export a session as Markdown, HTML or JSON", exportMain},
+		{"usage", "summarize the usage ledger", usageMain},
+		{"pricing", "show the pricing table", pricingMain},
+		{"permissions", "list or remove saved approval rules", permissionsMain},
+		{"artifacts", "list the files a run saved in .nano/runs", artifactsMain},
+		{"auth", "store, show or remove the API key in the system keychain", authMain},
+		{"doctor", "check the key, API, model and environment", doctorMain},
+		{"completion", "print a shell completion script", completionMain},
+		{"__complete", "", func(*flag.FlagSet) func([]string) int { return completeMain }},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands { if c.name == name { return c, true } }
+	return command{}, false
+}
+
+// flags declares c's flags on a fresh set, for completion to read without running anything.
+func (c command) flags() *flag.FlagSet { fs := flag.NewFlagSet(c.name, flag.ContinueOnError); c.declare(fs); return fs }
+
+// start declares c's flags and runs it with args.
+func (c command) start(args []string) int { return c.declare(flag.NewFlagSet(c.name, flag.ExitOnError))(args) }
+
+func completionMain(fs *flag.FlagSet) func(args []string) int {
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano completion bash|zsh|fish\n\n  bash, zsh: source <(nano completion bash)\n  fish:      nano completion fish > ~/.config/fish/completions/nano.fish"); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		script, ok := completionScripts[fs.Arg(0)]; if fs.NArg() != 1 || !ok { fs.Usage(); return 1 }
+		fmt.Print(script)
+		return 0
+	}
+}
+
+type candidate struct{ value, desc string }
+
+// completeMain prints the completions of the last word given the ones before it, one
+// "value<TAB>description" per line.
+func completeMain(words []string) int {
+	if len(words) == 0 { return 0 }
+	cur, words := words[len(words)-1], words[:len(words)-1]
+	fs, cmd := flag.CommandLine, ""
+	if len(words) > 0 { if c, ok := findCommand(words[0]); ok && c.summary != "" { cmd, words, fs = c.name, words[1:], c.flags() } }
+	var out []candidate; prefix := ""
+	if prev := lastFlag(fs, words); strings.HasPrefix(cur, "-") && strings.Contains(cur, "=") {
+		name, value, _ := strings.Cut(cur, "="); prefix, cur = name+"=", value
+		if f := fs.Lookup(strings.TrimLeft(name, "-")); f != nil { out = flagValues(f.Name, cur) }
+	} else if prev != nil {
+		out = flagValues(prev.Name, cur)
+	} else if strings.HasPrefix(cur, "-") {
+		fs.VisitAll(func(f *flag.Flag) {
+			dash := "--"; if len(f.Name) == 1 { dash = "-" }
+			_, usage := flag.UnquoteUsage(f); out = append(out, candidate{dash + f.Name, truncate(usage, 70)})
+		})
+	} else {
+		out = positional(cmd, fs, words)
+	}
+	if len(out) == 1 && out[0].value == ":file" { fmt.Println(":file"); return 0 }
+	for _, c := range out { if strings.HasPrefix(c.value, cur) { fmt.Printf("%s%s\t%s\n", prefix, c.value, c.desc) } }
+	return 0
+}
+
+// lastFlag is the flag the next word is a value for, if the last word is one that takes a value.
+func lastFlag(fs *flag.FlagSet, words []string) *flag.Flag {
+	if len(words) == 0 { return nil }
+	w := words[len(words)-1]
+	if !strings.HasPrefix(w, "-") || strings.Contains(w, "=") { return nil }
+	f := fs.Lookup(strings.TrimLeft(w, "-")); if f == nil { return nil }
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() { return nil }
+	return f
+}
+
+// flagValues lists the values for flag name; flags with no known values complete file names.
+func flagValues(name, cur string) []candidate {
+	switch name {
+	case "model", "escalate-model": return models()
+	case "resume": return sessionCandidates()
+	case "tools", "disable-tools": return toolList(cur)
+	case "tool-choice": return append([]candidate{{"auto", "let the model decide"}, {"any", "must call some tool"}, {"none", "no tool calls"}}, toolList("")...)
+	case "persona": return personas()
+	case "t", "template": return templates()
+	case "output": return []candidate{{"text", ""}, {"json", ""}}
+	case "log-level": return []candidate{{"debug", ""}, {"info", ""}, {"warn", ""}, {"error", ""}}
+	case "format": return []candidate{{"markdown", ""}, {"html", ""}, {"json", ""}}
+	case "by": return []candidate{{"project", ""}, {"model", ""}, {"day", ""}, {"phase", ""}}
+	case "deadline": return []candidate{{"10m", ""}, {"30m", ""}, {"1h", ""}}
+	}
+	return []candidate{{":file", ""}}
+}
+
+// positional completes a non-flag word: the subcommand itself, or a subcommand's arguments.
+func positional(cmd string, fs *flag.FlagSet, words []string) []candidate {
+	args := 0
+	for i := 0; i < len(words); i++ {
+		if strings.HasPrefix(words[i], "-") { if lastFlag(fs, words[:i+1]) != nil { i++ }; continue }
+		args++
+	}
+	switch cmd {
+	case "":
+		if len(words) > 0 { return nil }
+		var out []candidate
+		for _, c := range commands { if c.summary != "" { out = append(out, candidate{c.name, c.summary}) } }
+		return out
+	case "sessions":
+		if args == 0 { return []candidate{{"rm", "delete sessions"}} }
+		if words[0] == "rm" { return sessionCandidates() }
+	case "fork", "export", "artifacts": if args == 0 { return sessionCandidates() }
+	case "diff-sessions": if args < 2 { return sessionCandidates() }
+	case "permissions": if args == 0 { return []candidate{{"list", "show the saved rules"}, {"remove", "delete rules by number"}} }
+	case "auth": if args == 0 { return []candidate{{"set", "store a key"}, {"get", "show the stored key"}, {"delete", "remove it"}} }
+	case "completion": if args == 0 { return []candidate{{"bash", ""}, {"zsh", ""}, {"fish", ""}} }
+	case "eval", "fix": return []candidate{{":file", ""}}
+	}
+	return nil
+}
+
+func models() []candidate {
+	var out []candidate
+	for _, k := range sortedKeys(modelAliases) { out = append(out, candidate{k, modelAliases[k]}) }
+	for _, k := range sortedKeys(modelAliases) { out = append(out, candidate{modelAliases[k], ""}) }
+	return out
+}
+
+// sessionCandidates lists saved sessions, most recent first, titled.
+func sessionCandidates() []candidate {
+	all, _ := allSessions()
+	var out []candidate
+	for i := len(all) - 1; i >= 0; i-- {
+		s := all[i]; title := s.Title; if title == "" { title = s.Prompt }
+		out = append(out, candidate{s.ID, truncate(title, 60)})
+	}
+	return out
+}
+
+// toolList completes the last entry of a comma-separated tool list.
+func toolList(cur string) []candidate {
+	head := ""; if i := strings.LastIndex(cur, ","); i >= 0 { head = cur[:i+1] }
+	var out []candidate
+	for _, t := range registry {
+		if slices.Contains(strings.Split(head, ","), t.Name) { continue }
+		desc, _, _ := strings.Cut(t.Description, ". ")
+		out = append(out, candidate{head + t.Name, truncate(desc, 60)})
+	}
+	return out
+}
+
+func personas() []candidate {
+	all := map[string]Persona{}
+	for n, p := range builtinPersonas { all[n] = p }
+	for n, p := range cfg.Personas { all[n] = p }
+	var out []candidate
+	for _, n := range sortedKeys(all) { out = append(out, candidate{n, all[n].Description}) }
+	return out
+}
+
+func templates() []candidate {
+	all, _ := promptTemplates()
+	var out []candidate
+	for _, n := range sortedKeys(all) { out = append(out, candidate{n, all[n].Description}) }
+	return out
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for nano: source <(nano completion bash)
+_nano() {
+    local line=${COMP_LINE:0:COMP_POINT} cur=${COMP_WORDS[COMP_CWORD]}
+    local -a words out
+    read -ra words <<< "$line"
+    [[ $line == *[[:space:]] ]] && words+=("")
+    local IFS=$'\n'
+    out=($(command nano __complete "${words[@]:1}" 2>/dev/null | cut -f1))
+    if [[ ${out[0]} == :file ]]; then COMPREPLY=($(compgen -f -- "$cur")); return; fi
+    # bash splits words at '=', so drop whatever of the word comes before its idea of it
+    local word=${words[${#words[@]}-1]}
+    COMPREPLY=("${out[@]#"${word%"$cur"}"}")
+}
+complete -o default -F _nano nano
+`,
+	"zsh": `#compdef nano
+# zsh completion for nano: source <(nano completion zsh), or save as _nano on $fpath
+_nano() {
+  local -a out items
+  local l
+  out=("${(@f)$(command nano __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+  if [[ $out[1] == :file ]]; then _files; return; fi
+  for l in $out; do [[ -n $l ]] && items+=("${${l%%$'\t'*}//:/\\:}:${l#*$'\t'}"); done
+  _describe -t values nano items
+}
+if [[ $funcstack[1] == _nano ]]; then _nano "$@"; else compdef _nano nano; fi
+`,
+	"fish": `# fish completion for nano: nano completion fish > ~/.config/fish/completions/nano.fish
+function __nano_complete
+    set -l cur (commandline -ct)
+    set -l words (commandline -opc) "$cur"
+    set -e words[1]
+    set -l out (command nano __complete $words 2>/dev/null)
+    if test "$out[1]" = :file
+        __fish_complete_path "$cur"
+    else
+        printf '%s\n' $out
+    end
+end
+complete -c nano -f -a '(__nano_complete)'
+`,
+}
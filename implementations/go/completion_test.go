@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEveryCommandDeclaresItsFlags(t *testing.T) {
+	want := map[string]string{"fix": "max-attempts", "watch": "debounce", "serve": "pool", "sessions": "grep", "auth": "show", "tokens": "tools", "doctor": "offline"}
+	for _, c := range commands {
+		fs := c.flags() // must declare only: no command may run, read stdin or exit here
+		if fs.Name() != c.name { t.Errorf("%s: flag set named %q", c.name, fs.Name()) }
+		if f, ok := want[c.name]; ok && fs.Lookup(f) == nil { t.Errorf("%s: no --%s flag", c.name, f) }
+	}
+}
+
+func TestCompleteSubcommandFlags(t *testing.T) {
+	out := captureStdout(t, func() { completeMain([]string{"fix", "--max"}) })
+	if !strings.HasPrefix(out, "--max-attempts\t") || strings.Count(out, "\n") != 1 { t.Errorf("fix --max completed to %q", out) }
+	out = captureStdout(t, func() { completeMain([]string{"sessions", "--"}) })
+	for _, f := range []string{"--grep\t", "--dir\t", "--prune\t"} { if !strings.Contains(out, f) { t.Errorf("sessions flags %q lack %s", out, f) } }
+	out = captureStdout(t, func() { completeMain([]string{"ser"}) })
+	if !strings.HasPrefix(out, "serve\t") { t.Errorf("ser completed to %q", out) }
+}
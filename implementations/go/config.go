@@ -0,0 +1,76 @@
+// Configuration: ~/.config/nano/config.json overlaid by the project's .nano.json (keys present
+// in the project file win). Command-line flags override both.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type Config struct {
+	Tools        []string `json:"tools,omitempty"`
+	DisableTools []string `json:"disable_tools,omitempty"`
+	Temperature  *float64 `json:"temperature,omitempty"`
+	TopP         *float64 `json:"top_p,omitempty"`
+	Stop         []string `json:"stop_sequences,omitempty"`
+	Search       struct {
+		Provider string `json:"provider,omitempty"` // "searxng" or "brave"
+		URL      string `json:"url,omitempty"`      // SearxNG instance, or an alternative Brave endpoint
+		Key      string `json:"key,omitempty"`      // Brave key; defaults to $BRAVE_API_KEY
+	} `json:"search"`
+	FetchAllowPrivate bool                `json:"fetch_allow_private,omitempty"`
+	HTTPAllowPublic   bool                `json:"http_allow_public,omitempty"`
+	DownloadMaxBytes  int64               `json:"download_max_bytes,omitempty"` // default 100 MiB
+	MaxResponseBytes  int64               `json:"max_response_bytes,omitempty"` // API response bodies; default 64 MiB
+	MaxRequestBytes   int64               `json:"max_request_bytes,omitempty"`  // API request bodies; default 32 MB
+	PDFMode           string              `json:"pdf_mode,omitempty"`           // "text" (default) or "document"
+	PromptPrefix      string              `json:"prompt_prefix,omitempty"`      // put before the first prompt of a conversation; see prefix.go
+	VerifyCommand     string              `json:"verify_command,omitempty"`     // must pass before a run is done; see gate.go
+	Personas          map[string]Persona  `json:"personas,omitempty"`           // extra or overriding --persona presets
+	Pricing           []Price             `json:"pricing,omitempty"`            // tried before the built-in prices
+	BashEnv           []string            `json:"bash_env,omitempty"`           // extra variables bash commands get; NAME or PREFIX_*
+	UntrustedTools    map[string]bool     `json:"untrusted_tools,omitempty"`    // tool -> whether its results are outside content
+	InjectionPatterns map[string][]string `json:"injection_patterns,omitempty"` // tool (or "*") -> extra regexps
+	ContextWindows    map[string]int      `json:"context_windows,omitempty"`    // model pattern -> tokens, for the context meter
+	ProgressEvery     int                 `json:"progress_every,omitempty"`     // API calls between progress notes; default 25, negative: none
+	Context           struct {
+		Budget    int `json:"budget,omitempty"`     // input tokens a request may carry before older parts are trimmed
+		KeepTurns int `json:"keep_turns,omitempty"` // trailing turns never trimmed; default 4
+	} `json:"context"`
+	Provider          string              `json:"provider,omitempty"`           // gateway preset: "openrouter" or "litellm"; $PROVIDER wins
+	OpenRouter        struct {
+		Referer string         `json:"referer,omitempty"`  // HTTP-Referer; defaults to the project page
+		Title   string         `json:"title,omitempty"`    // X-Title; defaults to "nano-opencode"
+		Routing map[string]any `json:"provider,omitempty"` // provider routing preferences, sent as is
+	} `json:"openrouter"`
+}
+
+var cfg Config
+
+func configPaths() []string {
+	dir, _ := os.UserConfigDir()
+	return []string{filepath.Join(dir, "nano", "config.json"), ".nano.json"}
+}
+
+func loadConfig() (Config, error) {
+	var c Config
+	for _, p := range configPaths() {
+		data, err := os.ReadFile(p)
+		if os.IsNotExist(err) { continue } else if err != nil { return c, err }
+		if err := json.Unmarshal(data, &c); err != nil { return c, fmt.Errorf("%s: %w", p, err) }
+	}
+	return c, nil
+}
+
+// printConfig shows what a run would use after config files, environment and flags are applied.
+func (a *Agent) printConfig() {
+	var files []string
+	for _, p := range configPaths() { if _, err := os.Stat(p); err == nil { files = append(files, p) } }
+	key := "(unset)"; if a.Key != "" { key = "(set, from " + a.keySource + ")" }
+	v, commit := buildVersion(); provider := "anthropic"; if gw != nil { provider = gw.name }
+	data, _ := json.MarshalIndent(map[string]any{"version": v, "commit": commit, "config_files": files, "provider": provider, "url": a.URL, "api_key": key, "model": a.Model, "persona": a.Persona, "tools": toolNames(a.Tools), "params": a.Params, "headers": a.headers(), "project_env": projectEnvSummary()}, "", "  ")
+	fmt.Println(string(data))
+}
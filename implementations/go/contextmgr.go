@@ -0,0 +1,112 @@
+// Context manager: decides, before every API call, what of the history the request carries.
+// When the conversation fits the budget (config "context": {"budget": tokens}, by default the
+// model's window less room for the reply) it goes as is. Otherwise a trimmed copy is sent,
+// and a.Messages, and with it the session file, keeps everything. Pinned, never trimmed: the
+// system prompt, seed messages, the original prompt, the user's own text (queued messages and
+// directory instructions included) and the last keep_turns turns (default 4). Older tool
+// results are stubbed first, oldest first, then older assistant prose is cut down to its
+// opening, until the estimate fits; a file whose read is stubbed is sent in full when read
+// again. What was trimmed is logged. A request still too big after that falls through to
+// compaction (see compact.go), which rewrites the history itself.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ContextManager is the trimming policy; the zero value uses the defaults.
+type ContextManager struct {
+	Budget    int // input tokens per request; 0: the model's window less the output budget
+	KeepTurns int // trailing turns (assistant messages and what follows) sent untouched; 0: 4
+	ResultCap int // bytes an old tool result keeps; 0: 200
+	ProseCap  int // bytes of old assistant text kept; 0: 300
+}
+
+// trimStats is what one view left out.
+type trimStats struct{ tokens, budget, results, prose, bytes int }
+
+// WithContextManager sets the policy used before each call.
+func (a *Agent) WithContextManager(m ContextManager) *Agent { a.cm = m; return a }
+
+func (m ContextManager) keepTurns() int { if m.KeepTurns > 0 { return m.KeepTurns }; return 4 }
+
+func (m ContextManager) budget(model string, out int) int { if m.Budget > 0 { return m.Budget }; return windowFor(model) - out }
+
+// contextView returns the messages to send and whether they are still over the budget.
+func (a *Agent) contextView() ([]Message, bool) {
+	msgs := a.Messages
+	n, _ := a.countTokens(msgs); st := trimStats{tokens: n, budget: a.cm.budget(a.Model, a.maxTokens())}
+	if n <= st.budget { a.trimmed = trimStats{}; return msgs, false }
+	tail, note := a.pinnedUpTo(), lastProgressNote(msgs)
+	if note > tail { tail = note } // the note stands in for what came before it
+	over := (n - st.budget) * 4 // in bytes, the unit of the estimate
+	view := slices.Clone(msgs); var stubbed []string
+	for pass := 0; pass < 2 && over > 0; pass++ {
+		for i := a.pinned; i < tail && over > 0; i++ {
+			m := view[i]
+			if pass == 0 && m.Role == "user" && toolResults(m.Content) != nil {
+				before := size(m.Content); c := cloneContent(m.Content)
+				ids := stubResults(c, orInt(a.cm.ResultCap, 200)); n := len(ids); if i < note { n += stubProgress(c) }
+				if n == 0 { continue }
+				stubbed = append(stubbed, ids...)
+				view[i].Content = c; saved := before - size(c); over -= saved; st.results++; st.bytes += saved
+				slog.Debug("context: stubbed tool results", "message", i+1, "saved_bytes", saved)
+			}
+			if bl, ok := m.Content.([]Block); pass == 1 && ok && m.Role == "assistant" {
+				c, saved := clipProse(bl, orInt(a.cm.ProseCap, 300)); if saved == 0 { continue }
+				view[i].Content = c; over -= saved; st.prose++; st.bytes += saved
+				slog.Debug("context: trimmed assistant text", "message", i+1, "saved_bytes", saved)
+			}
+		}
+	}
+	switch last := a.trimmed; {
+	case st.results == last.results && st.prose == last.prose && last.budget > 0: // logged already
+	case st.results+st.prose == 0: slog.Info("context over budget; nothing outside the pinned turns to trim", "tokens", st.tokens, "budget", st.budget)
+	default: slog.Info("context over budget; sending a trimmed history", "tokens", st.tokens, "budget", st.budget, "stubbed_results", st.results, "trimmed_prose", st.prose, "saved_bytes", st.bytes)
+	}
+	a.trimmed = st
+	forgetReadsIn(msgs, stubbed) // the model won't have those files' contents any more
+	return view, over > 0
+}
+
+// pinnedUpTo is the index where the kept tail begins: the last KeepTurns assistant messages
+// and what follows them. The original prompt, being a user string, is never a candidate.
+func (a *Agent) pinnedUpTo() int {
+	k := a.cm.keepTurns()
+	for i := len(a.Messages) - 1; i >= a.pinned; i-- {
+		if a.Messages[i].Role == "assistant" { if k--; k == 0 { return i } }
+	}
+	return a.pinned
+}
+
+// cloneContent copies a user message's blocks deep enough that stubResults can rewrite them.
+func cloneContent(c any) any {
+	switch v := c.(type) {
+	case []map[string]any:
+		out := make([]map[string]any, len(v)); for i, m := range v { out[i] = maps.Clone(m) }; return out
+	case []any:
+		out := make([]any, len(v)); for i, x := range v { if m, ok := x.(map[string]any); ok { x = maps.Clone(m) }; out[i] = x }; return out
+	}
+	return c
+}
+
+// clipProse shortens the text blocks of an assistant message, leaving tool calls as they are.
+func clipProse(bl []Block, limit int) ([]Block, int) {
+	out, saved := slices.Clone(bl), 0
+	for i, b := range out {
+		if b.Type != "text" || len(b.Text) <= limit+100 { continue }
+		out[i].Text = strings.ToValidUTF8(b.Text[:limit], "") + fmt.Sprintf("… [%d bytes of this earlier reply left out to fit the context budget]", len(b.Text)-limit)
+		saved += len(b.Text) - len(out[i].Text)
+	}
+	return out, saved
+}
+
+func size(c any) int { data, _ := json.Marshal(c); return len(data) }
+
+func orInt(n, def int) int { if n > 0 { return n }; return def }
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStubbedReadIsSentAgainWhenReread(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "read_file", `{"path":"big.txt"}`), toolReply("t2", "bash", `{"command":"true"}`), toolReply("t3", "read_file", `{"path":"big.txt"}`), textReply("done"))
+	a := testAgent(t, f).WithContextManager(ContextManager{Budget: 20, KeepTurns: 1}) // estimates build on the fake's 10 input tokens per request
+	body := strings.Repeat("a line of the big file\n", 400)
+	writeTestFile(t, "big.txt", body)
+	if _, err := a.Run("read big.txt twice"); err != nil { t.Fatal(err) }
+	first, _ := toolResult(t, f.request(t, 2), "t1")["content"].(string)
+	if !strings.Contains(first, "bytes elided to fit the context window") { t.Fatalf("the first read wasn't stubbed in the third request (%d bytes); the test needs a smaller budget", len(first)) }
+	again, _ := toolResult(t, f.request(t, 3), "t3")["content"].(string)
+	if again != body { t.Errorf("the re-read after its first result was stubbed got %.70q, want the full file", again) }
+	if full, _ := a.Messages[2].Content.([]map[string]any); len(full) == 0 || full[0]["content"] != body { t.Error("the history itself should keep the full result") }
+}
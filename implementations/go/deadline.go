@@ -0,0 +1,66 @@
+// --deadline: a wall-clock budget for the whole run. Shortly before it runs out, in-flight
+// work (the API request, a running command, web fetches) is cancelled through runCtx; if
+// there's still time the model gets one short, tool-free turn to say where things stand, the
+// session is saved for --resume, and nano exits 124, like timeout(1). Tools keep their own
+// timeouts, but none outlives the deadline since they all run under runCtx. A backstop timer
+// exits at the deadline itself if the wrap-up hangs.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	exitDeadline   = 124
+	deadlineWrapUp = 30 * time.Second // cancel this long before the deadline to leave time to wrap up
+	wrapUpMinimum  = 20 * time.Second // skip the summary turn with less than this left
+)
+
+const wrapUpPrompt = "The run's time budget is used up and your last step was cancelled. Without calling any tools, briefly summarize for whoever resumes this session: what is done, what was in progress, and what remains."
+
+// runCtx is cancelled when the deadline is reached; API requests and tools run under it.
+var runCtx, cancelRun = context.WithCancel(context.Background())
+
+// armDeadline starts the timers for a run that must end by a.deadline.
+func (a *Agent) armDeadline(d time.Duration) {
+	a.deadline = time.Now().Add(d)
+	cancelAt := d - deadlineWrapUp; if cancelAt < d/2 { cancelAt = d * 3 / 4 }
+	time.AfterFunc(cancelAt, cancelRun)
+	time.AfterFunc(d, func() { fmt.Fprintln(os.Stderr, "⏱ deadline reached during wrap-up; exiting"); exit(exitDeadline) })
+}
+
+func (a *Agent) pastDeadline() bool { return !a.deadline.IsZero() && runCtx.Err() != nil && stopped() == nil }
+
+// timeNote is appended to the system prompt on every request so the model can prioritize.
+func (a *Agent) timeNote() string {
+	if a.deadline.IsZero() { return "" }
+	left := time.Until(a.deadline).Round(time.Minute)
+	if left < time.Minute { return "\n\nThis run is about to hit its deadline: finish now and leave the work in a state someone can resume." }
+	return fmt.Sprintf("\n\nThis run has a deadline: about %s remain. Do the most important parts first and keep the work in a state someone can resume.", strings.TrimSuffix(left.String(), "0s"))
+}
+
+// wrapUp saves the session and, when there's time, asks for a final state summary under a
+// fresh context that ends a little before the deadline.
+func (a *Agent) wrapUp() (string, error) {
+	a.autosave()
+	left := time.Until(a.deadline)
+	if left < wrapUpMinimum { return "", fmt.Errorf("deadline reached; no time left for a summary") }
+	ctx, cancel := context.WithTimeout(context.Background(), left-5*time.Second); defer cancel()
+	runCtx = ctx
+	a.notify("⏱ deadline near; asking for a summary of where things stand")
+	a.Messages = append(a.Messages, Message{Role: "user", Content: wrapUpPrompt})
+	req := map[string]any{"model": a.Model, "max_tokens": 1024, "messages": a.Messages, "system": a.System}
+	if len(a.Tools) > 0 { req["tools"], req["tool_choice"] = schemas(a.Tools), map[string]any{"type": "none"} }
+	body, _ := json.Marshal(req)
+	raw, err := a.post(body); if err != nil { return "", fmt.Errorf("deadline summary: %w", err) }
+	var res Response; if err := json.Unmarshal(raw, &res); err != nil { return "", fmt.Errorf("deadline summary: %w", err) }
+	a.Turns++; a.addUsage(phaseSummarize, res.Usage)
+	a.Messages = append(a.Messages, Message{Role: "assistant", Content: res.Content})
+	return responseText(res.Content), nil
+}
@@ -0,0 +1,40 @@
+// Minimal line diff (LCS over lines) for showing how two texts differ.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lineDiff returns "-"/"+" prefixed lines with two lines of context around each change and
+// "…" where unchanged lines were skipped; identical inputs produce "".
+func lineDiff(a, b string) string {
+	x, y := splitLines(a), splitLines(b)
+	if len(x)*len(y) > 4_000_000 { return fmt.Sprintf("(too large to diff: %d vs %d lines)\n", len(x), len(y)) }
+	lcs := make([][]int, len(x)+1); for i := range lcs { lcs[i] = make([]int, len(y)+1) }
+	for i := len(x) - 1; i >= 0; i-- { for j := len(y) - 1; j >= 0; j-- { if x[i] == y[j] { lcs[i][j] = lcs[i+1][j+1] + 1 } else { lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1]) } } }
+	type line struct{ op byte; text string }
+	var ops []line
+	for i, j := 0, 0; i < len(x) || j < len(y); {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]: ops = append(ops, line{' ', x[i]}); i++; j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]): ops = append(ops, line{'-', x[i]}); i++
+		default: ops = append(ops, line{'+', y[j]}); j++
+		}
+	}
+	var out strings.Builder; skipped := false
+	for k, l := range ops {
+		near := false; for d := -2; d <= 2; d++ { if k+d >= 0 && k+d < len(ops) && ops[k+d].op != ' ' { near = true } }
+		if !near { skipped = true; continue }
+		if skipped && out.Len() > 0 { out.WriteString("…\n") }
+		skipped = false; out.WriteString(string(l.op) + l.text + "\n")
+	}
+	return out.String()
+}
+
+// splitLines splits s into lines; the newline ending the last one doesn't start another.
+func splitLines(s string) []string {
+	if s == "" { return nil }
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,150 @@
+// Tool dispatch: every tool_use block passes through execTool, which handles replayed results,
+// approval, the audit trail, tracing and logging in one place.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// execTool runs one call and returns the tool_result text, its content blocks for tools that
+// return more than text (nil otherwise), and whether it is an error (sent to the API as
+// is_error so the model knows to recover).
+func (a *Agent) execTool(b Block) (string, []Block, bool) {
+	if r, blocks, isErr, ok := a.rec.toolResult(b.ID); ok { slog.Debug("tool result replayed", "tool", b.Name, "id", b.ID); return r, blocks, isErr }
+	out, isErr := a.execLive(b)
+	var blocks []Block
+	if !isErr && len(out.blocks) > 0 && !(len(out.blocks) == 1 && out.blocks[0].Type == "text") { blocks = out.blocks } // plain text stays a string
+	if a.rec != nil && !a.rec.replay && len(a.rec.Steps) > 0 {
+		s := &a.rec.Steps[len(a.rec.Steps)-1]; s.Tools = append(s.Tools, recTool{ID: b.ID, Name: b.Name, Input: b.Input, Result: out.text, Blocks: blocks, IsError: isErr})
+	}
+	return out.text, blocks, isErr
+}
+
+type toolOutput struct{ text string; blocks []Block }
+
+func (a *Agent) execLive(b Block) (toolOutput, bool) {
+	fail := func(s string) (toolOutput, bool) { return toolOutput{text: s}, true }
+	if err := stopped(); err != nil { return fail("Error: not run: nano was " + err.Error()) }
+	if runCtx.Err() != nil { return fail("Error: not run: the run's deadline was reached") }
+	if b.Name == missingName { return fail("Error: this tool call had no tool name; call again naming one of: " + strings.Join(toolNames(a.Tools), ", ")) }
+	t, ok := a.lookup(b.Name)
+	if _, exists := registered(b.Name); !ok && exists {
+		return fail(fmt.Sprintf("Error: tool '%s' is disabled for this run; available tools: %s", b.Name, strings.Join(toolNames(a.Tools), ", ")))
+	} else if !ok {
+		return fail(fmt.Sprintf("Error: unknown tool '%s'; available tools: %s", b.Name, strings.Join(toolNames(a.Tools), ", ")))
+	}
+	if errors.Is(b.inputErr, errTruncatedInput) { slog.Info("truncated tool input", "tool", b.Name, "id", b.ID); return fail(truncatedAdvice(b.Name)) }
+	if b.inputErr != nil {
+		a.badInputs++; slog.Info("malformed tool input", "tool", b.Name, "id", b.ID, "err", b.inputErr, "count", a.badInputs)
+		return fail(fmt.Sprintf("Error: your tool input could not be parsed: %s; please re-issue the call with valid JSON", b.inputErr))
+	}
+	in, _ := decodeInput(b.Input)
+	if err := validateInput(t.Schema, in); err != nil { return fail(fmt.Sprintf("Error: invalid input for %s: %s", b.Name, err)) }
+	if err := a.checkDryRun(b.Name, in); err != nil { return fail("Error: " + err.Error()) }
+	approved, by := a.approve(t, b.ID, in)
+	if !t.readOnlyCall(in) {
+		if err := audit(a.auditEntry("attempt", b.Name, in, by)); err != nil { slog.Error("audit log write failed", "err", err); return fail("Error: refusing to run " + b.Name + ": audit log unavailable: " + err.Error()) }
+	}
+	if !approved && strings.Contains(by, "prompt injection") { return fail("Error: not run: this " + b.Name + " call came right after content that looked like a prompt injection, and no one approved it") }
+	if !approved { return fail("Error: the user declined this " + b.Name + " call") }
+	var paths []string; if !t.readOnlyCall(in) { paths = a.backup(b.Name, in) }
+	slog.Debug("tool dispatch", "tool", b.Name, "id", b.ID, "input", b.Input)
+	sp := a.span.Child("tool " + b.Name); sp.Set("tool", b.Name)
+	start := time.Now()
+	out, blocks, err := a.runTool(t, ToolCall{ID: b.ID, Name: b.Name, Detail: describeCall(in), Input: in, ReadOnly: t.readOnlyCall(in)})
+	if b.Name == "search_replace" { releaseScan(in) } // a middleware may have answered without running it
+	if blocks == nil && len(out) > maxToolOutput { out = a.spill(b.Name, out) }
+	sp.End(err); a.noteDryRun(b.Name, in, err); a.emit(Event{Kind: "tool_call", Name: b.Name, Detail: describeCall(in), Paths: paths, Start: start, Duration: time.Since(start), Err: err})
+	slog.Info("tool finished", "tool", b.Name, "duration", time.Since(start), "result_bytes", len(out), "err", err)
+	if !t.readOnlyCall(in) { audit(a.auditResult(b.Name, in, by, err)) }
+	if err != nil { if strings.TrimSpace(out) == "" { out = "Error: " + err.Error() } else { out = strings.TrimRight(out, "\n") + "\n\nError: " + err.Error() } }
+	if blocks == nil { out = a.guardUntrusted(b, out) } // failures too: an error page or status line is outside content as well
+	return toolOutput{out, blocks}, err != nil
+}
+
+// blocksText is the text of a rich result, for logs, the progress line and recordings.
+func blocksText(blocks []Block) string {
+	var parts []string
+	for _, b := range blocks { if b.Type == "text" { parts = append(parts, b.Text) } else { parts = append(parts, "["+b.Type+"]") } }
+	return strings.Join(parts, "\n")
+}
+
+// maxBadInputs is how many unparseable tool inputs one user turn tolerates before giving up.
+const maxBadInputs = 3
+
+// missingName stands in for an empty tool name, so the echoed history stays well-formed.
+const missingName = "missing_tool_name"
+
+// sanitizeToolUses guards against malformed assistant content, as gateway retries and models
+// occasionally produce: a tool_use repeated verbatim is dropped, a later one reusing an id is
+// dropped, and one with no id is dropped with a note for the model, since nothing can answer
+// it. A call with no name keeps its id and gets an error result. What's left has one
+// tool_result coming per unique id.
+func sanitizeToolUses(content []Block) ([]Block, []string) {
+	var out []Block; var notes []string; seen := map[string]bool{}
+	for _, b := range content {
+		if b.Type != "tool_use" { out = append(out, b); continue }
+		switch {
+		case b.ID == "":
+			slog.Warn("dropping tool_use without an id", "tool", b.Name)
+			name := b.Name; if name == "" { name = "unnamed" }
+			notes = append(notes, fmt.Sprintf("Your %s tool call had no id, so it was not run; call it again if you still need it.", name))
+			continue
+		case seen[b.ID]:
+			slog.Warn("dropping duplicate tool_use", "tool", b.Name, "id", b.ID)
+			continue
+		case b.Name == "":
+			slog.Warn("tool_use without a name", "id", b.ID); b.Name = missingName
+		}
+		seen[b.ID] = true; out = append(out, b)
+	}
+	if len(out) == 0 && len(content) > 0 { out = []Block{{Type: "text", Text: "(malformed tool call omitted)"}} } // the API rejects empty content
+	return out, notes
+}
+
+// normalizeInput replaces the raw input with the parsed object, or {} when it can't be parsed
+// (remembering why, or that it was cut off), so the history echoed back to the API always carries a valid object.
+func (b *Block) normalizeInput() {
+	in, err := decodeInput(b.Input)
+	if err != nil && truncatedJSON(b.Input) { err = errTruncatedInput }
+	if err != nil { b.inputErr, in = err, Input{} }
+	b.Input, _ = json.Marshal(in)
+}
+
+// decodeInput parses a tool_use input. Absent input is an empty object; a JSON string holding
+// the object (as OpenAI-style gateways send it) is unwrapped; trailing commas are repaired.
+func decodeInput(raw json.RawMessage) (Input, error) {
+	if len(bytes.TrimSpace(raw)) == 0 { return Input{}, nil }
+	var s string; if json.Unmarshal(raw, &s) == nil { raw = json.RawMessage(s) }
+	var in Input
+	if err := json.Unmarshal(raw, &in); err != nil {
+		if json.Unmarshal(repairJSON(raw), &in) != nil { return nil, err }
+		slog.Debug("repaired tool input", "input", string(raw))
+	}
+	if in == nil { return nil, errors.New("input must be a JSON object") }
+	return in, nil
+}
+
+// repairJSON drops commas that directly precede a closing brace or bracket, outside strings.
+func repairJSON(raw []byte) []byte {
+	out := make([]byte, 0, len(raw)); inStr, esc := false, false
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case inStr: if esc { esc = false } else if c == '\\' { esc = true } else if c == '"' { inStr = false }
+		case c == '"': inStr = true
+		case c == ',':
+			j := i + 1; for j < len(raw) && strings.ContainsRune(" \t\r\n", rune(raw[j])) { j++ }
+			if j < len(raw) && (raw[j] == '}' || raw[j] == ']') { continue }
+		}
+		out = append(out, c)
+	}
+	return out
+}
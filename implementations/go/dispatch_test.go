@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFailedToolResultCarriesIsError(t *testing.T) {
+	f := newFakeAPI(t, reply("tool_use", toolBlock("t1", "read_file", `{"path":"missing.txt"}`), toolBlock("t2", "bash", `{"command":"exit 3"}`), toolBlock("t3", "bash", `{"command":"echo fine"}`)), textReply("done"))
+	a := testAgent(t, f)
+	if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+	req := f.request(t, 1)
+	for _, id := range []string{"t1", "t2"} {
+		if r := toolResult(t, req, id); r["is_error"] != true { t.Errorf("%s: is_error = %v, want true (content %q)", id, r["is_error"], r["content"]) }
+	}
+	if r := toolResult(t, req, "t3"); r["is_error"] != nil { t.Errorf("t3 succeeded but is_error = %v", r["is_error"]) }
+}
+
+func TestUnknownToolListsAvailableTools(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "frobnicate", `{}`), textReply("done"))
+	a := testAgent(t, f)
+	if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+	r := toolResult(t, f.request(t, 1), "t1")
+	content, _ := r["content"].(string)
+	if r["is_error"] != true || !strings.Contains(content, "unknown tool 'frobnicate'; available tools: ") { t.Fatalf("result = %v %q", r["is_error"], content) }
+	for _, name := range []string{"read_file", "write_file", "bash"} { if !strings.Contains(content, name) { t.Errorf("available tools don't name %s: %q", name, content) } }
+}
+
+func TestDisabledToolIsReportedAsDisabled(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "bash", `{"command":"true"}`), textReply("done"))
+	a := testAgent(t, f)
+	a.Tools = readOnly(a.Tools)
+	if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+	content, _ := toolResult(t, f.request(t, 1), "t1")["content"].(string)
+	if !strings.Contains(content, "tool 'bash' is disabled for this run") { t.Fatalf("content = %q", content) }
+}
+
+func TestMalformedToolUsesGetOneResultPerID(t *testing.T) {
+	appendLog := `{"command":"echo run >> log.txt"}`
+	for _, c := range []struct {
+		name   string
+		blocks []string
+		ids    []string // the tool_results expected, in order
+		note   string
+	}{
+		{"verbatim repeat", []string{toolBlock("t1", "bash", appendLog), toolBlock("t1", "bash", appendLog)}, []string{"t1"}, ""},
+		{"id reused by another call", []string{toolBlock("t1", "bash", appendLog), toolBlock("t1", "read_file", `{"path":"log.txt"}`), toolBlock("t2", "bash", `{"command":"true"}`)}, []string{"t1", "t2"}, ""},
+		{"no id", []string{toolBlock("", "bash", appendLog), toolBlock("t2", "bash", appendLog)}, []string{"t2"}, "Your bash tool call had no id, so it was not run"},
+		{"no id or name", []string{toolBlock("", "", `{}`)}, nil, "Your unnamed tool call had no id"},
+		{"no name", []string{toolBlock("t1", "", `{}`), toolBlock("t2", "bash", appendLog)}, []string{"t1", "t2"}, ""},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f := newFakeAPI(t, reply("tool_use", c.blocks...), textReply("done"))
+			a := testAgent(t, f)
+			if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+			msgs := f.request(t, 1)["messages"].([]any)
+			var useIDs, resultIDs, notes []string
+			for _, b := range msgs[1].(map[string]any)["content"].([]any) { if m := b.(map[string]any); m["type"] == "tool_use" { useIDs = append(useIDs, m["id"].(string)) } }
+			for _, b := range msgs[2].(map[string]any)["content"].([]any) {
+				switch m := b.(map[string]any); m["type"] {
+				case "tool_result": resultIDs = append(resultIDs, m["tool_use_id"].(string))
+				case "text": notes = append(notes, m["text"].(string))
+				}
+			}
+			if strings.Join(resultIDs, ",") != strings.Join(c.ids, ",") || strings.Join(useIDs, ",") != strings.Join(c.ids, ",") { t.Errorf("echoed calls %v and results %v, want %v", useIDs, resultIDs, c.ids) }
+			if got := strings.Join(notes, "\n"); c.note != "" && !strings.Contains(got, c.note) || c.note == "" && strings.Contains(got, "had no id") { t.Errorf("notes %q, want %q", got, c.note) }
+			if runs := strings.Count(readIfExists("log.txt"), "run"); runs > 1 { t.Errorf("the repeated call ran %d times", runs) }
+			if c.name == "no name" {
+				r := toolResult(t, f.request(t, 1), "t1")
+				if s, _ := r["content"].(string); r["is_error"] != true || !strings.HasPrefix(s, "Error: this tool call had no tool name") { t.Errorf("nameless call's result: %v", r) }
+				if name := msgs[1].(map[string]any)["content"].([]any)[0].(map[string]any)["name"]; name != missingName { t.Errorf("echoed name %v, want %s", name, missingName) }
+			}
+		})
+	}
+}
+
+func readIfExists(path string) string { data, _ := os.ReadFile(path); return string(data) }
@@ -0,0 +1,168 @@
+// nano doctor: checks the things most "it doesn't work" reports come down to (the key, the
+// base URL and anything proxying it, the model, git, the shell the bash tool runs, and the
+// directories nano writes to) and says how to fix each one. A failed check exits non-zero;
+// warnings don't.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // pass, warn or fail
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+func doctorMain(fs *flag.FlagSet) func(args []string) int {
+	output := fs.String("output", "text", "report format: text or json")
+	model := fs.String("model", "", "check `name` instead of the configured model")
+	sandbox := fs.String("sandbox", "", "also check that `dir` can be used as the sandbox")
+	off := fs.Bool("offline", os.Getenv("NANO_OFFLINE") == "1", "check as --offline runs: a local provider, and nothing reached beyond loopback")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano doctor [--output json] [--model name] [--sandbox dir] [--offline]"); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		if *off { goOffline() }
+		a, err := loadAgent()
+		var checks []check
+		add := func(name, status, detail, hint string) { checks = append(checks, check{name, status, detail, hint}) }
+		if err != nil { add("config", "fail", err.Error(), "fix the config file named in the error"); return printChecks(checks, *output) }
+		add("config", "pass", configSummary(), "")
+		if offline { checks = append(checks, offlineChecks(a)...) }
+		if *model != "" { a.Model = resolveModel(*model) }
+		checks = append(checks, checkKey(a.Key, a.keySource))
+		base := strings.TrimSuffix(a.URL, "/v1/messages")
+		reach, listed := checkBaseURL(a, base)
+		checks = append(checks, reach)
+		if listed { checks = append(checks, checkModel(a, base)) } else { add("model", "warn", a.Model+": not verified (the models endpoint didn't answer)", "") }
+		if _, _, ok := priceFor(a.Model); !ok { add("pricing", "warn", a.Model+": no pricing entry, so costs show as unknown", `add it to the "pricing" list in the config file`) }
+		if p, err := exec.LookPath("git"); err != nil {
+			add("git", "warn", "git not found on PATH", "install git; sessions, fork --worktree and usage by project rely on it")
+		} else {
+			out, _ := exec.Command(p, "--version").Output(); add("git", "pass", strings.TrimSpace(string(out)), "")
+		}
+		_, bashOn := a.lookup("bash")
+		if p, err := exec.LookPath("sh"); err == nil {
+			add("shell", "pass", p+" (used by the bash tool)", "")
+		} else if bashOn {
+			add("shell", "fail", "sh not found on PATH; the bash tool can't run commands", "put a POSIX shell on PATH, or run with --disable-tools bash")
+		} else {
+			add("shell", "warn", "sh not found on PATH (the bash tool is disabled)", "")
+		}
+		for _, t := range a.Tools { if t.Available != nil { if err := t.Available(); err != nil { add("tool "+t.Name, "warn", err.Error(), "configure it or disable it with --disable-tools "+t.Name) } } }
+		checks = append(checks, checkWritable("data directory", dataDir(), true))
+		if dir, err := os.UserConfigDir(); err == nil { checks = append(checks, checkWritable("config directory", filepath.Join(dir, "nano"), false)) }
+		if *sandbox != "" {
+			if err := setSandbox(*sandbox); err != nil { add("sandbox", "fail", err.Error(), "pass an existing directory to --sandbox") } else { checks = append(checks, checkWritable("sandbox", sandboxRoot, true)) }
+		}
+		return printChecks(checks, *output)
+	}
+}
+
+func configSummary() string {
+	var files []string
+	for _, p := range configPaths() { if _, err := os.Stat(p); err == nil { files = append(files, p) } }
+	if len(files) == 0 { return "no config files (defaults)" }
+	return "loaded " + strings.Join(files, ", ")
+}
+
+func checkKey(key, src string) check {
+	c := check{Name: "api key"}
+	switch {
+	case key == "": c.Status, c.Detail, c.Hint = "fail", "no key: not from --api-key, NANO_API_KEY_CMD, the keychain, ANTHROPIC_API_KEY or ANTHROPIC_AUTH_TOKEN", "export ANTHROPIC_API_KEY=sk-ant-..., or run nano auth set"
+	case strings.TrimSpace(key) != key || strings.ContainsAny(key, "\"' \n"): c.Status, c.Detail, c.Hint = "fail", src+" contains spaces, quotes or a newline", "re-export the key without quotes or surrounding whitespace"
+	case !strings.HasPrefix(key, "sk-ant-"): c.Status, c.Detail, c.Hint = "warn", fmt.Sprintf("%s is set (%d chars) but doesn't look like an Anthropic key", src, len(key)), "fine for a gateway; otherwise copy the key again from the console"
+	default: c.Status, c.Detail = "pass", fmt.Sprintf("%s is set (sk-ant-…%s)", src, key[len(key)-4:])
+	}
+	return c
+}
+
+var doctorClient = &http.Client{Timeout: 15 * time.Second}
+
+// doctorGet sends a GET with the same headers as a real request; err is only a transport error.
+func doctorGet(a *Agent, u string) (int, []byte, error) {
+	resp, err := doctorClient.Do(a.newRequest("GET", u, nil)); if err != nil { return 0, nil, err }
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp.StatusCode, raw, nil
+}
+
+// checkBaseURL lists models, which costs nothing, to see that the base URL answers as the API;
+// ok means the models endpoint works, so the model can be checked too.
+func checkBaseURL(a *Agent, base string) (check, bool) {
+	c := check{Name: "base url"}
+	if u, err := url.Parse(base); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.Status, c.Detail, c.Hint = "fail", fmt.Sprintf("%q is not an http(s) URL", base), "set ANTHROPIC_BASE_URL to e.g. https://api.anthropic.com (without /v1)"; return c, false
+	}
+	via := ""; if p := env("HTTPS_PROXY", env("https_proxy", "")); p != "" { via = " via proxy " + p }
+	status, raw, err := doctorGet(a, base+"/v1/models?limit=1")
+	if err != nil {
+		c.Status, c.Detail, c.Hint = "fail", base+via+": "+err.Error(), "check the URL, your network and any HTTPS_PROXY setting"; return c, false
+	}
+	c.Detail = fmt.Sprintf("%s%s answered %d", base, via, status)
+	var body struct{ Data json.RawMessage `json:"data"`; Error *struct{ Message string `json:"message"` } `json:"error"` }
+	isJSON := json.Unmarshal(raw, &body) == nil
+	switch {
+	case !isJSON: c.Status, c.Hint = "warn", "the response isn't JSON; a proxy or captive portal may be in the way, or the base URL includes a path it shouldn't"
+	case status == 200: c.Status = "pass"
+	case status == 401 || status == 403: c.Status, c.Hint = "fail", "the key was rejected; check it's current and belongs to this endpoint"
+	case status == 404: c.Status, c.Hint = "warn", "no /v1/models here; fine for some gateways, otherwise drop any /v1 from ANTHROPIC_BASE_URL"
+	default: c.Status = "warn"
+	}
+	if body.Error != nil { c.Detail += ": " + body.Error.Message }
+	return c, c.Status == "pass"
+}
+
+func checkModel(a *Agent, base string) check {
+	c := check{Name: "model"}
+	status, _, err := doctorGet(a, base+"/v1/models/"+url.PathEscape(a.Model))
+	switch {
+	case err != nil: c.Status, c.Detail = "warn", a.Model+": not verified: "+err.Error()
+	case status == 200: c.Status, c.Detail = "pass", a.Model
+	case status == 404:
+		c.Status, c.Detail = "fail", a.Model+": the API doesn't know this model"
+		c.Hint = "use an alias (" + strings.Join(sortedKeys(modelAliases), ", ") + ") or a full model id from the console"
+	default: c.Status, c.Detail = "warn", fmt.Sprintf("%s: not verified (HTTP %d)", a.Model, status)
+	}
+	return c
+}
+
+// checkWritable creates and removes a file in dir; hard turns a failure into fail instead of warn.
+func checkWritable(name, dir string, hard bool) check {
+	c := check{Name: name, Status: "pass", Detail: dir + " is writable"}
+	err := os.MkdirAll(dir, 0700)
+	if err == nil { var f *os.File; if f, err = os.CreateTemp(dir, ".doctor-*"); err == nil { f.Close(); os.Remove(f.Name()) } }
+	if err != nil {
+		c.Status, c.Detail, c.Hint = "warn", err.Error(), "fix the permissions on "+dir
+		if hard { c.Status = "fail" }
+	}
+	return c
+}
+
+func printChecks(checks []check, output string) int {
+	failed := slices.ContainsFunc(checks, func(c check) bool { return c.Status == "fail" })
+	if output == "json" {
+		data, _ := json.MarshalIndent(map[string]any{"ok": !failed, "checks": checks}, "", "  "); fmt.Println(string(data))
+	} else {
+		marks := map[string]string{"pass": "✓", "warn": "!", "fail": "✗"}
+		for _, c := range checks {
+			fmt.Printf("%s %-16s %s\n", marks[c.Status], c.Name, c.Detail)
+			if c.Hint != "" && c.Status != "pass" { fmt.Printf("  %-16s → %s\n", "", c.Hint) }
+		}
+	}
+	if failed { return 1 }
+	return 0
+}
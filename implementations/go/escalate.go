@@ -0,0 +1,106 @@
+// Escalation: with --escalate-model, a run that keeps stalling hands the rest of the work to
+// a stronger model. It triggers when a tool fails with the same error several times in a row
+// (--escalate-after-errors), or, in nano fix, when the check keeps failing after the agent's
+// attempts (--escalate-after). The new model is told it has taken over and what has been
+// tried. Usage is kept per model so costs stay right after the switch.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+type escalator struct {
+	model     string // "" disables escalation
+	maxErrors int    // identical tool errors in a row that trigger it
+	lastError string
+	repeats   int
+	done      *escalation
+}
+
+type escalation struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+	Turn   int    `json:"turn"`
+}
+
+// addUsage counts u toward the run total, the model that spent it and its phase (see
+// phases.go); calls outside the main loop report it here, main ones through the meter.
+func (a *Agent) addUsage(phase string, u Usage) {
+	a.countUsage(phase, a.Model, a.batch, u)
+	if phase != phaseMain { a.emit(Event{Kind: "usage", Name: a.Model, Phase: phase, Context: &ContextUsage{Turn: a.Turns, Usage: u, Window: windowFor(a.Model)}}) }
+}
+
+// countUsage adds u, spent by model on a call in phase, to the totals; batched says whether the
+// call went through the Batches API, and so costs half.
+func (a *Agent) countUsage(phase, model string, batched bool, u Usage) {
+	a.Usage.add(u)
+	if a.byModel == nil { a.byModel = map[string]Usage{} }
+	m := a.byModel[model]; m.add(u); a.byModel[model] = m
+	a.addPhase(phase, model, batched, u)
+}
+
+// runCost is the run's cost over every model it used; false when one of them isn't priced.
+func (a *Agent) runCost() (float64, bool) {
+	total := 0.0
+	for m := range a.byModel { c, ok := a.modelCost(m); if !ok { return 0, false }; total += c }
+	return total, true
+}
+
+// modelCost is what model cost over the run's phases, each at its own rate.
+func (a *Agent) modelCost(model string) (float64, bool) {
+	total := 0.0
+	for _, p := range a.phases { if p.Model == model { c, ok := p.cost(); if !ok { return 0, false }; total += c } }
+	return total, true
+}
+
+// costBreakdown is " (model $x + model $y)" once more than one model has been used.
+func (a *Agent) costBreakdown() string {
+	if len(a.byModel) < 2 { return "" }
+	var parts []string
+	for _, m := range sortedKeys(a.byModel) {
+		c, ok := a.modelCost(m)
+		if ok { parts = append(parts, fmt.Sprintf("%s $%.4f", m, c)) } else { parts = append(parts, m+" cost unknown") }
+	}
+	return " (" + strings.Join(parts, " + ") + ")"
+}
+
+// noteToolResult tracks repeated identical tool errors and escalates when they pile up.
+func (a *Agent) noteToolResult(name, result string, isErr bool) string {
+	if !isErr { a.esc.lastError, a.esc.repeats = "", 0; return "" }
+	if key := name + ": " + result; key == a.esc.lastError { a.esc.repeats++ } else { a.esc.lastError, a.esc.repeats = key, 1 }
+	if a.esc.maxErrors <= 0 || a.esc.repeats < a.esc.maxErrors { return "" }
+	return a.escalate(fmt.Sprintf("%s failed with the same error %d times in a row: %s", name, a.esc.repeats, truncate(result, 200)))
+}
+
+// escalate switches to the escalation model, once, and returns the note for the new model.
+func (a *Agent) escalate(reason string) string {
+	if a.esc.model == "" || a.esc.done != nil { return "" }
+	to := resolveModel(a.esc.model); if to == a.Model { return "" }
+	a.esc.done = &escalation{From: a.Model, To: to, Reason: reason, Turn: a.Turns}
+	a.notify(fmt.Sprintf("⬆ escalating to %s: %s", to, reason))
+	slog.Info("escalating model", "from", a.Model, "to", to, "reason", reason, "turn", a.Turns)
+	from := a.Model; a.Model = to
+	return fmt.Sprintf("Note: a more capable model (%s) is taking over this task from %s, because %s.%s Don't repeat approaches that already failed; step back and find the underlying problem.", to, from, reason, a.triedSoFar())
+}
+
+// triedSoFar lists the latest tool calls, so the new model knows what's been tried.
+func (a *Agent) triedSoFar() string {
+	var calls []string
+	for _, m := range a.Messages {
+		blocks, _ := m.Content.([]Block)
+		for _, b := range blocks { if b.Type == "tool_use" { in, _ := decodeInput(b.Input); calls = append(calls, "- "+b.Name+" "+truncate(describeCall(in), 100)) } }
+	}
+	if len(calls) == 0 { return "" }
+	if len(calls) > 15 { calls = calls[len(calls)-15:] }
+	return " Recent tool calls:\n" + strings.Join(calls, "\n") + "\n"
+}
+
+// escalationSummary is the summary-line mention of a switch, or "".
+func (a *Agent) escalationSummary() string {
+	if e := a.esc.done; e != nil { return fmt.Sprintf(" · escalated %s → %s at turn %d", e.From, e.To, e.Turn) }
+	return ""
+}
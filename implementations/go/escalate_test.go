@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func readMissing(id, path string) string { return toolReply(id, "read_file", `{"path":"`+path+`"}`) }
+
+func TestEscalatesAfterRepeatedIdenticalErrors(t *testing.T) {
+	f := newFakeAPI(t, readMissing("t1", "gone.txt"), readMissing("t2", "gone.txt"), readMissing("t3", "gone.txt"), textReply("done"))
+	a := testAgent(t, f)
+	a.esc.model, a.esc.maxErrors = "opus", 3
+	if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+	for i, want := range []string{"claude-sonnet-4-20250514", "claude-sonnet-4-20250514", "claude-sonnet-4-20250514", "claude-opus-4-5"} {
+		if got := f.request(t, i)["model"]; got != want { t.Errorf("request %d went to %v, want %s", i, got, want) }
+	}
+	msgs, _ := json.Marshal(f.request(t, 3)["messages"])
+	for _, want := range []string{"(claude-opus-4-5) is taking over this task from claude-sonnet-4-20250514", "read_file failed with the same error 3 times in a row", "Recent tool calls:\\n- read_file gone.txt"} {
+		if !strings.Contains(string(msgs), want) { t.Errorf("the escalated request lacks %q", want) }
+	}
+	if s := a.escalationSummary(); s != " · escalated claude-sonnet-4-20250514 → claude-opus-4-5 at turn 3" { t.Errorf("summary %q", s) }
+	if len(a.byModel) != 2 || a.byModel["claude-opus-4-5"].InputTokens != 10 { t.Errorf("usage by model %v, want the last call on opus", a.byModel) }
+}
+
+type outcome struct{ result string; isErr bool }
+
+func TestEscalationTriggers(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		calls []outcome
+		want  bool
+	}{
+		{"identical errors", []outcome{{"boom", true}, {"boom", true}, {"boom", true}}, true},
+		{"different errors", []outcome{{"boom", true}, {"bang", true}, {"boom", true}}, false},
+		{"success resets the count", []outcome{{"boom", true}, {"boom", true}, {"ok", false}, {"boom", true}}, false},
+		{"too few", []outcome{{"boom", true}, {"boom", true}}, false},
+	} {
+		a := &Agent{Model: "small"}; a.esc.model, a.esc.maxErrors = "big", 3
+		note := ""
+		for _, call := range c.calls { if n := a.noteToolResult("bash", call.result, call.isErr); n != "" { note = n } }
+		if got := note != ""; got != c.want || (a.Model == "big") != c.want { t.Errorf("%s: escalated %v (model %s), want %v", c.name, got, a.Model, c.want) }
+	}
+}
+
+func TestEscalateOnlyOnce(t *testing.T) {
+	a := &Agent{Model: "small"}; a.esc.model = "big"
+	if a.escalate("the check kept failing") == "" || a.Model != "big" { t.Fatal("the first escalation should switch models") }
+	a.esc.model = "bigger"
+	if note := a.escalate("again"); note != "" || a.Model != "big" { t.Errorf("a second escalation switched to %s", a.Model) }
+	b := &Agent{Model: "big"}; b.esc.model = "big"
+	if note := b.escalate("already there"); note != "" || b.esc.done != nil { t.Error("escalating to the current model should do nothing") }
+	c := &Agent{Model: "small"}
+	if note := c.escalate("no model"); note != "" { t.Error("without --escalate-model there is nothing to escalate to") }
+}
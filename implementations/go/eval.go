@@ -0,0 +1,155 @@
+// Eval harness: `nano eval suite.yaml` runs each case's prompt against a fresh copy of its
+// setup directory (in a separate nano process sandboxed to that copy), then the case's check
+// command, and reports pass rate, turns, cost and duration per case and in aggregate.
+//
+//	model: claude-sonnet-4-20250514   # optional, overrides $MODEL
+//	trials: 3                         # optional default for every case
+//	cases:
+//	  - name: add-flag
+//	    setup: fixtures/cli           # relative to the suite file
+//	    prompt: add a --verbose flag
+//	    check: go test ./...
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type evalCase struct{ Name, Setup, Prompt, Check string; Trials int }
+
+type evalTrial struct {
+	Passed     bool     `json:"passed"`
+	Turns      int      `json:"turns"`
+	CostUSD    *float64 `json:"cost_usd"`
+	DurationMS int64    `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+}
+
+type evalStats struct {
+	Name       string      `json:"name,omitempty"`
+	Trials     []evalTrial `json:"trials,omitempty"`
+	Passed     int         `json:"passed"`
+	Runs       int         `json:"runs"`
+	PassRate   float64     `json:"pass_rate"`
+	AvgTurns   float64     `json:"avg_turns"`
+	CostUSD    *float64    `json:"cost_usd"`
+	DurationMS int64       `json:"duration_ms"`
+}
+
+func evalMain(fs *flag.FlagSet) func(args []string) int {
+	output := fs.String("output", "text", "report format: text or json")
+	trials := fs.Int("trials", 0, "trials per case (overrides the suite)")
+	minRate := fs.Float64("min-pass-rate", 1, "exit non-zero when the overall pass rate is below this")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano eval [flags] suite.yaml"); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		if fs.NArg() != 1 { fs.Usage(); return 1 }
+		model, cases, err := loadSuite(fs.Arg(0)); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		self, err := os.Executable(); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		var all []evalStats; var total evalStats
+		for _, c := range cases {
+			n := c.Trials; if *trials > 0 { n = *trials }
+			st := evalStats{Name: c.Name}
+			for i := 1; i <= n; i++ {
+				fmt.Fprintf(os.Stderr, "▶ %s (%d/%d) ", c.Name, i, n)
+				t := runTrial(self, model, c); st.Trials = append(st.Trials, t)
+				slog.Info("eval trial", "case", c.Name, "trial", i, "passed", t.Passed, "turns", t.Turns, "duration_ms", t.DurationMS)
+				fmt.Fprintf(os.Stderr, "%s %.1fs %s\n", map[bool]string{true: "pass", false: "FAIL"}[t.Passed], float64(t.DurationMS)/1000, t.Error)
+			}
+			st.tally(st.Trials); all = append(all, st); total.Trials = append(total.Trials, st.Trials...)
+		}
+		total.tally(total.Trials); total.Trials = nil
+		if *output == "json" {
+			data, _ := json.MarshalIndent(map[string]any{"cases": all, "total": total}, "", "  "); fmt.Println(string(data))
+		} else {
+			fmt.Printf("%-24s %8s %7s %10s %9s\n", "case", "pass", "turns", "cost", "duration")
+			for _, st := range append(all, evalStats{Name: "total", Passed: total.Passed, Runs: total.Runs, AvgTurns: total.AvgTurns, CostUSD: total.CostUSD, DurationMS: total.DurationMS}) {
+				fmt.Printf("%-24s %8s %7.1f %10s %8.1fs\n", st.Name, fmt.Sprintf("%d/%d", st.Passed, st.Runs), st.AvgTurns, fmtCost(st.CostUSD), float64(st.DurationMS)/1000)
+			}
+		}
+		if total.PassRate < *minRate { return 1 }
+		return 0
+	}
+}
+
+func (st *evalStats) tally(trials []evalTrial) {
+	turns, cost, known := 0, 0.0, true
+	st.Runs, st.Passed, st.DurationMS = len(trials), 0, 0
+	for _, t := range trials {
+		if t.Passed { st.Passed++ }
+		turns += t.Turns; st.DurationMS += t.DurationMS
+		if t.CostUSD == nil { known = false } else { cost += *t.CostUSD }
+	}
+	if st.Runs > 0 { st.PassRate, st.AvgTurns = float64(st.Passed)/float64(st.Runs), float64(turns)/float64(st.Runs) }
+	if known { st.CostUSD = &cost }
+}
+
+func fmtCost(c *float64) string { if c == nil { return "unknown" }; return fmt.Sprintf("$%.4f", *c) }
+
+// runTrial copies the setup into a temp dir, runs nano there, then the check command.
+func runTrial(self, model string, c evalCase) evalTrial {
+	start := time.Now()
+	dir, err := os.MkdirTemp("", "nano-eval-*"); if err != nil { return evalTrial{Error: err.Error()} }
+	defer os.RemoveAll(dir)
+	if c.Setup != "" { if err := copyDir(c.Setup, dir); err != nil { return evalTrial{Error: "setup: " + err.Error()} } }
+	cmd := exec.Command(self, "--sandbox", dir, "--output", "json", c.Prompt); cmd.Dir = dir
+	if model != "" { cmd.Env = append(os.Environ(), "MODEL="+model) }
+	var stderr bytes.Buffer; cmd.Stderr = &stderr
+	out, _ := cmd.Output()
+	var rep report; t := evalTrial{}
+	if err := json.Unmarshal(out, &rep); err != nil {
+		t.Error = "nano: " + lastLine(stderr.String())
+	} else { t.Turns, t.CostUSD, t.Error = rep.Turns, rep.CostUSD, rep.Error }
+	code, _ := runCheck(dir, []string{c.Check})
+	t.Passed = code == 0; t.DurationMS = time.Since(start).Milliseconds()
+	return t
+}
+
+func lastLine(s string) string { lines := strings.Split(strings.TrimSpace(s), "\n"); return lines[len(lines)-1] }
+
+func loadSuite(file string) (string, []evalCase, error) {
+	data, err := os.ReadFile(file); if err != nil { return "", nil, err }
+	doc, err := parseYAML(string(data)); if err != nil { return "", nil, fmt.Errorf("%s: %w", file, err) }
+	top, _ := doc.(map[string]any); items, _ := top["cases"].([]any)
+	if len(items) == 0 { return "", nil, fmt.Errorf("%s: no cases", file) }
+	str := func(m map[string]any, k string) string { if v, ok := m[k]; ok && v != nil { return strings.TrimSpace(fmt.Sprint(v)) }; return "" }
+	trials := 1; if n, ok := top["trials"].(float64); ok && n >= 1 { trials = int(n) }
+	var cases []evalCase
+	for i, item := range items {
+		m, _ := item.(map[string]any)
+		c := evalCase{Name: str(m, "name"), Setup: str(m, "setup"), Prompt: str(m, "prompt"), Check: str(m, "check"), Trials: trials}
+		if c.Name == "" { c.Name = fmt.Sprintf("case-%d", i+1) }
+		if c.Prompt == "" || c.Check == "" { return "", nil, fmt.Errorf("%s: case %s needs a prompt and a check", file, c.Name) }
+		if n, ok := m["trials"].(float64); ok && n >= 1 { c.Trials = int(n) }
+		if c.Setup != "" && !filepath.IsAbs(c.Setup) { c.Setup = filepath.Join(filepath.Dir(file), c.Setup) }
+		cases = append(cases, c)
+	}
+	return str(top, "model"), cases, nil
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil { return err }
+		rel, _ := filepath.Rel(src, path); target := filepath.Join(dst, rel)
+		info, err := d.Info(); if err != nil { return err }
+		switch {
+		case d.IsDir(): return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0: link, err := os.Readlink(path); if err != nil { return err }; return os.Symlink(link, target)
+		}
+		in, err := os.Open(path); if err != nil { return err }; defer in.Close()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm()); if err != nil { return err }
+		if _, err := io.Copy(out, in); err != nil { out.Close(); return err }
+		return out.Close()
+	})
+}
@@ -0,0 +1,30 @@
+// Run events: the agent reports API calls, tool executions and progress to registered
+// listeners, so cross-cutting consumers (timing, the CLI's progress lines) observe the loop
+// from one place and the loop itself never prints.
+
+package main
+
+import "time"
+
+type Event struct {
+	Kind     string // "api_call", "usage", "tool_call", "tool_input", "tool_start", "tool_result" or "notice"
+	Name     string // model for API calls, tool name for tool events
+	Detail   string // command or path of a tool call
+	Text     string // a tool's result, or a notice
+	IsError  bool   // the tool result is an error
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+	Context  *ContextUsage // usage events: tokens and how full the context window is
+	Bytes    int           // tool_input events: input received so far
+	Phase    string        // usage events: what the call was for (see phases.go)
+	Paths    []string      // tool_call events: the files a mutating call set out to change, canonical
+}
+
+// On registers fn to receive every event the agent emits.
+func (a *Agent) On(fn func(Event)) { a.listeners = append(a.listeners, fn) }
+
+func (a *Agent) emit(e Event) { for _, fn := range a.listeners { fn(e) } }
+
+// notify reports progress worth showing a person ("compacting history", "escalating to ...").
+func (a *Agent) notify(text string) { a.emit(Event{Kind: "notice", Text: text, Start: time.Now()}) }
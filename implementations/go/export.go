@@ -0,0 +1,131 @@
+// Session export: `nano export` renders a saved session as a readable Markdown transcript
+// (prompts quoted, tool calls folded into <details> blocks, usage in a footer), an HTML
+// report (exporthtml.go) or dumps it as JSON. Secrets are scrubbed from every format.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const exportResultLimit = 2000
+
+func exportMain(fs *flag.FlagSet) func(args []string) int {
+	format := fs.String("format", "markdown", "markdown, html or json")
+	last := fs.Bool("last", false, "export the most recently saved session")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano export [--format markdown|html|json] <session-id> | nano export --last"); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		var s savedSession; var err error
+		switch {
+		case *last && fs.NArg() == 0:
+			all, _ := allSessions(); if len(all) == 0 { fmt.Fprintln(os.Stderr, "Error: no saved sessions"); return 1 }
+			s = all[len(all)-1]
+		case !*last && fs.NArg() == 1: s, err = loadSession(fs.Arg(0))
+		default: fs.Usage(); return 1
+		}
+		if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		var out string
+		switch *format {
+		case "markdown", "md": out = exportMarkdown(s)
+		case "html": if out, err = exportHTML(s); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		case "json": data, _ := json.MarshalIndent(s, "", "  "); out = string(data) + "\n"
+		default: fmt.Fprintf(os.Stderr, "Error: unknown --format %q (markdown, html or json)\n", *format); return 1
+		}
+		out, _ = scrubSecrets(out)
+		fmt.Print(out); return 0
+	}
+}
+
+// exportItem is one entry of a transcript: a prompt, the model's text, a note that rode
+// along with tool results (queued messages, directory instructions), or a tool call.
+type exportItem struct {
+	Kind    string // prompt, text, note or tool
+	Text    string
+	Call    Block
+	Input   Input
+	Result  string
+	IsError bool
+}
+
+func transcriptItems(s savedSession) []exportItem {
+	var items []exportItem
+	calls := map[string]Block{}
+	for _, m := range s.Messages {
+		switch c := m.Content.(type) {
+		case string: // a prompt (or a compaction summary)
+			items = append(items, exportItem{Kind: "prompt", Text: c})
+		case []Block:
+			for _, bl := range c {
+				switch bl.Type {
+				case "text": if t := strings.TrimSpace(bl.Text); t != "" { items = append(items, exportItem{Kind: "text", Text: t}) }
+				case "tool_use": calls[bl.ID] = bl
+				}
+			}
+		case []any:
+			for _, v := range c {
+				r, _ := v.(map[string]any)
+				switch r["type"] {
+				case "tool_result":
+					id, _ := r["tool_use_id"].(string); call := calls[id]; in, _ := decodeInput(call.Input)
+					items = append(items, exportItem{Kind: "tool", Call: call, Input: in, Result: resultText(r["content"]), IsError: r["is_error"] == true})
+				case "text":
+					if t, _ := r["text"].(string); t != "" { items = append(items, exportItem{Kind: "note", Text: t}) }
+				}
+			}
+		}
+	}
+	return items
+}
+
+func (it exportItem) summary() string {
+	s := it.Call.Name; if d := describeCall(it.Input); d != "" { s += " " + truncate(d, 60) }
+	if it.IsError { s += " (error)" }
+	return s
+}
+
+func clipResult(s string, limit int) string {
+	if len(s) <= limit { return s }
+	for limit > 0 && !utf8.RuneStart(s[limit]) { limit-- } // not inside a character
+	return s[:limit] + fmt.Sprintf("\n[... %d more bytes not included in the export]", len(s)-limit)
+}
+
+func exportMarkdown(s savedSession) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n`%s` · %s · %s · %s\n\n", s.heading(), s.ID, s.Model, s.Saved.Format("2006-01-02 15:04"), s.Dir)
+	for _, it := range transcriptItems(s) {
+		switch it.Kind {
+		case "prompt", "note": fmt.Fprintf(&b, "%s\n\n", quote(it.Text))
+		case "text": fmt.Fprintf(&b, "%s\n\n", it.Text)
+		case "tool":
+			input, _ := json.MarshalIndent(it.Input, "", "  ")
+			fmt.Fprintf(&b, "<details>\n<summary>%s</summary>\n\n%s\n\n%s\n</details>\n\n", htmlEscape(it.summary()), fence("json", string(input)), fence("", strings.TrimRight(clipResult(it.Result, exportResultLimit), "\n")))
+		}
+	}
+	fmt.Fprintf(&b, "---\n\n%s", s.usageLine())
+	if len(s.Files) > 0 { fmt.Fprintf(&b, " · files written: %s", strings.Join(s.Files, ", ")) }
+	b.WriteString("\n")
+	return b.String()
+}
+
+func (s savedSession) heading() string { if s.Title != "" { return s.Title }; return "Session " + s.ID }
+
+func (s savedSession) usageLine() string {
+	cost := "unknown"; if s.CostUSD != nil { cost = fmt.Sprintf("$%.4f", *s.CostUSD) }
+	return fmt.Sprintf("%d input + %d output tokens · cost %s", s.Usage.InputTokens, s.Usage.OutputTokens, cost)
+}
+
+// fence wraps s in a code fence longer than any backtick run inside it.
+func fence(lang, s string) string {
+	ticks := "```"; for strings.Contains(s, ticks) { ticks += "`" }
+	return ticks + lang + "\n" + s + "\n" + ticks
+}
+
+func quote(s string) string { return "> " + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n> ") }
+
+func htmlEscape(s string) string { return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s) }
@@ -0,0 +1,123 @@
+// HTML session reports (nano export --format html): one self-contained page with the usage
+// summary on top, a collapsible turn-by-turn timeline, tool outputs in expandable panels and
+// a colored diff for every file change. Sessions keep no file backups on disk, so diffs are
+// rebuilt from the session's own calls: what read_file returned and what write_file and
+// edit_file were asked to write.
+
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"strings"
+)
+
+const htmlResultLimit = 20000
+
+type htmlTurn struct {
+	Prompt string
+	Items  []htmlItem
+}
+
+type htmlItem struct {
+	Kind, Text, Summary, Input, Result string
+	IsError                            bool
+	Diff                               []diffLine
+}
+
+type diffLine struct{ Class, Text string }
+
+func exportHTML(s savedSession) (string, error) {
+	var turns []htmlTurn
+	known := map[string]string{} // path -> latest content the session saw or wrote
+	for _, it := range transcriptItems(s) {
+		if it.Kind == "prompt" || len(turns) == 0 { turns = append(turns, htmlTurn{}) }
+		t := &turns[len(turns)-1]
+		switch it.Kind {
+		case "prompt": t.Prompt = it.Text
+		case "text", "note": t.Items = append(t.Items, htmlItem{Kind: it.Kind, Text: it.Text})
+		case "tool":
+			input, _ := json.MarshalIndent(it.Input, "", "  ")
+			h := htmlItem{Kind: "tool", Summary: it.summary(), Input: string(input), Result: clipResult(it.Result, htmlResultLimit), IsError: it.IsError}
+			if !it.IsError { h.Diff = fileDiff(it, known) }
+			t.Items = append(t.Items, h)
+		}
+	}
+	var b strings.Builder
+	err := htmlReport.Execute(&b, map[string]any{"S": s, "Title": s.heading(), "Usage": s.usageLine(), "Turns": turns})
+	return b.String(), err
+}
+
+// fileDiff reconstructs the change a file tool made, updating known.
+func fileDiff(it exportItem, known map[string]string) []diffLine {
+	path := it.Input.Str("path")
+	before, seen := known[path]
+	var after string
+	switch it.Call.Name {
+	case "read_file":
+		if !strings.HasPrefix(it.Result, "unchanged since your last read") { known[path] = strings.TrimSuffix(it.Result, "\n[note: this path is ignored by .gitignore/.nanoignore]") }
+		return nil
+	case "write_file": after = it.Input.Str("content")
+	case "edit_file":
+		old, repl := it.Input.Str("old_string"), it.Input.Str("new_string")
+		if !seen || !strings.Contains(before, old) { before, after = old, repl; delete(known, path) } else { after = strings.Replace(before, old, repl, 1); known[path] = after }
+		return diffLines(before, after)
+	default: return nil
+	}
+	known[path] = after
+	return diffLines(before, after)
+}
+
+func diffLines(a, b string) []diffLine {
+	var out []diffLine
+	for _, l := range strings.Split(strings.TrimSuffix(lineDiff(a, b), "\n"), "\n") {
+		if l == "" { continue }
+		class := "ctx"
+		switch { case l[0] == '+': class = "add"; case l[0] == '-': class = "del"; case strings.HasPrefix(l, "…"): class = "gap" }
+		out = append(out, diffLine{class, l})
+	}
+	return out
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font: 14px/1.5 -apple-system, "Segoe UI", sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; color: #1f2328; }
+header { border-bottom: 1px solid #d0d7de; margin-bottom: 1em; }
+.meta { color: #59636e; }
+details { border: 1px solid #d0d7de; border-radius: 6px; margin: .5em 0; padding: .3em .8em; }
+details.turn > summary { font-weight: 600; }
+summary { cursor: pointer; }
+blockquote { border-left: 4px solid #0969da; margin: .5em 0; padding: .2em 1em; background: #f6f8fa; white-space: pre-wrap; }
+.note { border-left-color: #9a6700; }
+.text { white-space: pre-wrap; }
+.error > summary { color: #cf222e; }
+pre { background: #f6f8fa; padding: .6em; overflow-x: auto; border-radius: 4px; }
+pre.diff span { display: block; }
+.add { background: #dafbe1; } .del { background: #ffebe9; } .gap { color: #59636e; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p class="meta"><code>{{.S.ID}}</code> · {{.S.Model}} · {{.S.Saved.Format "2006-01-02 15:04"}} · {{.S.Dir}}</p>
+<p><strong>{{.Usage}}</strong>{{if .S.Files}} · files written: {{range $i, $f := .S.Files}}{{if $i}}, {{end}}<code>{{$f}}</code>{{end}}{{end}}</p>
+</header>
+{{range $n, $t := .Turns}}<details class="turn" open>
+<summary>Turn {{$n}}</summary>
+{{if $t.Prompt}}<blockquote>{{$t.Prompt}}</blockquote>
+{{end}}{{range $t.Items}}{{if eq .Kind "text"}}<div class="text">{{.Text}}</div>
+{{else if eq .Kind "note"}}<blockquote class="note">{{.Text}}</blockquote>
+{{else}}<details{{if .IsError}} class="error"{{end}}>
+<summary>{{.Summary}}</summary>
+<pre>{{.Input}}</pre>
+{{if .Diff}}<pre class="diff">{{range .Diff}}<span class="{{.Class}}">{{.Text}}</span>{{end}}</pre>
+{{end}}<pre>{{.Result}}</pre>
+</details>
+{{end}}{{end}}</details>
+{{end}}</body>
+</html>
+`))
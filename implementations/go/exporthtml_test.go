@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+var htmlToken = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)(?:\s[^<>]*)?>|<!DOCTYPE html>`)
+
+// htmlVoid are the elements the report uses that take no closing tag.
+var htmlVoid = map[string]bool{"meta": true}
+
+// pageText checks that page tokenizes into balanced tags with no stray markup in between, and
+// returns its text.
+func pageText(t *testing.T, page string) string {
+	t.Helper()
+	var stack []string; var text strings.Builder; last := 0
+	for _, m := range htmlToken.FindAllStringSubmatchIndex(page, -1) {
+		between := page[last:m[0]]; last = m[1]
+		inStyle := len(stack) > 0 && stack[len(stack)-1] == "style" // CSS has child selectors
+		if strings.ContainsAny(between, "<>") && !(inStyle && !strings.Contains(between, "<")) { t.Fatalf("stray markup in text: %q", truncate(between, 200)) }
+		text.WriteString(between)
+		if m[4] < 0 { continue } // the doctype
+		name := strings.ToLower(page[m[4]:m[5]])
+		switch {
+		case m[3] > m[2]:
+			if len(stack) == 0 || stack[len(stack)-1] != name { t.Fatalf("</%s> closes %v", name, stack) }
+			stack = stack[:len(stack)-1]
+		case !htmlVoid[name]: stack = append(stack, name)
+		}
+	}
+	if strings.ContainsAny(page[last:], "<>") || len(stack) > 0 { t.Fatalf("unclosed at the end: %v", stack) }
+	return text.String()
+}
+
+func TestExportHTMLIsWellFormedAndEscaped(t *testing.T) {
+	big := "x" + strings.Repeat("é", htmlResultLimit) // the limit falls inside a rune + "</pre><script>alert('late')</script>"
+	write, _ := json.Marshal(map[string]string{"path": "x.html", "content": "<script>alert('diff')</script>\n"})
+	s := savedSession{ID: "s1", Title: "<script>alert('title')</script>", Model: "claude-sonnet-4-5", Dir: "/work", Saved: time.Now(), Messages: []Message{
+		{Role: "user", Content: "fix <script>alert('prompt')</script>"},
+		{Role: "assistant", Content: []Block{{Type: "text", Text: "Looking <b>now</b> & then"}, {Type: "tool_use", ID: "t1", Name: "bash", Input: json.RawMessage(`{"command":"cat big"}`)}, {Type: "tool_use", ID: "t2", Name: "write_file", Input: write}}},
+		{Role: "user", Content: []any{map[string]any{"type": "tool_result", "tool_use_id": "t1", "content": big}, map[string]any{"type": "tool_result", "tool_use_id": "t2", "content": "ok"}}},
+	}}
+	page, err := exportHTML(s); if err != nil { t.Fatal(err) }
+	if !utf8.ValidString(page) { t.Error("the page isn't valid UTF-8") }
+	text := pageText(t, page)
+	for _, want := range []string{"&lt;script&gt;alert(&#39;prompt&#39;)&lt;/script&gt;", "&lt;script&gt;alert(&#39;title&#39;)", "&lt;b&gt;now&lt;/b&gt; &amp; then", "&lt;script&gt;alert(&#39;diff&#39;)"} {
+		if !strings.Contains(text, want) { t.Errorf("%s is missing or unescaped", want) }
+	}
+	if strings.Contains(page, "<script") { t.Error("a <script> tag made it into the page") }
+	if strings.Contains(page, "late") { t.Error("the large output wasn't truncated") }
+	if !strings.Contains(text, "more bytes not included in the export]") { t.Error("the truncation isn't marked") }
+}
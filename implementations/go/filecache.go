@@ -0,0 +1,89 @@
+// Per-run record of the files the agent has read or written, keyed by absolute path. It serves
+// two purposes: a repeat read of a file whose size and mtime still match what the model last
+// saw, and whose contents are still in its context (compaction forgets them), is answered
+// with a short note instead of the whole body, and a write or edit to a file whose contents
+// changed on disk since the agent last saw them is refused so the agent doesn't clobber edits
+// made in an editor or by another session.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+type readEntry struct {
+	modTime time.Time
+	size    int64
+	hash    string
+	seen    bool // the model has these exact contents in context (false after its own write)
+}
+
+var readCache = struct {
+	sync.Mutex
+	m map[string]readEntry
+}{m: map[string]readEntry{}}
+
+func cacheKey(path string) string { return canonPath(path) }
+
+// cachedRead returns the entry for path if the file on disk still matches it.
+func cachedRead(path string, fi os.FileInfo) (readEntry, bool) {
+	readCache.Lock(); defer readCache.Unlock()
+	e, ok := readCache.m[cacheKey(path)]
+	return e, ok && e.seen && e.size == fi.Size() && e.modTime.Equal(fi.ModTime())
+}
+
+func rememberRead(path string, fi os.FileInfo, data []byte) {
+	readCache.Lock(); defer readCache.Unlock()
+	readCache.m[cacheKey(path)] = readEntry{modTime: fi.ModTime(), size: fi.Size(), hash: contentHash(data), seen: true}
+}
+
+// forgetReads drops every entry, for when the conversation forgets what was read.
+func forgetReads() { readCache.Lock(); readCache.m = map[string]readEntry{}; readCache.Unlock() }
+
+// forgetReadsIn marks the files read by the read_file calls in msgs as no longer in the
+// model's context, so reading one again sends its contents: the calls whose results are in
+// ids, or all of them when ids is nil. The hashes stay, for checkUnmodified.
+func forgetReadsIn(msgs []Message, ids []string) {
+	readCache.Lock(); defer readCache.Unlock()
+	for _, m := range msgs {
+		bl, _ := m.Content.([]Block)
+		for _, b := range bl {
+			if b.Type != "tool_use" || b.Name != "read_file" || ids != nil && !slices.Contains(ids, b.ID) { continue }
+			in, err := decodeInput(b.Input); if err != nil { continue }
+			k := cacheKey(in.Str("path")); if e, ok := readCache.m[k]; ok { e.seen = false; readCache.m[k] = e }
+		}
+	}
+}
+
+// recordWrite notes the state the agent just wrote, so later edits compare against it.
+func recordWrite(path string, data []byte) { recordWriteHash(path, contentHash(data)) }
+
+func recordWriteHash(path, hash string) {
+	fi, err := os.Stat(path)
+	readCache.Lock(); defer readCache.Unlock()
+	if err != nil { delete(readCache.m, cacheKey(path)); return }
+	readCache.m[cacheKey(path)] = readEntry{modTime: fi.ModTime(), size: fi.Size(), hash: hash}
+}
+
+var errModified = errors.New("file modified outside this session since it was read; re-read before editing (or pass force: true to overwrite)")
+
+// checkUnmodified fails when path changed on disk since the agent last read or wrote it.
+// current is the file's present contents (nil if it no longer exists). Files the agent never
+// touched aren't checked.
+func checkUnmodified(path string, current []byte) error {
+	readCache.Lock(); defer readCache.Unlock()
+	e, ok := readCache.m[cacheKey(path)]
+	if !ok { return nil }
+	if current == nil || contentHash(current) != e.hash { return errModified }
+	return nil
+}
+
+func contentHash(data []byte) string { sum := sha256.Sum256(data); return hex.EncodeToString(sum[:4]) }
+
+func hashSum(sum []byte) string { return hex.EncodeToString(sum[:4]) }
@@ -0,0 +1,71 @@
+// The file_info tool: metadata for one path, so the model can check existence, size or age
+// without reading the file or listing its directory.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var languages = map[string]string{
+	".go": "Go", ".ts": "TypeScript", ".tsx": "TypeScript (JSX)", ".js": "JavaScript", ".jsx": "JavaScript (JSX)", ".mjs": "JavaScript",
+	".py": "Python", ".rs": "Rust", ".zig": "Zig", ".c": "C", ".h": "C header", ".cc": "C++", ".cpp": "C++", ".hpp": "C++ header",
+	".java": "Java", ".kt": "Kotlin", ".swift": "Swift", ".rb": "Ruby", ".php": "PHP", ".cs": "C#", ".sh": "Shell", ".bash": "Shell",
+	".md": "Markdown", ".json": "JSON", ".yaml": "YAML", ".yml": "YAML", ".toml": "TOML", ".xml": "XML", ".html": "HTML", ".css": "CSS",
+	".sql": "SQL", ".proto": "Protocol Buffers", ".lua": "Lua", ".txt": "text",
+}
+
+func fileInfo(in Input) (string, error) {
+	path, err := resolveEntry(in.Str("path")); if err != nil { return "", err }
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) { return "exists: no", nil } else if err != nil { return "", err }
+	var lines []string; prefix := ""
+	add := func(k string, v any) { lines = append(lines, fmt.Sprintf("%s%s: %v", prefix, k, v)) }
+	add("exists", "yes")
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, _ := os.Readlink(path); add("type", "symlink -> "+target)
+		if _, err := resolvePath(in.Str("path")); err != nil { add("target", "outside the sandbox; not followed"); return strings.Join(lines, "\n"), nil }
+		if fi, err = os.Stat(path); err != nil { add("target", "broken ("+err.Error()+")"); return strings.Join(lines, "\n"), nil }
+		prefix = "target "
+	}
+	switch {
+	case fi.IsDir():
+		add("type", "directory")
+		if entries, err := os.ReadDir(path); err == nil { add("entries", len(entries)) }
+	case fi.Mode().IsRegular(): add("type", "file")
+	default: add("type", fi.Mode().Type().String())
+	}
+	add("size", fi.Size()); add("mode", fi.Mode().Perm()); add("modified", fi.ModTime().Format(time.RFC3339))
+	if fi.Mode().IsRegular() {
+		if lang, ok := languages[strings.ToLower(filepath.Ext(path))]; ok { add("language", lang) }
+		if n, ok := countLines(path); ok { add("lines", n) } else { add("content", "binary") }
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// countLines counts newline-terminated lines (plus a final partial one) in a text file; it
+// reports false for binary content, judged by NULs or invalid UTF-8 in the first 8 KiB.
+func countLines(path string) (int, bool) {
+	f, err := os.Open(path); if err != nil { return 0, false }; defer f.Close()
+	buf := make([]byte, 64<<10); n, last, first := 0, byte('\n'), true
+	for {
+		k, err := f.Read(buf)
+		if first && k > 0 {
+			head := buf[:min(k, 8<<10)]
+			for i := 0; i < utf8.UTFMax-1 && k > len(head) && !utf8.Valid(head); i++ { head = head[:len(head)-1] } // rune split at the cut
+			if bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(head) { return 0, false }
+			first = false
+		}
+		n += bytes.Count(buf[:k], []byte("\n")); if k > 0 { last = buf[k-1] }
+		if err == io.EOF { break } else if err != nil { return 0, false }
+	}
+	if last != '\n' { n++ }
+	return n, true
+}
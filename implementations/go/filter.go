@@ -0,0 +1,84 @@
+// Filter mode: `cat report.csv | nano --filter "convert to a markdown table" > out.md`. Stdin is
+// the content, the prompt is the instruction, and stdout gets the answer and nothing else
+// (unwrapped when it is a single fenced block); progress and errors go to stderr. Tools are off
+// unless --tools asks for some. Long input is sent in line-aligned parts, each small enough for
+// its transformed output to fit in one reply, and input over filterMaxInput is refused up front
+// with its size rather than failing at the API. Exit status is 1 on errors and 3 on a refusal, so
+// `set -e` pipelines stop.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const filterPrompt = "You are a filter in a Unix pipeline. Apply the instruction to the input and reply with the transformed output only: no preamble, no explanation, no code fence around it unless the instruction asks for one."
+
+const (
+	filterChunk    = 24_000    // bytes of input per request, so the transformed part fits in maxOutput
+	filterMaxInput = 1_000_000 // larger stdin is refused
+)
+
+const filterPartNote = "\n\nThe input is too long for one reply, so it comes in %d parts and your replies are joined in order. This is part %d: transform just this part, continuing seamlessly from the previous one, and don't repeat headers or framing the previous parts already produced."
+
+// filter runs one instruction over stdin and returns the exit code.
+func (a *Agent) filter(instruction string) int {
+	if isTTY(os.Stdin) { fmt.Fprintln(os.Stderr, "Error: --filter transforms stdin; pipe the content in, e.g. cat file | nano --filter \"instruction\""); return 1 }
+	data, err := io.ReadAll(io.LimitReader(os.Stdin, filterMaxInput+1)); if err != nil { fmt.Fprintln(os.Stderr, "Error: reading stdin:", err); return 1 }
+	if len(data) > filterMaxInput { fmt.Fprintf(os.Stderr, "Error: stdin is over %d KB, the most --filter takes; split it (e.g. with split -l) and filter the parts\n", filterMaxInput/1000); return 1 }
+	if !utf8.Valid(data) { fmt.Fprintln(os.Stderr, "Error: stdin isn't UTF-8 text"); return 1 }
+	parts := chunkLines(string(data), filterChunk)
+	if len(parts) > 1 { fmt.Fprintf(ui, "stdin is %d KB; filtering it in %d parts\n", len(data)/1000, len(parts)) }
+	var out strings.Builder
+	for i, part := range parts {
+		prompt := instruction; if len(parts) > 1 { prompt += fmt.Sprintf(filterPartNote, len(parts), i+1) }
+		a.Messages, a.exchanges = nil, nil // each part is a request of its own
+		result, err := a.Send(fmt.Sprintf("%s\n\n<input>\n%s</input>", prompt, ensureNewline(part))); a.exitIfStopped()
+		if errors.Is(err, errRefused) { fmt.Fprintln(os.Stderr, "Error:", err); return exitRefused }
+		if err != nil { fmt.Fprintf(os.Stderr, "Error: part %d of %d: %v\n", i+1, len(parts), err); return 1 }
+		out.WriteString(ensureNewline(unfence(result)))
+	}
+	fmt.Print(out.String())
+	return 0
+}
+
+// chunkLines splits s into pieces of at most n bytes, breaking after a newline where it can.
+func chunkLines(s string, n int) []string {
+	var parts []string
+	for len(s) > n {
+		cut := strings.LastIndexByte(s[:n], '\n') + 1
+		if cut == 0 { cut = n; for cut > 0 && !utf8.RuneStart(s[cut]) { cut-- } }
+		parts, s = append(parts, s[:cut]), s[cut:]
+	}
+	return append(parts, s)
+}
+
+// unfence returns the body of s when all of s is one fenced code block, else s unchanged.
+func unfence(s string) string {
+	t := strings.TrimSpace(s)
+	open, rest, ok := strings.Cut(t, "\n"); if !ok { return s }
+	marker := open[:len(open)-len(strings.TrimLeft(open, "`~"))]
+	if len(marker) < 3 || strings.Trim(marker, "`") != "" && strings.Trim(marker, "~") != "" { return s }
+	if strings.Contains(strings.TrimSpace(open[len(marker):]), marker[:1]) { return s } // ```foo``` on one line
+	i := strings.LastIndex(rest, "\n"); last := rest[i+1:]
+	if strings.TrimSpace(last) != marker { return s }
+	body := rest[:max(i, 0)]
+	for _, l := range strings.Split(body, "\n") { if strings.HasPrefix(strings.TrimSpace(l), marker) { return s } } // a second block
+	return body
+}
+
+func ensureNewline(s string) string { if s != "" && !strings.HasSuffix(s, "\n") { return s + "\n" }; return s }
+
+// filterDefaults turns tools off for --filter unless --tools or --disable-tools was given.
+func filterDefaults(a *Agent, fs *flag.FlagSet) {
+	set := map[string]bool{}; fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["tools"] && !set["disable-tools"] { a.Tools = nil }
+	a.System, a.prefix = filterPrompt, ""
+	ui = os.Stderr
+}
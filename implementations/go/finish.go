@@ -0,0 +1,50 @@
+// The finish tool: the model ends a task by reporting its outcome (success, partial or
+// failure), a summary for the user and any follow-up items, instead of leaving scripts to
+// guess from its last message. The call ends the run whatever the stop_reason; the summary
+// becomes the result printed on stdout and the outcome sets the exit code (failure 1, partial
+// 4). A run the model ends without calling it is "unreported" and exits as before.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const exitPartial = 4
+
+const finishRule = "\n\nWhen the task is done, or you can't take it any further, call the finish tool with status success, partial or failure, a summary for the user of what you did and found, and follow_up_items for anything left to do. Calling it ends your turn, so make it your last call."
+
+// finishReport is what a finish call reported.
+type finishReport struct {
+	Status   string
+	Summary  string
+	FollowUp []string
+}
+
+func finishTool(in Input) (string, error) {
+	if _, err := finishInput(in); err != nil { return "", err }
+	return "Recorded; the run ends here.", nil
+}
+
+// finishInput checks a finish call beyond what validateInput does: the status must be one of
+// the three, the summary can't be blank and the follow-up items must be strings.
+func finishInput(in Input) (finishReport, error) {
+	f := finishReport{Status: in.Str("status"), Summary: strings.TrimSpace(in.Str("summary"))}
+	if !slices.Contains([]string{"success", "partial", "failure"}, f.Status) { return f, fmt.Errorf("status must be success, partial or failure, not %q", f.Status) }
+	if f.Summary == "" { return f, errors.New("summary is empty; say what was done") }
+	items, _ := in["follow_up_items"].([]any)
+	for i, v := range items {
+		s, ok := v.(string); if !ok { return f, fmt.Errorf("follow_up_items[%d] is not a string", i) }
+		if s = strings.TrimSpace(s); s != "" { f.FollowUp = append(f.FollowUp, s) }
+	}
+	return f, nil
+}
+
+// finishRule tells the model about the finish tool, when it has it.
+func (a *Agent) finishRule() string {
+	if _, ok := a.lookup("finish"); ok { return finishRule }
+	return ""
+}
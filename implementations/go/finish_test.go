@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func finishCall(id, input string) string { return toolBlock(id, "finish", input) }
+
+func TestFinishEndsTheRunWithItsReport(t *testing.T) {
+	f := newFakeAPI(t, reply("end_turn", textBlock("All done."), toolBlock("t0", "bash", `{"command":"echo hi"}`), finishCall("t1", `{"status":"partial","summary":"Fixed the parser; the docs are still stale.","follow_up_items":["update docs", "  "]}`)), textReply("never sent"))
+	a := testAgent(t, f)
+	r, err := a.Run("fix it")
+	if err != nil { t.Fatal(err) }
+	if f.count() != 1 { t.Errorf("%d requests; the finish call should end the run whatever the stop_reason", f.count()) }
+	if r.Text != "Fixed the parser; the docs are still stale." || r.Outcome != "partial" || len(r.FollowUp) != 1 || r.FollowUp[0] != "update docs" { t.Errorf("result %q %s %q", r.Text, r.Outcome, r.FollowUp) }
+	if len(r.Turns) != 1 || len(r.Turns[0].Tools) != 2 { t.Errorf("the bash call alongside finish should still run: %+v", r.Turns) }
+}
+
+func TestRunWithoutFinishIsUnreported(t *testing.T) {
+	f := newFakeAPI(t, textReply("I think it works now."))
+	a := testAgent(t, f)
+	r, err := a.Run("fix it")
+	if err != nil { t.Fatal(err) }
+	if r.Text != "I think it works now." || r.Outcome != "unreported" { t.Errorf("result %q %s", r.Text, r.Outcome) }
+}
+
+func TestMalformedFinishIsSentBack(t *testing.T) {
+	for _, c := range []struct{ name, input, want string }{
+		{"unknown status", `{"status":"done","summary":"ok"}`, `status must be success, partial or failure, not "done"`},
+		{"blank summary", `{"status":"success","summary":"   "}`, "summary is empty"},
+		{"non-string item", `{"status":"success","summary":"ok","follow_up_items":["a", 3]}`, "follow_up_items[1] is not a string"},
+		{"missing status", `{"summary":"ok"}`, `missing required field "status"`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			f := newFakeAPI(t, reply("tool_use", finishCall("t1", c.input)), reply("tool_use", finishCall("t2", `{"status":"failure","summary":"Couldn't do it."}`)))
+			a := testAgent(t, f)
+			r, err := a.Run("go")
+			if err != nil { t.Fatal(err) }
+			res := toolResult(t, f.request(t, 1), "t1"); content, _ := res["content"].(string)
+			if res["is_error"] != true || !strings.Contains(content, c.want) { t.Errorf("tool result %q, want an error quoting %q", content, c.want) }
+			if r.Outcome != "failure" || r.Text != "Couldn't do it." { t.Errorf("the corrected call should end the run: %s %q", r.Outcome, r.Text) }
+		})
+	}
+}
+
+func TestOutcomeExitCodes(t *testing.T) {
+	for outcome, want := range map[string]int{"success": 0, "unreported": 0, "partial": exitPartial, "failure": 1} {
+		if got := (report{Status: "completed", Outcome: outcome}).exitCode(); got != want { t.Errorf("%s exits %d, want %d", outcome, got, want) }
+	}
+	if got := (report{Status: "failed", Outcome: "success"}).exitCode(); got != 1 { t.Errorf("a failed run that reported success exits %d, want 1", got) }
+}
@@ -0,0 +1,65 @@
+// Fix mode: run a command, hand its failure to the agent, and re-run it after every
+// agent turn until it passes or attempts run out. Exit status mirrors the command: 0 once it
+// passes, else its last exit code (1 if it was killed by a signal, or the agent itself failed).
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func fixMain(fs *flag.FlagSet) func(args []string) int {
+	maxAttempts := fs.Int("max-attempts", 3, "agent turns to spend before giving up")
+	escalateTo := fs.String("escalate-model", "", "switch to `model` when the command keeps failing")
+	escalateAfter := fs.Int("escalate-after", 2, "with --escalate-model, escalate after `n` failed attempts in a row")
+	escalateErrors := fs.Int("escalate-after-errors", 3, "with --escalate-model, also escalate after `n` identical tool errors in a row")
+	ci := fs.Bool("ci", false, "GitHub Actions output, as for nano --ci; combine with --yes so the agent can edit")
+	fs.BoolVar(&autoApprove, "yes", autoApprove, "approve every write and command without asking")
+	noLock := fs.Bool("no-lock", false, "run even if another nano is running in this project")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano fix [--max-attempts N] [--escalate-model model] -- command [args...]"); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		cmd := fs.Args(); if len(cmd) == 0 { fs.Usage(); return 1 }
+		a, err := newAgent(); if err != nil { fmt.Fprintln(os.Stderr, err); return 1 }
+		a.renderProgress()
+		a.esc.model, a.esc.maxErrors = *escalateTo, *escalateErrors
+		shown := strings.Join(cmd, " ")
+		if *ci { a.ci = newCIRun("nano fix -- " + shown); atExit = a.writeSummary }
+		root := telemetry.Start("nano.fix"); a.span = root
+		code, out := runCheck("", cmd)
+		if code == 0 { fmt.Printf("✓ %s already passes\n", shown); return 0 }
+		if !*noLock && !acquireRunLock("nano fix -- " + shown) { return 1 }
+		prompt := fmt.Sprintf("The command `%s` fails with exit code %d. Output:\n```\n%s\n```\nFix the code so this command passes. Do not change the command itself.", shown, code, out)
+		for attempt := 1; attempt <= *maxAttempts; attempt++ {
+			fmt.Fprintf(os.Stderr, "🔧 attempt %d/%d\n", attempt, *maxAttempts)
+			slog.Info("fix attempt", "attempt", attempt, "max", *maxAttempts, "exit_code", code)
+			result, err := a.Send(prompt); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); root.End(err); return 1 }
+			fmt.Println(result)
+			if code, out = runCheck("", cmd); code == 0 { fmt.Printf("✓ %s passes after %d attempt(s)%s\n", shown, attempt, a.escalationSummary()); root.End(nil); return 0 }
+			prompt = fmt.Sprintf("I re-ran `%s` and it still fails with exit code %d. Output:\n```\n%s\n```\nKeep going until it passes.", shown, code, out)
+			if attempt >= *escalateAfter { if note := a.escalate(fmt.Sprintf("`%s` still failed after %d attempts", shown, attempt)); note != "" { prompt += "\n\n" + note } }
+		}
+		fmt.Fprintf(os.Stderr, "✗ %s still fails after %d attempt(s)%s\n", shown, *maxAttempts, a.escalationSummary())
+		if a.ci != nil { a.ci.annotate(out) }
+		root.End(fmt.Errorf("still failing after %d attempts", *maxAttempts))
+		if code < 1 { code = 1 }
+		return code
+	}
+}
+
+// runCheck runs cmd in dir (a single argument goes through sh -c) and returns its exit code
+// and combined output, keeping the tail when long since that's where failures are reported.
+func runCheck(dir string, cmd []string) (int, string) {
+	c := exec.Command(cmd[0], cmd[1:]...); if len(cmd) == 1 { c = exec.Command("sh", "-c", cmd[0]) }
+	c.Dir, c.Env = dir, withRunEnv(withProjectEnv(nil))
+	b, err := c.CombinedOutput(); if len(b) > 50000 { b = b[len(b)-50000:] }
+	out, _ := scrubSecrets(string(b))
+	if err == nil { return 0, out }
+	if ee, ok := err.(*exec.ExitError); ok { return ee.ExitCode(), out }
+	return 127, out + err.Error()
+}
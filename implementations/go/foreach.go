@@ -0,0 +1,156 @@
+// Monorepo-wide runs: `nano foreach --dirs 'services/*' --parallel 3 "prompt"` runs the prompt
+// once in every matching directory, each in a separate nano process sandboxed to it (so each
+// gets its own session, lock and summary), at most --parallel at a time. A line per
+// directory reports when it starts and how it ended; the final report has each directory's
+// status, turns, cost, changed files and diff, and the exit code is 1 if any failed. A
+// failure leaves its siblings running unless --halt-on-fail is given, which stops them with
+// SIGTERM (they save their sessions and say how to resume, in their logs) and starts no more.
+// Ctrl-C reaches every run from the terminal, as it would a single one; foreach itself then
+// starts no more and still reports. Each run's stderr is kept in
+// .nano/runs/foreach-<id>/. Flags after -- go to every run:
+//
+//	nano foreach --dirs 'services/*' --yes "upgrade library X to v2 and make tests pass" -- --model opus
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type dirRun struct {
+	Dir          string   `json:"dir"`
+	Status       string   `json:"status"` // a run's report status, "skipped" or "cancelled"
+	Outcome      string   `json:"outcome,omitempty"`
+	Turns        int      `json:"turns"`
+	CostUSD      *float64 `json:"cost_usd"`
+	FilesChanged []string `json:"files_changed,omitempty"`
+	Diff         string   `json:"diff,omitempty"`
+	Error        string   `json:"error,omitempty"`
+	Log          string   `json:"log,omitempty"` // the run's stderr
+	DurationMS   int64    `json:"duration_ms"`
+	ok           bool     // succeeded, by nano's exit code
+}
+
+func foreachMain(fs *flag.FlagSet) func(args []string) int {
+	pattern := fs.String("dirs", "", "run in every directory matching `glob`")
+	parallel := fs.Int("parallel", 3, "runs at a time")
+	halt := fs.Bool("halt-on-fail", false, "interrupt the other runs, and start no more, when one fails")
+	yes := fs.Bool("yes", false, "approve every write and command without asking, in every run")
+	output := fs.String("output", "text", "report format: text or json")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano foreach --dirs glob [flags] \"prompt\" [-- nano flags]"); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		rest := fs.Args(); var extra []string
+		for i, a := range rest { if a == "--" { rest, extra = rest[:i], rest[i+1:]; break } }
+		prompt := strings.Join(rest, " "); if prompt == "" || *pattern == "" || *parallel < 1 { fs.Usage(); return 1 }
+		matches, err := filepath.Glob(*pattern); if err != nil { fmt.Fprintln(os.Stderr, "Error: --dirs:", err); return 1 }
+		var dirs []string; for _, m := range matches { if fi, err := os.Stat(m); err == nil && fi.IsDir() { dirs = append(dirs, m) } }
+		if len(dirs) == 0 { fmt.Fprintf(os.Stderr, "Error: no directories match %s\n", *pattern); return 1 }
+		self, err := os.Executable(); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		logs, err := foreachLogDir(); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		flags := []string{"--output", "json", "--show-diff-on-exit"}; if *yes { flags = append(flags, "--yes") }
+		flags = append(flags, extra...)
+		fmt.Fprintf(os.Stderr, "▶ %d directories, %d at a time: %s\n", len(dirs), min(*parallel, len(dirs)), truncate(prompt, 80))
+
+		runs := make([]dirRun, len(dirs)); for i, d := range dirs { runs[i] = dirRun{Dir: d, Status: "skipped"} }
+		var mu sync.Mutex; running := map[int]*exec.Cmd{}; halted := false
+		interrupt := make(chan os.Signal, 1); signal.Notify(interrupt, os.Interrupt) // the runs get Ctrl-C from the terminal themselves
+		go func() { <-interrupt; mu.Lock(); halted = true; mu.Unlock() }()
+		slots := make(chan struct{}, *parallel); var wg sync.WaitGroup
+		for i, d := range dirs {
+			slots <- struct{}{}
+			mu.Lock(); stop := halted; mu.Unlock()
+			if stop { <-slots; continue }
+			cmd := exec.Command(self, append(append(append([]string{}, flags...), "--sandbox", "."), "--", prompt)...); cmd.Dir = d
+			mu.Lock(); running[i] = cmd; mu.Unlock()
+			wg.Add(1)
+			go func(i int, d string) {
+				defer func() { <-slots; wg.Done() }()
+				fmt.Fprintf(os.Stderr, "  ▷ %s started\n", d)
+				r := runInDir(cmd, d, filepath.Join(logs, strings.ReplaceAll(filepath.Clean(d), string(filepath.Separator), "_")+".log"))
+				mu.Lock(); defer mu.Unlock()
+				delete(running, i)
+				if halted && !r.ok { r.Status = "cancelled" }
+				runs[i] = r; fmt.Fprintln(os.Stderr, "  "+r.line())
+				if r.failed() && *halt && !halted {
+					halted = true; fmt.Fprintf(os.Stderr, "✗ %s failed; --halt-on-fail: stopping the other runs\n", d)
+					for _, c := range running { if c.Process != nil { c.Process.Signal(syscall.SIGTERM) } }
+				}
+			}(i, d)
+		}
+		wg.Wait()
+
+		failed := 0; for _, r := range runs { if !r.ok { failed++ } } // skipped ones included: the task isn't done there
+		if *output == "json" {
+			data, _ := json.MarshalIndent(map[string]any{"dirs": runs, "total": map[string]any{"dirs": len(runs), "failed": failed, "cost_usd": dirRunsCost(runs)}}, "", "  "); fmt.Println(string(data))
+		} else {
+			fmt.Printf("%-32s %-18s %6s %10s %6s %9s\n", "directory", "status", "turns", "cost", "files", "duration")
+			for _, r := range runs { fmt.Printf("%-32s %-18s %6d %10s %6d %8.1fs\n", truncate(r.Dir, 32), r.Status, r.Turns, fmtCost(r.CostUSD), len(r.FilesChanged), float64(r.DurationMS)/1000) }
+			fmt.Printf("%d/%d succeeded · %s\n", len(runs)-failed, len(runs), fmtCost(dirRunsCost(runs)))
+			for _, r := range runs {
+				if r.Diff == "" { continue }
+				fmt.Printf("\n=== %s\n", r.Dir)
+				switch d := r.Diff; {
+				case strings.Count(d, "\n") > diffPageLines: fmt.Printf("(%d-line diff; nano --resume in %s, or see %s)\n", strings.Count(d, "\n"), r.Dir, r.Log)
+				case isTTY(os.Stdout): fmt.Print(colorDiff(d))
+				default: fmt.Print(d)
+				}
+			}
+		}
+		if failed > 0 { return 1 }
+		return 0
+	}
+}
+
+// runInDir runs one directory's nano and reads its report.
+func runInDir(cmd *exec.Cmd, dir, logPath string) dirRun {
+	start := time.Now(); r := dirRun{Dir: dir, Log: relPath(logPath)}
+	var stderr bytes.Buffer; cmd.Stderr = &stderr
+	out, err := cmd.Output(); r.DurationMS = time.Since(start).Milliseconds()
+	os.WriteFile(logPath, stderr.Bytes(), 0644)
+	var rep report
+	if jerr := json.Unmarshal(out, &rep); jerr != nil {
+		r.Status, r.Error = "failed", "nano: "+lastLine(stderr.String())
+		if err != nil && r.Error == "nano: " { r.Error += err.Error() }
+		return r
+	}
+	r.Status, r.Outcome, r.Turns, r.CostUSD, r.FilesChanged, r.Diff, r.Error = rep.Status, rep.Outcome, rep.Turns, rep.CostUSD, rep.FilesChanged, rep.Diff, rep.Error
+	r.ok = err == nil // a reported failure or partial outcome exits non-zero too
+	return r
+}
+
+func (r dirRun) failed() bool { return !r.ok && r.Status != "skipped" }
+
+func (r dirRun) line() string {
+	mark := "✓"; if r.failed() { mark = "✗" }
+	status := r.Status; if r.Outcome != "" && r.Outcome != "unreported" { status += " (" + r.Outcome + ")" }
+	s := fmt.Sprintf("%s %s %s · %d turns · %s · %.1fs", mark, r.Dir, status, r.Turns, fmtCost(r.CostUSD), float64(r.DurationMS)/1000)
+	if r.Error != "" { s += " · " + truncate(r.Error, 100) }
+	return s
+}
+
+func dirRunsCost(runs []dirRun) *float64 {
+	total := 0.0
+	for _, r := range runs { if r.Status == "skipped" { continue }; if r.CostUSD == nil { return nil }; total += *r.CostUSD }
+	return &total
+}
+
+// foreachLogDir makes the run directory the logs go to.
+func foreachLogDir() (string, error) {
+	wd, _ := os.Getwd(); id := "foreach-" + newSessionID()
+	if err := os.MkdirAll(filepath.Join(runsDir(wd), id), 0755); err != nil { return "", err }
+	os.WriteFile(filepath.Join(runsDir(wd), ".gitignore"), []byte("*\n"), 0644)
+	pruneRuns(wd, id)
+	return filepath.Join(runsDir(wd), id), nil
+}
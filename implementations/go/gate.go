@@ -0,0 +1,51 @@
+// Verification gate: config "verify_command" (say "make check") is a project's definition of
+// done, enforced by nano rather than left to the model. When a step's run ends, nano runs the
+// command in the workspace; if it fails, its output goes back to the model as a marked user
+// turn and the loop continues, at most --max-verify-attempts times. A step whose command still
+// fails then fails (exit 1). It is fix mode's loop as a project setting. A run that reported
+// failure, was stopped or ran out of time isn't checked, and the attempts stop at the deadline.
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+const gateOutput = 20_000 // bytes of a failing command's output passed on, from the end
+
+type gate struct{ cmd string; attempts int }
+
+// gateRun is a step's verify_command record in the report.
+type gateRun struct {
+	Command  string `json:"command"`
+	Passed   bool   `json:"passed"`
+	Runs     int    `json:"runs"`
+	ExitCode int    `json:"exit_code,omitempty"` // the last run's, when it failed
+}
+
+// checkGate runs the verify command after a run, sending failures back for fixing until it
+// passes or attempts run out. It returns the final answer.
+func (a *Agent) checkGate(result string) (string, *gateRun, error) {
+	g := &gateRun{Command: a.gate.cmd}
+	for fixes := 0; ; fixes++ {
+		if stopped() != nil || a.pastDeadline() { return result, g, nil }
+		g.Runs++; a.notify("🔎 " + a.gate.cmd)
+		code, out := runCheck(workDir(), []string{a.gate.cmd})
+		slog.Info("verify command", "command", a.gate.cmd, "exit_code", code, "run", g.Runs)
+		if g.ExitCode = code; code == 0 { g.Passed = true; a.notify("✓ verification command passed"); return result, g, nil }
+		if len(out) > gateOutput { out = "…\n" + out[len(out)-gateOutput:] }
+		if fixes >= a.gate.attempts {
+			a.notify(fmt.Sprintf("✗ %s still fails (exit code %d)", a.gate.cmd, code))
+			return result, g, fmt.Errorf("verify_command `%s` still fails after %d attempt(s) to fix it (exit code %d)", a.gate.cmd, fixes, code)
+		}
+		a.notify(fmt.Sprintf("✗ %s failed (exit code %d); attempt %d/%d to fix it", a.gate.cmd, code, fixes+1, a.gate.attempts))
+		var err error
+		if result, err = a.Send(fmt.Sprintf("[verification %d/%d] The project verification command `%s` failed with exit code %d. Fix the problem so it passes (don't change the command or the configuration that defines it), then reply with your final answer again.\n\nOutput:\n```\n%s\n```", fixes+1, a.gate.attempts, a.gate.cmd, code, out)); err != nil { return result, g, err }
+	}
+}
+
+func (g *gateRun) summary() string {
+	if g.Passed { return "verified: " + truncate(g.Command, 60) }
+	return fmt.Sprintf("%s failed (exit code %d)", truncate(g.Command, 60), g.ExitCode)
+}
@@ -0,0 +1,106 @@
+// Gateway presets for routing traffic through OpenRouter or a LiteLLM proxy, chosen with
+// $PROVIDER (or config "provider"). Both speak the Messages API that nano already uses, with
+// small deviations: OpenRouter wants Bearer auth, attribution headers (HTTP-Referer,
+// X-Title) and takes provider routing preferences in the request body; LiteLLM serves its
+// own model names, so aliases aren't resolved, and reports the cost of each call in a header.
+// Errors that arrive in their shapes (the upstream provider's error embedded in a message or
+// in OpenRouter's metadata.raw) are unwrapped into APIError's type and message, and when a
+// gateway reports what a call cost, that figure replaces nano's estimate from the price table.
+//
+//	"provider": "openrouter",
+//	"openrouter": {"title": "my-app", "provider": {"order": ["anthropic"], "allow_fallbacks": false}}
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type gateway struct {
+	name, baseURL, model string // model is the default when $MODEL is unset
+	keyEnv               string // tried before $ANTHROPIC_API_KEY
+	passthrough          bool   // model names are the gateway's own; no aliases
+}
+
+var gateways = map[string]gateway{
+	"openrouter": {name: "openrouter", baseURL: "https://openrouter.ai/api", model: "anthropic/claude-sonnet-4", keyEnv: "OPENROUTER_API_KEY"},
+	"litellm":    {name: "litellm", baseURL: "http://localhost:4000", keyEnv: "LITELLM_API_KEY", passthrough: true},
+}
+
+// gw is the preset in use, nil when talking to the API directly.
+var gw *gateway
+
+// openRouterAliases are the short model names as OpenRouter spells the models.
+var openRouterAliases = map[string]string{"opus": "anthropic/claude-opus-4.5", "sonnet": "anthropic/claude-sonnet-4.5", "haiku": "anthropic/claude-haiku-4.5"}
+
+func selectGateway() (*gateway, error) {
+	name := env("PROVIDER", cfg.Provider)
+	if name == "" || name == "anthropic" { return nil, nil }
+	g, ok := gateways[name]; if !ok { return nil, fmt.Errorf("unknown PROVIDER %q (want anthropic, openrouter or litellm)", name) }
+	return &g, nil
+}
+
+// keyEnvs are the variables resolveKey tries, in order.
+func keyEnvs() []string {
+	envs := []string{"ANTHROPIC_API_KEY", "ANTHROPIC_AUTH_TOKEN"}
+	if g, _ := selectGateway(); g != nil { envs = append([]string{g.keyEnv}, envs...) }
+	return envs
+}
+
+// setAuth puts the key on req: x-api-key for the API, Bearer for a gateway.
+func (g *gateway) setAuth(req *http.Request, key string) {
+	if g == nil { req.Header.Set("x-api-key", key); return }
+	req.Header.Set("Authorization", "Bearer "+key)
+}
+
+// headers are the preset's extra request headers: OpenRouter's attribution.
+func (g *gateway) headers(h map[string]string) {
+	if g == nil || g.name != "openrouter" { return }
+	h["HTTP-Referer"], h["X-Title"] = orDefault(cfg.OpenRouter.Referer, "https://github.com/robotlearning123/nano-opencode"), orDefault(cfg.OpenRouter.Title, "nano-opencode")
+}
+
+// apply adds the preset's body fields: OpenRouter's routing preferences, and usage
+// accounting so the response says what the call cost.
+func (g *gateway) apply(req map[string]any) {
+	if g == nil || g.name != "openrouter" { return }
+	if len(cfg.OpenRouter.Routing) > 0 { req["provider"] = cfg.OpenRouter.Routing }
+	req["usage"] = map[string]any{"include": true}
+}
+
+// resolveModel maps a short name for the gateway; LiteLLM's names pass through untouched.
+func (g *gateway) resolveModel(name string) string {
+	if g.passthrough { return name }
+	if m, ok := openRouterAliases[name]; ok { return m }
+	return name
+}
+
+// responseCost is what the gateway says a call cost, from LiteLLM's header; 0 when unsaid.
+func (g *gateway) responseCost(h http.Header) float64 {
+	if g == nil { return 0 }
+	c, _ := strconv.ParseFloat(h.Get("x-litellm-response-cost"), 64); return c
+}
+
+// unwrapError fills in e's type and message from an upstream error the gateway passed on
+// inside its own, as JSON embedded in the message (LiteLLM) or in metadata.raw (OpenRouter),
+// naming the provider when the gateway does.
+func unwrapError(e *APIError, raw json.RawMessage, provider string) {
+	var r string; if json.Unmarshal(raw, &r) != nil { r = string(raw) }
+	for _, s := range []string{r, e.Message} {
+		i := strings.Index(s, "{"); if i < 0 { continue }
+		var v struct{ Error struct{ Type, Message string } }
+		if json.NewDecoder(strings.NewReader(s[i:])).Decode(&v) == nil && v.Error.Type != "" { e.Type, e.Message = v.Error.Type, v.Error.Message; break }
+	}
+	if provider != "" && e.Message != "" { e.Message = provider + ": " + e.Message }
+}
+
+// baseModel is a gateway model name as the price and window tables spell it:
+// "anthropic/claude-opus-4.5" is "claude-opus-4-5".
+func baseModel(model string) string {
+	return strings.ReplaceAll(model[strings.LastIndex(model, "/")+1:], ".", "-")
+}
+
+func orDefault(s, def string) string { if s == "" { return def }; return s }
@@ -0,0 +1,79 @@
+// --with-diff: the working tree's git diff attached to the first prompt, for the usual case
+// of asking about code just changed. --with-diff=staged takes the index instead, and
+// --with-diff=branch everything since the merge base with the default branch. The diff has
+// to fit --diff-tokens: context lines go first (three, then one, then none), and if the hunks
+// alone are still too big the model gets per-file line counts and is told to read the files
+// it needs. Binary changes show up only by name, as git prints them.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// diffMode is the --with-diff value: "" (off), "working", "staged" or "branch".
+type diffMode string
+
+func (m *diffMode) String() string { return string(*m) }
+
+func (m *diffMode) IsBoolFlag() bool { return true }
+
+func (m *diffMode) Set(v string) error {
+	switch v {
+	case "true", "working": *m = "working"
+	case "false": *m = ""
+	case "staged", "branch": *m = diffMode(v)
+	default: return fmt.Errorf("want staged or branch, got %q", v)
+	}
+	return nil
+}
+
+// diffArgs are git diff's arguments for the mode, after the base is found for "branch".
+func (m diffMode) diffArgs() ([]string, string, error) {
+	switch m {
+	case "staged": return []string{"--cached"}, "staged changes", nil
+	case "branch":
+		branch := defaultBranch(); if branch == "" { return nil, "", errors.New("found no default branch (origin/HEAD, main or master) to diff against") }
+		base, err := git("merge-base", "HEAD", branch); if err != nil { return nil, "", fmt.Errorf("merge-base with %s: %w", branch, err) }
+		return []string{strings.TrimSpace(base)}, "changes since the merge base with " + branch, nil
+	}
+	return nil, "unstaged changes", nil
+}
+
+func git(args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", workDir()}, args...)...).Output()
+	if ee := (*exec.ExitError)(nil); errors.As(err, &ee) && len(ee.Stderr) > 0 { return "", errors.New(strings.TrimSpace(string(ee.Stderr))) }
+	return string(out), err
+}
+
+func defaultBranch() string {
+	if ref, err := git("symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil { return strings.TrimSpace(ref) }
+	for _, b := range []string{"main", "master"} { if _, err := git("rev-parse", "--verify", "--quiet", b); err == nil { return b } }
+	return ""
+}
+
+// diffContext is the labeled block appended to the first prompt, "" when nothing changed.
+func diffContext(m diffMode, budget int) (string, error) {
+	args, label, err := m.diffArgs(); if err != nil { return "", err }
+	limit := budget * 4 // bytes, at about four per token
+	var diff string
+	for _, ctx := range []string{"-U3", "-U1", "-U0"} {
+		if diff, err = git(append([]string{"diff", "--no-color", "--no-ext-diff", ctx}, args...)...); err != nil { return "", fmt.Errorf("git diff: %w", err) }
+		if len(diff) <= limit { break }
+	}
+	if strings.TrimSpace(diff) == "" { return "", nil }
+	if len(diff) <= limit { return fmt.Sprintf("\n\n<git_diff of=%q>\n%s</git_diff>", label, diff), nil }
+	stat, err := git(append([]string{"diff", "--numstat"}, args...)...); if err != nil { return "", fmt.Errorf("git diff: %w", err) }
+	var sb strings.Builder
+	files := strings.Split(strings.TrimSpace(stat), "\n")
+	fmt.Fprintf(&sb, "\n\n<git_diff of=%q truncated=\"true\">\nThe diff is about %d tokens, over the %d token budget; these files changed (lines added, removed). Read the ones that matter for the task.\n", label, len(diff)/4, budget)
+	for i, l := range files {
+		added, rest, _ := strings.Cut(l, "\t"); removed, path, _ := strings.Cut(rest, "\t")
+		if i > 0 && sb.Len() > limit { fmt.Fprintf(&sb, "… and %d more files\n", len(files)-i); break }
+		if added == "-" { fmt.Fprintf(&sb, "%s (binary)\n", path) } else { fmt.Fprintf(&sb, "%s +%s -%s\n", path, added, removed) }
+	}
+	return sb.String() + "</git_diff>", nil
+}
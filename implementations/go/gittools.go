@@ -0,0 +1,100 @@
+// git_show and git_blame: read-only views of a file's history, so the model can compare with
+// how a file looked on main or at a tag without checking anything out. Both run git in the
+// workspace, which in worktree mode is the worktree, so refs resolve in the shared repository
+// while paths are the worktree's. Neither needs approval; both are left out of the tool set
+// outside a git repository.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// inRepo is the tools' Available check.
+func inRepo() error {
+	if _, err := exec.LookPath("git"); err != nil { return errors.New("git is not installed") }
+	if _, err := git("rev-parse", "--git-dir"); err != nil { return errors.New("the workspace is not in a git repository") }
+	return nil
+}
+
+// repoPath is path as git takes it after ref:, relative to the workspace ("./" makes git
+// read it that way), checked against the sandbox like any other.
+func repoPath(path string) (string, error) {
+	p, err := resolvePath(path); if err != nil { return "", err }
+	if p, err = filepath.Abs(p); err != nil { return "", err }
+	rel, err := filepath.Rel(workDir(), p); if err != nil { return "", err }
+	return "./" + filepath.ToSlash(rel), nil
+}
+
+// checkRef resolves ref to a commit, or says that it doesn't.
+func checkRef(ref string) (string, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") { return "", fmt.Errorf("bad ref %q", ref) }
+	sha, err := git("rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil || strings.TrimSpace(sha) == "" { return "", fmt.Errorf("unknown ref %q (try a branch, tag or commit; git_show can't see refs that were never fetched)", ref) }
+	return strings.TrimSpace(sha), nil
+}
+
+func gitShow(in Input) (string, error) {
+	ref := in.Str("ref"); sha, err := checkRef(ref); if err != nil { return "", err }
+	p, err := repoPath(in.Str("path")); if err != nil { return "", err }
+	data, err := git("show", sha+":"+p)
+	if err != nil { return "", fmt.Errorf("%s doesn't exist at %s (%s)", in.Str("path"), ref, err) }
+	if strings.IndexByte(data, 0) >= 0 { return fmt.Sprintf("(binary file, %s at %s)", humanBytes(int64(len(data))), ref), nil }
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s at %s (%s):\n", in.Str("path"), ref, sha[:min(12, len(sha))])
+	for i, l := range splitLines(data) { fmt.Fprintf(&b, "%6d\t%s\n", i+1, l) }
+	return b.String(), nil
+}
+
+func gitBlame(in Input) (string, error) {
+	p, err := repoPath(in.Str("path")); if err != nil { return "", err }
+	args := []string{"blame", "--porcelain"}
+	if start := in.Int("start_line", 0); start > 0 {
+		end := in.Int("end_line", start+99); if end < start { return "", fmt.Errorf("end_line %d is before start_line %d", end, start) }
+		args = append(args, "-L", fmt.Sprintf("%d,%d", start, end))
+	}
+	if ref := in.Str("ref"); ref != "" { sha, err := checkRef(ref); if err != nil { return "", err }; args = append(args, sha) }
+	out, err := git(append(args, "--", p)...); if err != nil { return "", fmt.Errorf("git blame %s: %s", in.Str("path"), err) }
+	return formatBlame(out, time.Now()), nil
+}
+
+// formatBlame turns porcelain output into a line per source line, commit, author and age
+// first. Porcelain gives a commit's details only the first time it appears.
+func formatBlame(porcelain string, now time.Time) string {
+	type commit struct{ author string; when time.Time }
+	commits := map[string]*commit{}; var b bytes.Buffer; var sha string; var line int
+	for _, l := range strings.Split(porcelain, "\n") {
+		switch f := strings.Fields(l); {
+		case strings.HasPrefix(l, "\t"):
+			c := commits[sha]; who, age := "?", "?"
+			if c != nil { who, age = c.author, shortAge(now.Sub(c.when)) }
+			if strings.Trim(sha, "0") == "" { who, age = "(not committed)", "-" }
+			fmt.Fprintf(&b, "%6d\t%.8s %-20s %5s\t%s\n", line, sha, truncate(who, 20), age, l[1:])
+		case len(f) >= 3 && len(f[0]) >= 40:
+			sha = f[0]; line, _ = strconv.Atoi(f[2])
+			if commits[sha] == nil { commits[sha] = &commit{} }
+		case len(f) >= 2 && f[0] == "author": commits[sha].author = strings.TrimPrefix(l, "author ")
+		case len(f) == 2 && f[0] == "author-time": t, _ := strconv.ParseInt(f[1], 10, 64); commits[sha].when = time.Unix(t, 0)
+		}
+	}
+	if b.Len() == 0 { return "(no lines in that range)" }
+	return b.String()
+}
+
+// shortAge is a duration as 5m, 3h, 12d, 8mo or 2y.
+func shortAge(d time.Duration) string {
+	switch {
+	case d < time.Hour: return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour: return fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 60*24*time.Hour: return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d < 730*24*time.Hour: return fmt.Sprintf("%dmo", int(d.Hours()/24/30))
+	}
+	return fmt.Sprintf("%dy", int(d.Hours()/24/365))
+}
@@ -0,0 +1,136 @@
+// Test helpers: a scripted stand-in for the Messages API, and an agent wired to it in a temp
+// directory with writes and commands approved.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAPI answers POST /v1/messages with its replies in order; once they run out it ends the
+// turn. Every request body and header set is kept.
+type fakeAPI struct {
+	srv     *httptest.Server
+	mu      sync.Mutex
+	replies []string
+	reqs    []map[string]any
+	headers []http.Header
+}
+
+func newFakeAPI(t *testing.T, replies ...string) *fakeAPI {
+	f := &fakeAPI{replies: replies}
+	f.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock(); defer f.mu.Unlock()
+		body, _ := io.ReadAll(r.Body); var req map[string]any; json.Unmarshal(body, &req)
+		if r.URL.Path != "/v1/messages" { w.WriteHeader(404); return }
+		f.reqs, f.headers = append(f.reqs, req), append(f.headers, r.Header.Clone())
+		reply := textReply("(script exhausted)")
+		if len(f.replies) > 0 { reply, f.replies = f.replies[0], f.replies[1:] }
+		w.Header().Set("Content-Type", "application/json"); io.WriteString(w, reply)
+	}))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// request returns the i-th request body sent (negative counts from the end).
+func (f *fakeAPI) request(t *testing.T, i int) map[string]any {
+	t.Helper(); f.mu.Lock(); defer f.mu.Unlock()
+	if i < 0 { i += len(f.reqs) }
+	if i < 0 || i >= len(f.reqs) { t.Fatalf("request %d not sent; %d were", i, len(f.reqs)) }
+	return f.reqs[i]
+}
+
+func (f *fakeAPI) count() int { f.mu.Lock(); defer f.mu.Unlock(); return len(f.reqs) }
+
+func reply(stop string, blocks ...string) string {
+	return fmt.Sprintf(`{"content":[%s],"stop_reason":%q,"usage":{"input_tokens":10,"output_tokens":5}}`, strings.Join(blocks, ","), stop)
+}
+
+func textReply(s string) string { return reply("end_turn", textBlock(s)) }
+
+func textBlock(s string) string { data, _ := json.Marshal(map[string]any{"type": "text", "text": s}); return string(data) }
+
+func toolBlock(id, name, input string) string { return fmt.Sprintf(`{"type":"tool_use","id":%q,"name":%q,"input":%s}`, id, name, input) }
+
+func toolReply(id, name, input string) string { return reply("tool_use", toolBlock(id, name, input)) }
+
+// inTempDir runs the rest of the test in a fresh directory.
+func inTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir(); wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil { t.Fatal(err) }
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+// testAgent is an agent talking to f from a temp directory, approving everything.
+func testAgent(t *testing.T, f *fakeAPI) *Agent {
+	t.Helper()
+	inTempDir(t)
+	t.Setenv("ANTHROPIC_BASE_URL", f.srv.URL); t.Setenv("XDG_DATA_HOME", t.TempDir()); t.Setenv("NANO_TITLE_MODEL", "none"); t.Setenv("MODEL", "")
+	saved := struct{ cfg Config; key string; approve bool; sandbox string }{cfg, apiKeyFlag, autoApprove, sandboxRoot}
+	t.Cleanup(func() { cfg, apiKeyFlag, autoApprove, sandboxRoot = saved.cfg, saved.key, saved.approve, saved.sandbox; forgetReads() })
+	cfg, apiKeyFlag, autoApprove, sandboxRoot = Config{}, "sk-test", true, ""
+	a, err := newAgent(); if err != nil { t.Fatal(err) }
+	return a
+}
+
+// TestMain lets a test start this binary as nano itself (see runNano).
+func TestMain(m *testing.M) {
+	if os.Getenv("NANO_TEST_MAIN") == "1" { os.Args[0] = "nano"; main(); os.Exit(0) }
+	os.Exit(m.Run())
+}
+
+// runNano runs nano with args in dir against f, approving everything, with its own data and
+// config directories, and returns its output and exit code.
+func runNano(t *testing.T, f *fakeAPI, dir string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	home := t.TempDir()
+	cmd := exec.Command(os.Args[0], args...); cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "NANO_TEST_MAIN=1", "ANTHROPIC_API_KEY=sk-test", "NANO_TITLE_MODEL=none", "MODEL=", "NANO_OFFLINE=", "HOME="+home, "XDG_DATA_HOME="+home+"/data", "XDG_CONFIG_HOME="+home+"/config")
+	if f != nil { cmd.Env = append(cmd.Env, "ANTHROPIC_BASE_URL="+f.srv.URL) }
+	var out, errOut strings.Builder; cmd.Stdout, cmd.Stderr = &out, &errOut
+	err := cmd.Run(); var exit *exec.ExitError
+	if err != nil && !errors.As(err, &exit) { t.Fatal(err) }
+	return out.String(), errOut.String(), cmd.ProcessState.ExitCode()
+}
+
+// toolResult finds the tool_result for id in a request's messages.
+func toolResult(t *testing.T, req map[string]any, id string) map[string]any {
+	t.Helper()
+	msgs, _ := req["messages"].([]any)
+	for _, m := range msgs {
+		content, _ := m.(map[string]any)["content"].([]any)
+		for _, c := range content { if r, _ := c.(map[string]any); r["type"] == "tool_result" && r["tool_use_id"] == id { return r } }
+	}
+	t.Fatalf("no tool_result for %s in the request", id); return nil
+}
+
+// captureStdout returns what fn printed.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe(); if err != nil { t.Fatal(err) }
+	stdout := os.Stdout; os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan []byte); go func() { data, _ := io.ReadAll(r); done <- data }()
+	fn(); w.Close()
+	return string(<-done)
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper(); if err := os.WriteFile(path, []byte(content), 0644); err != nil { t.Fatal(err) }
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper(); data, err := os.ReadFile(path); if err != nil { t.Fatal(err) }; return string(data)
+}
@@ -0,0 +1,65 @@
+// Seed history: synthetic earlier turns placed before the real conversation, such as
+// few-shot examples of the tool use wanted or an assistant acknowledging constraints.
+// --history loads them from a JSON array of messages, library callers pass them to
+// WithInitialMessages. Either way they are checked first: user and assistant alternate
+// (starting with user, ending with assistant, so the real prompt follows), and every
+// tool_use is answered by a tool_result in the next message, and only then. They are pinned:
+// compaction summarizes and stubs only what comes after them, and the session remembers this.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// WithInitialMessages puts seed messages before the history, pinned there. It panics when
+// they don't form a valid history (see validateHistory), which is a programming error.
+func (a *Agent) WithInitialMessages(msgs []Message) *Agent {
+	if err := validateHistory(msgs); err != nil { panic("WithInitialMessages: " + err.Error()) }
+	a.Messages = append(append([]Message{}, msgs...), a.Messages[a.pinned:]...); a.pinned = len(msgs)
+	return a
+}
+
+// loadHistory reads and checks a --history file.
+func loadHistory(path string) ([]Message, error) {
+	data, err := os.ReadFile(path); if err != nil { return nil, err }
+	var msgs []Message
+	if err := json.Unmarshal(data, &msgs); err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+	if err := normalizeMessages(msgs); err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+	if err := validateHistory(msgs); err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+	return msgs, nil
+}
+
+// validateHistory checks that msgs can stand in front of a real prompt.
+func validateHistory(msgs []Message) error {
+	if len(msgs) == 0 { return errors.New("no messages") }
+	var open map[string]bool // tool_use ids the previous assistant message left unanswered
+	for i, m := range msgs {
+		want := "user"; if i%2 == 1 { want = "assistant" }
+		if m.Role != want { return fmt.Errorf("message %d: role is %q, want %q (roles alternate, starting with user)", i+1, m.Role, want) }
+		if m.Content == nil { return fmt.Errorf("message %d: no content", i+1) }
+		if m.Role == "assistant" {
+			open = map[string]bool{}
+			bl, _ := m.Content.([]Block)
+			for _, b := range bl {
+				if b.Type != "tool_use" { continue }
+				if b.ID == "" || b.Name == "" { return fmt.Errorf("message %d: tool_use needs an id and a name", i+1) }
+				open[b.ID] = true
+			}
+			continue
+		}
+		for _, r := range toolResults(m.Content) {
+			id, _ := r["tool_use_id"].(string)
+			if !open[id] { return fmt.Errorf("message %d: tool_result %q doesn't answer a tool_use in the message before it", i+1, id) }
+			delete(open, id)
+		}
+		for id := range open { return fmt.Errorf("message %d: tool_use %q has no tool_result", i+1, id) }
+		open = nil
+	}
+	if msgs[len(msgs)-1].Role != "assistant" { return errors.New("the last message must be the assistant's, so the real prompt can follow") }
+	for id := range open { return fmt.Errorf("tool_use %q in the last message has no tool_result", id) }
+	return nil
+}
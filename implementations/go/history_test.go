@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSeedHistoryFromTestdata(t *testing.T) {
+	path, _ := filepath.Abs(filepath.Join("testdata", "history.json"))
+	seed, err := loadHistory(path)
+	if err != nil { t.Fatal(err) }
+	f := newFakeAPI(t, textReply("first"), textReply("second"), textReply("third"), textReply("summary of the middle"))
+	a := testAgent(t, f).WithInitialMessages(seed)
+	for _, p := range []string{"edit main.go", "and util.go", "and the tests"} { if _, err := a.Run(p); err != nil { t.Fatal(err) } }
+	msgs, _ := f.request(t, 0)["messages"].([]any)
+	if len(msgs) != 5 { t.Fatalf("first request has %d messages, want the 4 seeds and the prompt", len(msgs)) }
+	if first, _ := json.Marshal(msgs[0]); !strings.Contains(string(first), "read the file you are about to edit") { t.Errorf("first message %s, want the seed's", first) }
+	if last, _ := json.Marshal(msgs[4]); !strings.Contains(string(last), "edit main.go") { t.Errorf("last message %s, want the prompt", last) }
+	if second, _ := json.Marshal(msgs[1]); !strings.Contains(string(second), `"id":"seed_1"`) { t.Errorf("seed tool_use lost: %s", second) }
+
+	if err := a.compact(0); err != nil { t.Fatal(err) }
+	if a.pinned != 4 { t.Errorf("pinned %d, want the 4 seeds", a.pinned) }
+	for i := range seed { if got, want := size(a.Messages[i].Content), size(seed[i].Content); got != want { t.Errorf("seed message %d changed by compaction", i) } }
+	if s, _ := a.Messages[4].Content.(string); !strings.HasPrefix(s, "Summary of the earlier conversation") { t.Errorf("message after the seeds is %v, want the summary", a.Messages[4].Content) }
+}
+
+func TestLoadHistoryRejectsInvalidSeeds(t *testing.T) {
+	dir := t.TempDir()
+	for _, c := range []struct{ name, json, want string }{
+		{"empty", `[]`, "no messages"},
+		{"starts with assistant", `[{"role":"assistant","content":"hi"}]`, `message 1: role is "assistant", want "user"`},
+		{"ends with user", `[{"role":"user","content":"a"},{"role":"assistant","content":"b"},{"role":"user","content":"c"}]`, "the last message must be the assistant's"},
+		{"unanswered tool_use", `[{"role":"user","content":"a"},{"role":"assistant","content":[{"type":"tool_use","id":"x","name":"bash","input":{}}]},{"role":"user","content":"c"},{"role":"assistant","content":"d"}]`, `message 3: tool_use "x" has no tool_result`},
+		{"stray tool_result", `[{"role":"user","content":[{"type":"tool_result","tool_use_id":"y","content":"z"}]},{"role":"assistant","content":"b"}]`, `tool_result "y" doesn't answer`},
+		{"not json", `{`, "unexpected end of JSON input"},
+	} {
+		p := filepath.Join(dir, "h.json"); os.WriteFile(p, []byte(c.json), 0644)
+		if _, err := loadHistory(p); err == nil || !strings.Contains(err.Error(), c.want) { t.Errorf("%s: got %v, want %q", c.name, err, c.want) }
+	}
+}
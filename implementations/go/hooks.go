@@ -0,0 +1,62 @@
+// Hooks for embedding the agent. An Approver decides whether a mutating tool call may run, in
+// place of the terminal prompt; newAgent installs the CLI's (terminalApprover: --yes, saved
+// rules, CI, the y/N prompt), so there is a single path either way. Tool middleware wraps
+// every execution that was approved, for logging, metering or per-tenant policy; the first
+// registered is the outermost, so WithToolMiddleware(a).WithToolMiddleware(b) runs a's code
+// before b's on the way in and after it on the way out, and either can return early without
+// calling next. Declined calls, and calls that fail validation, reach no middleware.
+
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ToolCall is a call the model made, as approvers and middleware see it.
+type ToolCall struct {
+	ID, Name   string
+	Detail     string // the command, URL or path
+	Input      Input
+	ReadOnly   bool
+	Suspicious string // set when the call follows content that looked like a prompt injection
+}
+
+// Decision is an approver's answer. By says who or what decided, for the audit log.
+type Decision struct {
+	Allow bool
+	By    string
+}
+
+type Approver func(ctx context.Context, call ToolCall) (Decision, error)
+
+// ToolFunc executes a call: text for the model, rich blocks when the tool returns them.
+type ToolFunc func(ctx context.Context, call ToolCall) (string, []Block, error)
+
+type ToolMiddleware func(next ToolFunc) ToolFunc
+
+// WithApprover replaces the approver consulted before mutating calls.
+func (a *Agent) WithApprover(fn Approver) *Agent { a.approver = fn; return a }
+
+// WithToolMiddleware adds mw inside the middleware registered before it.
+func (a *Agent) WithToolMiddleware(mw ToolMiddleware) *Agent { a.middleware = append(a.middleware, mw); return a }
+
+// approve reports whether the call may run and who decided ("" for read-only calls).
+func (a *Agent) approve(t Tool, id string, in Input) (bool, string) {
+	if t.readOnlyCall(in) { return true, "" }
+	c := ToolCall{ID: id, Name: t.Name, Detail: describeCall(in), Input: in, Suspicious: a.suspicious}; a.suspicious = ""
+	d, err := a.approver(runCtx, c)
+	if err != nil { slog.Warn("approver failed; declining the call", "tool", t.Name, "err", err); return false, "approver error: " + err.Error() }
+	if d.By == "" { d.By = "approver" }
+	return d.Allow, d.By
+}
+
+// runTool executes an approved call through the middleware.
+func (a *Agent) runTool(t Tool, c ToolCall) (string, []Block, error) {
+	run := ToolFunc(func(_ context.Context, c ToolCall) (string, []Block, error) {
+		if t.Blocks != nil { blocks, err := t.Blocks(c.Input); return blocksText(blocks), blocks, err }
+		out, err := t.Run(c.Input); return out, nil, err
+	})
+	for i := len(a.middleware) - 1; i >= 0; i-- { run = a.middleware[i](run) }
+	return run(runCtx, c)
+}
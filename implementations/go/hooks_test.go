@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+// tracing is middleware that logs its name on the way in and out to trace.
+func tracing(name string, trace *[]string) ToolMiddleware {
+	return func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, c ToolCall) (string, []Block, error) {
+			*trace = append(*trace, name+" in"); out, blocks, err := next(ctx, c); *trace = append(*trace, name+" out")
+			return out, blocks, err
+		}
+	}
+}
+
+func TestFirstMiddlewareIsOutermost(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "bash", `{"command":"echo ran >> log.txt"}`), textReply("done"))
+	var trace []string
+	a := testAgent(t, f).WithToolMiddleware(tracing("a", &trace)).WithToolMiddleware(tracing("b", &trace))
+	if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+	if got := strings.Join(trace, ", "); got != "a in, b in, b out, a out" { t.Errorf("order %s", got) }
+	if got := readTestFile(t, "log.txt"); got != "ran\n" { t.Errorf("the tool ran as %q", got) }
+}
+
+func TestMiddlewareCanShortCircuit(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "bash", `{"command":"echo ran >> log.txt"}`), textReply("done"))
+	var trace []string
+	block := func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, c ToolCall) (string, []Block, error) { trace = append(trace, "block"); return "blocked by policy", nil, nil }
+	}
+	a := testAgent(t, f).WithToolMiddleware(block).WithToolMiddleware(tracing("inner", &trace))
+	if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+	if got := strings.Join(trace, ", "); got != "block" { t.Errorf("trace %s; the inner middleware shouldn't run", got) }
+	if _, err := os.Stat("log.txt"); err == nil { t.Error("the tool ran past a short-circuiting middleware") }
+	if r := toolResult(t, f.request(t, 1), "t1"); r["content"] != "blocked by policy" { t.Errorf("result %v", r["content"]) }
+}
+
+func TestDeclinedCallsReachNoMiddleware(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "bash", `{"command":"echo ran >> log.txt"}`), textReply("done"))
+	var trace []string
+	a := testAgent(t, f).WithToolMiddleware(tracing("a", &trace)).WithApprover(func(context.Context, ToolCall) (Decision, error) { return Decision{Allow: false, By: "test"}, nil })
+	if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+	if len(trace) > 0 { t.Errorf("a declined call reached middleware: %v", trace) }
+}
@@ -0,0 +1,100 @@
+// Ignore rules for the file tools: a built-in list of generated and vendored directories, then
+// the project's .gitignore, then .nanoignore (gitignore syntax; later rules win, "!" re-includes).
+// Listings skip ignored paths; reading one still works but says it is ignored. --no-ignore
+// turns all of this off.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var noIgnore bool
+
+var defaultIgnores = []string{".git/", "node_modules/", "dist/", ".venv/", "venv/", "__pycache__/", ".next/", ".cache/", "coverage/", "*.pyc"}
+
+type ignoreRule struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+type ignoreSet struct{ root string; rules []ignoreRule }
+
+var (
+	ignoreOnce     sync.Once
+	projectIgnores *ignoreSet
+)
+
+// ignores returns the rules for the project root (the sandbox, else the working directory).
+func ignores() *ignoreSet {
+	ignoreOnce.Do(func() {
+		root := sandboxRoot; if root == "" { root, _ = os.Getwd() }
+		s := &ignoreSet{root: root}
+		s.add(defaultIgnores)
+		for _, f := range []string{".gitignore", ".nanoignore"} { if data, err := os.ReadFile(filepath.Join(root, f)); err == nil { s.add(strings.Split(string(data), "\n")) } }
+		projectIgnores = s
+	})
+	return projectIgnores
+}
+
+func (s *ignoreSet) add(lines []string) {
+	for _, l := range lines {
+		l = strings.TrimRight(l, "\r"); if t := strings.TrimRight(l, " "); !strings.HasSuffix(t, `\`) { l = t }
+		if l == "" || strings.HasPrefix(l, "#") { continue }
+		r := ignoreRule{}
+		if strings.HasPrefix(l, "!") { r.negate, l = true, l[1:] } else if strings.HasPrefix(l, `\`) { l = l[1:] }
+		if strings.HasSuffix(l, "/") { r.dirOnly, l = true, strings.TrimSuffix(l, "/") }
+		anchored := strings.Contains(l, "/"); l = strings.TrimPrefix(l, "/")
+		prefix := "(^|.*/)"; if anchored { prefix = "^" }
+		if re, err := regexp.Compile(prefix + globRegexp(l) + "$"); err == nil { r.re = re; s.rules = append(s.rules, r) }
+	}
+}
+
+// globRegexp translates one gitignore glob to a regexp body.
+func globRegexp(g string) string {
+	var sb strings.Builder
+	for i := 0; i < len(g); i++ {
+		switch c := g[i]; {
+		case strings.HasPrefix(g[i:], "**/"): sb.WriteString("(.*/)?"); i += 2
+		case strings.HasPrefix(g[i:], "/**") && i+3 == len(g): sb.WriteString("/.*"); i += 2
+		case strings.HasPrefix(g[i:], "**"): sb.WriteString(".*"); i++
+		case c == '*': sb.WriteString("[^/]*")
+		case c == '?': sb.WriteString("[^/]")
+		case c == '[':
+			j := strings.IndexByte(g[i+1:], ']')
+			if j < 0 { sb.WriteString(`\[`); continue }
+			class := g[i+1 : i+1+j]; if strings.HasPrefix(class, "!") { class = "^" + class[1:] }
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]"); i += j + 1
+		case c == '\\' && i+1 < len(g): i++; sb.WriteString(regexp.QuoteMeta(g[i : i+1]))
+		default: sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+// match applies the rules to one slash-separated relative path; the last matching rule wins.
+func (s *ignoreSet) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range s.rules { if (!r.dirOnly || isDir) && r.re.MatchString(rel) { ignored = !r.negate } }
+	return ignored
+}
+
+// ignored reports whether path is ignored, itself or through an ignored parent directory (as
+// in git, a file can't be re-included from inside an excluded directory).
+func ignored(path string, isDir bool) bool {
+	if noIgnore { return false }
+	s := ignores()
+	abs, err := filepath.Abs(path); if err != nil { return false }
+	rel, err := filepath.Rel(s.root, abs); if err != nil || rel == "." || strings.HasPrefix(rel, "..") { return false }
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i <= len(parts); i++ {
+		last := i == len(parts)
+		if s.match(strings.Join(parts[:i], "/"), !last || isDir) { return true }
+	}
+	return false
+}
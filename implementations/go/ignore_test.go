@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreMatch(t *testing.T) {
+	s := &ignoreSet{}
+	s.add([]string{"# comment", "*.log", "!keep.log", "build/", "/top.txt", "docs/**/draft.md", `\#literal`, "tmp/*", "!tmp/keep"})
+	for _, c := range []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"a.log", false, true},
+		{"sub/b.log", false, true},
+		{"keep.log", false, false},     // negated by a later rule
+		{"sub/keep.log", false, false}, // unanchored negation applies at any depth
+		{"build", true, true},
+		{"build", false, false}, // directory-only rule: a file named build stays
+		{"src/build", true, true},
+		{"top.txt", false, true},
+		{"sub/top.txt", false, false}, // a leading slash anchors to the root
+		{"docs/draft.md", false, true},
+		{"docs/a/b/draft.md", false, true},
+		{"#literal", false, true},
+		{"comment", false, false},
+		{"tmp/x", false, true},
+		{"tmp/keep", false, false},
+	} {
+		if got := s.match(c.path, c.isDir); got != c.want { t.Errorf("match(%q, dir=%v) = %v, want %v", c.path, c.isDir, got, c.want) }
+	}
+}
+
+func TestNegationLastRuleWins(t *testing.T) {
+	s := &ignoreSet{}
+	s.add([]string{"!a.txt", "*.txt"})
+	if !s.match("a.txt", false) { t.Error("a later ignore should override an earlier negation") }
+	s.add([]string{"!a.txt"})
+	if s.match("a.txt", false) { t.Error("a later negation should re-include the file") }
+}
+
+func TestIgnoredThroughParentDirectory(t *testing.T) {
+	dir := inTempDir(t); ignores()
+	saved := projectIgnores; t.Cleanup(func() { projectIgnores = saved })
+	projectIgnores = &ignoreSet{root: dir}
+	projectIgnores.add(append(defaultIgnores, "gen/", "!gen/keep.go"))
+	for _, c := range []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"node_modules/pkg/index.js", false, true},
+		{"gen/keep.go", false, true}, // can't be re-included from inside an excluded directory
+		{"src/gen", false, false},
+		{"src/main.go", false, false},
+		{"x.pyc", false, true},
+		{filepath.Join(dir, "..", "outside", "node_modules"), true, false}, // outside the root: never ignored
+	} {
+		if got := ignored(c.path, c.isDir); got != c.want { t.Errorf("ignored(%q) = %v, want %v", c.path, got, c.want) }
+	}
+	noIgnore = true; t.Cleanup(func() { noIgnore = false })
+	if ignored("node_modules/x.js", false) { t.Error("--no-ignore should turn ignoring off") }
+}
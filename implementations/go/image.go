@@ -0,0 +1,78 @@
+// Images for the model: tool results carry them as base64 image blocks, scaled down so the
+// longer side fits maxImageSide and the encoding fits maxImageBytes.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const maxImageSide, maxImageBytes = 1568, 3 << 20
+
+var imageTypes = map[string]string{".png": "image/png", ".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".gif": "image/gif", ".webp": "image/webp"}
+
+func imageBlock(media string, data []byte) Block {
+	return Block{Type: "image", Source: &MediaSource{Type: "base64", MediaType: media, Data: base64.StdEncoding.EncodeToString(data)}}
+}
+
+// readImage returns the image at path as it should be sent: untouched if it already fits,
+// otherwise decoded, scaled and re-encoded. WebP can't be decoded here, so an oversized one is
+// rejected instead.
+func readImage(path string) ([]Block, error) {
+	media := imageTypes[strings.ToLower(filepath.Ext(path))]
+	data, err := os.ReadFile(path); if err != nil { return nil, err }
+	desc := fmt.Sprintf("%s (%s, %d bytes)", filepath.Base(path), media, len(data))
+	cfg, _, cerr := image.DecodeConfig(bytes.NewReader(data))
+	if cerr == nil { desc += fmt.Sprintf(", %dx%d", cfg.Width, cfg.Height) }
+	if len(data) <= maxImageBytes && (cerr != nil || max(cfg.Width, cfg.Height) <= maxImageSide) { return []Block{{Type: "text", Text: desc}, imageBlock(media, data)}, nil }
+	if media == "image/webp" { return nil, fmt.Errorf("%s is too large to send (%d bytes; limit %d) and WebP can't be resized here", path, len(data), maxImageBytes) }
+	img, _, err := image.Decode(bytes.NewReader(data)); if err != nil { return nil, fmt.Errorf("decoding %s: %w", path, err) }
+	img = shrink(img)
+	if data, media, err = encodeImage(img); err != nil { return nil, err }
+	b := img.Bounds()
+	return []Block{{Type: "text", Text: desc + fmt.Sprintf(", scaled to %dx%d", b.Dx(), b.Dy())}, imageBlock(media, data)}, nil
+}
+
+// shrink scales img so its longer side is at most maxImageSide.
+func shrink(img image.Image) image.Image {
+	b := img.Bounds(); if max(b.Dx(), b.Dy()) <= maxImageSide { return img }
+	scale := float64(maxImageSide) / float64(max(b.Dx(), b.Dy()))
+	return downscale(img, max(1, int(float64(b.Dx())*scale)), max(1, int(float64(b.Dy())*scale)))
+}
+
+// encodeImage encodes img as PNG, or as progressively lower-quality JPEG when the PNG is too big.
+func encodeImage(img image.Image) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil { return nil, "", err }
+	if buf.Len() <= maxImageBytes { return buf.Bytes(), "image/png", nil }
+	for q := 85; q >= 40; q -= 15 {
+		buf.Reset(); if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: q}); err != nil { return nil, "", err }
+		if buf.Len() <= maxImageBytes { return buf.Bytes(), "image/jpeg", nil }
+	}
+	return nil, "", fmt.Errorf("screenshot is still %d bytes after compression", buf.Len())
+}
+
+// downscale is a box filter: each output pixel averages the source pixels it covers.
+func downscale(src image.Image, w, h int) image.Image {
+	sb := src.Bounds(); dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := sb.Min.Y+y*sb.Dy()/h, sb.Min.Y+max((y+1)*sb.Dy()/h, y*sb.Dy()/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := sb.Min.X+x*sb.Dx()/w, sb.Min.X+max((x+1)*sb.Dx()/w, x*sb.Dx()/w+1)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ { for sx := x0; sx < x1; sx++ { cr, cg, cb, ca := src.At(sx, sy).RGBA(); r += uint64(cr); g += uint64(cg); bl += uint64(cb); a += uint64(ca); n++ } }
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
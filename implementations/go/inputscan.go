@@ -0,0 +1,137 @@
+// Incremental scanner for tool input as it streams in (input_json_delta fragments), so the
+// terminal can show a write_file's path or a bash command before the call is complete. It
+// handles the flat objects tools take: the top-level string fields are decoded as their
+// bytes arrive, escapes included, however the fragments split them; nested values and
+// numbers are skipped over. It never fails: malformed input just stops yielding fields.
+
+package main
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+type inputScanner struct {
+	state  int
+	key    strings.Builder
+	cur    *strings.Builder // the field value being read; nil while reading a key
+	fields map[string]*strings.Builder
+	done   map[string]bool
+	esc    bool   // after a backslash
+	hex    []byte // digits of a \u escape so far
+	high   rune   // a pending high surrogate
+	depth  int    // of a nested value being skipped
+	inStr  bool   // inside a string within a nested value
+	n      int    // bytes fed
+}
+
+const (
+	scanStart = iota // before the opening brace
+	scanKey          // before a key, or a closing brace
+	scanKeyStr       // inside a key
+	scanColon
+	scanValue        // before a value
+	scanStr          // inside a string value
+	scanNested       // inside an object or array value
+	scanScalar       // inside a number, true, false or null
+	scanEnd          // after the closing brace, or given up
+)
+
+func newInputScanner() *inputScanner { return &inputScanner{fields: map[string]*strings.Builder{}, done: map[string]bool{}} }
+
+// feed scans the next fragment.
+func (s *inputScanner) feed(frag string) {
+	s.n += len(frag)
+	for i := 0; i < len(frag); i++ {
+		c := frag[i]
+		switch s.state {
+		case scanStart:
+			if c == '{' { s.state = scanKey } else if !isSpace(c) { s.state = scanEnd }
+		case scanKey:
+			switch {
+			case c == '"': s.state = scanKeyStr; s.key.Reset()
+			case c == '}': s.state = scanEnd
+			case c != ',' && !isSpace(c): s.state = scanEnd
+			}
+		case scanKeyStr:
+			if s.str(c, &s.key) { s.state = scanColon }
+		case scanColon:
+			if c == ':' { s.state = scanValue } else if !isSpace(c) { s.state = scanEnd }
+		case scanValue:
+			switch {
+			case isSpace(c):
+			case c == '"': s.cur = &strings.Builder{}; s.fields[s.key.String()] = s.cur; s.state = scanStr
+			case c == '{' || c == '[': s.depth = 1; s.state = scanNested
+			default: s.state = scanScalar
+			}
+		case scanStr:
+			if s.str(c, s.cur) { s.done[s.key.String()] = true; s.cur = nil; s.state = scanKey }
+		case scanNested:
+			switch {
+			case s.inStr: s.inStr = !s.str(c, nil)
+			case c == '"': s.inStr = true
+			case c == '{' || c == '[': s.depth++
+			case c == '}' || c == ']': if s.depth--; s.depth == 0 { s.state = scanKey }
+			}
+		case scanScalar:
+			if c == ',' { s.state = scanKey } else if c == '}' { s.state = scanEnd }
+		}
+	}
+}
+
+// str takes one byte of a string's body, decoding into dst (unless nil), and reports
+// whether it was the closing quote.
+func (s *inputScanner) str(c byte, dst *strings.Builder) bool {
+	switch {
+	case s.hex != nil:
+		s.hex = append(s.hex, c); if len(s.hex) < 4 { return false }
+		r := rune(0); for _, h := range s.hex { r = r<<4 | rune(unhex(h)) }; s.hex = nil
+		if s.high != 0 && r >= 0xdc00 && r < 0xe000 { write(dst, string(utf16.DecodeRune(s.high, r))); s.high = 0; return false }
+		s.lone(dst)
+		if r >= 0xd800 && r < 0xdc00 { s.high = r; return false }
+		write(dst, string(r)) // a lone low surrogate comes out as U+FFFD
+	case s.esc:
+		s.esc = false
+		if c == 'u' { s.hex = []byte{}; return false }
+		s.lone(dst); write(dst, string(unescape(c)))
+	case c == '\\': s.esc = true
+	case c == '"': s.lone(dst); return true
+	default: s.lone(dst); if dst != nil { dst.WriteByte(c) }
+	}
+	return false
+}
+
+// lone ends a high surrogate that no low one followed as U+FFFD, as encoding/json does.
+func (s *inputScanner) lone(dst *strings.Builder) {
+	if s.high != 0 { write(dst, "\uFFFD"); s.high = 0 }
+}
+
+// field returns a top-level string field as far as it has arrived, and whether it is complete.
+func (s *inputScanner) field(name string) (string, bool) {
+	b, ok := s.fields[name]; if !ok { return "", false }
+	return b.String(), s.done[name]
+}
+
+func write(dst *strings.Builder, s string) { if dst != nil { dst.WriteString(s) } }
+
+func isSpace(c byte) bool { return c == ' ' || c == '\n' || c == '\r' || c == '\t' }
+
+func unescape(c byte) rune {
+	switch c {
+	case 'n': return '\n'
+	case 't': return '\t'
+	case 'r': return '\r'
+	case 'b': return '\b'
+	case 'f': return '\f'
+	}
+	return rune(c) // \" \\ \/
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9': return c - '0'
+	case c >= 'a' && c <= 'f': return c - 'a' + 10
+	case c >= 'A' && c <= 'F': return c - 'A' + 10
+	}
+	return 0
+}
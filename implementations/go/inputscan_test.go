@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var scanInputs = []string{
+	`{"path":"src/ünïcode/日本.go","content":"line \"one\"\nline\ttwo\\"}`,
+	`{"command":"echo \u00e9\u65e5 \ud83d\ude00 done","timeout":30}`,
+	`{ "path" : "a.txt" , "opts" : {"x":["}","\""]}, "content":"after \/ nested 🙂" }`,
+	`{"content":"lone \ud83d then text","n":null,"ok":true}`,
+	`{"content":"\ud83dX","path":"p"}`,
+	`{"content":"\ud83d\u0041 \ud83d\ud83d\ude00 \ude00 \ud83d\n","path":"p"}`,
+}
+
+// scanAll feeds the fragments and returns the string fields it decoded.
+func scanAll(frags ...string) map[string]string {
+	s := newInputScanner(); for _, f := range frags { s.feed(f) }
+	out := map[string]string{}
+	for k := range s.fields { if v, done := s.field(k); done { out[k] = v } }
+	return out
+}
+
+func sameFields(a, b map[string]string) bool {
+	if len(a) != len(b) { return false }
+	for k, v := range a { if b[k] != v { return false } }
+	return true
+}
+
+func TestInputScanMatchesTheJSONDecoder(t *testing.T) {
+	for _, in := range scanInputs {
+		var m map[string]any; if err := json.Unmarshal([]byte(in), &m); err != nil { t.Fatalf("bad fixture %s: %v", in, err) }
+		want := map[string]string{}
+		for k, v := range m { if s, ok := v.(string); ok { want[k] = s } }
+		if got := scanAll(in); !sameFields(got, want) { t.Errorf("%s:\n got %q\nwant %q", in, got, want) }
+	}
+}
+
+func TestInputScanIgnoresHowFragmentsSplit(t *testing.T) {
+	for _, in := range scanInputs {
+		whole := scanAll(in)
+		for i := 0; i <= len(in); i++ { // every single split: mid-rune, after a backslash, inside \uXXXX
+			if got := scanAll(in[:i], in[i:]); !sameFields(got, whole) { t.Errorf("%s split at %d (%q|%q): %q, want %q", in, i, in[:i], in[i:], got, whole) }
+		}
+		for i := 0; i < len(in); i++ {
+			for j := i + 1; j <= len(in) && j < i+8; j++ { // and pairs of nearby splits
+				if got := scanAll(in[:i], in[i:j], in[j:]); !sameFields(got, whole) { t.Errorf("%s split at %d and %d: %q", in, i, j, got) }
+			}
+		}
+		bytes := make([]string, len(in)); for i := 0; i < len(in); i++ { bytes[i] = in[i : i+1] }
+		if got := scanAll(bytes...); !sameFields(got, whole) { t.Errorf("%s byte by byte: %q", in, got) }
+	}
+}
+
+func TestInputScanFieldGrowsAsItArrives(t *testing.T) {
+	in := `{"path":"dir/日本.txt","content":"x"}`
+	s := newInputScanner(); last := ""
+	for i := 0; i < len(in); i++ {
+		s.feed(in[i : i+1])
+		v, done := s.field("path")
+		if !strings.HasPrefix(v, last) { t.Fatalf("after %d bytes the path went from %q to %q", i+1, last, v) }
+		if done && v != "dir/日本.txt" { t.Fatalf("complete path %q", v) }
+		last = v
+	}
+	if v, done := s.field("path"); !done || v != "dir/日本.txt" { t.Errorf("path %q %v", v, done) }
+}
@@ -0,0 +1,66 @@
+// Directory-scoped instructions. The first time a tool touches a path, every AGENTS.md or
+// .nano/instructions.md between that path and the project root that hasn't been seen yet is
+// added to the conversation, labelled with the directory it governs. They are kept aside so
+// compaction can restate them instead of summarizing them away.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const maxInstructions = 8 << 10
+
+var instructionFiles = []string{"AGENTS.md", filepath.Join(".nano", "instructions.md")}
+
+type instruction struct{ dir, file, text string }
+
+// discoverInstructions returns the instruction texts newly in scope for b's paths.
+func (a *Agent) discoverInstructions(b Block) []string {
+	in, _ := decodeInput(b.Input)
+	root := sandboxRoot; if root == "" { root, _ = os.Getwd() }
+	var out []string
+	for _, key := range []string{"path", "destination"} {
+		p := in.Str(key); if p == "" { continue }
+		path, err := resolvePath(p); if err != nil { continue }
+		dir, err := filepath.Abs(path); if err != nil { continue }
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() { dir = filepath.Dir(dir) }
+		if !within(dir, root) { continue }
+		var found []instruction
+		for d := dir; ; d = filepath.Dir(d) {
+			if a.seenDirs == nil { a.seenDirs = map[string]bool{} }
+			if !a.seenDirs[d] {
+				a.seenDirs[d] = true
+				for _, f := range instructionFiles {
+					if slices.ContainsFunc(a.instructions, func(i instruction) bool { return i.dir == d && i.file == f }) { continue }
+					data, err := os.ReadFile(filepath.Join(d, f)); if err != nil { continue }
+					text := string(data); if len(text) > maxInstructions { text = text[:maxInstructions] + "\n[... truncated]" }
+					found = append(found, instruction{d, f, text})
+				}
+			}
+			if d == root || d == filepath.Dir(d) { break }
+		}
+		for i := len(found) - 1; i >= 0; i-- { // outermost first, so deeper rules read as refinements
+			ins := found[i]; a.instructions = append(a.instructions, ins); out = append(out, ins.render(root))
+			rel, _ := filepath.Rel(root, filepath.Join(ins.dir, ins.file)); a.notify("📋 instructions from " + rel)
+		}
+	}
+	return out
+}
+
+func (ins instruction) render(root string) string {
+	rel, _ := filepath.Rel(root, ins.dir); scope := "the whole project"; if rel != "." { scope = rel + "/" }
+	return fmt.Sprintf("Instructions for %s (from %s), which apply to all work there:\n\n%s", scope, filepath.Join(rel, ins.file), strings.TrimSpace(ins.text))
+}
+
+// instructionsSummary restates every instruction seen so far, for after compaction.
+func (a *Agent) instructionsSummary() string {
+	if len(a.instructions) == 0 { return "" }
+	root := sandboxRoot; if root == "" { root, _ = os.Getwd() }
+	var parts []string; for _, ins := range a.instructions { parts = append(parts, ins.render(root)) }
+	return "\n\nDirectory instructions still in effect:\n\n" + strings.Join(parts, "\n\n")
+}
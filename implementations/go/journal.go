@@ -0,0 +1,185 @@
+// Crash journaling. The session file is only rewritten after each exchange, so a panic or an
+// OOM kill mid-turn would lose the turn's work. Alongside it each run keeps a journal,
+// sessions/<id>.journal: a header line, then one JSON line per message as it is appended and
+// per tool result as it completes. Every autosave checkpoints it (the header alone, noting
+// how many messages the session file holds), and a clean exit deletes it. A truncated last
+// line is simply skipped.
+//
+// On the next start in the same directory, a journal whose process is gone is folded back
+// into its session, with "interrupted" results for tools that never finished, and on a
+// terminal nano offers to resume it.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type journalRecord struct {
+	Type    string         `json:"t"` // "start", "msg" or "result"
+	ID      string         `json:"id,omitempty"`
+	Dir     string         `json:"dir,omitempty"`
+	Model   string         `json:"model,omitempty"`
+	PID     int            `json:"pid,omitempty"`
+	Base    int            `json:"base,omitempty"` // messages already in the session file
+	Index   int            `json:"i,omitempty"`    // the message a record is, or a result belongs to
+	Message *Message       `json:"msg,omitempty"`
+	Result  map[string]any `json:"result,omitempty"`
+}
+
+type journal struct {
+	f     *os.File
+	id    string
+	n     int  // messages journaled (or checkpointed) so far
+	dirty bool // records since the last checkpoint
+}
+
+func journalPath(id string) string { return strings.TrimSuffix(sessionPath(id), ".json") + ".journal" }
+
+// openJournal starts this run's journal, treating the messages so far as checkpointed.
+func (a *Agent) openJournal() {
+	a.journal = &journal{}
+	if err := a.checkpointJournal(); err != nil { fmt.Fprintln(os.Stderr, "warning: no crash journal:", err); a.journal = nil }
+}
+
+// checkpointJournal rewrites the journal as just its header once the session file is current.
+func (a *Agent) checkpointJournal() error {
+	j := a.journal; if j == nil { return nil }
+	if j.f != nil { j.f.Close() }
+	path := journalPath(a.Session)
+	if j.id != "" && j.id != a.Session { os.Remove(journalPath(j.id)) }
+	hdr, _ := json.Marshal(journalRecord{Type: "start", ID: a.Session, Dir: workDir(), Model: a.Model, PID: os.Getpid(), Base: len(a.Messages)})
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil { return err }
+	if err := os.WriteFile(path+".tmp", append(hdr, '\n'), 0600); err != nil { return err }
+	if err := os.Rename(path+".tmp", path); err != nil { return err }
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600); if err != nil { return err }
+	j.f, j.id, j.n, j.dirty = f, a.Session, len(a.Messages), false
+	return nil
+}
+
+func (j *journal) write(r journalRecord) {
+	data, err := json.Marshal(r); if err != nil { return }
+	j.f.Write(append(data, '\n')); j.dirty = true
+}
+
+// journalSync appends the messages added since the last call. A history that shrank
+// (compaction, /undo) or a new session ID can't be expressed as appends, so it saves the
+// session and checkpoints instead.
+func (a *Agent) journalSync() {
+	j := a.journal; if j == nil { return }
+	if len(a.Messages) < j.n || a.Session != j.id {
+		if _, err := a.saveSession(""); err == nil { a.checkpointJournal() }
+		return
+	}
+	for ; j.n < len(a.Messages); j.n++ { j.write(journalRecord{Type: "msg", Index: j.n, Message: &a.Messages[j.n]}) }
+}
+
+// journalResult records a tool result as soon as it's ready, ahead of its message.
+func (a *Agent) journalResult(r map[string]any) {
+	if a.journal != nil { a.journal.write(journalRecord{Type: "result", Index: len(a.Messages), Result: r}) }
+}
+
+// closeJournal deletes the journal on exit, unless it holds work no save has caught up with.
+func (a *Agent) closeJournal() {
+	j := a.journal; if j == nil { return }
+	j.f.Close(); if !j.dirty { os.Remove(journalPath(j.id)) }
+}
+
+// replayJournal rebuilds the session a journal belongs to. It returns false when the journal
+// holds nothing the session file doesn't.
+func replayJournal(path string) (savedSession, bool, error) {
+	f, err := os.Open(path); if err != nil { return savedSession{}, false, err }
+	defer f.Close()
+	sc := bufio.NewScanner(f); sc.Buffer(nil, 256<<20)
+	var hdr journalRecord
+	if !sc.Scan() || json.Unmarshal(sc.Bytes(), &hdr) != nil || hdr.Type != "start" || hdr.ID == "" { return savedSession{}, false, nil }
+	s := savedSession{Version: sessionVersion, ID: hdr.ID, Dir: hdr.Dir, Model: hdr.Model}
+	if hdr.Base > 0 {
+		if s, err = loadSession(hdr.ID); err != nil { return s, true, err }
+		if len(s.Messages) < hdr.Base { return s, true, fmt.Errorf("session %s has %d messages, the journal expects %d", hdr.ID, len(s.Messages), hdr.Base) }
+		s.Messages = s.Messages[:hdr.Base]
+	}
+	var results []map[string]any; resultsFor, records := -1, 0
+	for sc.Scan() {
+		var r journalRecord
+		if json.Unmarshal(sc.Bytes(), &r) != nil { break } // a torn final record
+		records++
+		switch {
+		case r.Type == "msg" && r.Message != nil && r.Index <= len(s.Messages):
+			s.Messages = append(s.Messages[:r.Index], *r.Message)
+		case r.Type == "result" && r.Result != nil:
+			if r.Index != resultsFor { results, resultsFor = nil, r.Index }
+			results = append(results, r.Result)
+		}
+	}
+	if err := normalizeMessages(s.Messages); err != nil { return s, true, err }
+	if resultsFor != len(s.Messages) { results = nil }
+	s.Messages = closeToolUses(s.Messages, results)
+	if s.Prompt == "" { s.Prompt = firstPrompt(s.Messages); s.Title = fallbackTitle(s.Prompt) }
+	fi, _ := f.Stat(); s.Saved = fi.ModTime()
+	return s, records > 0, nil
+}
+
+// closeToolUses ends a history that stops at an assistant tool call with its results: those
+// that completed, and an "interrupted" error for the rest, so the API accepts it again.
+func closeToolUses(msgs []Message, done []map[string]any) []Message {
+	if len(msgs) == 0 || msgs[len(msgs)-1].Role != "assistant" { return msgs }
+	blocks, _ := msgs[len(msgs)-1].Content.([]Block)
+	var results []map[string]any
+	for _, b := range blocks {
+		if b.Type != "tool_use" { continue }
+		i := slices.IndexFunc(done, func(r map[string]any) bool { return r["tool_use_id"] == b.ID })
+		if i >= 0 { results = append(results, done[i]); continue }
+		results = append(results, map[string]any{"type": "tool_result", "tool_use_id": b.ID, "content": "Error: interrupted: nano exited before this tool call finished", "is_error": true})
+	}
+	if len(results) == 0 { return msgs }
+	return append(msgs, Message{Role: "user", Content: results})
+}
+
+// recoverJournals folds the journals of dead runs in this directory back into their
+// sessions and returns the most recent one, if any.
+func recoverJournals() (savedSession, bool) {
+	files, _ := filepath.Glob(filepath.Join(dataDir(), "sessions", "*.journal"))
+	var latest savedSession; found := false
+	for _, path := range files {
+		hdr, ok := journalHeader(path)
+		if ok && (hdr.Dir != workDir() || hdr.PID == os.Getpid() || processAlive(hdr.PID)) { continue }
+		s, ok, err := replayJournal(path)
+		if err != nil { fmt.Fprintln(os.Stderr, "warning: recovering", filepath.Base(path)+":", err); continue }
+		if ok && len(s.Messages) > 0 {
+			if err := s.write(); err != nil { fmt.Fprintln(os.Stderr, "warning: recovering", s.ID+":", err); continue }
+			if !found || s.Saved.After(latest.Saved) { latest, found = s, true }
+		}
+		os.Remove(path)
+	}
+	return latest, found
+}
+
+func journalHeader(path string) (journalRecord, bool) {
+	f, err := os.Open(path); if err != nil { return journalRecord{}, false }
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadBytes('\n')
+	var hdr journalRecord
+	return hdr, json.Unmarshal(line, &hdr) == nil && hdr.Type == "start"
+}
+
+// offerRecovery recovers interrupted sessions and, on a terminal, asks whether to resume the
+// latest; elsewhere the answer is no and it only says where the session went.
+func offerRecovery() (savedSession, bool) {
+	s, ok := recoverJournals(); if !ok { return s, false }
+	ago := time.Since(s.Saved).Round(time.Minute)
+	when := "just now"; if ago >= time.Minute { when = strings.TrimSuffix(ago.String(), "0s") + " ago" }
+	if !isTTY(os.Stdin) { fmt.Fprintf(os.Stderr, "recovered an interrupted session from %s: nano --resume %s\n", when, s.ID); return s, false }
+	fmt.Fprintf(os.Stderr, "Resume interrupted session %q from %s? [y/N] ", s.Title, when)
+	ans := strings.ToLower(strings.TrimSpace(readAnswer()))
+	if ans == "y" || ans == "yes" { return s, true }
+	fmt.Fprintf(os.Stderr, "kept it as session %s (nano --resume %s)\n", s.ID, s.ID)
+	return s, false
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// journaledRun runs a few tool turns with the journal on and returns its bytes; the journal
+// is left in place as a crash would leave it.
+func journaledRun(t *testing.T) (*Agent, []byte) {
+	t.Helper()
+	f := newFakeAPI(t,
+		reply("tool_use", textBlock("Looking — “first”."), toolBlock("t1", "read_file", `{"path":"a.txt"}`), toolBlock("t2", "bash", `{"command":"echo hi"}`)),
+		toolReply("t3", "write_file", `{"path":"b.txt","content":"b\n"}`),
+		textReply("done"))
+	a := testAgent(t, f); writeTestFile(t, "a.txt", "a\n")
+	a.openJournal(); if a.journal == nil { t.Fatal("no journal") }
+	if _, err := a.Run("look around"); err != nil { t.Fatal(err) }
+	a.journal.f.Close()
+	data, err := os.ReadFile(journalPath(a.Session)); if err != nil { t.Fatal(err) }
+	return a, data
+}
+
+// checkHistory fails unless msgs start with a prompt, alternate roles and answer every
+// tool_use, and only those, in the next message.
+func checkHistory(t *testing.T, msgs []Message, at int) {
+	t.Helper()
+	data, _ := json.Marshal(msgs)
+	var ms []struct{ Role string; Content json.RawMessage }
+	json.Unmarshal(data, &ms)
+	var open []string
+	for i, m := range ms {
+		want := "user"; if i%2 == 1 { want = "assistant" }
+		if m.Role != want { t.Fatalf("cut at %d: message %d is %s, want %s", at, i, m.Role, want) }
+		var blocks []struct{ Type, ID string; ToolUseID string `json:"tool_use_id"` }
+		json.Unmarshal(m.Content, &blocks)
+		var answered []string
+		for _, b := range blocks { if b.Type == "tool_result" { answered = append(answered, b.ToolUseID) } }
+		if fmt.Sprint(answered) != fmt.Sprint(open) && (len(answered) > 0 || len(open) > 0) { t.Fatalf("cut at %d: message %d answers %v, want %v", at, i, answered, open) }
+		open = nil
+		for _, b := range blocks { if b.Type == "tool_use" { open = append(open, b.ID) } }
+	}
+	if len(open) > 0 { t.Fatalf("cut at %d: tool calls %v left unanswered", at, open) }
+}
+
+func TestReplayJournalTruncatedAnywhere(t *testing.T) {
+	a, data := journaledRun(t)
+	full, ok, err := replayJournal(journalPath(a.Session)); if err != nil || !ok { t.Fatalf("full journal: %v %v", ok, err) }
+	if len(full.Messages) != len(a.Messages) { t.Fatalf("full replay has %d messages, the run %d", len(full.Messages), len(a.Messages)) }
+	checkHistory(t, full.Messages, len(data))
+	path := journalPath(a.Session) + ".cut"; last := 0
+	for n := 0; n <= len(data); n++ {
+		if err := os.WriteFile(path, data[:n], 0600); err != nil { t.Fatal(err) }
+		s, _, err := replayJournal(path); if err != nil { t.Fatalf("cut at %d: %v", n, err) }
+		checkHistory(t, s.Messages, n)
+		if len(s.Messages) > 0 && s.Messages[0].Content != "look around" { t.Fatalf("cut at %d: first message %v", n, s.Messages[0].Content) }
+		if len(s.Messages)+1 < last { t.Fatalf("cut at %d: %d messages, fewer than the %d an earlier cut kept", n, len(s.Messages), last) }
+		last = max(last, len(s.Messages))
+	}
+}
+
+func TestRecoverJournalsOfADeadRun(t *testing.T) {
+	a, data := journaledRun(t)
+	dead := exec.Command("true"); if err := dead.Run(); err != nil { t.Skip("can't start a process") }
+	line, rest, _ := bytes.Cut(data, []byte("\n"))
+	var hdr journalRecord; json.Unmarshal(line, &hdr); hdr.PID = dead.Process.Pid
+	line, _ = json.Marshal(hdr)
+	cut := append(append(line, '\n'), rest[:len(rest)*2/3]...)
+	if err := os.WriteFile(journalPath(a.Session), cut, 0600); err != nil { t.Fatal(err) }
+	s, ok := recoverJournals(); if !ok || s.ID != a.Session { t.Fatalf("recovered %v %q, want %s", ok, s.ID, a.Session) }
+	checkHistory(t, s.Messages, len(cut))
+	if _, err := os.Stat(journalPath(a.Session)); !os.IsNotExist(err) { t.Error("the journal was left behind") }
+	saved, err := loadSession(a.Session); if err != nil { t.Fatal(err) }
+	checkHistory(t, saved.Messages, len(cut))
+}
@@ -0,0 +1,37 @@
+// Size guards on API traffic. Response bodies are read through a limit (config
+// "max_response_bytes", default 64 MiB; error bodies at most 1 MiB), so a misbehaving endpoint
+// can't exhaust memory, and a body over it is an error saying where it was cut. A request
+// body over config "max_request_bytes" (default 32 MB, what the Messages API accepts) fails
+// before it is sent, with a hint to compact or read less.
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	defaultMaxResponse = 64 << 20
+	defaultMaxRequest  = 32_000_000
+	maxErrorBody       = 1 << 20
+)
+
+func maxResponseBytes() int64 { if cfg.MaxResponseBytes > 0 { return cfg.MaxResponseBytes }; return defaultMaxResponse }
+
+func maxRequestBytes() int64 { if cfg.MaxRequestBytes > 0 { return cfg.MaxRequestBytes }; return defaultMaxRequest }
+
+// readBody reads at most limit bytes of r and reports whether there was more.
+func readBody(r io.Reader, limit int64) ([]byte, bool, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, limit+1)); if err != nil { return nil, false, err }
+	if int64(len(raw)) > limit { return raw[:limit], true, nil }
+	return raw, false, nil
+}
+
+// checkRequestSize fails a request body too big for the API to accept.
+func checkRequestSize(body []byte) error {
+	if n, limit := int64(len(body)), maxRequestBytes(); n > limit {
+		return fmt.Errorf("the request is %s, over the %s the API accepts (config max_request_bytes); compact the conversation (/compact) or read smaller parts of files", humanBytes(n), humanBytes(limit))
+	}
+	return nil
+}
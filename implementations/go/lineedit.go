@@ -0,0 +1,158 @@
+// A minimal line editor for interactive mode on a terminal: cursor movement, Emacs-style
+// kill keys, history on up/down (kept in the data directory across sessions), Ctrl-R
+// reverse search and bracketed paste, so a pasted block with newlines stays one entry (shown
+// with ⏎ marks). Piped input never comes here; the REPL reads it line by line.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const maxHistory = 1000
+
+var errInterrupt = errors.New("interrupted")
+
+// secretAssignment catches "password=..."-style lines that scrubSecrets' token formats miss.
+var secretAssignment = regexp.MustCompile(`(?i)(password|passwd|secret|token|api[_-]?key)\s*[:=]\s*\S`)
+
+type lineEditor struct {
+	history []string
+	path    string
+	prefill string // typed ahead during the last turn, to continue editing
+}
+
+func newLineEditor() *lineEditor {
+	e := &lineEditor{path: filepath.Join(dataDir(), "history")}
+	data, _ := os.ReadFile(e.path)
+	for _, l := range strings.Split(string(data), "\n") { if l != "" { e.history = append(e.history, unescapeHistory(l)) } }
+	return e
+}
+
+// add appends line to the history, moving an earlier duplicate to the end, and rewrites the
+// file without the lines that look like they hold secrets.
+func (e *lineEditor) add(line string) {
+	if strings.TrimSpace(line) == "" { return }
+	e.history = append(slices.DeleteFunc(e.history, func(h string) bool { return h == line }), line)
+	if len(e.history) > maxHistory { e.history = e.history[len(e.history)-maxHistory:] }
+	var sb strings.Builder
+	for _, h := range e.history { if !looksSecret(h) { sb.WriteString(escapeHistory(h) + "\n") } }
+	if err := os.MkdirAll(filepath.Dir(e.path), 0700); err == nil { os.WriteFile(e.path, []byte(sb.String()), 0600) }
+}
+
+func looksSecret(s string) bool { _, n := scrubSecrets(s); return n > 0 || secretAssignment.MatchString(s) }
+
+func escapeHistory(s string) string { return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s) }
+
+func unescapeHistory(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) { i++; if s[i] == 'n' { sb.WriteByte('\n') } else { sb.WriteByte(s[i]) }; continue }
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// readLine edits one line on the terminal; it returns errInterrupt on Ctrl-C and io.EOF on
+// Ctrl-D at an empty line.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	buf := []rune(e.prefill); pos, hist := len(buf), len(e.history); e.prefill = ""
+	draft := ""
+	redraw := func() { fmt.Fprintf(os.Stderr, "\r%s%s\x1b[K", prompt, strings.ReplaceAll(string(buf), "\n", "⏎")); if n := len(buf) - pos; n > 0 { fmt.Fprintf(os.Stderr, "\x1b[%dD", n) } }
+	set := func(s string) { buf = []rune(s); pos = len(buf); redraw() }
+	redraw()
+	for {
+		r, err := keys.next(); if err != nil { return "", err }
+		switch r {
+		case '\r', '\n': fmt.Fprint(os.Stderr, "\r\n"); return string(buf), nil
+		case 3: fmt.Fprint(os.Stderr, "^C\r\n"); return "", errInterrupt // Ctrl-C
+		case 4: // Ctrl-D
+			if len(buf) == 0 { fmt.Fprint(os.Stderr, "\r\n"); return "", io.EOF }
+			if pos < len(buf) { buf = slices.Delete(buf, pos, pos+1) }
+		case 1: pos = 0                                          // Ctrl-A
+		case 5: pos = len(buf)                                   // Ctrl-E
+		case 2: if pos > 0 { pos-- }                             // Ctrl-B
+		case 6: if pos < len(buf) { pos++ }                      // Ctrl-F
+		case 11: buf = buf[:pos]                                 // Ctrl-K
+		case 21: buf = buf[pos:]; pos = 0                        // Ctrl-U
+		case 12: fmt.Fprint(os.Stderr, "\x1b[H\x1b[2J")          // Ctrl-L
+		case 127, 8: if pos > 0 { buf = slices.Delete(buf, pos-1, pos); pos-- }
+		case 23: // Ctrl-W: delete the word before the cursor
+			i := pos; for i > 0 && buf[i-1] == ' ' { i-- }; for i > 0 && buf[i-1] != ' ' { i-- }
+			buf = slices.Delete(buf, i, pos); pos = i
+		case 18: // Ctrl-R
+			if s, ok := e.search(); ok { set(s) }
+		case 16, 14: // Ctrl-P, Ctrl-N
+			hist, draft = e.step(hist, r == 16, string(buf), draft, set)
+		case 27:
+			seq := keys.escape()
+			switch seq {
+			case "[A", "OA": hist, draft = e.step(hist, true, string(buf), draft, set)
+			case "[B", "OB": hist, draft = e.step(hist, false, string(buf), draft, set)
+			case "[C", "OC": if pos < len(buf) { pos++ }
+			case "[D", "OD": if pos > 0 { pos-- }
+			case "[H", "OH", "[1~": pos = 0
+			case "[F", "OF", "[4~": pos = len(buf)
+			case "[3~": if pos < len(buf) { buf = slices.Delete(buf, pos, pos+1) }
+			case "[200~": p := readPaste(); buf = slices.Insert(buf, pos, p...); pos += len(p)
+			}
+		default:
+			if r >= ' ' { buf = slices.Insert(buf, pos, r); pos++ }
+		}
+		redraw()
+	}
+}
+
+// step moves through history (older when up), remembering the unsent line as the draft.
+func (e *lineEditor) step(hist int, up bool, cur, draft string, set func(string)) (int, string) {
+	if hist == len(e.history) { draft = cur }
+	switch {
+	case up && hist > 0: hist--
+	case !up && hist < len(e.history): hist++
+	default: return hist, draft
+	}
+	if hist == len(e.history) { set(draft) } else { set(e.history[hist]) }
+	return hist, draft
+}
+
+// search runs an incremental reverse search; Enter or any editing key accepts the match,
+// Ctrl-G or Escape gives up.
+func (e *lineEditor) search() (string, bool) {
+	var q []rune; from, match := len(e.history), ""
+	find := func(start int) {
+		for i := start - 1; i >= 0; i-- { if strings.Contains(e.history[i], string(q)) { from, match = i, e.history[i]; return } }
+	}
+	for {
+		fmt.Fprintf(os.Stderr, "\r(reverse-i-search)`%s': %s\x1b[K", string(q), match)
+		r, err := keys.next(); if err != nil { return "", false }
+		switch {
+		case r == 18: find(from)
+		case r == 7 || r == 27 || r == 3: return "", false
+		case r == 127 || r == 8: if len(q) > 0 { q = q[:len(q)-1]; from, match = len(e.history), ""; find(from) }
+		case r >= ' ': q = append(q, r); from = min(from+1, len(e.history)); find(from)
+		default: return match, match != ""
+		}
+	}
+}
+
+// readPaste collects bracketed-paste text up to the closing ESC[201~, with newlines as \n.
+func readPaste() []rune {
+	var out []rune; cr := false
+	for {
+		r, err := keys.next(); if err != nil { return out }
+		switch {
+		case r == 27: if keys.escape() == "[201~" { return out }
+		case r == '\n' && cr:
+		case r == '\r' || r == '\n': out = append(out, '\n')
+		case r == '\t' || r >= ' ': out = append(out, r)
+		}
+		cr = r == '\r'
+	}
+}
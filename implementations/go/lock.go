@@ -0,0 +1,112 @@
+// Locking between nano processes, and between runs in one process, that share a project or the
+// data directory. Session files and the usage ledger are written under an advisory lock on a
+// sibling .lock file (flock on Unix, LockFileEx on Windows); file tools hold a per-path mutex
+// from reading a file to writing it; and every run holds its project's run lock, so a second
+// instance sees the first one's PID and prompt and asks before going on (or refuses without a
+// terminal) unless --no-lock. The OS drops a dead process's locks, so a crash leaves nothing
+// to clean up; where file locking isn't available the recorded PID is checked instead.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errLocked    = errors.New("locked by another process")
+	errNoLocking = errors.New("file locking is not supported on this platform")
+)
+
+const lockWait = 5 * time.Second
+
+// lockFile takes the lock on path, retrying for up to wait; the returned func releases it.
+func lockFile(path string, wait time.Duration) (func(), error) {
+	os.MkdirAll(filepath.Dir(path), 0700)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600); if err != nil { return func() {}, err }
+	for give := time.Now().Add(wait); ; time.Sleep(20 * time.Millisecond) {
+		err := lockFD(f)
+		if err == nil { return func() { f.Close() }, nil } // closing releases the lock
+		if !errors.Is(err, errLocked) || time.Now().After(give) { f.Close(); return func() {}, err }
+	}
+}
+
+// withLock runs fn holding the lock on path+".lock". If the lock can't be had in time fn runs
+// anyway, after a warning: a write racing another is better than a write lost.
+func withLock(path string, fn func() error) error {
+	unlock, err := lockFile(path+".lock", lockWait); defer unlock()
+	if err != nil && !errors.Is(err, errNoLocking) { slog.Warn("writing without the lock", "path", path, "err", err) }
+	return fn()
+}
+
+var pathLocks = struct { sync.Mutex; m map[string]*sync.Mutex }{m: map[string]*sync.Mutex{}}
+
+// lockPath serializes the file tools' read-check-write on one path; defer the returned func.
+func lockPath(path string) func() {
+	key := cacheKey(path)
+	pathLocks.Lock(); mu := pathLocks.m[key]; if mu == nil { mu = &sync.Mutex{}; pathLocks.m[key] = mu }; pathLocks.Unlock()
+	mu.Lock(); return mu.Unlock
+}
+
+// runLock is what the holder of a project's run lock records about itself.
+type runLock struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Prompt  string    `json:"prompt"`
+	Dir     string    `json:"dir"`
+	Started time.Time `json:"started"`
+}
+
+var heldRunLock *os.File // open for the life of the process; the OS releases it at exit
+
+// runLockPath is per project (the git top level) and lives in the data directory, not the tree.
+func runLockPath() string {
+	sum := sha256.Sum256([]byte(project()))
+	return filepath.Join(dataDir(), "locks", hex.EncodeToString(sum[:8])+".lock")
+}
+
+// acquireRunLock takes the project's run lock; false means another instance holds it and the
+// user (or the lack of one) said not to go on.
+func acquireRunLock(prompt string) bool {
+	path := runLockPath(); os.MkdirAll(filepath.Dir(path), 0700)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600); if err != nil { slog.Warn("run lock unavailable", "err", err); return true }
+	lerr := lockFD(f)
+	var other runLock; data, _ := io.ReadAll(f); json.Unmarshal(data, &other)
+	host, _ := os.Hostname()
+	held := errors.Is(lerr, errLocked) || errors.Is(lerr, errNoLocking) && other.PID != 0 && other.PID != os.Getpid() && other.Host == host && processAlive(other.PID)
+	if held { f.Close(); return sharedRunOK(other) }
+	if lerr != nil && !errors.Is(lerr, errNoLocking) { slog.Warn("run lock unavailable", "err", lerr); f.Close(); return true }
+	if other.PID != 0 && other.PID != os.Getpid() { slog.Info("reclaimed a stale run lock", "pid", other.PID, "started", other.Started) }
+	mine, _ := json.Marshal(runLock{PID: os.Getpid(), Host: host, Prompt: truncate(prompt, 200), Dir: workDir(), Started: time.Now()})
+	f.Truncate(0); f.WriteAt(mine, 0)
+	heldRunLock = f
+	return true
+}
+
+// releaseRunLock clears the record on the way out; exit calls it.
+func releaseRunLock() {
+	if heldRunLock == nil { return }
+	heldRunLock.Truncate(0); heldRunLock.Close(); heldRunLock = nil
+}
+
+func sharedRunOK(other runLock) bool {
+	what := "(details unavailable)"
+	if other.PID != 0 {
+		prompt := other.Prompt; if prompt == "" { prompt = "interactive session" }
+		what = fmt.Sprintf("pid %d, started %s ago in %s: %q", other.PID, time.Since(other.Started).Round(time.Second), relPath(other.Dir), prompt)
+	}
+	fmt.Fprintln(os.Stderr, "⚠ another nano is running in this project ("+what+"); its writes and yours may collide")
+	if !isTTY(os.Stdin) { fmt.Fprintln(os.Stderr, "Error: not starting a second run; pass --no-lock to run anyway"); return false }
+	fmt.Fprint(os.Stderr, "Run anyway? [y/N] ")
+	answer := strings.ToLower(strings.TrimSpace(readAnswer())); return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var procLockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+// lockFD locks one byte far past the end of f with LockFileEx without waiting; Windows locks
+// are mandatory, so locking the contents would stop others from reading who holds the lock.
+func lockFD(f *os.File) error {
+	ol := syscall.Overlapped{OffsetHigh: 0x7fffffff}
+	r, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(&ol)))
+	if r != 0 { return nil }
+	if errors.Is(err, errorLockViolation) { return errLocked }
+	return err
+}
@@ -0,0 +1,35 @@
+// Diagnostic logging via log/slog, kept apart from the human-facing terminal output (tool
+// lines, answers). Logs go to stderr as text at warn and above unless --log-level/--log-file
+// say otherwise; the file handler writes JSON. API keys are redacted from every record.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logSecrets are values scrubbed from every log record (the API key and friends); see scrubSecrets.
+var logSecrets []string
+
+func setupLogging(level, file string) error {
+	var lv slog.Level
+	if err := lv.UnmarshalText([]byte(level)); err != nil { return fmt.Errorf("--log-level: %w", err) }
+	for _, k := range []string{"ANTHROPIC_API_KEY", "ANTHROPIC_AUTH_TOKEN"} { if v := os.Getenv(k); v != "" { logSecrets = append(logSecrets, v) } }
+	opts := &slog.HandlerOptions{Level: lv, ReplaceAttr: redactAttr}
+	var w io.Writer = os.Stderr
+	if file == "" { slog.SetDefault(slog.New(slog.NewTextHandler(w, opts))); return nil }
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); if err != nil { return fmt.Errorf("--log-file: %w", err) }
+	slog.SetDefault(slog.New(slog.NewJSONHandler(f, opts))); return nil
+}
+
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	switch strings.ToLower(a.Key) { case "api_key", "x-api-key", "authorization": return slog.String(a.Key, "[REDACTED]") }
+	if k := a.Value.Kind(); k != slog.KindString && k != slog.KindAny { return a }
+	s, n := scrubSecrets(a.Value.String())
+	if n == 0 { return a }
+	return slog.String(a.Key, s)
+}
@@ -0,0 +1,71 @@
+// Context meter. After every API call the agent emits a "usage" event with the turn's tokens,
+// how full the model's context window now is and the run's cost so far; on a terminal the CLI
+// shows it as one stderr line. Crossing 70% and then 90% of the window also raises a
+// notice suggesting /compact or narrower reads, once each until the history shrinks again.
+// Window sizes come from config "context_windows" (model pattern -> tokens), then a built-in
+// table, else 200k.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// ContextUsage is the payload of a "usage" event.
+type ContextUsage struct {
+	Turn    int      // API calls so far this session
+	Usage   Usage    // this call's tokens
+	Context int      // tokens the conversation now takes up
+	Window  int      // the model's context window
+	CostUSD *float64 // the run so far; nil when a model's price is unknown
+}
+
+func (c ContextUsage) Percent() float64 { return 100 * float64(c.Context) / float64(c.Window) }
+
+var builtinWindows = []struct{ model string; tokens int }{
+	{"claude-*", 200000},
+}
+
+var contextWarnings = []float64{70, 90}
+
+// windowFor is the context window of model, in tokens.
+func windowFor(model string) int {
+	for _, p := range sortedKeys(cfg.ContextWindows) { if ok, _ := path.Match(p, model); ok && cfg.ContextWindows[p] > 0 { return cfg.ContextWindows[p] } }
+	for _, w := range builtinWindows { if ok, _ := path.Match(w.model, model); ok { return w.tokens } }
+	if b := baseModel(model); b != model { return windowFor(b) }
+	return contextWindow
+}
+
+// meter reports one call's usage and warns the first time the context crosses a threshold.
+func (a *Agent) meter(u Usage) {
+	c := ContextUsage{Turn: a.Turns, Usage: u, Context: u.InputTokens + u.CacheRead + u.CacheCreation + u.OutputTokens, Window: windowFor(a.Model)}
+	if cost, ok := a.runCost(); ok { c.CostUSD = &cost }
+	a.emit(Event{Kind: "usage", Name: a.Model, Phase: phaseMain, Context: &c})
+	pct := c.Percent(); level := 0
+	for i, t := range contextWarnings { if pct >= t { level = i + 1 } }
+	if level > a.ctxWarned {
+		a.notify(fmt.Sprintf("⚠ context %.0f%% full (%s of %s tokens); consider /compact, or reading narrower parts of files", pct, kTokens(c.Context), kTokens(c.Window)))
+	}
+	a.ctxWarned = level // back down after compaction, so the next climb warns again
+}
+
+// showUsage draws the meter line, on a terminal only.
+func (a *Agent) showUsage(e Event) {
+	if !isTTY(os.Stderr) || quiet { return }
+	c := e.Context
+	s := fmt.Sprintf("turn %d · in %s/out %s tok · ctx %.0f%%", c.Turn, kTokens(c.Usage.InputTokens+c.Usage.CacheRead+c.Usage.CacheCreation), kTokens(c.Usage.OutputTokens), c.Percent())
+	if c.CostUSD != nil { s += fmt.Sprintf(" · $%.2f total", *c.CostUSD) }
+	fmt.Fprintln(os.Stderr, s)
+}
+
+// kTokens writes a token count as 850, 12.4k or 1.2M.
+func kTokens(n int) string {
+	switch {
+	case n >= 1_000_000: return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e6), ".0") + "M"
+	case n >= 1000: return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(n)/1e3), ".0") + "k"
+	}
+	return fmt.Sprint(n)
+}
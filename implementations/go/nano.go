@@ -1,85 +1,498 @@
-// nano-opencode: Minimal AI coding agent in Go (~110 LOC)
-// Usage: ANTHROPIC_API_KEY=sk-... go run nano.go "your prompt"
-// Build: go build -o nano nano.go
+// nano-opencode: Minimal AI coding agent in Go
+// Usage: ANTHROPIC_API_KEY=sk-... go run . "your prompt"
+//        go run . fix -- go test ./...    (loop until the command passes)
+// Build: go build -o nano .
 
 package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
-	"os/exec"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 )
 
-var tools = json.RawMessage(`[
-  {"name":"read_file","description":"Read file","input_schema":{"type":"object","properties":{"path":{"type":"string"}},"required":["path"]}},
-  {"name":"write_file","description":"Write file","input_schema":{"type":"object","properties":{"path":{"type":"string"},"content":{"type":"string"}},"required":["path","content"]}},
-  {"name":"edit_file","description":"Edit file","input_schema":{"type":"object","properties":{"path":{"type":"string"},"old_string":{"type":"string"},"new_string":{"type":"string"}},"required":["path","old_string","new_string"]}},
-  {"name":"bash","description":"Run command","input_schema":{"type":"object","properties":{"command":{"type":"string"}},"required":["command"]}},
-  {"name":"list_dir","description":"List directory","input_schema":{"type":"object","properties":{"path":{"type":"string"}},"required":["path"]}}
-]`)
-
 type Message struct{ Role string `json:"role"`; Content any `json:"content"` }
-type Block struct{ Type string `json:"type"`; ID string `json:"id,omitempty"`; Name string `json:"name,omitempty"`; Input map[string]string `json:"input,omitempty"`; Text string `json:"text,omitempty"` }
-type Response struct{ Content []Block `json:"content"`; StopReason string `json:"stop_reason"` }
-
-func run(name string, input map[string]string) string {
-	switch name {
-	case "read_file":
-		data, err := os.ReadFile(input["path"]); if err != nil { return "Error: " + err.Error() }; return string(data)
-	case "write_file":
-		if err := os.WriteFile(input["path"], []byte(input["content"]), 0644); err != nil { return "Error: " + err.Error() }; return "OK"
-	case "edit_file":
-		data, err := os.ReadFile(input["path"]); if err != nil { return "Error: " + err.Error() }
-		if !strings.Contains(string(data), input["old_string"]) { return "old_string not found" }
-		return func() string { os.WriteFile(input["path"], []byte(strings.Replace(string(data), input["old_string"], input["new_string"], 1)), 0644); return "OK" }()
-	case "bash":
-		out, _ := exec.Command("sh", "-c", input["command"]).Output(); if len(out) > 50000 { out = out[:50000] }; return string(out)
-	case "list_dir":
-		entries, err := os.ReadDir(func() string { if p := input["path"]; p != "" { return p }; return "." }()); if err != nil { return "Error: " + err.Error() }
-		var lines []string; for _, e := range entries { t := "-"; if e.IsDir() { t = "d" }; lines = append(lines, t+" "+e.Name()) }; return strings.Join(lines, "\n")
+type Block struct{ Type string `json:"type"`; ID string `json:"id,omitempty"`; Name string `json:"name,omitempty"`; Input json.RawMessage `json:"input,omitempty"`; Text string `json:"text,omitempty"`; Source *MediaSource `json:"source,omitempty"`; inputErr error }
+
+// MediaSource is the base64 payload of an image or document block (tool results use these).
+type MediaSource struct{ Type string `json:"type"`; MediaType string `json:"media_type"`; Data string `json:"data"` }
+type Response struct{ Content []Block `json:"content"`; StopReason string `json:"stop_reason"`; Usage Usage `json:"usage"` }
+type Usage struct{ InputTokens int `json:"input_tokens"`; OutputTokens int `json:"output_tokens"`; CacheCreation int `json:"cache_creation_input_tokens,omitempty"`; CacheRead int `json:"cache_read_input_tokens,omitempty"`; Cost float64 `json:"cost,omitempty"` } // Cost: USD, when a gateway reports it
+
+func (u *Usage) add(v Usage) { u.InputTokens += v.InputTokens; u.OutputTokens += v.OutputTokens; u.CacheCreation += v.CacheCreation; u.CacheRead += v.CacheRead; u.Cost += v.Cost }
+
+func (u Usage) minus(v Usage) Usage { return Usage{u.InputTokens - v.InputTokens, u.OutputTokens - v.OutputTokens, u.CacheCreation - v.CacheCreation, u.CacheRead - v.CacheRead, u.Cost - v.Cost} }
+
+// ui receives progress lines (tool calls and their output); --output json moves it to stderr.
+var ui io.Writer = os.Stdout
+
+// Agent holds one conversation; Send appends a user turn and loops until the model stops calling tools.
+type Agent struct{ URL, Key, Model, System, Session, ToolChoice, Persona string; Tools []Tool; Messages []Message; Usage Usage; Turns int; badInputs int; rec *recording; span span; listeners []func(Event); counts tokenCounts; Params genParams; Version string; Betas []string; batch bool; seenDirs map[string]bool; instructions []instruction; pending func() []string; exchanges []exchange; parent, title string; touched []string; originals map[string]fileBackup; prior struct{ Usage Usage; CostUSD *float64 }; deadline time.Time; journal *journal; esc escalator; ci *ciRun; suspicious string; byModel map[string]Usage; repomap *repoMap; approver Approver; middleware []ToolMiddleware; dryRuns map[string]bool; ctxWarned int; keySource string; pinned int; gwCost float64; cm ContextManager; trimmed trimStats; stream, previewing bool; prefix string; gate gate; phases []phaseUsage; progress progressLog; out outputBudget }
+
+const systemPrompt = "You are a coding assistant. Use tools to help."
+const noToolsPrompt = "You are a coding assistant. Answer directly from your own knowledge and the conversation; no tools are involved in this chat."
+
+func (a *Agent) call() (*Response, error) {
+	msgs, _ := a.contextView()
+	req := map[string]any{"model": a.Model, "max_tokens": a.maxTokens(), "messages": msgs, "system": a.System + a.injectionRule() + a.finishRule() + a.outputRule() + a.repomap.prompt() + a.timeNote()}
+	if len(a.Tools) > 0 { req["tools"] = schemas(a.Tools) }
+	if a.stream && !a.batch { req["stream"] = true }
+	a.Params.apply(req); gw.apply(req)
+	// --tool-choice applies to the first successful request only; afterwards the model is free to finish.
+	if c := a.ToolChoice; c != "" {
+		if c == "auto" || c == "any" || c == "none" { req["tool_choice"] = map[string]any{"type": c} } else { req["tool_choice"] = map[string]any{"type": "tool", "name": c} }
+	}
+	body, _ := json.Marshal(req)
+	slog.Debug("api request", "model", a.Model, "messages", len(a.Messages), "tools", len(a.Tools), "bytes", len(body), "size", humanBytes(int64(len(body))), "headers", a.headers())
+	if err := checkRequestSize(body); err != nil { return nil, err }
+	start := time.Now()
+	sp := a.span.Child("anthropic.messages"); sp.Set("model", a.Model); sp.Set("request_bytes", len(body))
+	raw, err := a.post(body)
+	a.emit(Event{Kind: "api_call", Name: a.Model, Start: start, Duration: time.Since(start), Err: err})
+	if err != nil { if runCtx.Err() == nil { slog.Error("api call failed", "err", err, "duration", time.Since(start)) }; sp.End(err); return nil, err }
+	var res Response; if err := json.Unmarshal(raw, &res); err != nil { err = fmt.Errorf("decoding response: %w", err); sp.End(err); return nil, err }
+	if res.Usage.Cost == 0 { res.Usage.Cost = a.gwCost }
+	a.ToolChoice = ""; a.storeCount(msgs, res.Usage.InputTokens)
+	sp.Set("input_tokens", res.Usage.InputTokens); sp.Set("output_tokens", res.Usage.OutputTokens); sp.Set("stop_reason", res.StopReason); sp.End(nil)
+	attrs := map[string]any{"model": a.Model}
+	telemetry.Add("nano.tokens.input", float64(res.Usage.InputTokens), attrs); telemetry.Add("nano.tokens.output", float64(res.Usage.OutputTokens), attrs)
+	if c, ok := a.cost(res.Usage); ok { telemetry.Add("nano.cost.usd", c, attrs) }
+	slog.Debug("api response", "stop_reason", res.StopReason, "input_tokens", res.Usage.InputTokens, "output_tokens", res.Usage.OutputTokens, "bytes", len(raw), "duration", time.Since(start))
+	a.Turns++; a.addUsage(phaseMain, res.Usage); a.meter(res.Usage); return &res, nil
+}
+
+// post sends one request body, going through the recording when --record/--replay is active.
+func (a *Agent) post(body []byte) ([]byte, error) {
+	if a.rec != nil && a.rec.replay { return a.rec.next(body) }
+	if a.Key == "" { return nil, errNoKey }
+	var raw []byte; var err error
+	switch {
+	case a.batch: raw, err = a.postBatch(body)
+	case a.stream: raw, err = a.doStream(body)
+	default: raw, err = a.do("POST", a.URL, body)
 	}
-	return "Unknown tool"
+	if err != nil { return nil, err }
+	a.rec.add(body, raw, a.Messages); return raw, nil
+}
+
+// do sends one API request and returns the body of a 200 response.
+func (a *Agent) do(method, url string, body []byte) ([]byte, error) {
+	resp, err := http.DefaultClient.Do(a.newRequest(method, url, body)); if err != nil { return nil, err }; defer resp.Body.Close()
+	a.gwCost = gw.responseCost(resp.Header)
+	return a.readResponse(resp)
 }
 
-func call(url, key string, messages []Message, model string) (*Response, error) {
-	body, _ := json.Marshal(map[string]any{"model": model, "max_tokens": 8192, "tools": tools, "messages": messages, "system": "You are a coding assistant. Use tools to help."})
-	req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json"); req.Header.Set("x-api-key", key); req.Header.Set("anthropic-version", "2023-06-01")
-	resp, err := http.DefaultClient.Do(req); if err != nil { return nil, err }; defer resp.Body.Close()
-	if resp.StatusCode != 200 { b, _ := io.ReadAll(resp.Body); return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, b) }
-	var res Response; json.NewDecoder(resp.Body).Decode(&res); return &res, nil
+// readResponse returns the body of a 200 response, or the APIError of any other.
+func (a *Agent) readResponse(resp *http.Response) ([]byte, error) {
+	limit := maxResponseBytes(); if resp.StatusCode != 200 && limit > maxErrorBody { limit = maxErrorBody }
+	raw, cut, err := readBody(resp.Body, limit); if err != nil { return nil, err }
+	if resp.StatusCode != 200 { e := newAPIError(resp.StatusCode, raw); if cut { e.Truncated = limit }; return nil, e }
+	if cut { return nil, fmt.Errorf("response body truncated at %d bytes (config max_response_bytes)", limit) }
+	return raw, nil
 }
 
-func agent(prompt, url, key, model string) (string, error) {
-	messages := []Message{{Role: "user", Content: prompt}}
+// headers are the Anthropic-specific request headers, minus the key.
+func (a *Agent) headers() map[string]string {
+	h := map[string]string{"anthropic-version": a.Version}
+	if len(a.Betas) > 0 { h["anthropic-beta"] = strings.Join(a.Betas, ",") }
+	gw.headers(h); return h
+}
+
+func (a *Agent) newRequest(method, url string, body []byte) *http.Request {
+	req, _ := http.NewRequestWithContext(runCtx, method, url, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json"); gw.setAuth(req, a.Key); req.Header.Set("User-Agent", userAgent())
+	for k, v := range a.headers() { req.Header.Set(k, v) }
+	return req
+}
+
+// modelAliases are the short names --model and /model accept for the current models.
+var modelAliases = map[string]string{"opus": "claude-opus-4-5", "sonnet": "claude-sonnet-4-5", "haiku": "claude-haiku-4-5"}
+
+func resolveModel(name string) string {
+	if gw != nil { return gw.resolveModel(name) }
+	if m, ok := modelAliases[name]; ok { return m }; return name
+}
+
+// newAgent is loadAgent plus the --offline startup check, so every command that talks to the
+// model refuses a remote API before its first request.
+func newAgent() (*Agent, error) {
+	a, err := loadAgent(); if err != nil { return nil, err }
+	if offline { if err := checkOffline(a.URL); err != nil { return nil, err } }
+	return a, nil
+}
+
+// loadAgent builds the Agent the config, gateway and environment describe. doctor uses it
+// directly, to report a remote API under --offline as one of its checks.
+func loadAgent() (*Agent, error) {
+	tools, err := selectTools(cfg.Tools, cfg.DisableTools); if err != nil { return nil, fmt.Errorf("config: %w", err) }
+	if gw, err = selectGateway(); err != nil { return nil, err }
+	if err := loadProjectEnv(); err != nil { return nil, err }
+	key, source, err := resolveKey(); if err != nil { return nil, err }
+	base, model := "https://api.anthropic.com", "claude-sonnet-4-20250514"
+	if gw != nil { base, model = gw.baseURL, orDefault(gw.model, model) }
+	base = strings.TrimSuffix(env("ANTHROPIC_BASE_URL", base), "/")
+	params := genParams{Temperature: cfg.Temperature, TopP: cfg.TopP, Stop: cfg.Stop}; if err := params.validate(); err != nil { return nil, fmt.Errorf("config: %w", err) }
+	if err := validatePricing(cfg.Pricing); err != nil { return nil, fmt.Errorf("config: %w", err) }
+	a := &Agent{URL: base + "/v1/messages", Key: key, Model: env("MODEL", model), System: systemPrompt, Session: newSessionID(), Tools: tools, span: noopSpan{}, Params: params, Version: env("NANO_ANTHROPIC_VERSION", "2023-06-01"), Betas: splitList(os.Getenv("NANO_ANTHROPIC_BETAS"))}
+	runMeta = func() (string, string, int) { return a.Session, a.Model, a.Turns }
+	a.approver, a.keySource, a.prefix, a.cm = a.terminalApprover, source, cfg.PromptPrefix, ContextManager{Budget: cfg.Context.Budget, KeepTurns: cfg.Context.KeepTurns}
+	a.progress.every = progressEvery()
+	return a, nil
+}
+
+// maxPauses bounds how often one turn is resumed after stop_reason "pause_turn".
+const maxPauses = 5
+
+// errRefused is returned when the model stops with stop_reason "refusal"; nano exits 3 on it.
+var errRefused = errors.New("the model refused to continue")
+
+const exitRefused = 3
+
+// Send runs one user turn and returns only the final text; see Run for the whole story.
+func (a *Agent) Send(prompt string) (string, error) { r, err := a.Run(prompt); return r.Text, err }
+
+// Run sends prompt and drives the tool loop until the model is done. The Result is never nil
+// and is complete even when err isn't: the turns so far, files changed, usage and history.
+func (a *Agent) Run(prompt string) (*Result, error) {
+	a.exchanges = append(a.exchanges, exchange{start: len(a.Messages), instr: len(a.instructions), prompt: prompt})
+	a.Messages = append(a.Messages, Message{Role: "user", Content: a.prefixed(prompt)}); a.badInputs = 0
+	r := a.newResult()
+	pauses := 0
 	for {
-		res, err := call(url, key, messages, model); if err != nil { return "", err }
-		messages = append(messages, Message{Role: "assistant", Content: res.Content})
-		if res.StopReason != "tool_use" {
-			var texts []string; for _, b := range res.Content { if b.Type == "text" { texts = append(texts, b.Text) } }; return strings.Join(texts, ""), nil
+		a.journalSync()
+		start := time.Now()
+		res, err := a.request(); if err != nil { return r.finish(a, "", err) }
+		res = a.retryCutOff(res)
+		for i := range res.Content { if res.Content[i].Type == "tool_use" { res.Content[i].normalizeInput() } }
+		var dropped []string; res.Content, dropped = sanitizeToolUses(res.Content); markTruncated(res)
+		a.Messages = append(a.Messages, Message{Role: "assistant", Content: res.Content}); a.journalSync()
+		turn := TurnRecord{StopReason: res.StopReason, Text: responseText(res.Content), Usage: res.Usage, DurationMS: time.Since(start).Milliseconds()}
+		r.Turns = append(r.Turns, turn)
+		switch res.StopReason {
+		case "pause_turn": // a long server-side turn: send it back as is and the model picks up where it left off
+			if pauses++; pauses > maxPauses { return r.finish(a, "", limitError(fmt.Sprintf("the model paused its turn %d times without finishing", maxPauses))) }
+			slog.Info("turn paused; continuing", "continuation", pauses); continue
+		case "refusal":
+			// The refused turn is dropped so a later message isn't answered in its shadow.
+			a.Messages = a.Messages[:len(a.Messages)-1]; a.rec.flush(a.Messages)
+			if text := strings.TrimSpace(responseText(res.Content)); text != "" { return r.finish(a, text, fmt.Errorf("%w: %s", errRefused, text)) }
+			return r.finish(a, "", errRefused)
+		case "end_turn", "tool_use", "max_tokens", "stop_sequence":
+		default: slog.Warn("unrecognized stop_reason; treating it as the end of the turn", "stop_reason", res.StopReason)
 		}
-		var results []map[string]any
+		calls := slices.ContainsFunc(res.Content, func(b Block) bool { return b.Type == "tool_use" }) || len(dropped) > 0
+		cutOff := res.StopReason == "max_tokens" && calls // answered with advice to split the call
+		finishing := slices.ContainsFunc(res.Content, func(b Block) bool { return b.Type == "tool_use" && b.Name == "finish" })
+		if res.StopReason != "tool_use" && !cutOff && !finishing || len(a.Tools) == 0 || !calls {
+			if n := a.takeNudges(); len(n) > 0 { a.Messages = append(a.Messages, Message{Role: "user", Content: n}); continue }
+			a.rec.flush(a.Messages); return r.finish(a, turn.Text, nil)
+		}
+		var results, notes []map[string]any; var reported *finishReport; failed := batchPaths{}
+		for _, d := range dropped { notes = append(notes, map[string]any{"type": "text", "text": d}) }
 		for _, b := range res.Content {
-			if b.Type == "tool_use" { fmt.Println("⚡", b.Name); r := run(b.Name, b.Input); fmt.Println(r[:min(len(r), 100)]); results = append(results, map[string]any{"type": "tool_result", "tool_use_id": b.ID, "content": r}) }
+			if b.Type != "tool_use" { continue }
+			in, _ := decodeInput(b.Input); detail := describeCall(in); began := time.Now()
+			a.emit(Event{Kind: "tool_start", Name: b.Name, Detail: detail, Start: began})
+			path, changes := a.target(b, in)
+			out, blocks, isErr := failed.blocked(path, changes), []Block(nil), true
+			if out == "" { out, blocks, isErr = a.execTool(b) }
+			if b.Name == "bash" { a.progress.trackCommand(in.Str("command"), out, isErr) }
+			failed.record(path, changes, b.Name, len(results)+1, isErr)
+			a.emit(Event{Kind: "tool_result", Name: b.Name, Detail: detail, Text: out, IsError: isErr, Start: began, Duration: time.Since(began)})
+			r.addTool(b.Name, detail, out, isErr, time.Since(began))
+			if b.Name == "finish" && !isErr { f, _ := finishInput(in); reported = &f }
+			result := map[string]any{"type": "tool_result", "tool_use_id": b.ID, "content": out}; if isErr { result["is_error"] = true }
+			if blocks != nil { result["content"] = blocks }
+			results = append(results, result); a.journalResult(result)
+			if note := a.noteToolResult(b.Name, out, isErr); note != "" { notes = append(notes, map[string]any{"type": "text", "text": note}) }
+			for _, t := range a.discoverInstructions(b) { notes = append(notes, map[string]any{"type": "text", "text": t}) }
+		}
+		if note := a.progressNote(); note != "" { notes = append(notes, map[string]any{"type": "text", "text": note}) }
+		if a.pending != nil {
+			for _, m := range a.pending() { a.notify("↪ sending queued message: " + m); notes = append(notes, map[string]any{"type": "text", "text": m}) }
 		}
-		messages = append(messages, Message{Role: "user", Content: results})
+		nudged := a.takeNudges(); notes = append(notes, nudged...)
+		a.Messages = append(a.Messages, Message{Role: "user", Content: append(results, notes...)})
+		if reported != nil && len(nudged) == 0 { a.rec.flush(a.Messages); r.Outcome, r.FollowUp = reported.Status, reported.FollowUp; return r.finish(a, reported.Summary, nil) }
+		if a.badInputs > maxBadInputs { return r.finish(a, "", limitError(fmt.Sprintf("giving up: the model sent %d tool calls with unparseable input this turn", a.badInputs))) }
 	}
 }
 
+func responseText(content []Block) string {
+	var texts []string; for _, b := range content { if b.Type == "text" { texts = append(texts, b.Text) } }; return strings.Join(texts, "")
+}
+
+// report is the --output json document describing a finished run.
+type report struct {
+	Step         int              `json:"step,omitempty"`     // with --then
+	Prompt       string           `json:"prompt,omitempty"`   // with --then
+	Result       string           `json:"result"`
+	Status       string           `json:"status"`
+	Outcome      string           `json:"outcome,omitempty"`  // reported with the finish tool, or "unreported"
+	FollowUp     []string         `json:"follow_up_items,omitempty"`
+	Persona      string           `json:"persona,omitempty"`
+	Error        string           `json:"error,omitempty"`
+	ErrorType    string           `json:"error_type,omitempty"` // the API error's type, as in apiHints
+	Hint         string           `json:"hint,omitempty"`
+	Turns        int              `json:"turns"`
+	Usage        Usage            `json:"usage"`
+	CostUSD      *float64         `json:"cost_usd"`
+	Steps        []TurnRecord     `json:"steps,omitempty"`    // each model turn and its tool calls
+	FilesChanged []string         `json:"files_changed,omitempty"`
+	ByModel      map[string]Usage `json:"by_model,omitempty"` // when the run escalated
+	Phases       []phaseUsage     `json:"phases,omitempty"`
+	Escalation   *escalation      `json:"escalation,omitempty"`
+	Verify       *verification    `json:"verification,omitempty"`
+	Check        *gateRun         `json:"verify_command,omitempty"`
+	Diff         string           `json:"diff,omitempty"`           // with --show-diff-on-exit
+	AnswerFile   string           `json:"answer_file,omitempty"`  // where the result was written
+	AnswerBytes  int              `json:"-"`
+	DurationMS   int64            `json:"duration_ms"`
+	Timing       any              `json:"timing,omitempty"`
+}
+
 func env(key, def string) string { if v := os.Getenv(key); v != "" { return v }; return def }
 
+// atExit, when set, runs just before exit; main uses it to record the run in the usage ledger.
+var atExit func(code int)
+
+// exit flushes telemetry before leaving; use it instead of os.Exit once main is running.
+func exit(code int) { if atExit != nil { atExit(code) }; releaseRunLock(); telemetry.Shutdown(); os.Exit(code) }
+
 func main() {
-	if len(os.Args) < 2 { fmt.Fprintln(os.Stderr, "Usage: nano \"your prompt\""); os.Exit(1) }
-	key := env("ANTHROPIC_API_KEY", env("ANTHROPIC_AUTH_TOKEN", "")); if key == "" { fmt.Fprintln(os.Stderr, "Set ANTHROPIC_API_KEY or ANTHROPIC_AUTH_TOKEN"); os.Exit(1) }
-	base := strings.TrimSuffix(env("ANTHROPIC_BASE_URL", "https://api.anthropic.com"), "/")
-	result, err := agent(strings.Join(os.Args[1:], " "), base+"/v1/messages", key, env("MODEL", "claude-sonnet-4-20250514"))
-	if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(1) }
-	fmt.Println(result)
+	telemetry = newTracer()
+	if err := setupLogging(env("NANO_LOG_LEVEL", "warn"), env("NANO_LOG_FILE", "")); err != nil { fmt.Fprintln(os.Stderr, err); os.Exit(1) }
+	var err error
+	var dir string
+	if d, rest := leadingDir(os.Args[1:]); d != "" {
+		if err := changeDir(d); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(2) }
+		dir, os.Args = d, append(os.Args[:1], rest...)
+	}
+	if cfg, err = loadConfig(); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(1) }
+	flag.StringVar(&dir, "C", dir, "run in `dir` as if started there: project config, instructions, sessions and tools use it")
+	flag.StringVar(&dir, "dir", dir, "same as -C")
+	record := flag.String("record", "", "save every raw API exchange and tool result to `file`")
+	replay := flag.String("replay", "", "run against the canned responses in `file` instead of the network")
+	liveTools := flag.Bool("replay-live-tools", false, "with --replay, execute tools instead of reusing recorded results")
+	output := flag.String("output", "text", "result format: text or json (json moves progress to stderr)")
+	answerFile := flag.String("answer-file", "", "write the final answer to `path` (replacing it) instead of stdout")
+	flag.BoolVar(&quiet, "quiet", false, "print only the result and errors: no progress lines or run summary")
+	sandbox := flag.String("sandbox", "", "confine file tools to `dir` and run commands there")
+	logLevel := flag.String("log-level", env("NANO_LOG_LEVEL", "warn"), "diagnostic log level: debug, info, warn or error")
+	logFile := flag.String("log-file", env("NANO_LOG_FILE", ""), "append JSON diagnostic logs to `file` instead of stderr")
+	noLock := flag.Bool("no-lock", false, "run even if another nano is running in this project (see the run lock warning)")
+	flag.BoolVar(&noIgnore, "no-ignore", false, "let file tools see paths matched by .gitignore, .nanoignore and the built-in ignores")
+	inherit := flag.Bool("inherit-env", false, "run bash commands in the full environment instead of a minimal one (see config \"bash_env\")")
+	flag.BoolVar(&autoApprove, "yes", false, "approve every write and command without asking")
+	flag.StringVar(&apiKeyFlag, "api-key", "", "API `key` to use, ahead of $NANO_API_KEY_CMD, the keychain and $ANTHROPIC_API_KEY (visible in process listings)")
+	flag.StringVar(&auditPath, "audit-log", auditPath, "append the side-effect audit trail to `file`")
+	enable, disable := toolFlags(flag.CommandLine)
+	ci := flag.Bool("ci", false, "GitHub Actions output: grouped tool output, error annotations, a job summary in $GITHUB_STEP_SUMMARY; declines writes and commands unless --yes")
+	filter := flag.Bool("filter", false, "transform stdin to stdout: the prompt is the instruction, only the answer is printed, tools are off unless --tools")
+	noTools := flag.Bool("no-tools", false, "plain chat: send no tools and answer in a single reply")
+	toolChoice := flag.String("tool-choice", "", "first request's tool_choice: auto, any, none or a tool `name`")
+	plan := flag.Bool("plan", false, "draft a read-only plan and ask for approval before changing anything")
+	planOnly := flag.Bool("plan-only", false, "print the plan and exit without executing it")
+	params := paramFlags(flag.CommandLine)
+	var betas []string
+	flag.Func("beta", "send anthropic-beta `name`; repeatable, added to NANO_ANTHROPIC_BETAS", func(v string) error { betas = append(betas, splitList(v)...); return nil })
+	version := flag.String("anthropic-version", "", "override the anthropic-version header (default NANO_ANTHROPIC_VERSION or 2023-06-01)")
+	verbose := flag.Bool("verbose", false, "log requests, headers and tool calls (same as --log-level debug)")
+	batch := flag.Bool("batch", false, "send each API call through the Message Batches API (half price, slow)")
+	maxOut := flag.Int("max-output-tokens", maxOutput, "max_tokens of replies to a prompt, which are mostly prose")
+	maxOutTools := flag.Int("max-output-tokens-tools", maxOutput, "max_tokens of replies to tool results, which carry most file writes")
+	progressEvery := flag.Int("progress-every", progressEvery(), "add a note of the files written and commands run to the context every `n` API calls; 0: never (default from $NANO_PROGRESS_EVERY or config \"progress_every\")")
+	flag.Bool("offline", os.Getenv("NANO_OFFLINE") == "1", "never reach beyond this machine: needs a local provider, drops the web tools, refuses non-loopback connections")
+	stream := flag.Bool("stream", os.Getenv("NANO_STREAM") == "1", "stream responses, showing long tool inputs (file writes, commands) as they are generated")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration and exit")
+	showVersion := flag.Bool("version", false, "print the version, commit and Go version and exit")
+	countOnly := flag.Bool("count-only", false, "print the input tokens of the would-be request and exit")
+	model := flag.String("model", "", "model to use, or an alias: opus, sonnet, haiku (default $MODEL or claude-sonnet-4-20250514)")
+	var tmpl string
+	flag.StringVar(&tmpl, "t", "", "run prompt template `name` (see nano prompts); extra arguments are appended")
+	flag.StringVar(&tmpl, "template", "", "same as -t")
+	vars := varFlag(flag.CommandLine)
+	resume := flag.String("resume", "", "continue saved session `id` (see nano sessions)")
+	history := flag.String("history", "", "start from the seed messages in `file` (a JSON array of messages), kept through compaction")
+	interactive := flag.Bool("interactive", false, "start an interactive session even when stdin isn't a terminal")
+	escalateTo := flag.String("escalate-model", "", "switch the rest of the run to `model` when it stalls (see --escalate-after-errors)")
+	escalateErrors := flag.Int("escalate-after-errors", 3, "with --escalate-model, escalate after `n` identical tool errors in a row")
+	verify := flag.Bool("verify", false, "when the model is done, have it review the run's diff and fix the problems it finds")
+	verifyRounds := flag.Int("verify-rounds", 2, "with --verify, review at most `n` times")
+	verifyAttempts := flag.Int("max-verify-attempts", 3, "turns the model gets to make config \"verify_command\" pass before the run fails")
+	var withDiff diffMode
+	flag.Var(&withDiff, "with-diff", "append the git diff to the prompt; =staged for the index, =branch for everything since the default branch")
+	diffTokens := flag.Int("diff-tokens", 8000, "with --with-diff, the diff's budget in `tokens`; past it only per-file stats are sent")
+	flag.IntVar(&keepRuns, "keep-runs", keepRuns, "keep the artifacts of the newest `n` runs in .nano/runs (0 keeps all)")
+	var thens []string
+	flag.Func("then", "after the prompt finishes, send `prompt` as the next turn in the same session; repeatable", func(v string) error { thens = append(thens, v); return nil })
+	keepGoing := flag.Bool("continue-on-error", false, "with --then, run the remaining prompts after one fails")
+	noPrefix := flag.Bool("no-prefix", false, "don't put config \"prompt_prefix\" before the first prompt")
+	changesFile := flag.String("changes-file", "", "at exit, write the files the run changed, with hashes and diffs, to `path` as JSON (see changes.go)")
+	changesNoDiff := flag.Bool("changes-no-diff", false, "leave the diffs out of --changes-file")
+	showDiff := flag.Bool("show-diff-on-exit", false, "print the net diff of every file the run wrote to stderr before exiting")
+	repomap := flag.Bool("repomap", false, "put a map of the repository's files and top-level symbols in the system prompt (cached in .nano/cache)")
+	repomapTokens := flag.Int("repomap-tokens", 2048, "with --repomap, the map's size budget in `tokens`")
+	deadline := flag.Duration("deadline", 0, "finish within `duration` (e.g. 10m): cancel, summarize, save the session and exit 124 when it runs out")
+	personaName := flag.String("persona", "", "preset of prompt, permissions and tools: reviewer, tester, refactorer, docs or one from config \"personas\"")
+	flag.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano [flags] \"your prompt\" | nano fix -- command | nano watch --glob pattern \"prompt\" | nano eval suite.yaml | nano foreach --dirs glob \"prompt\" | nano serve --base dir --repo url | nano sessions | nano fork id | nano diff-sessions a b | nano export id | nano usage | nano pricing | nano doctor | nano permissions | nano auth set|get|delete | nano artifacts [session] | nano tokens \"prompt\" | nano (interactive) | nano -t template --var k=v | nano prompts | nano completion bash|zsh|fish"); flag.PrintDefaults() }
+	// Subcommands are dispatched only now, so `nano __complete` can list the flags above, and
+	// after going offline, so every one of them is.
+	if offlineRequested(os.Args[1:]) { goOffline() }
+	if len(os.Args) > 1 { if c, ok := findCommand(os.Args[1]); ok { exit(c.start(offlineArgs(c, os.Args[2:]))) } }
+	flag.Parse()
+	if *showVersion { printVersion(); exit(0) }
+	fromInvocation(record, replay, logFile, history, answerFile, changesFile, &auditPath)
+	if wd, _ := os.Getwd(); dir != "" && wd == invocationDir { // -C after other flags
+		if err := changeDir(dir); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(2) }
+		if cfg, err = loadConfig(); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(1) }
+	}
+	prompt := strings.Join(flag.Args(), " ")
+	if tmpl != "" { if prompt, err = applyTemplate(flag.CommandLine, tmpl, vars, prompt); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(1) } }
+	if prompt == "" && !*printConfig && !*interactive && !isTTY(os.Stdin) { flag.Usage(); os.Exit(1) }
+	var persona Persona
+	if *personaName != "" { if persona, err = lookupPersona(*personaName); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(1) }; persona.defaults(flag.CommandLine, enable, disable) }
+	if *inherit { useInheritedEnv() }
+	if *verbose { *logLevel = "debug" }
+	if err := setupLogging(*logLevel, *logFile); err != nil { fmt.Fprintln(os.Stderr, err); os.Exit(1) }
+	if *output == "json" { ui = os.Stderr }
+	if quiet { ui = io.Discard }
+	if *sandbox != "" { if err := setSandbox(*sandbox); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(1) } }
+	start := time.Now()
+	a, err := newAgent(); if err != nil { fmt.Fprintln(os.Stderr, err); os.Exit(1) }
+	a.renderProgress()
+	if *deadline > 0 { a.armDeadline(*deadline) }
+	a.esc.model, a.esc.maxErrors = *escalateTo, *escalateErrors
+	if *ci { a.ci = newCIRun(prompt) }
+	a.watchSignals()
+	if a.Tools, err = selectTools(*enable, *disable); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(1) }
+	if *noTools { if *toolChoice != "" || *plan || *planOnly { fmt.Fprintln(os.Stderr, "Error: --no-tools can't be combined with --tool-choice or --plan"); os.Exit(1) }; a.Tools, a.System = nil, noToolsPrompt }
+	persona.restrict(a, flag.CommandLine); a.Persona = *personaName
+	if *filter { filterDefaults(a, flag.CommandLine) }
+	if c := *toolChoice; c != "" && c != "auto" && c != "any" && c != "none" {
+		if _, ok := a.lookup(c); !ok { fmt.Fprintf(os.Stderr, "Error: --tool-choice: unknown tool %q\n", c); os.Exit(1) }
+	}
+	a.ToolChoice = *toolChoice
+	if err := params.validate(); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(1) }
+	a.Params = *params
+	if *model != "" { a.Model = resolveModel(*model) }
+	a.Betas = append(a.Betas, betas...); if *version != "" { a.Version = *version }
+	if *maxOut < 1 || *maxOutTools < 1 { fmt.Fprintln(os.Stderr, "Error: --max-output-tokens and --max-output-tokens-tools must be positive"); os.Exit(2) }
+	a.WithOutputBudget(*maxOut, *maxOutTools)
+	a.stream, a.progress.every = *stream, *progressEvery; if *noPrefix { a.prefix = "" }
+	a.gate = gate{cfg.VerifyCommand, *verifyAttempts}
+	if a.batch = *batch; a.batch && len(a.Tools) > 0 { fmt.Fprintln(os.Stderr, "warning: --batch waits out a full batch round trip on every tool turn; prefer --no-tools or single-turn prompts") }
+	if *printConfig { a.printConfig(); exit(0) }
+	if *record != "" { a.rec = &recording{file: *record}; a.rec.Version, _ = buildVersion() }
+	if *resume == "" && *replay == "" && !*countOnly && !*filter { if s, ok := offerRecovery(); ok { *resume = s.ID } }
+	if *resume != "" {
+		s, err := loadSession(*resume); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(1) }
+		chosen := a.Model; a.resume(s); if *model != "" { a.Model = chosen }
+		if wd, _ := os.Getwd(); s.Dir != wd && *sandbox == "" {
+			if err := os.Chdir(s.Dir); err != nil { fmt.Fprintln(os.Stderr, "Error: session directory:", err); os.Exit(1) }
+			fmt.Fprintln(os.Stderr, "working in", s.Dir, "where the session ran")
+		}
+	}
+	if *history != "" {
+		if *resume != "" { fmt.Fprintln(os.Stderr, "Error: --history can't be combined with --resume; the session already has its history"); os.Exit(2) }
+		msgs, err := loadHistory(*history); if err != nil { fmt.Fprintln(os.Stderr, "Error: --history:", err); os.Exit(1) }
+		a.WithInitialMessages(msgs)
+	}
+	if *repomap && len(a.Tools) > 0 { a.useRepoMap(*repomapTokens) }
+	if withDiff != "" {
+		if prompt == "" { fmt.Fprintln(os.Stderr, "Error: --with-diff needs a prompt to attach the diff to"); os.Exit(2) }
+		block, err := diffContext(withDiff, *diffTokens); if err != nil { fmt.Fprintln(os.Stderr, "Error: --with-diff:", err); os.Exit(1) }
+		if block == "" { fmt.Fprintln(os.Stderr, "warning: --with-diff: no", withDiff, "changes to include") }
+		prompt += block
+	}
+	if *replay != "" { if a.rec, err = loadRecording(*replay, *liveTools); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); os.Exit(1) } }
+	root := telemetry.Start("nano.run"); root.Set("model", a.Model); a.span = root
+	v, commit := buildVersion(); root.Set("nano.version", v); slog.Info("run start", "version", v, "commit", commit, "model", a.Model, "session", a.Session)
+	tm := newTiming(a)
+	if *countOnly { a.printCount(prompt); exit(0) }
+	if *filter { atExit = func(code int) { a.recordUsage(start, code) }; code := a.filter(prompt); root.End(nil); exit(code) }
+	if !*noLock && !acquireRunLock(prompt) { exit(1) }
+	if a.rec == nil { a.openJournal() }
+	atExit = func(code int) { a.closeJournal(); a.recordUsage(start, code); a.writeSummary(code) }
+	if prompt == "" { code := a.repl(); root.Set("turns", a.Turns); root.End(nil); exit(code) }
+	if *plan || *planOnly {
+		prompt, err = a.Plan(prompt, *planOnly); a.exitIfStopped()
+		if err != nil && !a.pastDeadline() { fmt.Fprintln(os.Stderr, "Error:", err); root.End(err); exit(1) }
+		if prompt == "" && err == nil { root.End(nil); exit(0) }
+	}
+	steps := append([]string{prompt}, thens...)
+	reps := make([]report, len(steps)); failed := -1; answerFailed := false
+	for i, p := range steps {
+		reps[i] = report{Step: i + 1, Prompt: p, Status: "skipped"}
+		if failed >= 0 && (!*keepGoing || reps[failed].Status == "deadline_exceeded") { continue }
+		if len(steps) > 1 { fmt.Fprintf(ui, "▶ step %d/%d: %s\n", i+1, len(steps), truncate(p, 100)) }
+		if i > 0 { err = nil } // the plan's error belongs to the first step
+		reps[i] = a.step(p, err, *verify, *verifyRounds, *deadline); reps[i].Step = i + 1
+		if *answerFile != "" && reps[i].Result != "" {
+			if n, err := writeAnswer(*answerFile, reps[i].Result); err != nil { fmt.Fprintln(os.Stderr, "Error: --answer-file:", err); answerFailed = true } else { reps[i].AnswerFile, reps[i].AnswerBytes = *answerFile, n }
+		}
+		if reps[i].failed() && failed < 0 { failed = i }
+		if len(steps) > 1 && *output != "json" { reps[i].print() }
+	}
+	a.autosave()
+	root.Set("turns", a.Turns); if failed >= 0 { root.End(errors.New(reps[failed].failure())) } else { root.End(nil) }
+	code := 0; if answerFailed { code = 1 }; if failed >= 0 { code = reps[failed].exitCode() } else { for _, r := range reps { if code == 0 { code = r.exitCode() } } }
+	var stepLine string
+	if len(steps) > 1 {
+		var parts []string
+		for _, r := range reps {
+			mark := map[string]string{"completed": "✓", "skipped": "–"}[r.Status]; if mark == "" || r.failed() { mark = "✗" }
+			label := r.Status; if r.Status == "completed" && r.Outcome != "unreported" { label = r.Outcome } // as reported with finish
+			parts = append(parts, fmt.Sprintf("%s %d %s", mark, r.Step, label))
+		}
+		stepLine = "steps: " + strings.Join(parts, " · ")
+	} else { // one prompt: the report covers the whole run, planning and verification included
+		r := &reps[0]; r.Step, r.Prompt = 0, ""
+		r.Persona, r.Turns, r.Usage, r.DurationMS, r.Timing = a.Persona, a.Turns, a.Usage, time.Since(start).Milliseconds(), tm.report()
+		r.CostUSD = nil; if c, ok := a.runCost(); ok { r.CostUSD = &c }
+		r.Escalation = a.esc.done; if len(a.byModel) > 1 { r.ByModel = a.byModel }
+		r.Phases = a.phaseCosts()
+		if *showDiff { r.Diff = a.runDiff() }
+	}
+	if *output == "json" {
+		var data []byte
+		if len(steps) > 1 { data, _ = json.MarshalIndent(reps, "", "  ") } else { data, _ = json.MarshalIndent(reps[0], "", "  ") }
+		fmt.Println(string(data))
+		if *showDiff { a.showDiff(os.Stderr) }
+	} else {
+		if len(steps) == 1 { reps[0].print() }
+		if *showDiff { a.showDiff(os.Stderr) }
+		sum := tm.summary(); if a.Persona != "" { sum = "persona " + a.Persona + " · " + sum }
+		cost := "cost unknown"; if c, ok := a.runCost(); ok { cost = fmt.Sprintf("$%.4f", c) }
+		cost += a.costBreakdown() + a.phaseBreakdown() + a.escalationSummary(); if v := reps[0].Verify; v != nil && len(steps) == 1 { cost += " · " + v.summary() }
+		if g := reps[0].Check; g != nil && len(steps) == 1 { cost += " · " + g.summary() }
+		if first, rest, ok := strings.Cut(sum, "\n"); ok { sum = first + " · " + cost + "\n" + rest } else { sum += " · " + cost }
+		if stepLine != "" { sum = stepLine + "\n" + sum }
+		if !quiet { fmt.Fprintln(os.Stderr, sum) }
+	}
+	if *changesFile != "" {
+		status := reps[0].Status; if failed >= 0 { status = reps[failed].Status }
+		if err := a.changeSet(prompt, status, code, !*changesNoDiff).write(*changesFile); err != nil { fmt.Fprintln(os.Stderr, "Error: --changes-file:", err); if code == 0 { code = 1 } }
+	}
+	if code != 0 { exit(code) }
+	if a.rec != nil && a.rec.mismatches > 0 { fmt.Fprintf(os.Stderr, "replay: %d request(s) diverged from the recording\n", a.rec.mismatches); exit(1) }
+	exit(0)
 }
 
 func min(a, b int) int { if a < b { return a }; return b }
+
+func sortedKeys[V any](m map[string]V) []string { keys := make([]string, 0, len(m)); for k := range m { keys = append(keys, k) }; sort.Strings(keys); return keys }
+
+func randHex(n int) string { b := make([]byte, n); rand.Read(b); return hex.EncodeToString(b) }
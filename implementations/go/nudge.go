@@ -0,0 +1,73 @@
+// Soft interrupts. Pressing i while a run is working, with a person at the terminal, opens a
+// one-line input; the line is an interjection, delivered at the earliest point the protocol
+// allows without throwing work away: appended after the current batch of tool results, or,
+// when the model has just ended its turn, sent as a user message of its own so the loop goes
+// on. Several accumulate in order. They are marked "[user interjection]" in the conversation,
+// so the model and the transcript can tell them from tool output. In interactive mode the
+// running turn's typeahead takes the key (at the start of a line, so a queued message can't
+// begin with i; type a space first); in a one-shot run the key is watched while an API call
+// is in flight. Without a terminal there is no key to press.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+const nudgePrompt = "\r\033[K✎ interject: "
+
+var nudges struct {
+	sync.Mutex
+	lines []string
+}
+
+func addNudge(line string) {
+	if line = strings.TrimSpace(line); line == "" { fmt.Fprint(os.Stderr, "\r\033[K"); return }
+	nudges.Lock(); nudges.lines = append(nudges.lines, line); nudges.Unlock()
+	fmt.Fprintf(os.Stderr, "\r\033[K↪ interjection queued for the model: %s\n", truncate(line, 100))
+}
+
+// takeNudges hands over the interjections typed so far as message blocks, announcing each.
+func (a *Agent) takeNudges() []map[string]any {
+	nudges.Lock(); lines := nudges.lines; nudges.lines = nil; nudges.Unlock()
+	var out []map[string]any
+	for _, l := range lines { a.notify("↪ interjection sent: " + truncate(l, 100)); out = append(out, map[string]any{"type": "text", "text": "[user interjection] " + l}) }
+	return out
+}
+
+// watchNudgeKey listens for i during an API call in a one-shot run; the returned stop waits
+// for an interjection being typed to be finished.
+func watchNudgeKey() (stop func()) {
+	if keys != nil || !isTTY(os.Stdin) || !isTTY(os.Stderr) { return func() {} }
+	restore, err := makePolling(os.Stdin); if err != nil { return func() {} }
+	done, exited := make(chan struct{}), make(chan struct{}); var typed []byte
+	go func() {
+		defer close(exited)
+		buf := make([]byte, 1); var line []byte; typing := false
+		for {
+			if !typing { select { case <-done: return; default: } }
+			if n, _ := os.Stdin.Read(buf); n == 0 { continue } // a read times out after 100ms
+			c := buf[0]
+			switch {
+			case c == 3: // Ctrl-C: what the terminal would have sent without raw mode
+				restore(); p, _ := os.FindProcess(os.Getpid()); p.Signal(os.Interrupt); return
+			case !typing && (c == 'i' || c == 'I'): typing = true; fmt.Fprint(os.Stderr, nudgePrompt)
+			case !typing: if c == '\r' { c = '\n' }; typed = append(typed, c) // kept for whatever reads stdin next
+			case c == '\r' || c == '\n': addNudge(string(bytes.ToValidUTF8(line, nil))); line, typing = nil, false
+			case c == 27: fmt.Fprint(os.Stderr, "\r\033[K"); line, typing = nil, false
+			case c == 127 || c == 8: if len(line) > 0 { line = line[:len(line)-1]; fmt.Fprint(os.Stderr, "\b \b") }
+			case c >= ' ' || c == '\t' || c >= 0x80: line = append(line, c); os.Stderr.Write(buf)
+			}
+		}
+	}()
+	return func() {
+		close(done); <-exited; restore()
+		if len(typed) > 0 { stdin = bufio.NewReader(io.MultiReader(bytes.NewReader(typed), stdin)) }
+	}
+}
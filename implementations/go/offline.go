@@ -0,0 +1,120 @@
+// --offline (or NANO_OFFLINE=1): for a machine with only a local model server, such as Ollama
+// or a local LiteLLM, where nano must never reach out. It needs the API base URL on a loopback
+// address and refuses to start otherwise. web_search, fetch_url and download_file are taken
+// out of the tool registry, and screenshot refuses URLs other than local ones. Every HTTP
+// connection nano itself makes, the network tools' guarded clients included, fails fast unless
+// it goes to a loopback address. Host names resolve from the hosts file only, so not even a
+// DNS query leaves the machine. It is set up before subcommands are dispatched, so fix, watch,
+// serve and the rest are covered as well (`nano serve --offline` works though serve has no such
+// flag of its own), and it is passed on to the nano processes they start. Commands the bash
+// tool runs are not covered; they are ordinary processes. `nano doctor --offline` checks the
+// same things.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var offline bool
+
+var errOffline = errors.New("offline: only loopback addresses can be reached")
+
+// offlineTools are removed from the registry by --offline.
+var offlineTools = []string{"web_search", "fetch_url", "download_file"}
+
+// hostsOnly resolves from the hosts file and never asks a DNS server.
+var hostsOnly = &net.Resolver{PreferGo: true, Dial: func(context.Context, string, string) (net.Conn, error) { return nil, errOffline }}
+
+// offlineRequested reports whether args (the command line after the program name) or
+// NANO_OFFLINE=1 ask for --offline; the last --offline[=bool] ahead of a "--" wins.
+func offlineRequested(args []string) bool {
+	on := os.Getenv("NANO_OFFLINE") == "1"
+	for _, arg := range args {
+		if arg == "--" { break }
+		if v, ok := offlineArg(arg); ok { on = v }
+	}
+	return on
+}
+
+// offlineArg parses arg as -offline, --offline or --offline=bool.
+func offlineArg(arg string) (on, ok bool) {
+	name, v, set := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+	if !strings.HasPrefix(arg, "-") || name != "offline" { return false, false }
+	if !set { return true, true }
+	on, err := strconv.ParseBool(v); return on, err == nil
+}
+
+// offlineArgs drops --offline from a subcommand's args, already acted on, unless the command
+// declares the flag itself.
+func offlineArgs(c command, args []string) []string {
+	if c.flags().Lookup("offline") != nil { return args }
+	var out []string
+	for i, arg := range args {
+		if arg == "--" { return append(out, args[i:]...) }
+		if _, ok := offlineArg(arg); !ok { out = append(out, arg) }
+	}
+	return out
+}
+
+// goOffline removes the network tools and confines the default transport to loopback; nano
+// processes started from here on inherit it.
+func goOffline() {
+	offline = true; os.Setenv("NANO_OFFLINE", "1")
+	registry = slices.DeleteFunc(registry, func(t Tool) bool { return slices.Contains(offlineTools, t.Name) })
+	if t, ok := http.DefaultTransport.(*http.Transport); ok { t.DialContext, t.Proxy = loopbackDial, nil }
+}
+
+// loopbackDial dials address only if its host is, or resolves from the hosts file to,
+// loopback addresses.
+func loopbackDial(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address); if err != nil { return nil, err }
+	ip, err := loopbackIP(ctx, host); if err != nil { return nil, err }
+	return (&net.Dialer{Timeout: 10 * time.Second}).DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+}
+
+// loopbackIP resolves host without DNS and returns its address if every address it has is loopback.
+func loopbackIP(ctx context.Context, host string) (net.IP, error) {
+	addrs, err := hostsOnly.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 { return nil, fmt.Errorf("%s refused: %w (and it isn't in the hosts file)", host, errOffline) }
+	for _, a := range addrs { if !a.IP.IsLoopback() { return nil, fmt.Errorf("%s (%s) refused: %w", host, a.IP, errOffline) } }
+	return addrs[0].IP, nil
+}
+
+// checkOffline is the startup check: the API must be served from this machine.
+func checkOffline(apiURL string) error {
+	u, err := url.Parse(apiURL); if err != nil { return err }
+	if _, err := loopbackIP(context.Background(), u.Hostname()); err != nil {
+		return fmt.Errorf("--offline needs a local provider, but the API is at %s; set ANTHROPIC_BASE_URL to a loopback address, e.g. an Ollama or LiteLLM server on localhost", u.Host)
+	}
+	return nil
+}
+
+// localURL reports whether a URL a tool was given points at this machine.
+func localURL(raw string) bool {
+	u, err := url.Parse(raw); if err != nil { return false }
+	_, err = loopbackIP(context.Background(), u.Hostname()); return err == nil
+}
+
+// offlineChecks are doctor --offline's additions.
+func offlineChecks(a *Agent) []check {
+	var checks []check
+	if err := checkOffline(a.URL); err != nil { checks = append(checks, check{"offline provider", "fail", err.Error(), "point ANTHROPIC_BASE_URL (or the gateway's base URL) at a local server"}) } else {
+		checks = append(checks, check{"offline provider", "pass", "the API is local: " + a.URL, ""})
+	}
+	checks = append(checks, check{"offline tools", "pass", "removed: " + strings.Join(offlineTools, ", ") + "; other connections are limited to loopback", ""})
+	for _, v := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"} {
+		if e := os.Getenv(v); e != "" && !localURL(e) { checks = append(checks, check{"offline telemetry", "warn", v + " is " + e + "; exports to it will be refused", "unset it, or point it at a local collector"}) }
+	}
+	return checks
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoopbackDialRefusesExternalHosts(t *testing.T) {
+	for _, addr := range []string{"example.com:443", "8.8.8.8:53", "[2001:4860:4860::8888]:53", "no-such-host.invalid:80"} {
+		conn, err := loopbackDial(context.Background(), "tcp", addr)
+		if err == nil { conn.Close() }
+		if !errors.Is(err, errOffline) { t.Errorf("%s: got %v, want an offline refusal", addr, err) }
+	}
+}
+
+func TestLoopbackDialAllowsLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})); defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	for _, host := range []string{"127.0.0.1", "localhost"} {
+		conn, err := loopbackDial(context.Background(), "tcp", host+":"+port)
+		if err != nil { t.Errorf("%s: %v", host, err); continue }
+		conn.Close()
+	}
+}
+
+func TestCheckOfflineNeedsALocalAPI(t *testing.T) {
+	if err := checkOffline("http://127.0.0.1:11434"); err != nil { t.Errorf("loopback API refused: %v", err) }
+	if err := checkOffline("https://api.anthropic.com"); err == nil { t.Error("a remote API passed the offline check") }
+}
+
+func TestOfflineRequested(t *testing.T) {
+	for _, c := range []struct{ env string; args []string; want bool }{
+		{"", []string{"fix", "--", "go", "test"}, false},
+		{"", []string{"serve", "--offline", "--base", "b"}, true},
+		{"", []string{"-offline", "prompt"}, true},
+		{"1", []string{"watch"}, true},
+		{"1", []string{"watch", "--offline=false"}, false},
+		{"", []string{"fix", "--", "run", "--offline"}, false}, // the command's, not nano's
+	} {
+		t.Setenv("NANO_OFFLINE", c.env)
+		if got := offlineRequested(c.args); got != c.want { t.Errorf("NANO_OFFLINE=%q %v: %v, want %v", c.env, c.args, got, c.want) }
+	}
+}
+
+func TestOfflineArgsLeavesOnlyDeclaredFlags(t *testing.T) {
+	serve, _ := findCommand("serve"); doctor, _ := findCommand("doctor")
+	if got := offlineArgs(serve, []string{"--offline", "--pool", "2", "--", "--offline"}); !slices.Equal(got, []string{"--pool", "2", "--", "--offline"}) { t.Errorf("serve: %v", got) }
+	if got := offlineArgs(doctor, []string{"--offline"}); !slices.Equal(got, []string{"--offline"}) { t.Errorf("doctor: %v", got) }
+}
+
+func TestGoOfflineConfinesTheDefaultTransport(t *testing.T) {
+	tr := http.DefaultTransport.(*http.Transport); dial, proxy := tr.DialContext, tr.Proxy
+	saved := slices.Clone(registry)
+	t.Setenv("NANO_OFFLINE", "")
+	t.Cleanup(func() { tr.DialContext, tr.Proxy, registry, offline = dial, proxy, saved, false })
+	goOffline()
+	if slices.ContainsFunc(registry, func(tool Tool) bool { return slices.Contains(offlineTools, tool.Name) }) { t.Error("network tools still registered") }
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })); defer srv.Close()
+	if res, err := http.Get(srv.URL); err != nil { t.Errorf("loopback request failed: %v", err) } else { res.Body.Close() }
+	if _, err := http.Get("http://example.com/"); !errors.Is(err, errOffline) { t.Errorf("external request: got %v, want an offline refusal", err) }
+}
+
+func TestOfflineRefusesARemoteAPIInEveryCommand(t *testing.T) {
+	t.Setenv("ANTHROPIC_BASE_URL", "https://api.example.com")
+	for _, args := range [][]string{{"--offline", "hello"}, {"fix", "--offline", "--", "true"}, {"watch", "--offline", "--glob", "*.go", "fix it"}, {"tokens", "--offline", "hello"}} {
+		_, stderr, code := runNano(t, nil, t.TempDir(), args...)
+		if code != 1 || !strings.Contains(stderr, "--offline needs a local provider, but the API is at api.example.com") { t.Errorf("%v: exit %d: %s", args, code, stderr) }
+	}
+	// doctor still runs, and reports it as a check of its own
+	stdout, _, _ := runNano(t, nil, t.TempDir(), "doctor", "--offline", "--output", "json")
+	if !strings.Contains(stdout, `"offline provider"`) || !strings.Contains(stdout, "needs a local provider") { t.Errorf("doctor: %s", stdout) }
+}
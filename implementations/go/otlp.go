@@ -0,0 +1,125 @@
+//go:build !nano_nootel
+
+// OTLP/HTTP JSON exporter for the telemetry seam, written against the wire format directly to
+// keep the module dependency-free. Spans and counters are buffered in memory and sent once at
+// Shutdown, which suits a short-lived CLI. Honours OTEL_EXPORTER_OTLP_ENDPOINT,
+// OTEL_EXPORTER_OTLP_{TRACES,METRICS}_ENDPOINT, OTEL_EXPORTER_OTLP_HEADERS and OTEL_SERVICE_NAME.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func newTracer() tracer {
+	base := strings.TrimSuffix(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/")
+	traces, metrics := env("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", ""), env("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "")
+	if base == "" && traces == "" && metrics == "" { return noopTracer{} }
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); p != "" && p != "http/json" { slog.Warn("only the http/json OTLP protocol is supported", "protocol", p) }
+	if traces == "" && base != "" { traces = base + "/v1/traces" }
+	if metrics == "" && base != "" { metrics = base + "/v1/metrics" }
+	t := &otlpTracer{traces: traces, metrics: metrics, headers: map[string]string{}, start: time.Now(), sums: map[string]*otlpSum{}}
+	for _, kv := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok { t.headers[strings.TrimSpace(k)] = strings.TrimSpace(v) }
+	}
+	return t
+}
+
+type otlpTracer struct {
+	traces, metrics string
+	headers         map[string]string
+	start           time.Time
+	mu              sync.Mutex
+	spans           []map[string]any
+	sums            map[string]*otlpSum
+}
+
+type otlpSum struct{ name string; attrs map[string]any; value float64 }
+
+type otlpSpan struct {
+	t                     *otlpTracer
+	trace, id, parent     string
+	name                  string
+	start                 time.Time
+	attrs                 map[string]any
+}
+
+func (t *otlpTracer) Start(name string) span { return t.span(name, randHex(16), "") }
+
+func (t *otlpTracer) span(name, trace, parent string) *otlpSpan {
+	return &otlpSpan{t: t, trace: trace, id: randHex(8), parent: parent, name: name, start: time.Now(), attrs: map[string]any{}}
+}
+
+func (s *otlpSpan) Child(name string) span { return s.t.span(name, s.trace, s.id) }
+func (s *otlpSpan) Set(key string, value any) { s.attrs[key] = value }
+
+func (s *otlpSpan) End(err error) {
+	status := map[string]any{"code": 1}
+	if err != nil { status = map[string]any{"code": 2, "message": err.Error()} }
+	sp := map[string]any{"traceId": s.trace, "spanId": s.id, "name": s.name, "kind": 1, "startTimeUnixNano": nanos(s.start), "endTimeUnixNano": nanos(time.Now()), "attributes": otlpAttrs(s.attrs), "status": status}
+	if s.parent != "" { sp["parentSpanId"] = s.parent }
+	s.t.mu.Lock(); s.t.spans = append(s.t.spans, sp); s.t.mu.Unlock()
+}
+
+func (t *otlpTracer) Add(metric string, value float64, attrs map[string]any) {
+	key := metric; for _, k := range sortedKeys(attrs) { key += "|" + k + "=" + fmt.Sprint(attrs[k]) }
+	t.mu.Lock(); defer t.mu.Unlock()
+	if t.sums[key] == nil { t.sums[key] = &otlpSum{name: metric, attrs: attrs} }
+	t.sums[key].value += value
+}
+
+func (t *otlpTracer) Shutdown() {
+	t.mu.Lock(); defer t.mu.Unlock()
+	resource := map[string]any{"attributes": otlpAttrs(map[string]any{"service.name": env("OTEL_SERVICE_NAME", "nano-opencode")})}
+	scope := map[string]any{"name": "nano-opencode"}
+	if len(t.spans) > 0 && t.traces != "" {
+		t.send(t.traces, map[string]any{"resourceSpans": []any{map[string]any{"resource": resource, "scopeSpans": []any{map[string]any{"scope": scope, "spans": t.spans}}}}})
+	}
+	if len(t.sums) > 0 && t.metrics != "" {
+		byName := map[string][]any{}
+		for _, s := range t.sums { byName[s.name] = append(byName[s.name], map[string]any{"attributes": otlpAttrs(s.attrs), "startTimeUnixNano": nanos(t.start), "timeUnixNano": nanos(time.Now()), "asDouble": s.value}) }
+		var metrics []any
+		for _, name := range sortedKeys(byName) { metrics = append(metrics, map[string]any{"name": name, "sum": map[string]any{"aggregationTemporality": 2, "isMonotonic": true, "dataPoints": byName[name]}}) }
+		t.send(t.metrics, map[string]any{"resourceMetrics": []any{map[string]any{"resource": resource, "scopeMetrics": []any{map[string]any{"scope": scope, "metrics": metrics}}}}})
+	}
+	t.spans, t.sums = nil, map[string]*otlpSum{}
+}
+
+func (t *otlpTracer) send(url string, payload any) {
+	body, _ := json.Marshal(payload)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second); defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body)); if err != nil { slog.Warn("otlp export failed", "err", err); return }
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers { req.Header.Set(k, v) }
+	resp, err := http.DefaultClient.Do(req); if err != nil { slog.Warn("otlp export failed", "url", url, "err", err); return }
+	resp.Body.Close()
+	if resp.StatusCode >= 300 { slog.Warn("otlp export rejected", "url", url, "status", resp.StatusCode) }
+}
+
+func otlpAttrs(m map[string]any) []any {
+	out := []any{}
+	for _, k := range sortedKeys(m) {
+		var v map[string]any
+		switch x := m[k].(type) {
+		case int: v = map[string]any{"intValue": strconv.Itoa(x)}
+		case int64: v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+		case float64: v = map[string]any{"doubleValue": x}
+		case bool: v = map[string]any{"boolValue": x}
+		default: v = map[string]any{"stringValue": fmt.Sprint(x)}
+		}
+		out = append(out, map[string]any{"key": k, "value": v})
+	}
+	return out
+}
+
+func nanos(t time.Time) string { return strconv.FormatInt(t.UnixNano(), 10) }
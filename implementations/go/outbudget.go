@@ -0,0 +1,61 @@
+// Output budgets. A reply's max_tokens is shared by its text and its tool inputs, so a long
+// preamble can leave a write_file cut off halfway. Turns that answer tool results, where the
+// model is mid-task and most likely to write, get --max-output-tokens-tools; the others (the
+// reply to a prompt, typically prose) get --max-output-tokens. Both default to 8192, and the
+// system prompt tells the model the limits and to keep prose before a tool call short. A reply
+// that still stops at max_tokens inside a tool call is asked for again, once, with twice the
+// budget (at most maxRetryOutput); only if that fails too does the model get the cut-off
+// error and its advice to write in parts (see truncated.go).
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+const maxRetryOutput = 64000
+
+type outputBudget struct {
+	prose, tools int // 0: maxOutput
+	retry        int // this request's raised budget, while a cut-off turn is retried
+}
+
+// WithOutputBudget sets max_tokens for prose turns and for turns after tool results; 0 keeps the default.
+func (a *Agent) WithOutputBudget(prose, tools int) *Agent { a.out.prose, a.out.tools = prose, tools; return a }
+
+// maxTokens is the max_tokens of the next request.
+func (a *Agent) maxTokens() int {
+	if a.out.retry > 0 { return a.out.retry }
+	if a.afterTools() { return orInt(a.out.tools, maxOutput) }
+	return orInt(a.out.prose, maxOutput)
+}
+
+// afterTools reports whether the conversation ends in tool results.
+func (a *Agent) afterTools() bool {
+	if len(a.Messages) == 0 { return false }
+	m := a.Messages[len(a.Messages)-1]; return m.Role == "user" && toolResults(m.Content) != nil
+}
+
+// outputRule is the system prompt's note on the limits; the tools' limit only matters with tools.
+func (a *Agent) outputRule() string {
+	if len(a.Tools) == 0 { return "" }
+	return fmt.Sprintf("\n\nEach reply can be at most about %d tokens (%d after tool results), shared by your text and your tool inputs. Keep explanation ahead of a tool call brief, and write long files in parts so a call isn't cut off.", orInt(a.out.prose, maxOutput), orInt(a.out.tools, maxOutput))
+}
+
+// cutOffCall reports whether res stopped at max_tokens inside a tool call.
+func cutOffCall(res *Response) bool {
+	if res.StopReason != "max_tokens" || len(res.Content) == 0 { return false }
+	b := res.Content[len(res.Content)-1]; return b.Type == "tool_use"
+}
+
+// retryCutOff asks for a reply cut off inside a tool call again with twice its budget. The
+// first reply stands when the budget can't grow or the retry fails.
+func (a *Agent) retryCutOff(res *Response) *Response {
+	used := a.maxTokens(); raised := min(2*used, maxRetryOutput)
+	if !cutOffCall(res) || raised <= used { return res }
+	a.notify(fmt.Sprintf("↻ the reply was cut off inside a %s call at %d output tokens; asking again with %d", res.Content[len(res.Content)-1].Name, used, raised))
+	a.out.retry = raised; again, err := a.request(); a.out.retry = 0
+	if err != nil { slog.Warn("retry with a larger output budget failed; keeping the cut-off reply", "err", err); return res }
+	return again
+}
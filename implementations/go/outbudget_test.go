@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func maxTokensOf(t *testing.T, f *fakeAPI, i int) int {
+	t.Helper(); n, _ := f.request(t, i)["max_tokens"].(float64); return int(n)
+}
+
+func TestOutputBudgetSplitsProseAndToolTurns(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "read_file", `{"path":"a.txt"}`), textReply("done"))
+	a := testAgent(t, f).WithOutputBudget(100, 300); writeTestFile(t, "a.txt", "a\n")
+	if _, err := a.Run("read a.txt"); err != nil { t.Fatal(err) }
+	if got := maxTokensOf(t, f, 0); got != 100 { t.Errorf("reply to the prompt: max_tokens %d, want 100", got) }
+	if got := maxTokensOf(t, f, 1); got != 300 { t.Errorf("reply to tool results: max_tokens %d, want 300", got) }
+	if sys, _ := f.request(t, 0)["system"].(string); !strings.Contains(sys, "about 100 tokens (300 after tool results)") { t.Errorf("system prompt doesn't state the limits: %q", sys) }
+}
+
+func TestOutputBudgetDefaults(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "read_file", `{"path":"a.txt"}`), textReply("done"))
+	a := testAgent(t, f); writeTestFile(t, "a.txt", "a\n")
+	if _, err := a.Run("read a.txt"); err != nil { t.Fatal(err) }
+	for i := 0; i < 2; i++ { if got := maxTokensOf(t, f, i); got != maxOutput { t.Errorf("request %d: max_tokens %d, want %d", i, got, maxOutput) } }
+}
+
+func TestCutOffCallIsRetriedWithTwiceTheBudget(t *testing.T) {
+	cut := reply("max_tokens", textBlock("Writing it now."), toolBlock("t1", "write_file", `{"path":"a.txt","content":"par"}`))
+	f := newFakeAPI(t, cut, toolReply("t2", "write_file", `{"path":"a.txt","content":"partly, then all\n"}`), textReply("done"))
+	a := testAgent(t, f).WithOutputBudget(100, 100)
+	if _, err := a.Run("write a.txt"); err != nil { t.Fatal(err) }
+	if got := maxTokensOf(t, f, 1); got != 200 { t.Errorf("retry: max_tokens %d, want 200", got) }
+	if got := maxTokensOf(t, f, 2); got != 100 { t.Errorf("after the retry: max_tokens %d, want the budget back at 100", got) }
+	if got := readTestFile(t, "a.txt"); got != "partly, then all\n" { t.Errorf("a.txt is %q; the cut-off call should have been replaced by the retry", got) }
+	toolResult(t, f.request(t, 2), "t2")
+	if data, _ := json.Marshal(a.Messages); strings.Contains(string(data), `"t1"`) { t.Error("the cut-off reply stayed in the history") }
+}
+
+func TestCutOffRetryStopsAtTheCap(t *testing.T) {
+	cut := reply("max_tokens", toolBlock("t1", "write_file", `{"path":"a.txt","content":"par"}`))
+	f := newFakeAPI(t, cut, textReply("I'll write it in parts."))
+	a := testAgent(t, f).WithOutputBudget(maxRetryOutput, maxRetryOutput)
+	if _, err := a.Run("write a.txt"); err != nil { t.Fatal(err) }
+	if f.count() != 2 { t.Fatalf("%d requests, want no retry at the cap", f.count()) }
+	if r := toolResult(t, f.request(t, 1), "t1"); r["is_error"] != true { t.Errorf("the cut-off call wasn't reported as an error: %v", r) }
+	if _, err := os.Stat("a.txt"); err == nil { t.Error("the cut-off call ran") }
+}
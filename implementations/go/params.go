@@ -0,0 +1,45 @@
+// Generation parameters: --temperature, --top-p and repeatable --stop, defaulting to the config
+// file's "temperature", "top_p" and "stop_sequences". Unset values are left to the API default.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type genParams struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Stop        []string `json:"stop_sequences,omitempty"`
+}
+
+func paramFlags(fs *flag.FlagSet) *genParams {
+	p := &genParams{Temperature: cfg.Temperature, TopP: cfg.TopP, Stop: cfg.Stop}
+	num := func(dst **float64) func(string) error {
+		return func(v string) error { f, err := strconv.ParseFloat(v, 64); if err != nil { return err }; *dst = &f; return nil }
+	}
+	fs.Func("temperature", "sampling temperature `t`, 0 to 1 (config \"temperature\")", num(&p.Temperature))
+	fs.Func("top-p", "nucleus sampling `p`, 0 to 1 (config \"top_p\")", num(&p.TopP))
+	fromFlags := false
+	fs.Func("stop", "stop generating at `text`; repeatable (config \"stop_sequences\")", func(v string) error {
+		if !fromFlags { p.Stop, fromFlags = nil, true }; p.Stop = append(p.Stop, v); return nil
+	})
+	return p
+}
+
+// validate catches out-of-range values before they cost a round trip and a 400.
+func (p genParams) validate() error {
+	if t := p.Temperature; t != nil && (*t < 0 || *t > 1) { return fmt.Errorf("temperature must be between 0 and 1, got %g", *t) }
+	if t := p.TopP; t != nil && (*t < 0 || *t > 1) { return fmt.Errorf("top_p must be between 0 and 1, got %g", *t) }
+	for _, s := range p.Stop { if strings.TrimSpace(s) == "" { return fmt.Errorf("stop sequences must contain non-whitespace text, got %q", s) } }
+	return nil
+}
+
+func (p genParams) apply(req map[string]any) {
+	if p.Temperature != nil { req["temperature"] = *p.Temperature }
+	if p.TopP != nil { req["top_p"] = *p.TopP }
+	if len(p.Stop) > 0 { req["stop_sequences"] = p.Stop }
+}
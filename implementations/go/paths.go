@@ -0,0 +1,41 @@
+// Canonical paths. The model names the same file many ways ("./a.go", "sub/../a.go", an
+// absolute path, or one through a symlinked workspace root), so everything that compares or
+// shows paths goes through here: canonPath is the key for backups, the files-changed list,
+// the read cache and file locks; relPath is the display form in tool lines, approval prompts
+// and saved permission rules, and summaries. Tools themselves still open the path the model gave.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// canonPath is p made absolute (against the sandbox, if any, else the working directory),
+// cleaned, and with symlinks resolved as far as it exists.
+func canonPath(p string) string {
+	if r, err := resolveEntry(p); err == nil { p = r }
+	abs, err := filepath.Abs(p); if err != nil { return filepath.Clean(p) }
+	return realPath(abs)
+}
+
+// relPath shows p relative to the workspace root when it is inside, as ~/... when it is under
+// the home directory, and absolute otherwise.
+func relPath(p string) string {
+	if p == "" { return "" }
+	c := canonPath(p)
+	if r, ok := under(realPath(workDir()), c); ok { return r }
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		if r, ok := under(realPath(home), c); ok { if r == "." { return "~" }; return "~" + string(os.PathSeparator) + r }
+	}
+	return c
+}
+
+// under returns p relative to root if it is inside it. filepath.Rel fails across Windows
+// drives and compares them without regard to case.
+func under(root, p string) (string, bool) {
+	r, err := filepath.Rel(root, p)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(os.PathSeparator)) { return "", false }
+	return r, true
+}
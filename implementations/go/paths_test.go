@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// linkedRoot is a workspace entered through a symlink; it returns the real directory and the link.
+func linkedRoot(t *testing.T) (real, link string) {
+	t.Helper()
+	base, _ := filepath.EvalSymlinks(t.TempDir())
+	real, link = filepath.Join(base, "real"), filepath.Join(base, "link")
+	if err := os.MkdirAll(filepath.Join(real, "sub"), 0755); err != nil { t.Fatal(err) }
+	if err := os.Symlink(real, link); err != nil { t.Skip("no symlinks here:", err) }
+	wd, _ := os.Getwd(); if err := os.Chdir(link); err != nil { t.Fatal(err) }
+	t.Cleanup(func() { os.Chdir(wd) })
+	return real, link
+}
+
+func TestCanonPathThroughASymlinkedRoot(t *testing.T) {
+	real, link := linkedRoot(t)
+	writeTestFile(t, filepath.Join(real, "a.go"), "")
+	want := filepath.Join(real, "a.go")
+	for _, p := range []string{"a.go", "./a.go", "sub/../a.go", filepath.Join(link, "a.go"), want} {
+		if got := canonPath(p); got != want { t.Errorf("canonPath(%q) = %q, want %q", p, got, want) }
+		if got := relPath(p); got != "a.go" { t.Errorf("relPath(%q) = %q, want a.go", p, got) }
+	}
+	if got := canonPath(filepath.Join(link, "new", "x.go")); got != filepath.Join(real, "new", "x.go") { t.Errorf("a path that doesn't exist yet: %q", got) }
+	if got := relPath("new/x.go"); got != filepath.Join("new", "x.go") { t.Errorf("relPath of a new file: %q", got) }
+}
+
+func TestRelPathOfALinkLeavingTheWorkspace(t *testing.T) {
+	real, _ := linkedRoot(t)
+	outside := filepath.Join(filepath.Dir(real), "outside"); os.Mkdir(outside, 0755)
+	if err := os.Symlink(outside, filepath.Join(real, "out")); err != nil { t.Fatal(err) }
+	if got := relPath("out/f.txt"); got != filepath.Join(outside, "f.txt") { t.Errorf("got %q; a link leaving the workspace should show where it goes", got) }
+}
+
+func TestUnder(t *testing.T) {
+	sep := string(filepath.Separator); root := filepath.Join(sep+"work", "app")
+	for _, c := range []struct{ p, rel string; ok bool }{
+		{root, ".", true},
+		{filepath.Join(root, "a", "b.go"), filepath.Join("a", "b.go"), true},
+		{filepath.Join(root, "..data"), "..data", true}, // a name starting with dots is still inside
+		{filepath.Join(sep+"work", "application"), "", false},
+		{filepath.Join(sep+"work", "other", "x"), "", false},
+		{sep + "work", "", false},
+	} {
+		rel, ok := under(root, c.p)
+		if rel != c.rel || ok != c.ok { t.Errorf("under(%q, %q) = %q %v, want %q %v", root, c.p, rel, ok, c.rel, c.ok) }
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestUnderWindowsDrives(t *testing.T) {
+	for _, c := range []struct{ root, p, rel string; ok bool }{
+		{`C:\work`, `C:\work\a.go`, "a.go", true},
+		{`C:\Work`, `c:\work\sub\a.go`, `sub\a.go`, true}, // drive letters and names compare without case
+		{`C:\work`, `D:\work\a.go`, "", false},
+		{`C:\work`, `C:\workshop\a.go`, "", false},
+		{`C:\work`, `\\server\share\work\a.go`, "", false},
+	} {
+		rel, ok := under(c.root, c.p)
+		if rel != c.rel || ok != c.ok { t.Errorf("under(%q, %q) = %q %v, want %q %v", c.root, c.p, rel, ok, c.rel, c.ok) }
+	}
+}
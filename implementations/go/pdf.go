@@ -0,0 +1,355 @@
+// PDF support for read_file. By default the text layer is extracted in-process: a small
+// object parser walks the page tree (object streams included), inflates each page's content
+// streams and collects the strings shown by the text operators, decoding them through the
+// font's ToUnicode map when there is one. With "pdf_mode": "document" in config the file is
+// sent as a native document block instead, which also covers scanned PDFs.
+
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const maxPDFPages, maxPDFBytes = 50, 32 << 20
+
+type pdfName string
+type pdfOp string
+type pdfRef int
+type pdfDict map[string]any
+
+type pdfObj struct {
+	val    any
+	stream []byte // raw, still encoded
+}
+
+type pdfDoc struct{ objs map[int]*pdfObj }
+
+func readPDF(path string, pages string) ([]Block, error) {
+	data, err := os.ReadFile(path); if err != nil { return nil, err }
+	if m := cfg.PDFMode; m != "" && m != "text" && m != "document" { return nil, fmt.Errorf("config pdf_mode must be \"text\" or \"document\", got %q", m) }
+	if cfg.PDFMode == "document" {
+		if len(data) > maxPDFBytes { return nil, fmt.Errorf("%s is %d bytes; documents are limited to %d", path, len(data), maxPDFBytes) }
+		note := fmt.Sprintf("%s (PDF, %d bytes) attached as a document", path, len(data)); if pages != "" { note += "; pages is ignored in document mode" }
+		return []Block{{Type: "text", Text: note}, {Type: "document", Source: &MediaSource{Type: "base64", MediaType: "application/pdf", Data: base64.StdEncoding.EncodeToString(data)}}}, nil
+	}
+	text, err := pdfText(data, pages); if err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+	return []Block{{Type: "text", Text: text}}, nil
+}
+
+// pdfText renders the selected pages ("" for the first maxPDFPages) as text.
+func pdfText(data []byte, pages string) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \r\n\t"), []byte("%PDF")) { return "", errors.New("not a PDF file") }
+	d := parsePDF(data)
+	if regexp.MustCompile(`/Encrypt\s`).Match(data) { return "", errors.New("encrypted PDF; text can't be extracted (try pdf_mode \"document\")") }
+	all := d.pages(); if len(all) == 0 { return "", errors.New("no pages found; the file may be damaged") }
+	first, last, err := pageRange(pages, len(all)); if err != nil { return "", err }
+	var sb strings.Builder; found := false
+	fmt.Fprintf(&sb, "PDF, %d pages; showing %d-%d\n", len(all), first, last)
+	for n := first; n <= last; n++ {
+		t := strings.TrimSpace(d.pageText(all[n-1])); if t != "" { found = true }
+		fmt.Fprintf(&sb, "\n--- page %d ---\n%s\n", n, t)
+	}
+	if last < len(all) { fmt.Fprintf(&sb, "\n[%d more pages; pass pages (e.g. \"%d-%d\") to read on]\n", len(all)-last, last+1, min(last+maxPDFPages, len(all))) }
+	if !found { sb.WriteString("\nWarning: no text layer found; this looks like a scanned or image-only PDF. Set \"pdf_mode\": \"document\" in config to have the model read it directly.\n") }
+	return clip(sb.String()), nil
+}
+
+// pageRange parses "N" or "N-M" (1-based, inclusive), capped at maxPDFPages pages.
+func pageRange(s string, total int) (int, int, error) {
+	first, last := 1, total
+	if s = strings.TrimSpace(s); s != "" {
+		a, b, isRange := strings.Cut(s, "-")
+		var err1, err2 error
+		first, err1 = strconv.Atoi(strings.TrimSpace(a)); last = first
+		if isRange { if last, err2 = strconv.Atoi(strings.TrimSpace(b)); strings.TrimSpace(b) == "" { last, err2 = total, nil } }
+		if err1 != nil || err2 != nil || first < 1 || last < first { return 0, 0, fmt.Errorf("pages must look like \"3\" or \"2-5\", got %q", s) }
+		if first > total { return 0, 0, fmt.Errorf("pages %q is past the end; the document has %d pages", s, total) }
+	}
+	if last > total { last = total }
+	if last-first+1 > maxPDFPages { last = first + maxPDFPages - 1 }
+	return first, last, nil
+}
+
+var objStart = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+
+func parsePDF(data []byte) *pdfDoc {
+	d := &pdfDoc{objs: map[int]*pdfObj{}}
+	for _, m := range objStart.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		l := &pdfLexer{b: data, i: m[1]}
+		o := &pdfObj{val: l.value()}
+		if tok, ok := l.token(); ok && tok == pdfOp("stream") {
+			start := l.i; if start < len(data) && data[start] == '\r' { start++ }; if start < len(data) && data[start] == '\n' { start++ }
+			end := -1
+			if dict, ok := o.val.(pdfDict); ok { if n, ok := dict["Length"].(float64); ok && start+int(n) <= len(data) && bytes.Contains(data[start+int(n):min(start+int(n)+20, len(data))], []byte("endstream")) { end = start + int(n) } }
+			if end < 0 { if k := bytes.Index(data[start:], []byte("endstream")); k >= 0 { end = start + k } else { end = len(data) } }
+			o.stream = data[start:end]
+		}
+		d.objs[num] = o // later definitions (incremental updates) win
+	}
+	for _, o := range d.objs {
+		dict, ok := o.val.(pdfDict); if !ok || dict["Type"] != pdfName("ObjStm") { continue }
+		body := d.decode(o); n, _ := dict["N"].(float64); first, _ := dict["First"].(float64)
+		if body == nil || int(first) > len(body) { continue }
+		l := &pdfLexer{b: body[:int(first)]}
+		var nums, offs []int
+		for i := 0; i < int(n); i++ { a, _ := l.token(); b, _ := l.token(); x, _ := a.(float64); y, _ := b.(float64); nums = append(nums, int(x)); offs = append(offs, int(y)) }
+		for i, num := range nums {
+			if _, exists := d.objs[num]; exists || int(first)+offs[i] > len(body) { continue }
+			d.objs[num] = &pdfObj{val: (&pdfLexer{b: body, i: int(first) + offs[i]}).value()}
+		}
+	}
+	return d
+}
+
+func (d *pdfDoc) resolve(v any) any {
+	for i := 0; i < 16; i++ { r, ok := v.(pdfRef); if !ok { return v }; o := d.objs[int(r)]; if o == nil { return nil }; v = o.val }
+	return nil
+}
+
+func (d *pdfDoc) dict(v any) pdfDict { x, _ := d.resolve(v).(pdfDict); return x }
+
+// decode inflates a stream; filters other than FlateDecode aren't supported.
+func (d *pdfDoc) decode(o *pdfObj) []byte {
+	if o == nil || o.stream == nil { return nil }
+	dict, _ := o.val.(pdfDict)
+	switch f := d.resolve(dict["Filter"]).(type) {
+	case nil: return o.stream
+	case pdfName: if f != "FlateDecode" { return nil }
+	case []any: if len(f) != 1 || f[0] != pdfName("FlateDecode") { return nil }
+	}
+	r, err := zlib.NewReader(bytes.NewReader(o.stream)); if err != nil { return nil }
+	out, _ := io.ReadAll(r) // keep what inflated before any corruption
+	return out
+}
+
+// pages lists page dictionaries in document order: the catalog's tree if it can be found,
+// otherwise every /Type /Page object.
+func (d *pdfDoc) pages() []pdfDict {
+	var out []pdfDict; seen := map[pdfRef]bool{}
+	var walk func(v any)
+	walk = func(v any) {
+		if r, ok := v.(pdfRef); ok { if seen[r] { return }; seen[r] = true }
+		n := d.dict(v); if n == nil { return }
+		if n["Type"] == pdfName("Page") { out = append(out, n); return }
+		if kids, ok := d.resolve(n["Kids"]).([]any); ok { for _, k := range kids { walk(k) } }
+	}
+	for _, o := range d.objs {
+		if dict, ok := o.val.(pdfDict); ok && dict["Type"] == pdfName("Catalog") { walk(dict["Pages"]); break }
+	}
+	if len(out) > 0 { return out }
+	var nums []int; for num := range d.objs { nums = append(nums, num) }; sort.Ints(nums)
+	for _, num := range nums { if dict, ok := d.objs[num].val.(pdfDict); ok && dict["Type"] == pdfName("Page") { out = append(out, dict) } }
+	return out
+}
+
+// fonts maps a page's font resource names to their ToUnicode maps (nil when absent).
+func (d *pdfDoc) fonts(page pdfDict) map[string]*cmap {
+	res := page["Resources"]
+	for p := page; res == nil && p != nil; { p = d.dict(p["Parent"]); if p != nil { res = p["Resources"] } }
+	out := map[string]*cmap{}
+	for name, f := range d.dict(d.dict(res)["Font"]) {
+		if r, ok := d.dict(f)["ToUnicode"].(pdfRef); ok { out[name] = parseCMap(d.decode(d.objs[int(r)])) } else { out[name] = nil }
+	}
+	return out
+}
+
+func (d *pdfDoc) pageText(page pdfDict) string {
+	var content []byte
+	switch c := d.resolve(page["Contents"]).(type) {
+	case []any: for _, r := range c { if ref, ok := r.(pdfRef); ok { content = append(append(content, d.decode(d.objs[int(ref)])...), '\n') } }
+	default: if ref, ok := page["Contents"].(pdfRef); ok { content = d.decode(d.objs[int(ref)]) }
+	}
+	fonts := d.fonts(page)
+	var sb strings.Builder; var font *cmap; var args []any
+	newline := func() { if s := sb.String(); s != "" && !strings.HasSuffix(s, "\n") { sb.WriteByte('\n') } }
+	show := func(s []byte) { sb.WriteString(font.decode(s)) }
+	l := &pdfLexer{b: content}
+	for {
+		tok, ok := l.token(); if !ok { break }
+		op, isOp := tok.(pdfOp)
+		if !isOp || op == "[" || op == "<<" { l.i = l.last; args = append(args, l.value()); continue }
+		switch op {
+		case "Tf": if len(args) >= 2 { if n, ok := args[len(args)-2].(pdfName); ok { font = fonts[string(n)] } }
+		case "Tj": if len(args) > 0 { if s, ok := args[len(args)-1].([]byte); ok { show(s) } }
+		case "'", "\"": newline(); if len(args) > 0 { if s, ok := args[len(args)-1].([]byte); ok { show(s) } }
+		case "TJ":
+			if len(args) > 0 { if arr, ok := args[len(args)-1].([]any); ok { for _, e := range arr { switch v := e.(type) { case []byte: show(v); case float64: if v < -200 { sb.WriteByte(' ') } } } } }
+		case "T*", "ET": newline()
+		case "Td", "TD": if len(args) >= 2 { if y, ok := args[len(args)-1].(float64); ok && y != 0 { newline() } else if x, ok := args[len(args)-2].(float64); ok && x > 0 { sb.WriteByte(' ') } }
+		case "ID": if k := bytes.Index(l.b[l.i:], []byte("EI")); k >= 0 { l.i += k + 2 } else { l.i = len(l.b) } // skip inline image data
+		}
+		args = args[:0]
+	}
+	return sb.String()
+}
+
+// cmap is a ToUnicode map: character codes of a fixed byte width to text.
+type cmap struct{ width int; m map[uint32]string }
+
+var (
+	bfchar  = regexp.MustCompile(`<([0-9A-Fa-f]+)>\s*<([0-9A-Fa-f]*)>`)
+	bfrange = regexp.MustCompile(`<([0-9A-Fa-f]+)>\s*<([0-9A-Fa-f]+)>\s*(<[0-9A-Fa-f]*>|\[[^\]]*\])`)
+	hexStr  = regexp.MustCompile(`<([0-9A-Fa-f]*)>`)
+)
+
+func parseCMap(b []byte) *cmap {
+	if b == nil { return nil }
+	c := &cmap{width: 1, m: map[uint32]string{}}
+	s := string(b)
+	for _, sec := range sectionsOf(s, "beginbfrange", "endbfrange") {
+		for _, m := range bfrange.FindAllStringSubmatch(sec, -1) {
+			lo, hi := hexNum(m[1]), hexNum(m[2]); c.width = max(c.width, (len(m[1])+1)/2)
+			if strings.HasPrefix(m[3], "[") {
+				for i, h := range hexStr.FindAllStringSubmatch(m[3], -1) { c.m[lo+uint32(i)] = utf16Hex(h[1]) }
+				continue
+			}
+			dst := []rune(utf16Hex(strings.Trim(m[3], "<>")))
+			for code := lo; code <= hi && code-lo < 65536 && len(dst) > 0; code++ {
+				r := append([]rune{}, dst...); r[len(r)-1] += rune(code - lo); c.m[code] = string(r)
+			}
+		}
+	}
+	for _, sec := range sectionsOf(s, "beginbfchar", "endbfchar") {
+		for _, m := range bfchar.FindAllStringSubmatch(sec, -1) { c.m[hexNum(m[1])] = utf16Hex(m[2]); c.width = max(c.width, (len(m[1])+1)/2) }
+	}
+	return c
+}
+
+func sectionsOf(s, begin, end string) (out []string) {
+	for {
+		i := strings.Index(s, begin); if i < 0 { return }
+		s = s[i+len(begin):]; j := strings.Index(s, end); if j < 0 { return append(out, s) }
+		out = append(out, s[:j]); s = s[j:]
+	}
+}
+
+func hexNum(h string) uint32 { n, _ := strconv.ParseUint(h, 16, 32); return uint32(n) }
+
+func utf16Hex(h string) string {
+	var u []uint16
+	for i := 0; i+4 <= len(h); i += 4 { u = append(u, uint16(hexNum(h[i:i+4]))) }
+	if len(h)%4 == 2 { u = append(u, uint16(hexNum(h[len(h)-2:]))) }
+	return string(utf16.Decode(u))
+}
+
+// decode maps shown bytes to text; without a ToUnicode map bytes are taken as Latin-1.
+func (c *cmap) decode(s []byte) string {
+	var sb strings.Builder
+	if c == nil {
+		for _, b := range s { if b >= 32 || b == '\t' { sb.WriteRune(rune(b)) } }
+		return sb.String()
+	}
+	for i := 0; i+c.width <= len(s); i += c.width {
+		var code uint32; for _, b := range s[i : i+c.width] { code = code<<8 | uint32(b) }
+		sb.WriteString(c.m[code])
+	}
+	return sb.String()
+}
+
+// pdfLexer tokenizes both object syntax and content streams.
+type pdfLexer struct{ b []byte; i, last int }
+
+func isPDFSpace(c byte) bool { return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0 }
+func isPDFDelim(c byte) bool { return strings.IndexByte("()<>[]{}/%", c) >= 0 }
+
+func (l *pdfLexer) token() (any, bool) {
+	for l.i < len(l.b) {
+		if c := l.b[l.i]; isPDFSpace(c) { l.i++ } else if c == '%' { for l.i < len(l.b) && l.b[l.i] != '\n' && l.b[l.i] != '\r' { l.i++ } } else { break }
+	}
+	l.last = l.i
+	if l.i >= len(l.b) { return nil, false }
+	c := l.b[l.i]
+	switch {
+	case c == '(': return l.literal(), true
+	case c == '<' && l.i+1 < len(l.b) && l.b[l.i+1] == '<': l.i += 2; return pdfOp("<<"), true
+	case c == '>' && l.i+1 < len(l.b) && l.b[l.i+1] == '>': l.i += 2; return pdfOp(">>"), true
+	case c == '<':
+		j := bytes.IndexByte(l.b[l.i:], '>'); if j < 0 { j = len(l.b) - l.i }
+		h := strings.Map(func(r rune) rune { if strings.ContainsRune("0123456789abcdefABCDEF", r) { return r }; return -1 }, string(l.b[l.i+1:l.i+j]))
+		if len(h)%2 == 1 { h += "0" }
+		out := make([]byte, len(h)/2); for k := range out { v, _ := strconv.ParseUint(h[2*k:2*k+2], 16, 8); out[k] = byte(v) }
+		l.i += j + 1; return out, true
+	case c == '[' || c == ']' || c == '{' || c == '}': l.i++; return pdfOp(string(c)), true
+	case c == '/':
+		j := l.i + 1; for j < len(l.b) && !isPDFSpace(l.b[j]) && !isPDFDelim(l.b[j]) { j++ }
+		name := string(l.b[l.i+1 : j]); l.i = j; return pdfName(name), true
+	}
+	j := l.i; for j < len(l.b) && !isPDFSpace(l.b[j]) && !isPDFDelim(l.b[j]) { j++ }
+	if j == l.i { l.i++; return pdfOp(string(c)), true }
+	word := string(l.b[l.i:j]); l.i = j
+	if f, err := strconv.ParseFloat(word, 64); err == nil { return f, true }
+	return pdfOp(word), true
+}
+
+func (l *pdfLexer) literal() []byte {
+	var out []byte; depth := 0; l.i++
+	for ; l.i < len(l.b); l.i++ {
+		c := l.b[l.i]
+		switch {
+		case c == '(': depth++; out = append(out, c)
+		case c == ')': if depth == 0 { l.i++; return out }; depth--; out = append(out, c)
+		case c == '\\' && l.i+1 < len(l.b):
+			l.i++; e := l.b[l.i]
+			switch e {
+			case 'n': out = append(out, '\n')
+			case 'r': out = append(out, '\r')
+			case 't': out = append(out, '\t')
+			case 'b': out = append(out, '\b')
+			case 'f': out = append(out, '\f')
+			case '\r': if l.i+1 < len(l.b) && l.b[l.i+1] == '\n' { l.i++ }
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0; k := 0; for ; k < 3 && l.i+k < len(l.b) && l.b[l.i+k] >= '0' && l.b[l.i+k] <= '7'; k++ { v = v*8 + int(l.b[l.i+k]-'0') }
+					l.i += k - 1; out = append(out, byte(v))
+				} else { out = append(out, e) }
+			}
+		default: out = append(out, c)
+		}
+	}
+	return out
+}
+
+// value parses one object: dictionaries, arrays and "N G R" references included.
+func (l *pdfLexer) value() any {
+	tok, ok := l.token(); if !ok { return nil }
+	switch t := tok.(type) {
+	case pdfOp:
+		switch t {
+		case "<<":
+			d := pdfDict{}
+			for {
+				save := l.i; k, ok := l.token(); if !ok || k == pdfOp(">>") { return d }
+				name, isName := k.(pdfName); if !isName { l.i = save; l.value(); continue }
+				d[string(name)] = l.value()
+			}
+		case "[":
+			var a []any
+			for {
+				save := l.i; k, ok := l.token(); if !ok || k == pdfOp("]") { return a }
+				l.i = save; a = append(a, l.value())
+			}
+		case "true": return true
+		case "false": return false
+		case "null": return nil
+		}
+		return t
+	case float64:
+		save := l.i
+		if g, ok := l.token(); ok { if _, isNum := g.(float64); isNum { if r, ok := l.token(); ok && r == pdfOp("R") { return pdfRef(int(t)) } } }
+		l.i = save; return t
+	}
+	return tok
+}
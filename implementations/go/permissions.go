@@ -0,0 +1,113 @@
+// Persisted approval decisions. At the approval prompt, "always" and "never" save a rule to the
+// project's .nano/permissions.json: the tool and the exact command, path or URL, which can be
+// edited into a glob (* matches anything) before it is saved. Rules are consulted before
+// prompting on later runs, in CI and without a terminal; a matching deny wins over any allow,
+// and over --yes. Each rule records who made it and when, and calls it decides are audited
+// with that. Nothing is saved under --yes, where no one made the decision. `nano permissions`
+// lists and removes rules.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type permissionRule struct {
+	Tool     string `json:"tool"`
+	Pattern  string `json:"pattern"`  // the command, path or URL; * matches any run of characters
+	Decision string `json:"decision"` // "allow" or "deny"
+	By       string `json:"by"`
+	At       string `json:"at"`
+}
+
+type permissionFile struct{ Rules []permissionRule `json:"rules"` }
+
+func permissionsPath() string { return filepath.Join(workDir(), ".nano", "permissions.json") }
+
+func loadPermissions() (permissionFile, error) {
+	var pf permissionFile
+	data, err := os.ReadFile(permissionsPath())
+	if errors.Is(err, os.ErrNotExist) { return pf, nil } else if err != nil { return pf, err }
+	if err := json.Unmarshal(data, &pf); err != nil { return pf, fmt.Errorf("%s: %w", permissionsPath(), err) }
+	return pf, nil
+}
+
+func (pf permissionFile) save() error {
+	path := permissionsPath()
+	data, _ := json.MarshalIndent(pf, "", "  ")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil { return err }
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0644); err != nil { return err }
+	return os.Rename(path+".tmp", path)
+}
+
+func (r permissionRule) matches(tool, call string) bool {
+	if r.Tool != tool { return false }
+	if !strings.Contains(r.Pattern, "*") { return r.Pattern == call }
+	re := "^" + strings.ReplaceAll(regexp.QuoteMeta(r.Pattern), `\*`, ".*") + "$"
+	ok, _ := regexp.MatchString(re, call); return ok
+}
+
+// permissionFor returns the saved rule deciding a call, denies first.
+func permissionFor(tool, call string) (permissionRule, bool) {
+	pf, err := loadPermissions(); if err != nil { fmt.Fprintln(os.Stderr, "warning: permissions:", err); return permissionRule{}, false }
+	var allow *permissionRule
+	for i, r := range pf.Rules {
+		if !r.matches(tool, call) { continue }
+		if r.Decision == "deny" { return r, true }
+		if allow == nil { allow = &pf.Rules[i] }
+	}
+	if allow != nil { return *allow, true }
+	return permissionRule{}, false
+}
+
+func (r permissionRule) approval() string { return fmt.Sprintf("saved %s (%s, %s)", r.Decision, r.By, r.At) }
+
+// rememberDecision offers the call's pattern for editing and saves it as a rule.
+func rememberDecision(tool, call, decision string) {
+	label := "Always allow"; if decision == "deny" { label = "Never allow" }
+	fmt.Fprintf(os.Stderr, "%s %s calls matching (edit into a glob with *, Enter keeps it) [%s]: ", label, tool, call)
+	pattern := strings.TrimSpace(readAnswer()); if pattern == "" { pattern = call }
+	pf, err := loadPermissions()
+	if err == nil {
+		pf.Rules = append(pf.Rules, permissionRule{Tool: tool, Pattern: pattern, Decision: decision, By: username(), At: time.Now().UTC().Format(time.RFC3339)})
+		err = pf.save()
+	}
+	if err != nil { fmt.Fprintln(os.Stderr, "warning: could not save the permission:", err); return }
+	fmt.Fprintf(os.Stderr, "saved to %s\n", relPath(permissionsPath()))
+}
+
+func permissionsMain(fs *flag.FlagSet) func(args []string) int {
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano permissions [list] | nano permissions remove N...\n\nManages the approval rules saved in .nano/permissions.json."); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		pf, err := loadPermissions(); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		switch fs.Arg(0) {
+		case "", "list":
+			if len(pf.Rules) == 0 { fmt.Println("no saved permissions in", relPath(permissionsPath())); return 0 }
+			for i, r := range pf.Rules { fmt.Printf("%3d  %-5s  %-12s %s  (%s, %s)\n", i+1, r.Decision, r.Tool, r.Pattern, r.By, r.At) }
+		case "remove", "rm":
+			if fs.NArg() < 2 { fs.Usage(); return 1 }
+			drop := map[int]bool{}
+			for _, s := range fs.Args()[1:] {
+				n, err := strconv.Atoi(s); if err != nil || n < 1 || n > len(pf.Rules) { fmt.Fprintf(os.Stderr, "Error: no rule %s (see nano permissions list)\n", s); return 1 }
+				drop[n-1] = true
+			}
+			var kept []permissionRule
+			for i, r := range pf.Rules { if drop[i] { fmt.Printf("removed %s %s %s\n", r.Decision, r.Tool, r.Pattern) } else { kept = append(kept, r) } }
+			pf.Rules = kept
+			if err := pf.save(); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		default:
+			fs.Usage(); return 1
+		}
+		return 0
+	}
+}
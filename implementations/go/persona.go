@@ -0,0 +1,59 @@
+// Personas: named presets of system prompt, permission mode and tool set (--persona). The
+// built-ins can be replaced, and more added, under "personas" in the config file. Flags given
+// explicitly on the command line win over whatever the persona sets.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+type Persona struct {
+	Description  string   `json:"description,omitempty"`
+	System       string   `json:"system,omitempty"`     // appended to the base system prompt
+	Permission   string   `json:"permission,omitempty"` // ask (default), yes, read-only or plan
+	Tools        []string `json:"tools,omitempty"`
+	DisableTools []string `json:"disable_tools,omitempty"`
+}
+
+var builtinPersonas = map[string]Persona{
+	"reviewer":   {Description: "read-only code review", Permission: "read-only", System: "You are reviewing code, not changing it. Read what you need, then report concrete problems (bugs, missing error handling, unclear naming, missing tests) with file and line, most serious first. Say so plainly when something is fine."},
+	"tester":     {Description: "writes and runs tests", System: "Your job is tests. Before and after every change, run the project's test suite with bash and read the failures closely. Prefer adding focused tests for the behavior in question over changing production code, and never weaken an existing test to make it pass."},
+	"refactorer": {Description: "bold, behavior-preserving restructuring", System: "You are refactoring. Be willing to make sweeping structural changes (extract, rename, inline, move) when they simplify the code, but preserve behavior exactly: run the build and tests after each step and stop to fix anything that breaks."},
+	"docs":       {Description: "documentation writer, no shell", DisableTools: []string{"bash"}, System: "You write documentation: READMEs, doc comments and guides. Match the project's existing tone and format, keep examples accurate to the code you have read, and don't change program behavior."},
+}
+
+func lookupPersona(name string) (Persona, error) {
+	if p, ok := cfg.Personas[name]; ok { return p, p.validate(name) }
+	if p, ok := builtinPersonas[name]; ok { return p, nil }
+	names := map[string]bool{}
+	for n := range builtinPersonas { names[n] = true }
+	for n := range cfg.Personas { names[n] = true }
+	return Persona{}, fmt.Errorf("unknown persona %q (available: %s)", name, strings.Join(sortedKeys(names), ", "))
+}
+
+func (p Persona) validate(name string) error {
+	switch p.Permission { case "", "ask", "yes", "read-only", "plan": return nil }
+	return fmt.Errorf("persona %s: permission must be ask, yes, read-only or plan, not %q", name, p.Permission)
+}
+
+// defaults applies the persona's tool lists and permission mode wherever fs's flags were not
+// given explicitly; call it after parsing and before selecting tools.
+func (p Persona) defaults(fs *flag.FlagSet, enable, disable *[]string) {
+	set := map[string]bool{}; fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["tools"] && len(p.Tools) > 0 { *enable = p.Tools }
+	if !set["disable-tools"] && len(p.DisableTools) > 0 { *disable = p.DisableTools }
+	switch p.Permission {
+	case "yes": autoApprove = true
+	case "plan": if !set["plan"] && !set["plan-only"] && !set["no-tools"] { fs.Set("plan", "true") }
+	}
+}
+
+// restrict narrows a's tools and extends its system prompt once the tool set is chosen.
+func (p Persona) restrict(a *Agent, fs *flag.FlagSet) {
+	set := map[string]bool{}; fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if p.Permission == "read-only" && !set["tools"] && !set["yes"] { a.Tools = readOnly(a.Tools) }
+	if p.System != "" { a.System += "\n\n" + p.System }
+}
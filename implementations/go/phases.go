@@ -0,0 +1,65 @@
+// Cost by phase. Every API call is counted under the phase it served and the model that
+// served it, so a run can say where the money went, not only how much: main (the
+// conversation itself), compaction (summarizing history to fit the window), verify (--verify
+// reviews), summarize (the wrap-up when a deadline is near) and title (naming the session).
+// The run summary breaks the cost down by phase once there is more than one, --output json
+// and the usage ledger carry "phases" per phase and model, and `nano usage --by phase` totals
+// them. "usage" events carry the phase as well; only main calls drive the context meter.
+// Each phase is priced on its own: with --batch, only the calls that went through the
+// Batches API are halved, so the phases always add up to the run's cost.
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	phaseMain       = "main"
+	phaseCompaction = "compaction"
+	phaseVerify     = "verify"
+	phaseSummarize  = "summarize"
+	phaseTitle      = "title"
+)
+
+// phaseUsage is what one phase spent on one model.
+type phaseUsage struct {
+	Phase   string   `json:"phase"`
+	Model   string   `json:"model"`
+	Batched bool     `json:"batched,omitempty"` // sent through the Batches API, at half price
+	Calls   int      `json:"calls"`
+	Usage   Usage    `json:"usage"`
+	CostUSD *float64 `json:"cost_usd"` // nil when the model isn't priced
+}
+
+func (a *Agent) addPhase(phase, model string, batched bool, u Usage) {
+	for i := range a.phases { if p := &a.phases[i]; p.Phase == phase && p.Model == model && p.Batched == batched { p.Calls++; p.Usage.add(u); return } }
+	a.phases = append(a.phases, phaseUsage{Phase: phase, Model: model, Batched: batched, Calls: 1, Usage: u})
+}
+
+// cost prices p, halved when its calls were batched.
+func (p phaseUsage) cost() (float64, bool) {
+	c, ok := estimateCost(p.Model, p.Usage); if p.Batched { c /= 2 }; return c, ok
+}
+
+// phaseCosts are the phases priced, in the order they were first used.
+func (a *Agent) phaseCosts() []phaseUsage {
+	out := slices.Clone(a.phases)
+	for i := range out { if c, ok := out[i].cost(); ok { out[i].CostUSD = &c } }
+	return out
+}
+
+// phaseBreakdown is " · main $x, compaction $y" once calls went to more than one phase.
+func (a *Agent) phaseBreakdown() string {
+	var names []string; costs := map[string]*float64{}
+	for _, p := range a.phaseCosts() {
+		c, seen := costs[p.Phase]
+		if !seen { names = append(names, p.Phase); c = new(float64); costs[p.Phase] = c }
+		if c != nil && p.CostUSD != nil { *c += *p.CostUSD } else { costs[p.Phase] = nil }
+	}
+	if len(names) < 2 { return "" }
+	for i, n := range names { if c := costs[n]; c != nil { names[i] = fmt.Sprintf("%s $%.4f", n, *c) } else { names[i] = n + " cost unknown" } }
+	return " · " + strings.Join(names, ", ")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// phaseSum is the run's cost as the phases report it.
+func phaseSum(t *testing.T, a *Agent) float64 {
+	t.Helper(); sum := 0.0
+	for _, p := range a.phaseCosts() { if p.CostUSD == nil { t.Fatalf("%s on %s unpriced", p.Phase, p.Model) }; sum += *p.CostUSD }
+	return sum
+}
+
+func TestTitleCountsTowardTheRunTotals(t *testing.T) {
+	f := newFakeAPI(t, textReply("done"), textReply("Fix the parser"))
+	a := testAgent(t, f); t.Setenv("NANO_TITLE_MODEL", "claude-haiku-4-5")
+	if _, err := a.Run("fix the parser"); err != nil { t.Fatal(err) }
+	if title := a.makeTitle("fix the parser"); title != "Fix the parser" { t.Fatalf("title %q", title) }
+	if a.Usage.InputTokens != 20 || a.Usage.OutputTokens != 10 { t.Errorf("usage %+v, want the main and the title call", a.Usage) }
+	if u := a.byModel["claude-haiku-4-5"]; u.InputTokens != 10 { t.Errorf("title model usage %+v", u) }
+	total, ok := a.runCost(); if !ok { t.Fatal("unpriced") }
+	if sum := phaseSum(t, a); math.Abs(sum-total) > 1e-12 { t.Errorf("phases add up to %v, the run cost %v", sum, total) }
+	s, err := a.snapshot("s"); if err != nil { t.Fatal(err) }
+	if s.CostUSD == nil || math.Abs(*s.CostUSD-total) > 1e-12 { t.Errorf("session cost %v, want %v", s.CostUSD, total) }
+}
+
+func TestBatchDiscountsOnlyBatchedPhases(t *testing.T) {
+	a := testAgent(t, newFakeAPI(t)); a.batch = true
+	u := Usage{InputTokens: 1000, OutputTokens: 1000}
+	a.addUsage(phaseMain, u); a.countUsage(phaseTitle, "claude-haiku-4-5", false, u)
+	main, _ := estimateCost(a.Model, u); title, _ := estimateCost("claude-haiku-4-5", u)
+	for _, p := range a.phaseCosts() {
+		want := map[string]float64{phaseMain: main / 2, phaseTitle: title}[p.Phase]
+		if p.CostUSD == nil || math.Abs(*p.CostUSD-want) > 1e-12 { t.Errorf("%s costs %v, want %v", p.Phase, p.CostUSD, want) }
+		if p.Batched != (p.Phase == phaseMain) { t.Errorf("%s batched = %v", p.Phase, p.Batched) }
+	}
+	total, _ := a.runCost()
+	if want := main/2 + title; math.Abs(total-want) > 1e-12 { t.Errorf("run cost %v, want %v", total, want) }
+	if sum := phaseSum(t, a); math.Abs(sum-total) > 1e-12 { t.Errorf("phases add up to %v, the run cost %v", sum, total) }
+}
+
+func TestResultCostIsThisRunOnly(t *testing.T) {
+	a := testAgent(t, newFakeAPI(t, textReply("one"), textReply("two")))
+	r1, err := a.Run("first"); if err != nil { t.Fatal(err) }
+	r2, err := a.Run("second"); if err != nil { t.Fatal(err) }
+	if r1.CostUSD == nil || r2.CostUSD == nil { t.Fatal("unpriced") }
+	if math.Abs(*r1.CostUSD-*r2.CostUSD) > 1e-12 || *r2.CostUSD <= 0 { t.Errorf("costs %v and %v, want the same per call", *r1.CostUSD, *r2.CostUSD) }
+	if r2.Usage.InputTokens != 10 { t.Errorf("second run usage %+v", r2.Usage) }
+}
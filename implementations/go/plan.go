@@ -0,0 +1,59 @@
+// Plan mode: a read-only first phase drafts a numbered plan, the user accepts, edits or
+// aborts it, and the second phase executes it in the same conversation.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+const planInstruction = "\n\nYou are in planning mode and can only read files. Investigate as needed, then reply with a numbered implementation plan: the files to change, what changes, and how to verify the result. Do not write code yet."
+
+// Plan runs the planning phase and returns the prompt for the execution phase, or "" when
+// the run should stop here (--plan-only or the user aborted).
+func (a *Agent) Plan(prompt string, only bool) (string, error) {
+	tools, system := a.Tools, a.System
+	a.System, a.Tools = system+planInstruction, planTools(tools)
+	plan, err := a.Send(prompt)
+	a.System, a.Tools = system, tools
+	if err != nil { return "", err }
+	fmt.Println(plan)
+	if only { return "", nil }
+	if !isTTY(os.Stdin) { return "", fmt.Errorf("--plan needs a terminal to review the plan; use --plan-only to just print it") }
+	in := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "\nExecute this plan? [a]ccept / [e]dit / a[b]ort: ")
+		line, err := readStopping(func() (string, error) { return in.ReadString('\n') }); if err != nil { return "", err }
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "a", "accept", "y", "yes":
+			return "The plan is approved. Implement it now, following it step by step:\n\n" + plan, nil
+		case "e", "edit":
+			if plan, err = editText(plan); err != nil { return "", err }
+			fmt.Println(plan)
+		case "b", "abort", "n", "no":
+			fmt.Fprintln(os.Stderr, "Aborted; nothing was changed."); return "", nil
+		}
+	}
+}
+
+// planTools are the read-only tools less finish, so the planning phase ends with a plan
+// rather than an outcome.
+func planTools(tools []Tool) []Tool {
+	return slices.DeleteFunc(readOnly(tools), func(t Tool) bool { return t.Name == "finish" })
+}
+
+// editText opens text in $EDITOR (vi if unset) and returns the saved result.
+func editText(text string) (string, error) {
+	f, err := os.CreateTemp("", "nano-plan-*.md"); if err != nil { return "", err }
+	defer os.Remove(f.Name())
+	f.WriteString(text); f.Close()
+	cmd := exec.Command("sh", "-c", env("EDITOR", "vi")+` "$1"`, "sh", f.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil { return "", fmt.Errorf("editor: %w", err) }
+	data, err := os.ReadFile(f.Name()); return strings.TrimSpace(string(data)), err
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanningPhaseCannotFinish(t *testing.T) {
+	f := newFakeAPI(t, textReply("1. change main.go"))
+	a := testAgent(t, f); before := len(a.Tools)
+	if _, ok := a.lookup("finish"); !ok { t.Fatal("finish isn't registered; nothing to check") }
+	out := captureStdout(t, func() { if _, err := a.Plan("add a flag", true); err != nil { t.Fatal(err) } })
+	if !strings.Contains(out, "1. change main.go") { t.Errorf("plan not printed: %q", out) }
+	var names []string
+	for _, s := range f.request(t, 0)["tools"].([]any) { names = append(names, s.(map[string]any)["name"].(string)) }
+	for _, n := range names { if n == "finish" || n == "write_file" { t.Errorf("planning offered %s: %v", n, names) } }
+	if !strings.Contains(strings.Join(names, " "), "read_file") { t.Errorf("planning lost read_file: %v", names) }
+	if sys, _ := f.request(t, 0)["system"].(string); strings.Contains(sys, finishRule) { t.Error("the system prompt still tells the planner to call finish") }
+	if len(a.Tools) != before { t.Errorf("tools not restored: %d, want %d", len(a.Tools), before) }
+}
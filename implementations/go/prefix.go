@@ -0,0 +1,28 @@
+// Project prompt prefix: config "prompt_prefix", usually from a committed .nano.json, goes
+// before the first prompt of a conversation, in the user turn rather than the system prompt,
+// for standing guidance that works better there ("follow CONTRIBUTING.md, run make check
+// before finishing"). It is prepended to whatever that first prompt is: a rendered -t
+// template, the --plan request, the first line typed in the REPL, or the first prompt after
+// --history's seed messages. With --then only the first step gets it, the others being turns
+// of the same conversation, and a resumed session already had it. --no-prefix leaves it out;
+// --filter never uses it, each chunk being a one-off transform.
+
+package main
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// prefixed returns prompt with the prefix before it when it starts the conversation.
+func (a *Agent) prefixed(prompt string) string {
+	p := strings.TrimSpace(a.prefix); if p == "" || len(a.Messages) > a.pinned { return prompt }
+	slog.Debug("prompt prefix from config", "prefix", p)
+	return p + "\n\n" + prompt
+}
+
+// unprefixed strips the configured prefix from a first prompt, for titles and listings.
+func unprefixed(s string) string {
+	if p := strings.TrimSpace(cfg.PromptPrefix); p != "" { return strings.TrimPrefix(s, p+"\n\n") }
+	return s
+}
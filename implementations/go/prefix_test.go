@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPrefix = "Follow CONTRIBUTING.md."
+
+// userTexts are the string prompts of a request, in order.
+func userTexts(t *testing.T, req map[string]any) []string {
+	t.Helper(); var out []string
+	for _, m := range req["messages"].([]any) { if s, ok := m.(map[string]any)["content"].(string); ok { out = append(out, s) } }
+	return out
+}
+
+// prefixProject is a project directory whose config sets testPrefix and a "tests" template.
+func prefixProject(t *testing.T) string {
+	t.Helper(); dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, ".nano.json"), `{"prompt_prefix":"`+testPrefix+`"}`)
+	os.MkdirAll(filepath.Join(dir, ".nano", "prompts"), 0755)
+	writeTestFile(t, filepath.Join(dir, ".nano", "prompts", "tests.md"), "Write tests for {{.target}}.")
+	return dir
+}
+
+func TestPrefixGoesOnlyOnTheFirstPrompt(t *testing.T) {
+	f := newFakeAPI(t, textReply("one"), textReply("two"), textReply("three"))
+	if _, stderr, code := runNano(t, f, prefixProject(t), "--then", "second", "--then", "third", "first"); code != 0 { t.Fatalf("exit %d: %s", code, stderr) }
+	got := userTexts(t, f.request(t, 2))
+	if len(got) != 3 || got[0] != testPrefix+"\n\nfirst" || got[1] != "second" || got[2] != "third" { t.Errorf("prompts %q", got) }
+}
+
+func TestPrefixComposesWithATemplate(t *testing.T) {
+	f := newFakeAPI(t, textReply("ok"))
+	if _, stderr, code := runNano(t, f, prefixProject(t), "-t", "tests", "--var", "target=parser", "Keep them short."); code != 0 { t.Fatalf("exit %d: %s", code, stderr) }
+	if got := userTexts(t, f.request(t, 0)); len(got) != 1 || got[0] != testPrefix+"\n\nWrite tests for parser.\n\nKeep them short." { t.Errorf("prompt %q", got) }
+}
+
+func TestNoPrefixLeavesItOut(t *testing.T) {
+	f := newFakeAPI(t, textReply("ok"), textReply("ok"))
+	if _, stderr, code := runNano(t, f, prefixProject(t), "--no-prefix", "--then", "second", "first"); code != 0 { t.Fatalf("exit %d: %s", code, stderr) }
+	if got := userTexts(t, f.request(t, 1)); len(got) != 2 || got[0] != "first" || got[1] != "second" { t.Errorf("prompts %q", got) }
+}
+
+func TestPrefixFollowsHistorySeeds(t *testing.T) {
+	f := newFakeAPI(t, textReply("ok"))
+	a := testAgent(t, f); a.prefix = testPrefix
+	a.WithInitialMessages([]Message{{Role: "user", Content: "seed question"}, {Role: "assistant", Content: []Block{{Type: "text", Text: "seed answer"}}}})
+	if _, err := a.Run("real prompt"); err != nil { t.Fatal(err) }
+	if got := userTexts(t, f.request(t, 0)); len(got) != 2 || got[0] != "seed question" || got[1] != testPrefix+"\n\nreal prompt" { t.Errorf("prompts %q", got) }
+}
+
+func TestUnprefixedStripsThePrefixForTitles(t *testing.T) {
+	saved := cfg; t.Cleanup(func() { cfg = saved }); cfg.PromptPrefix = testPrefix
+	if got := unprefixed(testPrefix + "\n\nfix the parser"); got != "fix the parser" { t.Errorf("got %q", got) }
+	if got := unprefixed("fix the parser"); got != "fix the parser" { t.Errorf("got %q", got) }
+}
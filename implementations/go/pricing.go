@@ -0,0 +1,95 @@
+// Model pricing in USD per million tokens. Built-in entries cover the Anthropic models; the
+// config file's "pricing" list overrides or extends them, e.g.
+//
+//	"pricing": [{"model": "gpt-4o-*", "input": 2.5, "output": 10}]
+//
+// Patterns are globs over the model name (path.Match syntax); user entries are tried first,
+// in order, then the built-ins, and the first match wins. Cache prices default to 1.25x
+// (writes) and 0.1x (reads) the input price. A gateway's "vendor/model" name falls back to
+// the model part. A model no entry matches has an unknown cost.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+)
+
+type Price struct {
+	Model      string  `json:"model"`
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheWrite float64 `json:"cache_write,omitempty"`
+	CacheRead  float64 `json:"cache_read,omitempty"`
+}
+
+var builtinPricing = []Price{
+	{"claude-opus-4-5*", 5, 25, 6.25, 0.5}, {"claude-opus-4*", 15, 75, 18.75, 1.5}, {"claude-3-opus*", 15, 75, 18.75, 1.5},
+	{"claude-sonnet-4*", 3, 15, 3.75, 0.3}, {"claude-3-7-sonnet*", 3, 15, 3.75, 0.3}, {"claude-3-5-sonnet*", 3, 15, 3.75, 0.3},
+	{"claude-haiku-4*", 1, 5, 1.25, 0.1}, {"claude-3-5-haiku*", 0.8, 4, 1, 0.08}, {"claude-3-haiku*", 0.25, 1.25, 0.3, 0.03},
+}
+
+type priceSource struct{ name string; table []Price }
+
+func pricingSources() []priceSource { return []priceSource{{"user", cfg.Pricing}, {"builtin", builtinPricing}} }
+
+func (p Price) withCacheDefaults() Price {
+	if p.CacheWrite == 0 { p.CacheWrite = p.Input * 1.25 }
+	if p.CacheRead == 0 { p.CacheRead = p.Input * 0.1 }
+	return p
+}
+
+// priceFor resolves model against the user's entries, then the built-ins.
+func priceFor(model string) (Price, string, bool) {
+	for _, src := range pricingSources() {
+		for _, p := range src.table { if ok, _ := path.Match(p.Model, model); ok { return p.withCacheDefaults(), src.name, true } }
+	}
+	if b := baseModel(model); b != model { return priceFor(b) }
+	return Price{}, "", false
+}
+
+// estimateCost returns the cost of u on model, or false when the model isn't priced. What a
+// gateway reported takes the place of the estimate.
+func estimateCost(model string, u Usage) (float64, bool) {
+	if u.Cost > 0 { return u.Cost, true }
+	p, _, ok := priceFor(model); if !ok { return 0, false }
+	return (float64(u.InputTokens)*p.Input + float64(u.OutputTokens)*p.Output + float64(u.CacheCreation)*p.CacheWrite + float64(u.CacheRead)*p.CacheRead) / 1e6, true
+}
+
+// cost is estimateCost for this agent's model, halved for the Batches API.
+func (a *Agent) cost(u Usage) (float64, bool) {
+	c, ok := estimateCost(a.Model, u); if a.batch && u.Cost == 0 { c /= 2 }; return c, ok
+}
+
+func validatePricing(table []Price) error {
+	for _, p := range table {
+		if _, err := path.Match(p.Model, ""); err != nil || p.Model == "" { return fmt.Errorf("pricing: bad model pattern %q", p.Model) }
+		if p.Input < 0 || p.Output < 0 || p.CacheWrite < 0 || p.CacheRead < 0 { return fmt.Errorf("pricing: %s: prices can't be negative", p.Model) }
+	}
+	return nil
+}
+
+func pricingMain(fs *flag.FlagSet) func(args []string) int {
+	model := fs.String("model", "", "show which entry prices `name`")
+	return func(args []string) int {
+		fs.Parse(args)
+		if err := validatePricing(cfg.Pricing); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		if *model != "" {
+			p, src, ok := priceFor(resolveModel(*model))
+			if !ok { fmt.Printf("%s: no pricing entry; cost unknown\n", *model); return 1 }
+			fmt.Printf("%s: matched %s (%s): $%g in, $%g out, $%g cache write, $%g cache read per Mtok\n", *model, p.Model, src, p.Input, p.Output, p.CacheWrite, p.CacheRead)
+			return 0
+		}
+		fmt.Printf("%-28s %8s %8s %11s %10s  %s\n", "model", "input", "output", "cache write", "cache read", "source")
+		for _, src := range pricingSources() {
+			for _, p := range src.table {
+				p = p.withCacheDefaults()
+				fmt.Printf("%-28s %8g %8g %11g %10g  %s\n", p.Model, p.Input, p.Output, p.CacheWrite, p.CacheRead, src.name)
+			}
+		}
+		fmt.Println("(USD per million tokens; first match wins, user entries first)")
+		return 0
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+func processAlive(pid int) bool { _, err := os.FindProcess(pid); return err == nil }
+
+func ownProcessGroup(*exec.Cmd) {}
@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// processAlive reports whether pid is running; EPERM means it is, just not ours to signal.
+func processAlive(pid int) bool { err := syscall.Kill(pid, 0); return err == nil || err == syscall.EPERM }
+
+// ownProcessGroup starts cmd in a process group of its own and makes cancelling it kill the
+// whole group, so the children of a build or a watcher die with it.
+func ownProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+}
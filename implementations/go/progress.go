@@ -0,0 +1,106 @@
+// Progress notes. On a long run the model loses track of what it has already done and starts
+// redoing it, so every progress_every API calls (config "progress_every", $NANO_PROGRESS_EVERY
+// or --progress-every; default 25, 0 or less turns them off) nano adds a short account of the
+// run's state to the next tool results: the files written so far and every command run, with
+// the exit code of its last run. It is built from what the tools did, not asked of a model, so
+// it costs nothing and says only what is so. Unlike compaction it rewrites nothing; but once a
+// note is sent, the context manager may trim what precedes it, keep_turns or not, and stubs
+// earlier notes it supersedes. Each note is announced on stderr and stays in the transcript.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultProgressEvery = 25
+	progressMarker       = "[progress so far"
+	progressCommands     = 15 // most recent distinct commands listed
+	progressFiles        = 30
+)
+
+// progressLog is what the notes are made from.
+type progressLog struct {
+	every    int
+	lastNote int        // a.Turns when the last note went out
+	commands []cmdState // distinct commands, most recently run last
+}
+
+type cmdState struct{ command, exit string; runs int }
+
+var exitStatus = regexp.MustCompile(`exit status (\d+)\s*$`)
+
+// WithProgressNotes sets how many API calls go between progress notes; 0 turns them off.
+func (a *Agent) WithProgressNotes(every int) *Agent { a.progress.every = every; return a }
+
+// progressEvery is the configured interval: the environment, then config, then the default.
+func progressEvery() int {
+	if v, err := strconv.Atoi(env("NANO_PROGRESS_EVERY", "")); err == nil { return max(v, 0) }
+	if cfg.ProgressEvery != 0 { return max(cfg.ProgressEvery, 0) }
+	return defaultProgressEvery
+}
+
+// trackCommand records a bash call's outcome: its exit code, or "error" when it never ran.
+func (p *progressLog) trackCommand(command, out string, isErr bool) {
+	exit := "0"
+	if isErr { exit = "error"; if m := exitStatus.FindStringSubmatch(out); m != nil { exit = m[1] } }
+	runs := 1
+	if i := slices.IndexFunc(p.commands, func(c cmdState) bool { return c.command == command }); i >= 0 { runs += p.commands[i].runs; p.commands = slices.Delete(p.commands, i, i+1) }
+	p.commands = append(p.commands, cmdState{command, exit, runs})
+}
+
+// progressNote returns the note due now, or "" when none is.
+func (a *Agent) progressNote() string {
+	if a.progress.every <= 0 || a.Turns-a.progress.lastNote < a.progress.every { return "" }
+	a.progress.lastNote = a.Turns
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s, after %d turns; kept by nano from what the tools did, so trust it over your memory of earlier turns]\n", progressMarker, a.Turns)
+	files := make([]string, 0, len(a.touched)); for _, p := range a.touched { files = append(files, relPath(p)) }
+	switch {
+	case len(files) == 0: b.WriteString("Files written: none yet.\n")
+	case len(files) > progressFiles: fmt.Fprintf(&b, "Files written (%d): %s, and %d more.\n", len(files), strings.Join(files[:progressFiles], ", "), len(files)-progressFiles)
+	default: fmt.Fprintf(&b, "Files written (%d): %s.\n", len(files), strings.Join(files, ", "))
+	}
+	cmds := a.progress.commands
+	if len(cmds) == 0 { b.WriteString("Commands run: none yet.\n") } else {
+		if len(cmds) > progressCommands { fmt.Fprintf(&b, "Commands run: %d distinct; the last %d, with the exit code of their latest run:\n", len(cmds), progressCommands); cmds = cmds[len(cmds)-progressCommands:] } else { b.WriteString("Commands run, with the exit code of their latest run:\n") }
+		for _, c := range cmds {
+			runs := ""; if c.runs > 1 { runs = fmt.Sprintf(" (run %d times)", c.runs) }
+			fmt.Fprintf(&b, "  exit %-5s $ %s%s\n", c.exit, truncate(strings.ReplaceAll(c.command, "\n", " "), 160), runs)
+		}
+	}
+	b.WriteString("Carry on from here; don't redo what is listed unless something has changed since.")
+	a.notify(fmt.Sprintf("↻ progress note after %d turns: files written %d, commands run %d", a.Turns, len(files), len(a.progress.commands)))
+	return b.String()
+}
+
+// lastProgressNote is the index of the latest message carrying a progress note, or -1.
+func lastProgressNote(msgs []Message) int {
+	for i := len(msgs) - 1; i >= 0; i-- { if msgs[i].Role == "user" && progressBlocks(msgs[i].Content) != nil { return i } }
+	return -1
+}
+
+// progressBlocks returns the progress-note text blocks of a user message's content.
+func progressBlocks(content any) []map[string]any {
+	var out []map[string]any; var items []any
+	switch c := content.(type) {
+	case []map[string]any: for _, m := range c { items = append(items, m) }
+	case []any: items = c
+	}
+	for _, v := range items {
+		if m, ok := v.(map[string]any); ok && m["type"] == "text" { if s, _ := m["text"].(string); strings.HasPrefix(s, progressMarker) { out = append(out, m) } }
+	}
+	return out
+}
+
+// stubProgress shortens the superseded progress notes in cloned content.
+func stubProgress(content any) int {
+	n := 0
+	for _, m := range progressBlocks(content) { m["text"] = progressMarker + ": superseded by a later note]"; n++ }
+	return n
+}
@@ -0,0 +1,107 @@
+// Project variables: .nano/env holds the settings a project's own commands need but that
+// don't belong in a committed .nano.json (a database URL for a migration check, an internal
+// registry token). They go to the commands the user defines, verify_command (see gate.go)
+// and fix mode's check command, and to nothing the model writes: bash runs without them,
+// and the model is never told them. In verify_command, ${NAME} refers to one (or to an
+// environment variable); the shell expands it when the command runs, so the value itself
+// stays out of notices, logs and the report, and a reference to a variable set nowhere
+// fails at startup rather than at the end of a run. Values that look secret are scrubbed
+// from logs and from command output before the model sees it; --print-config shows them
+// as redacted.
+//
+//	# comments and blank lines are skipped; "export " is allowed
+//	DATABASE_URL=postgres://localhost/dev   # unquoted: up to a " #" comment, trimmed
+//	GREETING="two\nlines"                   # double quotes: \n \t \" \\ escapes
+//	REGISTRY_TOKEN='lit$eral'               # single quotes: taken as is
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// projectEnv are the .nano/env variables, as NAME=VALUE.
+var projectEnv []string
+
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var varRef = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+func projectEnvPath() string { return filepath.Join(".nano", "env") }
+
+// loadProjectEnv reads .nano/env, if there is one, and checks the commands that refer to it.
+func loadProjectEnv() error {
+	data, err := os.ReadFile(projectEnvPath())
+	if os.IsNotExist(err) { projectEnv = nil } else if err != nil { return err } else if projectEnv, err = parseEnv(string(data)); err != nil { return fmt.Errorf("%s: %w", projectEnvPath(), err) }
+	for _, kv := range projectEnv { if k, v, _ := strings.Cut(kv, "="); v != "" && looksSecret(k+"="+v) { logSecrets = append(logSecrets, v) } }
+	return checkRefs("verify_command", cfg.VerifyCommand)
+}
+
+// parseEnv reads KEY=VALUE lines; later lines win.
+func parseEnv(s string) ([]string, error) {
+	var out []string; seen := map[string]int{}
+	for i, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if line == "" || line[0] == '#' { continue }
+		line = strings.TrimPrefix(line, "export ")
+		k, v, ok := strings.Cut(line, "="); k = strings.TrimSpace(k)
+		if !ok || !envName.MatchString(k) { return nil, fmt.Errorf("line %d: want NAME=VALUE", i+1) }
+		v, err := envValue(strings.TrimSpace(v)); if err != nil { return nil, fmt.Errorf("line %d: %s: %w", i+1, k, err) }
+		if j, ok := seen[k]; ok { out[j] = k + "=" + v; continue }
+		seen[k] = len(out); out = append(out, k+"="+v)
+	}
+	return out, nil
+}
+
+// envValue unquotes a value and drops a trailing comment.
+func envValue(v string) (string, error) {
+	if v == "" || v[0] != '"' && v[0] != '\'' {
+		if i := strings.Index(v, " #"); i >= 0 { v = v[:i] }
+		return strings.TrimSpace(v), nil
+	}
+	q := v[0]; var b strings.Builder
+	for i := 1; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == q:
+			if rest := strings.TrimSpace(v[i+1:]); rest != "" && rest[0] != '#' { return "", fmt.Errorf("unexpected %q after the closing quote", rest) }
+			return b.String(), nil
+		case c == '\\' && q == '"' && i+1 < len(v): i++; b.WriteRune(unescape(v[i]))
+		default: b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("missing closing %c", q)
+}
+
+// checkRefs fails when cmd refers to a variable that is set neither in .nano/env nor in the environment.
+func checkRefs(what, cmd string) error {
+	var missing []string
+	for _, m := range varRef.FindAllStringSubmatch(cmd, -1) {
+		if !envName.MatchString(m[1]) { return fmt.Errorf("config %s: bad variable reference %q", what, m[0]) }
+		if _, ok := lookupProjectEnv(m[1]); !ok { missing = append(missing, m[1]) }
+	}
+	if missing != nil { return fmt.Errorf("config %s refers to %s, set neither in %s nor in the environment", what, strings.Join(missing, ", "), projectEnvPath()) }
+	return nil
+}
+
+func lookupProjectEnv(name string) (string, bool) {
+	for i := len(projectEnv) - 1; i >= 0; i-- { if v, ok := strings.CutPrefix(projectEnv[i], name+"="); ok { return v, true } }
+	return os.LookupEnv(name)
+}
+
+// withProjectEnv adds the .nano/env variables to env (nil meaning nano's own).
+func withProjectEnv(env []string) []string {
+	if env == nil { env = os.Environ() }
+	return append(env, projectEnv...)
+}
+
+// projectEnvSummary is what --print-config shows: the names, with secret-looking values redacted.
+func projectEnvSummary() map[string]string {
+	out := map[string]string{}
+	for _, kv := range projectEnv { k, v, _ := strings.Cut(kv, "="); if looksSecret(kv) { v = "[REDACTED]" }; out[k] = v }
+	return out
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseEnv(t *testing.T) {
+	for _, c := range []struct{ name, in string; want []string; err string }{
+		{"comments and blanks", "# top\n\n  # indented\nA=1\n\n", []string{"A=1"}, ""},
+		{"export and spaces", "export A = 1 \r\n", []string{"A=1"}, ""},
+		{"unquoted comment", "URL=postgres://localhost/dev   # local\nFRAG=a#b", []string{"URL=postgres://localhost/dev", "FRAG=a#b"}, ""},
+		{"double quotes", `G="two\nlines \"q\" \\ # not a comment" # but this is`, []string{"G=two\nlines \"q\" \\ # not a comment"}, ""},
+		{"single quotes", `T='lit$eral\n'`, []string{`T=lit$eral\n`}, ""},
+		{"empty values", "A=\nB=\"\"", []string{"A=", "B="}, ""},
+		{"later wins in place", "A=1\nB=2\nA=3", []string{"A=3", "B=2"}, ""},
+		{"value with =", "Q=a=b", []string{"Q=a=b"}, ""},
+		{"no equals", "A=1\nJUSTNAME", nil, "line 2: want NAME=VALUE"},
+		{"bad name", "1A=x", nil, "line 1: want NAME=VALUE"},
+		{"unclosed quote", `A="open`, nil, `line 1: A: missing closing "`},
+		{"junk after quote", `A='x' y`, nil, `line 1: A: unexpected "y" after the closing quote`},
+	} {
+		got, err := parseEnv(c.in)
+		if c.err != "" { if err == nil || err.Error() != c.err { t.Errorf("%s: error %v, want %q", c.name, err, c.err) }; continue }
+		if err != nil || !slices.Equal(got, c.want) { t.Errorf("%s: %q %v, want %q", c.name, got, err, c.want) }
+	}
+}
+
+func TestUnsetVariableInVerifyCommandFailsAtStartup(t *testing.T) {
+	inTempDir(t)
+	saved, savedEnv := cfg, projectEnv; t.Cleanup(func() { cfg, projectEnv = saved, savedEnv })
+	cfg.VerifyCommand = "migrate --check ${DATABASE_URL} ${NANO_TEST_UNSET}"
+	t.Setenv("NANO_TEST_UNSET", ""); os.Unsetenv("NANO_TEST_UNSET") // restored at cleanup
+	err := loadProjectEnv()
+	if err == nil || !strings.Contains(err.Error(), "refers to DATABASE_URL, NANO_TEST_UNSET, set neither in") { t.Fatalf("got %v", err) }
+	os.MkdirAll(".nano", 0755); writeTestFile(t, filepath.Join(".nano", "env"), "DATABASE_URL=postgres://localhost/dev\n")
+	t.Setenv("NANO_TEST_UNSET", "now set")
+	if err := loadProjectEnv(); err != nil { t.Errorf("with both set: %v", err) }
+	cfg.VerifyCommand = "check ${not-a-name}"
+	if err := loadProjectEnv(); err == nil || !strings.Contains(err.Error(), "bad variable reference") { t.Errorf("bad reference: %v", err) }
+}
+
+func TestUnsetVariableStopsNanoBeforeAnyRequest(t *testing.T) {
+	f := newFakeAPI(t, textReply("never"))
+	dir := t.TempDir(); writeTestFile(t, filepath.Join(dir, ".nano.json"), `{"verify_command":"make check DB=${NANO_TEST_MISSING_DB}"}`)
+	_, stderr, code := runNano(t, f, dir, "hello")
+	if code != 1 || !strings.Contains(stderr, "NANO_TEST_MISSING_DB") { t.Errorf("exit %d: %s", code, stderr) }
+	if f.count() != 0 { t.Errorf("%d requests sent before failing", f.count()) }
+}
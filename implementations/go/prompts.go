@@ -0,0 +1,151 @@
+// Prompt templates: markdown files in ~/.config/nano/prompts/ and .nano/prompts/ (the project's
+// win on a name clash), run with -t name --var key=value. The body is a text/template over the
+// variables; an optional front-matter block declares them and sets default flags:
+//
+//	---
+//	description: Table-driven tests for a package
+//	vars: {target: package to test, style: test style}   (or a list of names)
+//	model: claude-opus-4-20250514
+//	mode: plan                                            (plan, plan-only or chat)
+//	---
+//
+// Any other front-matter key names a flag (tool-choice, temperature, ...). Flags given on the
+// command line win over the template's.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+type promptTemplate struct {
+	Name, Path, Description string
+	Vars                    map[string]string // name -> description
+	Flags                   map[string]string
+	Body                    string
+}
+
+func promptDirs() []string {
+	dir, _ := os.UserConfigDir()
+	return []string{filepath.Join(dir, "nano", "prompts"), filepath.Join(".nano", "prompts")}
+}
+
+// promptTemplates loads every template, later directories overriding earlier ones.
+func promptTemplates() (map[string]promptTemplate, error) {
+	out := map[string]promptTemplate{}
+	for _, dir := range promptDirs() {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.md"))
+		for _, f := range files {
+			t, err := loadPrompt(f); if err != nil { return nil, err }
+			out[t.Name] = t
+		}
+	}
+	return out, nil
+}
+
+func loadPrompt(path string) (promptTemplate, error) {
+	data, err := os.ReadFile(path); if err != nil { return promptTemplate{}, err }
+	t := promptTemplate{Name: strings.TrimSuffix(filepath.Base(path), ".md"), Path: path, Vars: map[string]string{}, Flags: map[string]string{}}
+	body := strings.ReplaceAll(string(data), "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(body, "---\n"); ok {
+		front, after, ok := strings.Cut(rest, "\n---\n")
+		if !ok { front, after, ok = strings.Cut(rest, "\n---"); ok = ok && strings.TrimSpace(after) == "" }
+		if !ok { return t, fmt.Errorf("%s: front matter has no closing ---", path) }
+		body = after
+		v, err := parseYAML(front); if err != nil { return t, fmt.Errorf("%s: %w", path, err) }
+		m, _ := v.(map[string]any); if v != nil && m == nil { return t, fmt.Errorf("%s: front matter must be a mapping", path) }
+		for k, val := range m {
+			switch k {
+			case "description": t.Description = fmt.Sprint(val)
+			case "vars":
+				switch vs := val.(type) {
+				case []any: for _, n := range vs { t.Vars[fmt.Sprint(n)] = "" }
+				case map[string]any: for n, d := range vs { t.Vars[n] = fmt.Sprint(d) }
+				default: return t, fmt.Errorf("%s: vars must be a list or a mapping", path)
+				}
+			case "mode":
+				switch val { case "plan", "plan-only": t.Flags[fmt.Sprint(val)] = "true"; case "chat": t.Flags["no-tools"] = "true"
+				default: return t, fmt.Errorf("%s: mode must be plan, plan-only or chat", path) }
+			default: t.Flags[k] = fmt.Sprint(val)
+			}
+		}
+	}
+	t.Body = strings.TrimSpace(body)
+	return t, nil
+}
+
+// render substitutes vars, failing with every missing variable named at once.
+func (t promptTemplate) render(vars map[string]string) (string, error) {
+	tmpl, err := template.New(t.Name).Option("missingkey=error").Parse(t.Body); if err != nil { return "", fmt.Errorf("template %s: %w", t.Name, err) }
+	var missing []string
+	for _, n := range append(sortedKeys(t.Vars), templateFields(tmpl.Tree.Root)...) {
+		if _, ok := vars[n]; !ok && !slices.Contains(missing, n) { missing = append(missing, n) }
+	}
+	if len(missing) > 0 { return "", fmt.Errorf("template %s: missing variables: %s (pass --var name=value)", t.Name, strings.Join(missing, ", ")) }
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil { return "", fmt.Errorf("template %s: %w", t.Name, err) }
+	return b.String(), nil
+}
+
+// templateFields lists the top-level .Name references in a template, in order of appearance.
+func templateFields(n parse.Node) (out []string) {
+	switch n := n.(type) {
+	case *parse.ListNode: if n != nil { for _, c := range n.Nodes { out = append(out, templateFields(c)...) } }
+	case *parse.ActionNode: out = templateFields(n.Pipe)
+	case *parse.PipeNode: if n != nil { for _, c := range n.Cmds { out = append(out, templateFields(c)...) } }
+	case *parse.CommandNode: for _, a := range n.Args { out = append(out, templateFields(a)...) }
+	case *parse.FieldNode: out = n.Ident[:1]
+	case *parse.IfNode: out = append(append(templateFields(n.Pipe), templateFields(n.List)...), templateFields(n.ElseList)...)
+	case *parse.RangeNode: out = append(templateFields(n.Pipe), templateFields(n.ElseList)...) // inside the body . is the element
+	case *parse.WithNode: out = append(templateFields(n.Pipe), templateFields(n.ElseList)...)
+	}
+	return
+}
+
+// applyTemplate loads template name, sets its default flags on fs where the command line
+// didn't, and returns the rendered prompt with any extra prompt words appended.
+func applyTemplate(fs *flag.FlagSet, name string, vars map[string]string, extra string) (string, error) {
+	all, err := promptTemplates(); if err != nil { return "", err }
+	t, ok := all[name]
+	if !ok { return "", fmt.Errorf("no prompt template %q (see nano prompts)", name) }
+	set := map[string]bool{}; fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, k := range sortedKeys(t.Flags) {
+		if fs.Lookup(k) == nil || slices.Contains([]string{"t", "template", "var"}, k) { return "", fmt.Errorf("template %s: unknown flag %q in front matter", name, k) }
+		if set[k] { continue }
+		if err := fs.Set(k, t.Flags[k]); err != nil { return "", fmt.Errorf("template %s: %s: %w", name, k, err) }
+	}
+	prompt, err := t.render(vars); if err != nil { return "", err }
+	if extra != "" { prompt += "\n\n" + extra }
+	return prompt, nil
+}
+
+func varFlag(fs *flag.FlagSet) map[string]string {
+	vars := map[string]string{}
+	fs.Func("var", "template variable `name=value`; repeatable", func(v string) error {
+		k, val, ok := strings.Cut(v, "="); if !ok || k == "" { return fmt.Errorf("want name=value") }
+		vars[k] = val; return nil
+	})
+	return vars
+}
+
+func promptsMain(fs *flag.FlagSet) func(args []string) int {
+	return func(args []string) int {
+		fs.Parse(args)
+		all, err := promptTemplates(); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		if len(all) == 0 { fmt.Fprintf(os.Stderr, "no prompt templates (add *.md files to %s)\n", strings.Join(promptDirs(), " or ")); return 0 }
+		for _, name := range sortedKeys(all) {
+			t := all[name]
+			fmt.Printf("%-16s %s\n", name, t.Description)
+			for _, v := range sortedKeys(t.Vars) { d := t.Vars[v]; if d != "" { d = "  " + d }; fmt.Printf("  --var %s=…%s\n", v, d) }
+			if len(t.Flags) > 0 { var fl []string; for _, k := range sortedKeys(t.Flags) { fl = append(fl, "--"+k+"="+t.Flags[k]) }; fmt.Printf("  defaults: %s\n", strings.Join(fl, " ")) }
+		}
+		return 0
+	}
+}
@@ -0,0 +1,187 @@
+// The query_data tool: pull matching fragments out of large JSON, YAML or CSV files so they
+// never have to be read whole. JSON and YAML take dotted paths ("services.*.image",
+// "items[0].name", "**.memory" for any depth) with an optional comparison ("... > 2Gi"); CSV
+// takes column conditions joined by "and" ("status = failed and retries >= 3").
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const maxMatches = 200
+
+type condition struct{ lhs, op, rhs string }
+
+var condRe = regexp.MustCompile(`^(.*?)\s*(>=|<=|!=|=|>|<|~)\s*(.*)$`)
+
+func parseCondition(q string) condition {
+	m := condRe.FindStringSubmatch(q); if m == nil { return condition{lhs: strings.TrimSpace(q)} }
+	return condition{strings.TrimSpace(m[1]), m[2], strings.Trim(strings.TrimSpace(m[3]), `"'`)}
+}
+
+func queryData(in Input) (string, error) {
+	path, err := resolvePath(in.Str("path")); if err != nil { return "", err }
+	data, err := os.ReadFile(path); if err != nil { return "", err }
+	q := strings.TrimSpace(in.Str("query")); if q == "" { return "", errors.New("query is empty") }
+	var lines []string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv", ".tsv": lines, err = queryCSV(data, ext == ".tsv", q)
+	default:
+		var doc any
+		if ext == ".yaml" || ext == ".yml" {
+			if doc, err = parseYAML(string(data)); err != nil { return "", fmt.Errorf("file isn't valid YAML (or uses YAML this parser doesn't support): %w", err) }
+		} else if err = json.Unmarshal(data, &doc); err != nil { return "", fmt.Errorf("file isn't valid JSON: %w", err) }
+		lines, err = queryTree(doc, q)
+	}
+	if err != nil { return "", err }
+	n := len(lines)
+	if n > maxMatches { lines = append(lines[:maxMatches], fmt.Sprintf("... and %d more matches; narrow the query", n-maxMatches)) }
+	head := fmt.Sprintf("%d matches\n", n); if n == 1 { head = "1 match\n" }
+	return clip(head + strings.Join(lines, "\n")), nil
+}
+
+type pathSeg struct {
+	key   string // "*" any key or index, "**" any depth
+	index int    // -1 unless a [N] segment
+}
+
+var segRe = regexp.MustCompile(`([^.\[\]]+)|\[(\d+|\*)\]`)
+
+func parsePath(p string) []pathSeg {
+	var segs []pathSeg
+	for _, m := range segRe.FindAllStringSubmatch(p, -1) {
+		switch { case m[1] != "": segs = append(segs, pathSeg{m[1], -1}); case m[2] == "*": segs = append(segs, pathSeg{"*", -1}); default: i, _ := strconv.Atoi(m[2]); segs = append(segs, pathSeg{"", i}) }
+	}
+	return segs
+}
+
+func queryTree(doc any, q string) ([]string, error) {
+	c := parseCondition(q); segs := parsePath(c.lhs)
+	if c.lhs == "" || c.lhs == "." || c.lhs == "$" { segs = nil }
+	var out []string; reached, deepest := "", -1
+	var walk func(v any, segs []pathSeg, loc string, depth int)
+	walk = func(v any, segs []pathSeg, loc string, depth int) {
+		if depth > deepest { deepest, reached = depth, loc }
+		if len(segs) == 0 {
+			if c.op == "" || compare(v, c.op, c.rhs) { out = append(out, fmt.Sprintf("%s = %s", orRoot(loc), fragment(v))) }
+			return
+		}
+		s := segs[0]
+		if s.key == "**" { walk(v, segs[1:], loc, depth); eachChild(v, loc, func(cv any, cl string) { walk(cv, segs, cl, depth) }); return }
+		switch x := v.(type) {
+		case map[string]any:
+			if s.key == "*" { eachChild(v, loc, func(cv any, cl string) { walk(cv, segs[1:], cl, depth+1) }) } else if cv, ok := x[s.key]; ok { walk(cv, segs[1:], joinKey(loc, s.key), depth+1) }
+		case []any:
+			if s.key == "*" { eachChild(v, loc, func(cv any, cl string) { walk(cv, segs[1:], cl, depth+1) }) } else if s.index >= 0 && s.index < len(x) { walk(x[s.index], segs[1:], fmt.Sprintf("%s[%d]", loc, s.index), depth+1) }
+		}
+	}
+	walk(doc, segs, "", 0)
+	if len(out) == 0 {
+		msg := fmt.Sprintf("query matched nothing: %q", q)
+		if c.op != "" { msg += " (the path may exist but no value satisfied the comparison)" }
+		var cur any = doc
+		for _, s := range parsePath(reached) { if m, ok := cur.(map[string]any); ok { cur = m[s.key] } else if a, ok := cur.([]any); ok && s.index >= 0 && s.index < len(a) { cur = a[s.index] } }
+		if m, ok := cur.(map[string]any); ok { msg += fmt.Sprintf("; keys at %s: %s", orRoot(reached), strings.Join(truncList(sortedKeys(m), 30), ", ")) }
+		return nil, errors.New(msg)
+	}
+	return out, nil
+}
+
+func eachChild(v any, loc string, fn func(any, string)) {
+	switch x := v.(type) {
+	case map[string]any: for _, k := range sortedKeys(x) { fn(x[k], joinKey(loc, k)) }
+	case []any: for i, cv := range x { fn(cv, fmt.Sprintf("%s[%d]", loc, i)) }
+	}
+}
+
+func joinKey(loc, k string) string { if loc == "" { return k }; return loc + "." + k }
+func orRoot(loc string) string      { if loc == "" { return "(root)" }; return loc }
+
+func truncList(s []string, n int) []string { if len(s) > n { return append(s[:n:n], "…") }; return s }
+
+// fragment renders a matched value compactly; large subtrees are cut.
+func fragment(v any) string { b, _ := json.Marshal(v); return truncate(string(b), 500) }
+
+// compare applies op to a value and the query's right-hand side. Numbers and quantities with
+// units ("512Mi", "2G", "250m") compare numerically; everything else as strings.
+func compare(v any, op, rhs string) bool {
+	var s string
+	switch x := v.(type) { case string: s = x; case float64, bool: s = fmt.Sprint(x); default: return false }
+	if a, ok := quantity(s); ok { if b, ok := quantity(rhs); ok { return cmpOp(op, cmpFloat(a, b), s, rhs) } }
+	return cmpOp(op, strings.Compare(s, rhs), s, rhs)
+}
+
+func cmpFloat(a, b float64) int { if a < b { return -1 } else if a > b { return 1 }; return 0 }
+
+func cmpOp(op string, c int, s, rhs string) bool {
+	switch op {
+	case "=": return c == 0
+	case "!=": return c != 0
+	case ">": return c > 0
+	case "<": return c < 0
+	case ">=": return c >= 0
+	case "<=": return c <= 0
+	case "~": return strings.Contains(strings.ToLower(s), strings.ToLower(rhs))
+	}
+	return false
+}
+
+var quantityRe = regexp.MustCompile(`^\s*(-?\d+(?:\.\d+)?(?:[eE][-+]?\d+)?)\s*(Ki|Mi|Gi|Ti|k|K|M|G|T|m|KB|MB|GB|TB|B)?\s*$`)
+
+var units = map[string]float64{"": 1, "B": 1, "m": 1e-3, "k": 1e3, "K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12, "KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+	"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40}
+
+func quantity(s string) (float64, bool) {
+	m := quantityRe.FindStringSubmatch(s); if m == nil { return 0, false }
+	f, err := strconv.ParseFloat(m[1], 64); if err != nil || math.IsInf(f, 0) { return 0, false }
+	return f * units[m[2]], true
+}
+
+func queryCSV(data []byte, tsv bool, q string) ([]string, error) {
+	r := csv.NewReader(strings.NewReader(string(data))); r.FieldsPerRecord = -1; r.LazyQuotes = true
+	if tsv { r.Comma = '\t' }
+	rows, err := r.ReadAll(); if err != nil { return nil, fmt.Errorf("file isn't valid CSV: %w", err) }
+	if len(rows) == 0 { return nil, errors.New("file has no rows") }
+	header := rows[0]; col := map[string]int{}; for i, h := range header { col[strings.TrimSpace(h)] = i }
+	if m := regexp.MustCompile(`^row\s+(\d+)$`).FindStringSubmatch(q); m != nil {
+		n, _ := strconv.Atoi(m[1]); if n < 1 || n >= len(rows) { return nil, fmt.Errorf("no row %d; the file has %d data rows", n, len(rows)-1) }
+		return []string{csvRow(header, rows[n], n)}, nil
+	}
+	var conds []condition
+	for _, part := range regexp.MustCompile(`(?i)\s+and\s+`).Split(q, -1) {
+		c := parseCondition(part)
+		if _, ok := col[c.lhs]; !ok { return nil, fmt.Errorf("no column %q; columns are: %s", c.lhs, strings.Join(header, ", ")) }
+		conds = append(conds, c)
+	}
+	var out []string
+	for n, row := range rows[1:] {
+		ok := true
+		for _, c := range conds {
+			i := col[c.lhs]; v := ""; if i < len(row) { v = row[i] }
+			if c.op == "" { continue }
+			if !compare(v, c.op, c.rhs) { ok = false; break }
+		}
+		if !ok { continue }
+		if len(conds) == 1 && conds[0].op == "" { i := col[conds[0].lhs]; v := ""; if i < len(row) { v = row[i] }; out = append(out, fmt.Sprintf("row %d: %s", n+1, v)); continue }
+		out = append(out, csvRow(header, row, n+1))
+	}
+	if len(out) == 0 { return nil, fmt.Errorf("query matched nothing: %q over %d rows", q, len(rows)-1) }
+	return out, nil
+}
+
+func csvRow(header, row []string, n int) string {
+	var parts []string
+	for i, v := range row { h := fmt.Sprint(i); if i < len(header) { h = header[i] }; parts = append(parts, h+"="+v) }
+	return fmt.Sprintf("row %d: %s", n, strings.Join(parts, ", "))
+}
+
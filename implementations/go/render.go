@@ -0,0 +1,20 @@
+// The CLI's view of a run. Progress lines (tool calls, notices) are drawn from the agent's
+// events, so an Agent used as a library stays silent and gets everything from Run's Result.
+
+package main
+
+import "fmt"
+
+// renderProgress prints the run's progress to ui as it happens.
+func (a *Agent) renderProgress() { a.On(a.render) }
+
+func (a *Agent) render(e Event) {
+	if e.Kind != "tool_input" { a.clearPreview() }
+	switch e.Kind {
+	case "tool_input": a.showToolInput(e)
+	case "usage": if e.Phase == phaseMain { a.showUsage(e) }
+	case "tool_start": a.showToolStart(e)
+	case "tool_result": a.showToolResult(e)
+	case "notice": fmt.Fprintln(ui, e.Text)
+	}
+}
@@ -0,0 +1,175 @@
+// Interactive mode: with no prompt on a terminal (or with --interactive), nano reads prompts
+// line by line and keeps one conversation going. Lines starting with / are commands for nano
+// itself and never reach the model. On a terminal, lines typed while a turn runs are queued
+// and reach the model between its steps, or as the next prompt once the turn is over.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+type slashCommand struct {
+	name, args, help string
+	run              func(a *Agent, arg string) error
+}
+
+var errQuit = errors.New("quit")
+
+// sendPrompt is returned by commands that want the REPL to send a prompt on their behalf.
+type sendPrompt string
+
+func (p sendPrompt) Error() string { return "send " + string(p) }
+
+var slashCommands []slashCommand
+
+func init() {
+	slashCommands = []slashCommand{
+		{"help", "", "list commands", func(a *Agent, _ string) error { printSlashHelp(); return nil }},
+		{"clear", "", "start over with an empty history", func(a *Agent, _ string) error { a.reset(); fmt.Fprintln(ui, "history cleared"); return nil }},
+		{"model", "[name]", "show or switch the model", slashModel},
+		{"cost", "", "tokens and estimated cost so far", slashCost},
+		{"compact", "", "summarize older history now", func(a *Agent, _ string) error { if err := a.compact(0); err != nil { return err }; fmt.Fprintf(ui, "compacted to %d messages\n", len(a.Messages)); return nil }},
+		{"tools", "[name ...]", "list tools, or toggle the named ones on/off", slashTools},
+		{"undo", "", "drop the last exchange and restore the files it changed", func(a *Agent, _ string) error { _, s, err := a.undo(); if err == nil { fmt.Fprintln(ui, "↩", s) }; return err }},
+		{"diff", "", "show the net change to every file written this session", func(a *Agent, _ string) error { a.showDiff(ui); return nil }},
+		{"retry", "[--model name]", "undo the last exchange and send its prompt again", slashRetry},
+		{"fork", "[--worktree]", "save a copy of this conversation as a new session", slashFork},
+		{"save", "[name]", "snapshot the session to disk", func(a *Agent, arg string) error { p, err := a.saveSession(arg); if err == nil { fmt.Fprintln(ui, "saved", p) }; return err }},
+		{"quit", "", "leave (also /exit or Ctrl-D)", func(*Agent, string) error { return errQuit }},
+	}
+}
+
+var slashAliases = map[string]string{"exit": "quit", "q": "quit", "?": "help"}
+
+func printSlashHelp() {
+	for _, c := range slashCommands { fmt.Fprintf(ui, "  %-22s %s\n", strings.TrimSpace("/"+c.name+" "+c.args), c.help) }
+}
+
+// slash runs one command line ("/name args"); it returns errQuit to end the session.
+func (a *Agent) slash(line string) error {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "/"), " ")
+	if n, ok := slashAliases[name]; ok { name = n }
+	for _, c := range slashCommands { if c.name == name { return c.run(a, strings.TrimSpace(arg)) } }
+	fmt.Fprintf(ui, "unknown command /%s\n", name); printSlashHelp(); return nil
+}
+
+func slashModel(a *Agent, arg string) error {
+	if arg == "" { fmt.Fprintln(ui, "model:", a.Model); return nil }
+	a.Model = resolveModel(arg); fmt.Fprintln(ui, "model is now", a.Model); return nil
+}
+
+func slashFork(a *Agent, arg string) error {
+	s, err := a.snapshot(a.Session); if err != nil { return err }
+	child, err := fork(s, arg == "--worktree"); if err != nil { return err }
+	fmt.Fprintf(ui, "forked as %s; this conversation continues as %s (continue the fork with: cd %s && nano --resume %s)\n", child.ID, a.Session, child.Dir, child.ID)
+	return nil
+}
+
+func slashRetry(a *Agent, arg string) error {
+	fs := flag.NewFlagSet("/retry", flag.ContinueOnError); fs.SetOutput(ui)
+	model := fs.String("model", "", "model to retry with (stays selected afterwards)")
+	if err := fs.Parse(strings.Fields(arg)); err != nil { return err }
+	x, s, err := a.undo(); if err != nil { return errors.New("nothing to retry") }
+	fmt.Fprintln(ui, "↩", s)
+	if *model != "" { a.Model = resolveModel(*model); fmt.Fprintln(ui, "model is now", a.Model) }
+	return sendPrompt(x.prompt)
+}
+
+func slashCost(a *Agent, _ string) error {
+	s := fmt.Sprintf("%d turns · %d input + %d output tokens", a.Turns, a.Usage.InputTokens, a.Usage.OutputTokens)
+	if c, ok := a.runCost(); ok { s += fmt.Sprintf(" · ~$%.4f%s", c, a.costBreakdown()) } else { s += " · cost unknown for " + a.Model }
+	fmt.Fprintln(ui, s); return nil
+}
+
+func slashTools(a *Agent, arg string) error {
+	names := strings.Fields(arg)
+	for _, name := range names { // check them all first so a bad name changes nothing
+		t, ok := registered(name); if !ok { return fmt.Errorf("unknown tool %q (available: %s)", name, strings.Join(toolNames(registry), ", ")) }
+		if t.Available != nil && !slices.Contains(toolNames(a.Tools), name) { if err := t.Available(); err != nil { return fmt.Errorf("%s: %w", name, err) } }
+	}
+	for _, name := range names {
+		if i := slices.Index(toolNames(a.Tools), name); i >= 0 { a.Tools = slices.Delete(a.Tools, i, i+1) } else { t, _ := registered(name); a.Tools = append(a.Tools, t) }
+	}
+	for _, t := range registry {
+		state := "off"; if slices.ContainsFunc(a.Tools, func(e Tool) bool { return e.Name == t.Name }) { state = "on" }
+		fmt.Fprintf(ui, "  %-14s %s\n", t.Name, state)
+	}
+	return nil
+}
+
+// reset forgets the conversation, including which files and instructions the model has seen.
+func (a *Agent) reset() {
+	a.Messages, a.seenDirs, a.instructions, a.exchanges = nil, nil, nil, nil
+	forgetReads()
+}
+
+// repl runs the interactive loop until /quit or end of input and returns the exit code.
+func (a *Agent) repl() int {
+	fmt.Fprintln(os.Stderr, "nano interactive · /help for commands · \"\"\" or a trailing \\ for multi-line · Ctrl-D to quit")
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt); line, err := readStopping(func() (string, error) { return stdin.ReadString('\n') })
+		if line != "" && err == io.EOF { err = nil }
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	var ed *lineEditor
+	if isTTY(os.Stdin) {
+		in, err := startTermInput(); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		keys = in; defer in.stop()
+		ed = newLineEditor(); read = ed.readLine
+	}
+	var queued []string
+	for {
+		msg, err := "", error(nil)
+		if len(queued) > 0 { msg = strings.Join(queued, "\n\n"); queued = nil; fmt.Fprintln(ui, "↪ sending queued message:", msg) } else { msg, err = readMessage(read) }
+		a.exitIfStopped()
+		if err == errInterrupt { continue }
+		if err == io.EOF { fmt.Fprintln(os.Stderr); return 0 }
+		if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		if msg = strings.TrimSpace(msg); msg == "" { continue }
+		if ed != nil { ed.add(msg) }
+		if strings.HasPrefix(msg, "/") && !strings.Contains(msg, "\n") {
+			e := a.slash(msg); p, resend := e.(sendPrompt)
+			if e == errQuit { return 0 } else if e != nil && !resend { fmt.Fprintln(os.Stderr, "Error:", e) }
+			if !resend { continue }
+			msg = string(p)
+		}
+		var q *typeahead
+		if ed != nil { q = startTypeahead(); a.pending = q.drain }
+		result, e := a.Send(msg)
+		if q != nil { queued, ed.prefill = q.stop(); a.pending = nil }
+		a.exitIfStopped()
+		if a.pastDeadline() {
+			if summary, err := a.wrapUp(); err == nil { fmt.Println(summary) } else { fmt.Fprintln(os.Stderr, "warning:", err) }
+			a.autosave(); fmt.Fprintln(os.Stderr, "⏱ deadline reached; resume with nano --resume", a.Session); return exitDeadline
+		}
+		if e != nil { _, hint := a.errorHint(e); printError(hint, e) } else { fmt.Println(result) }
+		a.autosave()
+	}
+}
+
+// readMessage reads one message, which may span lines: each line ending in a backslash
+// continues onto the next, and a line of just """ opens a block that runs to the next """.
+// Continuation lines are prompted with "... "; Ctrl-C there drops the unfinished message.
+func readMessage(read func(prompt string) (string, error)) (string, error) {
+	var lines []string; block := false
+	for {
+		prompt := "› "; if block || len(lines) > 0 { prompt = "... " }
+		line, err := read(prompt)
+		if err == errInterrupt && (block || len(lines) > 0) { fmt.Fprintln(os.Stderr, "(multi-line message discarded)") }
+		if err == io.EOF && len(lines) > 0 { return strings.Join(lines, "\n"), nil }
+		if err != nil { return "", err }
+		switch {
+		case strings.TrimSpace(line) == `"""`: if block { return strings.Join(lines, "\n"), nil }; block = true
+		case block: lines = append(lines, line)
+		case strings.HasSuffix(line, `\`): lines = append(lines, strings.TrimSuffix(line, `\`))
+		default: return strings.Join(append(lines, line), "\n"), nil
+		}
+	}
+}
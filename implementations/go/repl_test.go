@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// captureUI collects what the REPL prints for the rest of the test.
+func captureUI(t *testing.T) *strings.Builder {
+	var b strings.Builder; saved := ui; ui = &b
+	t.Cleanup(func() { ui = saved })
+	return &b
+}
+
+func TestSlashParsesNameAndArguments(t *testing.T) {
+	a := testAgent(t, newFakeAPI(t)); out := captureUI(t)
+	if err := a.slash("  /model   opus  "); err != nil { t.Fatal(err) }
+	if a.Model != resolveModel("opus") { t.Errorf("model %q after /model opus", a.Model) }
+	out.Reset()
+	if err := a.slash("/model"); err != nil || !strings.Contains(out.String(), "model: "+a.Model) { t.Errorf("/model with no argument: %v %q", err, out) }
+	for _, line := range []string{"/quit", "/exit", "/q"} { if err := a.slash(line); !errors.Is(err, errQuit) { t.Errorf("%s: %v, want errQuit", line, err) } }
+	if err := a.slash("/retry --bogus"); err == nil { t.Error("/retry took an unknown flag") }
+	if err := a.slash("/retry"); err == nil || err.Error() != "nothing to retry" { t.Errorf("/retry with no exchange: %v", err) }
+	if err := a.slash("/tools no_such_tool"); err == nil || !strings.Contains(err.Error(), `unknown tool "no_such_tool"`) { t.Errorf("/tools with a bad name: %v", err) }
+}
+
+func TestSlashRetrySendsThePromptAgain(t *testing.T) {
+	a := testAgent(t, newFakeAPI(t, textReply("one"))); captureUI(t)
+	if _, err := a.Run("first"); err != nil { t.Fatal(err) }
+	err := a.slash("/retry --model haiku")
+	if p, ok := err.(sendPrompt); !ok || string(p) != "first" { t.Errorf("got %v, want the prompt sent again", err) }
+	if a.Model != resolveModel("haiku") || len(a.Messages) != 0 { t.Errorf("model %q, %d messages after /retry", a.Model, len(a.Messages)) }
+}
+
+func TestUnknownSlashCommandShowsHelp(t *testing.T) {
+	a := testAgent(t, newFakeAPI(t)); out := captureUI(t)
+	if err := a.slash("/frobnicate now"); err != nil { t.Fatal(err) }
+	if !strings.HasPrefix(out.String(), "unknown command /frobnicate\n") || !strings.Contains(out.String(), "/help") { t.Errorf("got %q", out) }
+}
+
+func TestHelpListsTheCommandTable(t *testing.T) {
+	a := testAgent(t, newFakeAPI(t)); out := captureUI(t)
+	if err := a.slash("/?"); err != nil { t.Fatal(err) }
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(slashCommands) { t.Fatalf("%d help lines for %d commands:\n%s", len(lines), len(slashCommands), out) }
+	for i, c := range slashCommands {
+		if want := fmt.Sprintf("/%s", strings.TrimSpace(c.name+" "+c.args)); !strings.Contains(lines[i], want) || !strings.HasSuffix(lines[i], c.help) { t.Errorf("line %d %q, want %s and %q", i, lines[i], want, c.help) }
+	}
+}
@@ -0,0 +1,71 @@
+// Record/replay: --record saves every raw request/response pair plus the tool results of
+// each step; --replay feeds those responses back instead of calling the API and reports
+// any request that differs from the recorded one, so a recording works as a golden test.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+type recStep struct {
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+	Tools    []recTool       `json:"tools,omitempty"`
+}
+type recTool struct{ ID, Name, Result string; Blocks []Block `json:",omitempty"`; IsError bool `json:",omitempty"`; Input json.RawMessage }
+
+type recording struct {
+	Version    string    `json:"nano_version,omitempty"`
+	Steps      []recStep `json:"steps"`
+	Transcript []Message `json:"transcript,omitempty"`
+	file       string
+	replay     bool
+	live       bool
+	pos        int
+	mismatches int
+}
+
+func loadRecording(file string, live bool) (*recording, error) {
+	data, err := os.ReadFile(file); if err != nil { return nil, err }
+	r := &recording{file: file, replay: true, live: live}
+	if err := json.Unmarshal(data, r); err != nil { return nil, fmt.Errorf("%s: %w", file, err) }
+	return r, nil
+}
+
+// add appends a live exchange and rewrites the file so a crash still leaves a usable recording.
+func (r *recording) add(req, resp []byte, msgs []Message) {
+	if r == nil || r.replay { return }
+	r.Steps = append(r.Steps, recStep{Request: req, Response: resp}); r.flush(msgs)
+}
+
+func (r *recording) flush(msgs []Message) {
+	if r == nil || r.replay { return }
+	r.Transcript = msgs
+	data, _ := json.MarshalIndent(r, "", "  ")
+	if err := os.WriteFile(r.file, data, 0644); err != nil { slog.Warn("writing recording failed", "file", r.file, "err", err) }
+}
+
+// next returns the recorded response for the upcoming step, diffing the request against it.
+func (r *recording) next(req []byte) ([]byte, error) {
+	if r.pos >= len(r.Steps) { return nil, fmt.Errorf("replay: recording has only %d step(s)", len(r.Steps)) }
+	step := r.Steps[r.pos]; r.pos++
+	if want, got := indentJSON(step.Request), indentJSON(req); want != got {
+		r.mismatches++; slog.Debug("replay mismatch", "step", r.pos); fmt.Fprintf(os.Stderr, "replay: request %d differs from recording:\n%s", r.pos, lineDiff(want, got))
+	}
+	return step.Response, nil
+}
+
+func (r *recording) toolResult(id string) (result string, blocks []Block, isErr, ok bool) {
+	if r == nil || !r.replay || r.live || r.pos == 0 { return "", nil, false, false }
+	for _, t := range r.Steps[r.pos-1].Tools { if t.ID == id { return t.Result, t.Blocks, t.IsError, true } }
+	return "", nil, false, false
+}
+
+func indentJSON(raw []byte) string {
+	var buf bytes.Buffer; if json.Indent(&buf, raw, "", "  ") != nil { return string(raw) }; return buf.String() + "\n"
+}
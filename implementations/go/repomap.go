@@ -0,0 +1,271 @@
+// Repo map (--repomap): a compact outline of the project put in the system prompt, so the model
+// can go straight to the right files instead of exploring. Non-ignored source files are read
+// for their top-level symbols (go/parser for Go, line patterns for other languages) and their
+// imports; files are ranked by how often other files import them and how recently they
+// changed, and the best are listed as a path tree with their symbols until --repomap-tokens is
+// used up. The extraction is cached in .nano/cache under a key of git HEAD plus a hash of the
+// dirty files, and entries are refreshed as the agent writes files during the run.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	repoMapMaxFiles   = 20000 // files walked at most
+	repoMapMaxSymbols = 25    // symbols listed per file
+	repoMapMaxBytes   = 1 << 20
+)
+
+type mapEntry struct {
+	Mod     int64    `json:"mod"` // mtime, unix nanoseconds
+	Size    int64    `json:"size"`
+	Symbols []string `json:"symbols,omitempty"`
+	Imports []string `json:"imports,omitempty"`
+}
+
+type repoMap struct {
+	root   string
+	budget int // tokens
+	files  map[string]mapEntry
+	text   string
+}
+
+type repoMapCache struct {
+	Key   string              `json:"key"`
+	Files map[string]mapEntry `json:"files"`
+}
+
+// symbolPatterns find top-level definitions line by line; the last group is the name.
+var symbolPatterns = map[string]*regexp.Regexp{
+	".py":    regexp.MustCompile(`^(?:async\s+)?(?:class|def)\s+(\w+)`),
+	".js":    regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?(?:function\*?|class|interface|type|enum)\s+(\w+)`),
+	".rs":    regexp.MustCompile(`^(?:pub(?:\([^)]*\))?\s+)?(?:async\s+)?(?:fn|struct|enum|trait|mod|type|union)\s+(\w+)`),
+	".java":  regexp.MustCompile(`^\s{0,4}(?:(?:public|private|protected|internal|static|final|abstract|sealed|data|open)\s+)*(?:class|interface|enum|record|object|fun)\s+(\w+)`),
+	".rb":    regexp.MustCompile(`^\s{0,2}(?:class|module|def)\s+([\w.:?!]+)`),
+	".php":   regexp.MustCompile(`^\s{0,4}(?:(?:abstract|final|public|private|protected|static)\s+)*(?:class|interface|trait|enum|function)\s+(\w+)`),
+	".swift": regexp.MustCompile(`^\s{0,4}(?:(?:public|open|private|internal|final)\s+)*(?:class|struct|enum|protocol|extension|func|actor)\s+(\w+)`),
+	".c":     regexp.MustCompile(`^(?:typedef\s+)?(?:struct|enum|union)\s+(\w+)\s*\{|^[A-Za-z_][\w \t*]*?\b(\w+)\s*\([^;]*$`),
+	".lua":   regexp.MustCompile(`^(?:local\s+)?function\s+([\w.:]+)`),
+	".sh":    regexp.MustCompile(`^(?:function\s+)?([\w-]+)\s*\(\)\s*\{`),
+}
+
+var symbolAliases = map[string]string{".ts": ".js", ".tsx": ".js", ".jsx": ".js", ".mjs": ".js", ".cjs": ".js", ".kt": ".java", ".cs": ".java", ".scala": ".java", ".h": ".c", ".cc": ".c", ".cpp": ".c", ".hpp": ".c", ".bash": ".sh"}
+
+var importPatterns = map[string]*regexp.Regexp{
+	".py":   regexp.MustCompile(`^\s*(?:from\s+([\w.]+)\s+import|import\s+([\w.]+))`),
+	".js":   regexp.MustCompile(`(?:\bfrom\s+|\brequire\(\s*|^\s*import\s+)['"]([^'"]+)['"]`),
+	".rs":   regexp.MustCompile(`^\s*(?:pub\s+)?(?:use|mod)\s+([\w:]+)`),
+	".java": regexp.MustCompile(`^\s*(?:import|using)\s+(?:static\s+)?([\w.]+)`),
+	".rb":   regexp.MustCompile(`^\s*require(?:_relative)?\s+['"]([^'"]+)['"]`),
+	".php":  regexp.MustCompile(`^\s*(?:use|require(?:_once)?|include(?:_once)?)\s*\(?\s*['"]?([\w\\/.]+)`),
+	".c":    regexp.MustCompile(`^\s*#\s*include\s+"([^"]+)"`),
+	".lua":  regexp.MustCompile(`require\s*\(?\s*['"]([^'"]+)['"]`),
+}
+
+func sourceKind(p string) string {
+	ext := strings.ToLower(filepath.Ext(p))
+	if a, ok := symbolAliases[ext]; ok { return a }
+	if _, ok := symbolPatterns[ext]; ok || ext == ".go" { return ext }
+	return ""
+}
+
+// buildRepoMap loads or builds the map for the working directory.
+func buildRepoMap(budget int) *repoMap {
+	m := &repoMap{root: workDir(), budget: budget}
+	start := time.Now()
+	key := m.cacheKey()
+	old := m.loadCache()
+	if old.Key != "" && old.Key == key { m.files = old.Files } else {
+		m.files = m.scan(old.Files)
+		if key != "" { m.saveCache(repoMapCache{Key: key, Files: m.files}) }
+	}
+	m.render()
+	slog.Info("repo map", "files", len(m.files), "bytes", len(m.text), "cached", old.Key == key && key != "", "duration", time.Since(start))
+	return m
+}
+
+// scan walks the tree, reusing entries from prev whose size and mtime still match.
+func (m *repoMap) scan(prev map[string]mapEntry) map[string]mapEntry {
+	files := map[string]mapEntry{}
+	filepath.WalkDir(m.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == m.root { return nil }
+		if ignored(p, d.IsDir()) || strings.HasPrefix(d.Name(), ".") { if d.IsDir() { return filepath.SkipDir }; return nil }
+		if d.IsDir() || sourceKind(p) == "" { return nil }
+		if len(files) >= repoMapMaxFiles { return filepath.SkipAll }
+		rel, _ := filepath.Rel(m.root, p); rel = filepath.ToSlash(rel)
+		fi, err := d.Info(); if err != nil || fi.Size() > repoMapMaxBytes { return nil }
+		if e, ok := prev[rel]; ok && e.Mod == fi.ModTime().UnixNano() && e.Size == fi.Size() { files[rel] = e; return nil }
+		if e, ok := extractEntry(p, fi); ok { files[rel] = e }
+		return nil
+	})
+	return files
+}
+
+func extractEntry(p string, fi fs.FileInfo) (mapEntry, bool) {
+	src, err := os.ReadFile(p); if err != nil { return mapEntry{}, false }
+	e := mapEntry{Mod: fi.ModTime().UnixNano(), Size: fi.Size()}
+	if kind := sourceKind(p); kind == ".go" { e.Symbols, e.Imports = goSymbols(p, src) } else { e.Symbols, e.Imports = lineSymbols(kind, src) }
+	return e, true
+}
+
+func goSymbols(p string, src []byte) (syms, imports []string) {
+	f, err := parser.ParseFile(token.NewFileSet(), p, src, parser.SkipObjectResolution)
+	if err != nil { return nil, nil }
+	for _, im := range f.Imports { imports = append(imports, strings.Trim(im.Path.Value, `"`)) }
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 { name = receiverName(d.Recv.List[0].Type) + "." + name }
+			syms = append(syms, name)
+		case *ast.GenDecl:
+			for _, s := range d.Specs { if ts, ok := s.(*ast.TypeSpec); ok { syms = append(syms, "type "+ts.Name.Name) } }
+		}
+	}
+	return syms, imports
+}
+
+func receiverName(x ast.Expr) string {
+	switch t := x.(type) {
+	case *ast.StarExpr: return receiverName(t.X)
+	case *ast.IndexExpr: return receiverName(t.X)
+	case *ast.IndexListExpr: return receiverName(t.X)
+	case *ast.Ident: return t.Name
+	}
+	return "?"
+}
+
+func lineSymbols(kind string, src []byte) (syms, imports []string) {
+	sym, imp := symbolPatterns[kind], importPatterns[kind]
+	for _, line := range strings.Split(string(src), "\n") {
+		if m := sym.FindStringSubmatch(line); m != nil { if name := lastGroup(m); name != "" { syms = append(syms, name) } }
+		if imp == nil { continue }
+		if m := imp.FindStringSubmatch(line); m != nil { if name := lastGroup(m); name != "" { imports = append(imports, name) } }
+	}
+	return syms, imports
+}
+
+func lastGroup(m []string) string {
+	for i := len(m) - 1; i > 0; i-- { if m[i] != "" { return m[i] } }
+	return ""
+}
+
+// importStem is what an import is matched against files by: its last path element without
+// an extension ("./lib/http.js", "pkg.http" and "example.com/x/http" are all "http").
+func importStem(s string) string {
+	s = strings.TrimRight(s, "/"); if i := strings.LastIndexAny(s, "/\\:"); i >= 0 { s = s[i+1:] }
+	if ext := path.Ext(s); ext != "" && sourceKind(s) != "" { s = strings.TrimSuffix(s, ext) }
+	if i := strings.LastIndex(s, "."); i >= 0 { s = s[i+1:] } // dotted module paths
+	return s
+}
+
+// fileStems are the names a file can be imported by: its own stem, and its directory for Go
+// and for index/__init__/mod files.
+func fileStems(rel string) []string {
+	base := path.Base(rel); stem := strings.TrimSuffix(base, path.Ext(base)); dir := path.Base(path.Dir(rel))
+	if path.Ext(base) == ".go" { return []string{dir} }
+	if stem == "index" || stem == "__init__" || stem == "mod" || stem == "lib" { return []string{dir} }
+	return []string{stem}
+}
+
+// render ranks the files and lists as many as the budget allows, as a tree.
+func (m *repoMap) render() {
+	imported := map[string]int{}
+	for _, e := range m.files { seen := map[string]bool{}; for _, im := range e.Imports { if s := importStem(im); !seen[s] { seen[s] = true; imported[s]++ } } }
+	paths := sortedKeys(m.files)
+	byMod := append([]string{}, paths...)
+	sort.SliceStable(byMod, func(i, j int) bool { return m.files[byMod[i]].Mod > m.files[byMod[j]].Mod })
+	score := map[string]float64{}
+	for i, p := range byMod {
+		s := 5 * (1 - float64(i)/float64(len(byMod))) // recency, 0-5
+		for _, stem := range fileStems(p) { s += float64(imported[stem]) }
+		if len(m.files[p].Symbols) == 0 { s /= 4 }
+		score[p] = s
+	}
+	ranked := append([]string{}, paths...)
+	sort.SliceStable(ranked, func(i, j int) bool { return score[ranked[i]] > score[ranked[j]] })
+	var chosen []string; used := 0
+	for _, p := range ranked {
+		n := (len(p) + len(strings.Join(m.files[p].Symbols, ", ")) + 8) / 4
+		if used+n > m.budget { continue }
+		chosen = append(chosen, p); used += n
+	}
+	sort.Strings(chosen)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n\nRepository map: top-level symbols of the %d most relevant of %d source files (most imported and recently changed first; use it to find code, then read the files):\n", len(chosen), len(m.files))
+	lastDir := ""
+	for _, p := range chosen {
+		dir, file := path.Split(p)
+		if dir != lastDir { if dir != "" { sb.WriteString(dir + "\n") }; lastDir = dir }
+		syms := m.files[p].Symbols; more := ""
+		if len(syms) > repoMapMaxSymbols { more = fmt.Sprintf(", … %d more", len(syms)-repoMapMaxSymbols); syms = syms[:repoMapMaxSymbols] }
+		indent := ""; if dir != "" { indent = "  " }
+		if len(syms) == 0 { fmt.Fprintf(&sb, "%s%s\n", indent, file); continue }
+		fmt.Fprintf(&sb, "%s%s: %s%s\n", indent, file, strings.Join(syms, ", "), more)
+	}
+	m.text = sb.String()
+}
+
+// prompt is the map's part of the system prompt.
+func (m *repoMap) prompt() string { if m == nil { return "" }; return m.text }
+
+// refresh re-reads a file the agent just wrote and redraws the map.
+func (m *repoMap) refresh(p string) {
+	if m == nil || p == "" { return }
+	abs, err := resolvePath(p); if err == nil { abs, err = filepath.Abs(abs) }; if err != nil { return }
+	rel, err := filepath.Rel(m.root, abs); if err != nil || strings.HasPrefix(rel, "..") || sourceKind(abs) == "" { return }
+	rel = filepath.ToSlash(rel)
+	if fi, err := os.Stat(abs); err != nil { delete(m.files, rel) } else if e, ok := extractEntry(abs, fi); ok { m.files[rel] = e } else { return }
+	m.render()
+}
+
+func (m *repoMap) cachePath() string { return filepath.Join(m.root, ".nano", "cache", "repomap.json") }
+
+// cacheKey is git HEAD plus a hash of the dirty files' status, sizes and mtimes; "" outside git.
+func (m *repoMap) cacheKey() string {
+	head, err := exec.Command("git", "-C", m.root, "rev-parse", "HEAD").Output(); if err != nil { return "" }
+	status, err := exec.Command("git", "-C", m.root, "status", "--porcelain", "-z", "--untracked-files=all").Output(); if err != nil { return "" }
+	h := sha256.New(); h.Write(head); h.Write(status)
+	for _, entry := range strings.Split(string(status), "\x00") {
+		if len(entry) < 4 { continue }
+		if fi, err := os.Stat(filepath.Join(m.root, entry[3:])); err == nil { fmt.Fprintf(h, "%s %d %d\n", entry[3:], fi.Size(), fi.ModTime().UnixNano()) }
+	}
+	return strings.TrimSpace(string(head)) + "-" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func (m *repoMap) loadCache() repoMapCache {
+	var c repoMapCache
+	if data, err := os.ReadFile(m.cachePath()); err == nil { json.Unmarshal(data, &c) }
+	return c
+}
+
+func (m *repoMap) saveCache(c repoMapCache) {
+	p := m.cachePath(); data, _ := json.Marshal(c)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil { slog.Warn("repo map cache", "err", err); return }
+	os.WriteFile(filepath.Join(filepath.Dir(p), ".gitignore"), []byte("*\n"), 0644)
+	if err := os.WriteFile(p+".tmp", data, 0644); err == nil { err = os.Rename(p+".tmp", p) } else { slog.Warn("repo map cache", "err", err) }
+}
+
+// useRepoMap builds the map and keeps it current as the agent's file tools write.
+func (a *Agent) useRepoMap(budget int) {
+	a.repomap = buildRepoMap(budget)
+	a.On(func(e Event) { if e.Kind == "tool_call" && e.Err == nil { a.repomap.refresh(e.Detail) } })
+}
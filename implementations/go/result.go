@@ -0,0 +1,83 @@
+// What Agent.Run returns: the final text plus how the run got there (each model turn with its
+// tool calls, files changed, usage and cost) and the message history to resume from. The JSON
+// output is built from it; library callers get it without parsing anything.
+
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+type Result struct {
+	Text         string       `json:"text"`
+	Status       string       `json:"status"`  // "completed", "limit", "aborted" or "failed"
+	Outcome      string       `json:"outcome"` // from the finish tool: "success", "partial", "failure", else "unreported"
+	FollowUp     []string     `json:"follow_up_items,omitempty"`
+	Error        string       `json:"error,omitempty"`
+	Turns        []TurnRecord `json:"turns"`
+	FilesChanged []string     `json:"files_changed,omitempty"`
+	Usage        Usage        `json:"usage"`
+	CostUSD      *float64     `json:"cost_usd,omitempty"` // nil when a model's price is unknown
+	Messages     []Message    `json:"messages"`
+
+	byModel  map[string]Usage // usage when the run started, to take the difference from
+	costFrom float64          // and the cost
+}
+
+// TurnRecord is one model response and the tools it called.
+type TurnRecord struct {
+	StopReason string       `json:"stop_reason"`
+	Text       string       `json:"text,omitempty"`
+	Tools      []ToolRecord `json:"tools,omitempty"`
+	Usage      Usage        `json:"usage"`
+	DurationMS int64        `json:"duration_ms"`
+}
+
+type ToolRecord struct {
+	Name       string `json:"name"`
+	Detail     string `json:"detail,omitempty"` // command, URL or path
+	Output     string `json:"output"`           // truncated to maxRecordOutput
+	IsError    bool   `json:"is_error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+const maxRecordOutput = 2000
+
+// limitError is a run stopped by one of the loop's own limits rather than a failure.
+type limitError string
+
+func (e limitError) Error() string { return string(e) }
+
+func (a *Agent) newResult() *Result {
+	before := map[string]Usage{}; for m, u := range a.byModel { before[m] = u }
+	cost, _ := a.runCost()
+	return &Result{byModel: before, costFrom: cost}
+}
+
+func (r *Result) addTool(name, detail, out string, isErr bool, d time.Duration) {
+	t := &r.Turns[len(r.Turns)-1]
+	t.Tools = append(t.Tools, ToolRecord{Name: name, Detail: detail, Output: truncate(out, maxRecordOutput), IsError: isErr, DurationMS: d.Milliseconds()})
+}
+
+// finish fills in the totals and status and returns r with err.
+func (r *Result) finish(a *Agent, text string, err error) (*Result, error) {
+	r.Text, r.Messages, r.Status = text, a.Messages, "completed"
+	if r.Outcome == "" { r.Outcome = "unreported" }
+	for m, u := range a.byModel { r.Usage.add(u.minus(r.byModel[m])) }
+	if c, ok := a.runCost(); ok { c -= r.costFrom; r.CostUSD = &c }
+	if ex := a.exchanges; len(ex) > 0 {
+		seen := map[string]bool{}
+		for _, b := range ex[len(ex)-1].backups { if p := relPath(b.path); !seen[p] { seen[p] = true; r.FilesChanged = append(r.FilesChanged, p) } }
+	}
+	var limit limitError
+	switch {
+	case err == nil && len(r.Turns) > 0 && r.Turns[len(r.Turns)-1].StopReason == "max_tokens": r.Status = "limit"
+	case err == nil:
+	case errors.As(err, &limit): r.Status = "limit"
+	case errors.Is(err, errRefused) || stopped() != nil || runCtx.Err() != nil: r.Status = "aborted"
+	default: r.Status = "failed"
+	}
+	if err != nil { r.Error = err.Error() }
+	return r, err
+}
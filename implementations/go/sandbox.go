@@ -0,0 +1,53 @@
+// Path sandbox: with --sandbox set, file tools resolve relative paths against the root and
+// refuse any path that escapes it, symlinks included. bash only gets the root as its working
+// directory; it is not confined.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var sandboxRoot string
+
+func setSandbox(dir string) error {
+	abs, err := filepath.Abs(dir); if err != nil { return err }
+	if abs, err = filepath.EvalSymlinks(abs); err != nil { return fmt.Errorf("sandbox: %w", err) }
+	sandboxRoot = abs; return nil
+}
+
+// resolvePath maps a model-supplied path to the one to open ("" means ".").
+func resolvePath(p string) (string, error) {
+	p, err := resolveEntry(p); if err != nil { return "", err }
+	if sandboxRoot != "" && !within(realPath(p), sandboxRoot) { return "", fmt.Errorf("%s is outside the sandbox %s", p, sandboxRoot) }
+	return p, nil
+}
+
+// resolveEntry is resolvePath for inspecting the directory entry itself: only the parent has
+// to be inside the sandbox, so a symlink pointing out can still be described (not followed).
+func resolveEntry(p string) (string, error) {
+	if p == "" { p = "." }
+	if sandboxRoot == "" { return p, nil }
+	if !filepath.IsAbs(p) { p = filepath.Join(sandboxRoot, p) }
+	p = filepath.Clean(p)
+	if p != sandboxRoot && !within(realPath(filepath.Dir(p)), sandboxRoot) { return "", fmt.Errorf("%s is outside the sandbox %s", p, sandboxRoot) }
+	return p, nil
+}
+
+// realPath resolves symlinks in the longest existing prefix of p, so paths that don't exist
+// yet (write targets) are still checked against where their parent really lives.
+func realPath(p string) string {
+	rest := ""
+	for dir := p; ; dir = filepath.Dir(dir) {
+		if r, err := filepath.EvalSymlinks(dir); err == nil { return filepath.Join(r, rest) }
+		if dir == filepath.Dir(dir) { return p }
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+func within(p, root string) bool {
+	return p == root || strings.HasPrefix(p, root+string(os.PathSeparator))
+}
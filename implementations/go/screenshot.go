@@ -0,0 +1,53 @@
+// The screenshot tool: capture the desktop with whatever the platform has installed, or render
+// a URL in headless Chrome, then shrink the PNG to fit the API's image limits. Capturing the
+// desktop needs approval since it sends whatever is on screen; rendering a URL does not.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// captureCommands are tried in order; each is argv with "{}" standing for the output file.
+var captureCommands = map[string][][]string{
+	"darwin": {{"screencapture", "-x", "{}"}},
+	"linux":  {{"grim", "{}"}, {"import", "-window", "root", "{}"}, {"gnome-screenshot", "-f", "{}"}, {"scrot", "-o", "{}"}},
+}
+
+var browsers = []string{"google-chrome", "chromium", "chromium-browser", "chrome", "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"}
+
+func screenshot(in Input) ([]Block, error) {
+	dir, err := os.MkdirTemp("", "nano-shot"); if err != nil { return nil, err }; defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "shot.png")
+	var argv []string
+	if u := in.Str("url"); u != "" {
+		if offline && !localURL(u) { return nil, fmt.Errorf("%s refused: %w", u, errOffline) }
+		for _, b := range browsers { if p, err := exec.LookPath(b); err == nil { argv = []string{p, "--headless", "--disable-gpu", "--hide-scrollbars", "--window-size=1280,800", "--screenshot=" + file, u}; break } }
+		if argv == nil { return nil, errors.New("screenshot of a URL needs Chrome or Chromium installed (looked for " + strings.Join(browsers[:4], ", ") + ")") }
+	} else {
+		var tried []string
+		for _, c := range captureCommands[runtime.GOOS] {
+			tried = append(tried, c[0])
+			if _, err := exec.LookPath(c[0]); err == nil { argv = append([]string{}, c...); break }
+		}
+		if argv == nil && len(tried) == 0 { return nil, fmt.Errorf("screen capture isn't supported on %s; pass a url to render a page instead", runtime.GOOS) }
+		if argv == nil { return nil, errors.New("no screen capture tool found (install one of: " + strings.Join(tried, ", ") + "), or pass a url") }
+		for i, a := range argv { if a == "{}" { argv[i] = file } }
+	}
+	if out, err := exec.Command(argv[0], argv[1:]...).CombinedOutput(); err != nil { return nil, fmt.Errorf("%s failed: %v: %s", filepath.Base(argv[0]), err, truncate(string(out), 300)) }
+	data, err := os.ReadFile(file); if err != nil { return nil, fmt.Errorf("%s produced no image: %w", filepath.Base(argv[0]), err) }
+	img, err := png.Decode(bytes.NewReader(data)); if err != nil { return nil, fmt.Errorf("decoding screenshot: %w", err) }
+	orig := img.Bounds(); img = shrink(img)
+	data, media, err := encodeImage(img); if err != nil { return nil, err }
+	desc := fmt.Sprintf("screenshot %dx%d via %s", orig.Dx(), orig.Dy(), filepath.Base(argv[0]))
+	if b := img.Bounds(); b != orig { desc += fmt.Sprintf(", scaled to %dx%d", b.Dx(), b.Dy()) }
+	return []Block{{Type: "text", Text: desc}, imageBlock(media, data)}, nil
+}
@@ -0,0 +1,169 @@
+// search_replace: one pattern (literal, or a regexp with regex: true) replaced across every
+// non-ignored text file under the project root, optionally narrowed by an include glob. A dry
+// run reports the matches per file and writes nothing; a real run computes every file's new
+// contents first and writes only when all of them succeed, putting back the ones already
+// written if a later write fails (and naming any it couldn't). The files stay locked from the
+// scan that backs them up for /undo to the last write, and each is checked against what was
+// scanned just before it is written. The dispatcher refuses a real run until the same call has
+// been dry-run in this session, so a repo-wide regex is always previewed before it lands.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	replaceMaxFiles = 5000    // files scanned per call
+	replaceMaxBytes = 1 << 20 // larger files are skipped
+)
+
+type replaceEdit struct {
+	path      string
+	mode      os.FileMode
+	old, data []byte
+	n         int
+	unlock    func()
+}
+
+// replaceScan is a real run's scan with its files still locked.
+type replaceScan struct {
+	edits   []replaceEdit
+	skipped int
+	err     error
+}
+
+// scans hands the scan replaceTargets made for the backup to the write, keyed by dryRunKey.
+var scans = struct {
+	sync.Mutex
+	m map[string]replaceScan
+}{m: map[string]replaceScan{}}
+
+func searchReplace(in Input) (string, error) {
+	edits, skipped, err := takeScan(in)
+	defer func() { for _, e := range edits { e.unlock() } }()
+	if err != nil { return "", err }
+	total := 0; var lines []string
+	for _, e := range edits { total += e.n; lines = append(lines, fmt.Sprintf("%s: %d", relPath(e.path), e.n)) }
+	head := fmt.Sprintf("%d match(es) in %d file(s)", total, len(edits))
+	if skipped > 0 { head += fmt.Sprintf(" (%d file(s) over %s skipped)", skipped, humanBytes(replaceMaxBytes)) }
+	if in.Bool("dry_run") || len(edits) == 0 {
+		if len(edits) > 0 { head += "; dry run, nothing written. Call again with the same arguments and dry_run false to apply." }
+		return strings.Join(append([]string{head}, lines...), "\n"), nil
+	}
+	for i, e := range edits {
+		written, err := i, error(nil) // the files to put back if this one fails
+		if cur, rerr := os.ReadFile(e.path); rerr != nil || !bytes.Equal(cur, e.old) { err = errors.New("it changed on disk since it was scanned") } else if err = os.WriteFile(e.path, e.data, e.mode); err != nil { written++ } // a failed write may have truncated it
+		if err == nil { continue }
+		var lost []string
+		for _, w := range edits[:written] { if os.WriteFile(w.path, w.old, w.mode) != nil { lost = append(lost, relPath(w.path)) } }
+		if len(lost) > 0 { return "", fmt.Errorf("writing %s: %v; could not put back %s, which may be left replaced or truncated; the other files were restored", relPath(e.path), err, strings.Join(lost, ", ")) }
+		return "", fmt.Errorf("writing %s: %v; no files were changed", relPath(e.path), err)
+	}
+	for _, e := range edits { recordWrite(e.path, e.data) }
+	return strings.Join(append([]string{"replaced " + head}, lines...), "\n"), nil
+}
+
+// replaceEdits scans the project and returns the new contents of every file with a match,
+// each file locked (the caller unlocks them) so nothing changes between reading and writing.
+// skipped counts files too large to scan.
+func replaceEdits(in Input) (edits []replaceEdit, skipped int, err error) {
+	replace, err := replacer(in); if err != nil { return nil, 0, err }
+	include, err := includeRegexp(in.Str("include")); if err != nil { return nil, 0, err }
+	root := sandboxRoot; if root == "" { root, _ = os.Getwd() }
+	var failed []string; seen := 0
+	walkErr := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == root { return nil }
+		if ignored(p, d.IsDir()) { if d.IsDir() { return filepath.SkipDir }; return nil }
+		if !d.Type().IsRegular() { return nil }
+		rel, _ := filepath.Rel(root, p)
+		if include != nil && !include.MatchString(filepath.ToSlash(rel)) { return nil }
+		if seen++; seen > replaceMaxFiles { return fmt.Errorf("more than %d files to scan; narrow it with include", replaceMaxFiles) }
+		fi, err := d.Info(); if err != nil { return nil }
+		if fi.Size() > replaceMaxBytes { skipped++; return nil }
+		path, err := resolvePath(p); if err != nil { failed = append(failed, err.Error()); return nil }
+		unlock := lockPath(path)
+		data, err := os.ReadFile(path); if err != nil { unlock(); failed = append(failed, rel+": "+err.Error()); return nil }
+		f, text, err := decodeText(data); if err != nil { unlock(); return nil } // binary
+		out, n := replace(text)
+		if n == 0 { unlock(); return nil }
+		if err := checkUnmodified(path, data); err != nil { failed = append(failed, rel+": "+err.Error()) }
+		edits = append(edits, replaceEdit{path: path, mode: fi.Mode().Perm(), old: data, data: f.encode(out, true), n: n, unlock: unlock})
+		return nil
+	})
+	if walkErr == nil && len(failed) > 0 { walkErr = errors.New("nothing was changed: " + strings.Join(failed, "; ")) }
+	return edits, skipped, walkErr
+}
+
+// replacer returns a function applying the call's replacement to a file's text, with the
+// number of matches it replaced.
+func replacer(in Input) (func(string) (string, int), error) {
+	pattern, repl := in.Str("pattern"), normalizeNewlines(in.Str("replacement"))
+	if pattern == "" { return nil, errors.New("pattern is empty") }
+	if !in.Bool("regex") {
+		pattern = normalizeNewlines(pattern)
+		return func(s string) (string, int) { n := strings.Count(s, pattern); return strings.ReplaceAll(s, pattern, repl), n }, nil
+	}
+	re, err := regexp.Compile(pattern); if err != nil { return nil, fmt.Errorf("pattern: %w", err) }
+	return func(s string) (string, int) { n := len(re.FindAllStringIndex(s, -1)); return re.ReplaceAllString(s, repl), n }, nil
+}
+
+// includeRegexp compiles an include glob the way .gitignore reads one: without a slash it
+// matches a file name at any depth, with one it is anchored at the project root.
+func includeRegexp(g string) (*regexp.Regexp, error) {
+	g = strings.TrimPrefix(filepath.ToSlash(g), "./"); if g == "" { return nil, nil }
+	prefix := "(^|.*/)"; if strings.Contains(g, "/") { prefix = "^" }
+	re, err := regexp.Compile(prefix + globRegexp(strings.TrimPrefix(g, "/")) + "$"); if err != nil { return nil, fmt.Errorf("include %q: %w", g, err) }
+	return re, nil
+}
+
+// dryRunKey identifies a search_replace call by everything but dry_run.
+func dryRunKey(in Input) string {
+	return strings.Join([]string{in.Str("pattern"), in.Str("replacement"), in.Str("include"), fmt.Sprint(in.Bool("regex"))}, "\x00")
+}
+
+// checkDryRun refuses a real search_replace that hasn't been previewed with a dry run.
+func (a *Agent) checkDryRun(tool string, in Input) error {
+	if tool != "search_replace" || in.Bool("dry_run") || a.dryRuns[dryRunKey(in)] { return nil }
+	return errors.New("not run: run this search_replace with dry_run: true first and check the matches, then repeat it with the same pattern, replacement, include and regex")
+}
+
+// noteDryRun remembers a successful dry run, and forgets it once the real run has happened.
+func (a *Agent) noteDryRun(tool string, in Input, err error) {
+	if tool != "search_replace" || err != nil { return }
+	if a.dryRuns == nil { a.dryRuns = map[string]bool{} }
+	if in.Bool("dry_run") { a.dryRuns[dryRunKey(in)] = true } else { delete(a.dryRuns, dryRunKey(in)) }
+}
+
+// replaceTargets are the files a real search_replace call is about to change, for backup. They
+// stay locked until searchReplace writes them, or releaseScan if the call doesn't get that far.
+func replaceTargets(in Input) []string {
+	releaseScan(in)
+	edits, skipped, err := replaceEdits(in)
+	scans.Lock(); scans.m[dryRunKey(in)] = replaceScan{edits, skipped, err}; scans.Unlock()
+	var out []string
+	for _, e := range edits { out = append(out, e.path) }
+	return out
+}
+
+// takeScan returns the scan replaceTargets left for this call, or scans now.
+func takeScan(in Input) ([]replaceEdit, int, error) {
+	scans.Lock(); s, ok := scans.m[dryRunKey(in)]; delete(scans.m, dryRunKey(in)); scans.Unlock()
+	if ok && !in.Bool("dry_run") { return s.edits, s.skipped, s.err }
+	for _, e := range s.edits { e.unlock() }
+	return replaceEdits(in)
+}
+
+// releaseScan unlocks a scan that was left for a call and not taken.
+func releaseScan(in Input) {
+	scans.Lock(); s := scans.m[dryRunKey(in)]; delete(scans.m, dryRunKey(in)); scans.Unlock()
+	for _, e := range s.edits { e.unlock() }
+}
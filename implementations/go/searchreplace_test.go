@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const renameCall = `{"pattern":"oldName","replacement":"newName"%s}`
+
+func TestSearchReplaceAfterDryRunIsUndoable(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "search_replace", strings.Replace(renameCall, "%s", `,"dry_run":true`, 1)), toolReply("t2", "search_replace", strings.Replace(renameCall, "%s", "", 1)), textReply("done"))
+	a := testAgent(t, f)
+	writeTestFile(t, "a.go", "oldName()\n"); writeTestFile(t, "b.go", "x := oldName\noldName()\n"); writeTestFile(t, "c.go", "other\n")
+	if _, err := a.Run("rename oldName"); err != nil { t.Fatal(err) }
+	if r, _ := toolResult(t, f.request(t, 2), "t2")["content"].(string); !strings.HasPrefix(r, "replaced 3 match(es) in 2 file(s)") { t.Fatalf("real run: %q", r) }
+	if got := readTestFile(t, "b.go"); got != "x := newName\nnewName()\n" { t.Errorf("b.go: %q", got) }
+	if _, _, err := a.undo(); err != nil { t.Fatal(err) }
+	if readTestFile(t, "a.go") != "oldName()\n" || readTestFile(t, "b.go") != "x := oldName\noldName()\n" { t.Error("/undo didn't restore the replaced files") }
+}
+
+func TestSearchReplaceHoldsLocksFromBackupToWrite(t *testing.T) {
+	inTempDir(t); t.Cleanup(forgetReads)
+	writeTestFile(t, "a.go", "oldName()\n")
+	in, _ := decodeInput([]byte(strings.Replace(renameCall, "%s", "", 1)))
+	if got := replaceTargets(in); len(got) != 1 { t.Fatalf("targets %v", got) }
+	edited := make(chan error, 1)
+	go func() { e, _ := decodeInput([]byte(`{"path":"a.go","old_string":"()","new_string":"(1)"}`)); _, err := editFile(e); edited <- err }()
+	select {
+	case err := <-edited: t.Fatalf("edit_file ran while the replace held the file (err %v)", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := searchReplace(in); err != nil { t.Fatal(err) }
+	if err := <-edited; err != nil { t.Fatal(err) }
+	if got := readTestFile(t, "a.go"); got != "newName(1)\n" { t.Errorf("a.go: %q, want the edit applied on top of the replace", got) }
+}
+
+func TestSearchReplaceRefusesFilesChangedSinceTheScan(t *testing.T) {
+	inTempDir(t); t.Cleanup(forgetReads)
+	writeTestFile(t, "a.go", "oldName()\n"); writeTestFile(t, "b.go", "oldName()\n")
+	in, _ := decodeInput([]byte(strings.Replace(renameCall, "%s", "", 1)))
+	replaceTargets(in)
+	writeTestFile(t, "b.go", "oldName() // edited elsewhere\n") // outside nano, so no lock stops it
+	_, err := searchReplace(in)
+	if err == nil || !strings.Contains(err.Error(), "b.go: it changed on disk since it was scanned; no files were changed") { t.Fatalf("got %v", err) }
+	if readTestFile(t, "a.go") != "oldName()\n" || readTestFile(t, "b.go") != "oldName() // edited elsewhere\n" { t.Error("files weren't left as they were") }
+}
+
+func TestReleaseScanUnlocks(t *testing.T) {
+	inTempDir(t); t.Cleanup(forgetReads)
+	writeTestFile(t, "a.go", "oldName()\n")
+	in, _ := decodeInput([]byte(strings.Replace(renameCall, "%s", "", 1)))
+	replaceTargets(in); releaseScan(in)
+	done := make(chan struct{}); go func() { lockPath("a.go")(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second): t.Fatal("a.go is still locked after releaseScan")
+	}
+}
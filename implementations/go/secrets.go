@@ -0,0 +1,27 @@
+// Secret scrubbing for text that leaves the process: log records, and content pulled in from
+// outside the workspace (the clipboard) before the model sees it. Known values (the API key)
+// are matched exactly; common token formats by pattern.
+
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var secretPatterns = regexp.MustCompile(strings.Join([]string{
+	`sk-ant-[A-Za-z0-9_-]{20,}`, `sk-[A-Za-z0-9_-]{32,}`, // Anthropic, OpenAI
+	`AKIA[0-9A-Z]{16}`,                                     // AWS access key ID
+	`gh[pousr]_[A-Za-z0-9]{36,}`, `github_pat_[A-Za-z0-9_]{40,}`,
+	`xox[abprs]-[A-Za-z0-9-]{10,}`, // Slack
+	`AIza[0-9A-Za-z_-]{35}`,        // Google API key
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+}, "|"))
+
+// scrubSecrets replaces secrets in s with [REDACTED] and reports how many it found.
+func scrubSecrets(s string) (string, int) {
+	n := 0
+	for _, secret := range logSecrets { if secret != "" && strings.Contains(s, secret) { n += strings.Count(s, secret); s = strings.ReplaceAll(s, secret, "[REDACTED]") } }
+	s = secretPatterns.ReplaceAllStringFunc(s, func(string) string { n++; return "[REDACTED]" })
+	return s, n
+}
@@ -0,0 +1,330 @@
+// HTTP serve mode with a workspace pool, so a team can share one server without runs sharing a
+// tree: `nano serve --base /srv/nano --repo git@host:app.git --pool 4`. Each POST /runs
+// ({"prompt": "…"}) leases a workspace, one of --pool directories under --base, each a clone
+// of --repo or a copy of --template, and runs nano there as a separate process sandboxed to
+// it, as foreach does. The response carries the run's report and its --changes-file record
+// (diffs included); the tree itself is reset before the next lease, so nothing a run did is
+// seen by another. A run waits for a free workspace while its client does. GET /pool reports
+// how many are free, and GET /health {"status": "ok", "version": …} that the server is up.
+//
+// A workspace is leased from a channel holding one value per directory, and the janitor takes
+// idle ones from the same channel, so two runs, or a run and a cleanup, never have the same
+// directory. Each directory also stays flocked for the server's life: a second server on the
+// same --base refuses to start rather than sharing it.
+//
+// --cleanup reuse (the default) resets a clone to the commit it was cloned at (git reset
+// --hard; git clean -ffdx); discard, and a --template copy, provision afresh for every run. A
+// reset only covers the tree, so a clone whose .git changed during the run (config such as
+// core.fsmonitor or core.hooksPath, hooks, refs, the stash, the reflog) is provisioned afresh
+// too. The server's own git commands run with fsmonitor and hooks off, so nothing a run left
+// in .git runs as the server.
+// --quota-mb stops a run whose workspace outgrows it, and that workspace is discarded. The
+// janitor, every minute, removes checkouts idle for longer than --idle (they are provisioned
+// again when next needed) and workspace directories no server holds, left by one that
+// crashed or ran a bigger pool. Flags after -- go to every run; --yes approves everything,
+// otherwise runs get the permission rules the project config gives them. The server has no
+// authentication of its own and listens on 127.0.0.1 unless --addr says otherwise.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// workspace is one pool directory. Only whoever took it from the pool's channel touches it.
+type workspace struct {
+	name  string // ws-<n>
+	dir   string
+	rev   string // the commit a clone was made at; "" until provisioned, and for a template copy
+	meta  string // fingerprint of .git when the clone was last clean
+	ready bool   // provisioned and clean
+	used  time.Time
+}
+
+type pool struct {
+	base, repo, template string
+	discard              bool          // --cleanup discard
+	quota                int64         // bytes; 0: no limit
+	idle                 time.Duration // before the janitor removes a free checkout
+	free                 chan *workspace
+	size                 int
+	runs                 string // where changes files and run logs go
+}
+
+// serveRun is the response to POST /runs.
+type serveRun struct {
+	ID         string          `json:"id"`
+	Workspace  string          `json:"workspace"`
+	ExitCode   int             `json:"exit_code"`
+	Report     json.RawMessage `json:"report,omitempty"`  // the run's --output json
+	Changes    json.RawMessage `json:"changes,omitempty"` // its --changes-file (see changes.go)
+	Error      string          `json:"error,omitempty"`
+	DurationMS int64           `json:"duration_ms"`
+}
+
+const janitorEvery = time.Minute
+
+func serveMain(fs *flag.FlagSet) func(args []string) int {
+	addr := fs.String("addr", "127.0.0.1:8088", "listen on `host:port`")
+	base := fs.String("base", "", "keep the workspaces under `dir`")
+	repo := fs.String("repo", "", "clone each workspace from git `url`")
+	template := fs.String("template", "", "copy each workspace from `dir`")
+	size := fs.Int("pool", 2, "workspaces, and so runs at a time")
+	cleanup := fs.String("cleanup", "reuse", "after a run: reuse (reset a clone in place) or discard (provision afresh)")
+	quota := fs.Int64("quota-mb", 0, "stop a run whose workspace grows past this many MB (0: no limit)")
+	idle := fs.String("idle", "1h", "remove a free workspace unused for this long (30m, 12h, 1d)")
+	yes := fs.Bool("yes", false, "approve every write and command without asking, in every run")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano serve --base dir --repo url|--template dir [flags] [-- nano flags]"); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		extra := fs.Args(); if len(extra) > 0 && extra[0] == "--" { extra = extra[1:] }
+		if *base == "" || (*repo == "") == (*template == "") || *size < 1 || (*cleanup != "reuse" && *cleanup != "discard") { fs.Usage(); return 1 }
+		idleFor, err := parseAge(*idle); if err != nil { fmt.Fprintln(os.Stderr, "Error: --idle:", err); return 1 }
+		self, err := os.Executable(); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		p := &pool{repo: *repo, discard: *cleanup == "discard", quota: *quota << 20, idle: idleFor, size: *size}
+		if p.base, err = filepath.Abs(*base); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		if *template != "" { if p.template, err = filepath.Abs(*template); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 } }
+		release, err := p.open(); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }; defer release()
+		flags := []string{"--output", "json", "--sandbox", "."}; if *yes { flags = append(flags, "--yes") }
+		flags = append(flags, extra...)
+
+		srv := &http.Server{Addr: *addr, Handler: p.handler(self, flags)}
+		ctx, stop := context.WithCancel(context.Background()); defer stop()
+		go p.janitor(ctx)
+		sig := make(chan os.Signal, 1); signal.Notify(sig, os.Interrupt, syscall.SIGTERM); shut := make(chan struct{})
+		go func() {
+			<-sig; fmt.Fprintln(os.Stderr, "stopping: waiting for the runs in progress (again to stop them)")
+			go func() { <-sig; srv.Close() }()
+			srv.Shutdown(context.Background()); close(shut)
+		}()
+		source := p.repo; if source == "" { source = p.template }
+		fmt.Fprintf(os.Stderr, "▶ serving on http://%s · %d workspaces of %s in %s\n", *addr, p.size, source, relPath(p.base))
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		<-shut; return 0
+	}
+}
+
+// handler serves the API: POST /runs, GET /pool and GET /health, which answers as soon as the
+// server is listening, for a load balancer or a container's health check.
+func (p *pool) handler(self string, flags []string) http.Handler {
+	mux := http.NewServeMux(); var n atomic.Int64
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" { writeJSON(w, 405, map[string]string{"error": "GET /health"}); return }
+		v, _ := buildVersion(); writeJSON(w, 200, map[string]string{"status": "ok", "version": v})
+	})
+	mux.HandleFunc("/pool", func(w http.ResponseWriter, r *http.Request) { writeJSON(w, 200, map[string]int{"size": p.size, "free": len(p.free)}) })
+	mux.HandleFunc("/runs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" { writeJSON(w, 405, map[string]string{"error": "POST a run: {\"prompt\": \"…\"}"}); return }
+		var req struct{ Prompt string `json:"prompt"` }
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil || strings.TrimSpace(req.Prompt) == "" { writeJSON(w, 400, map[string]string{"error": "the body must be JSON with a non-empty \"prompt\""}); return }
+		ws, err := p.lease(r.Context()); if err != nil { return } // the client went away while waiting
+		defer p.release(ws)
+		id := fmt.Sprintf("%s-%d", newSessionID(), n.Add(1))
+		fmt.Fprintf(os.Stderr, "  ▷ %s in %s: %s\n", id, ws.name, truncate(req.Prompt, 80))
+		res := p.run(r.Context(), ws, self, flags, id, req.Prompt)
+		line := fmt.Sprintf("✓ %s exit 0 · %.1fs", id, float64(res.DurationMS)/1000)
+		if res.ExitCode != 0 { line = fmt.Sprintf("✗ %s exit %d · %.1fs", id, res.ExitCode, float64(res.DurationMS)/1000) }
+		if res.Error != "" { line += " · " + truncate(res.Error, 100) }
+		fmt.Fprintln(os.Stderr, "  "+line)
+		writeJSON(w, 200, res)
+	})
+	return mux
+}
+
+// open locks the pool's directories and fills the channel. A directory left from an earlier
+// server may hold anything, so each is provisioned afresh on its first lease.
+func (p *pool) open() (func(), error) {
+	p.runs = filepath.Join(p.base, "runs")
+	if err := os.MkdirAll(p.runs, 0755); err != nil { return nil, err }
+	p.free = make(chan *workspace, p.size); var unlocks []func()
+	release := func() { for _, u := range unlocks { u() } }
+	for i := 0; i < p.size; i++ {
+		name := fmt.Sprintf("ws-%d", i)
+		unlock, err := lockFile(filepath.Join(p.base, name+".lock"), 0)
+		if errors.Is(err, errLocked) { release(); return nil, fmt.Errorf("%s is in use by another nano serve", filepath.Join(p.base, name)) }
+		if err != nil && !errors.Is(err, errNoLocking) { release(); return nil, err }
+		unlocks = append(unlocks, unlock)
+		p.free <- &workspace{name: name, dir: filepath.Join(p.base, name), used: time.Now()}
+	}
+	p.sweep()
+	return release, nil
+}
+
+// lease takes a free workspace, waiting for one as long as ctx lasts, and makes it ready.
+func (p *pool) lease(ctx context.Context) (*workspace, error) {
+	var ws *workspace
+	select {
+	case ws = <-p.free:
+	case <-ctx.Done(): return nil, ctx.Err()
+	}
+	if !ws.ready {
+		if err := p.provision(ws); err != nil { ws.ready = false; fmt.Fprintf(os.Stderr, "  ✗ %s: %v\n", ws.name, err) } // run reports the failure
+	}
+	return ws, nil
+}
+
+// release cleans a workspace and returns it to the pool.
+func (p *pool) release(ws *workspace) {
+	if ws.ready && (p.discard || ws.rev == "") { ws.ready = false }
+	if ws.ready {
+		if meta, err := gitMeta(ws.dir); err != nil || meta != ws.meta { fmt.Fprintf(os.Stderr, "  %s: .git changed during the run; provisioning afresh\n", ws.name); ws.ready = false }
+	}
+	if ws.ready {
+		if out, err := hostGit(ws.dir, "reset", "-q", "--hard", ws.rev).CombinedOutput(); err != nil { fmt.Fprintf(os.Stderr, "  %s: git reset: %s\n", ws.name, strings.TrimSpace(string(out))); ws.ready = false }
+	}
+	if ws.ready {
+		if out, err := hostGit(ws.dir, "clean", "-q", "-ffdx").CombinedOutput(); err != nil { fmt.Fprintf(os.Stderr, "  %s: git clean: %s\n", ws.name, strings.TrimSpace(string(out))); ws.ready = false }
+	}
+	if ws.ready {
+		var err error; if ws.meta, err = gitMeta(ws.dir); err != nil { ws.ready = false } // the reset's own reflog entry and ORIG_HEAD
+	}
+	if !ws.ready { os.RemoveAll(ws.dir) }
+	ws.used = time.Now(); p.free <- ws
+}
+
+// provision makes a fresh clone or copy in the workspace's directory.
+func (p *pool) provision(ws *workspace) error {
+	if err := os.RemoveAll(ws.dir); err != nil { return err }
+	ws.rev = ""
+	if p.template != "" {
+		if err := copyDir(p.template, ws.dir); err != nil { return fmt.Errorf("copying %s: %w", p.template, err) }
+		ws.ready = true; return nil
+	}
+	if out, err := hostGit("", "clone", "-q", p.repo, ws.dir).CombinedOutput(); err != nil { return fmt.Errorf("git clone: %s", strings.TrimSpace(string(out))) }
+	rev, err := hostGit(ws.dir, "rev-parse", "HEAD").Output(); if err != nil { return fmt.Errorf("git rev-parse HEAD: %w", err) }
+	if ws.meta, err = gitMeta(ws.dir); err != nil { return fmt.Errorf("reading %s: %w", filepath.Join(ws.dir, ".git"), err) }
+	ws.rev, ws.ready = strings.TrimSpace(string(rev)), true
+	return nil
+}
+
+// hostGit is git run by the server itself, in dir unless it is "", with fsmonitor and hooks
+// off: a run may have set either in the clone's config.
+func hostGit(dir string, args ...string) *exec.Cmd {
+	pre := []string{"-c", "core.fsmonitor=false", "-c", "core.hooksPath=/dev/null"}
+	if dir != "" { pre = append(pre, "-C", dir) }
+	return exec.Command("git", append(pre, args...)...)
+}
+
+// gitMeta fingerprints a clone's .git: every entry's path, type and content, except the index,
+// which a reset rewrites, and object files, which are inert (objects/info, which can point
+// git at other object stores, is included). A symlink is hashed as its target, never followed.
+func gitMeta(dir string) (string, error) {
+	root := filepath.Join(dir, ".git"); h := sha256.New()
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil { return err }
+		rel, _ := filepath.Rel(root, path); rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(rel, "objects/") && !strings.HasPrefix(rel+"/", "objects/info/") { if d.IsDir() { return filepath.SkipDir }; return nil }
+		if rel == "index" { return nil }
+		info, err := d.Info(); if err != nil { return err }
+		fmt.Fprintf(h, "%s\x00%v\x00", rel, info.Mode())
+		switch {
+		case d.Type()&os.ModeSymlink != 0: target, err := os.Readlink(path); if err != nil { return err }; io.WriteString(h, target)
+		case d.Type().IsRegular(): data, err := os.ReadFile(path); if err != nil { return err }; h.Write(data)
+		}
+		h.Write([]byte{0}); return nil
+	})
+	return hex.EncodeToString(h.Sum(nil)), err
+}
+
+// run runs nano in the workspace and collects its report and changes. The run is stopped, with
+// SIGTERM so it saves its session, if the client goes away or the workspace outgrows its quota.
+func (p *pool) run(ctx context.Context, ws *workspace, self string, flags []string, id, prompt string) (res serveRun) {
+	start := time.Now(); res = serveRun{ID: id, Workspace: ws.name, ExitCode: 1}
+	defer func() { res.DurationMS = time.Since(start).Milliseconds() }()
+	if !ws.ready { res.Error = "the workspace could not be provisioned; see the server's log"; return res }
+	changes := filepath.Join(p.runs, id+".changes.json")
+	cmd := exec.CommandContext(ctx, self, append(append(append([]string{}, flags...), "--changes-file", changes), "--", prompt)...); cmd.Dir = ws.dir
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }; cmd.WaitDelay = 10 * time.Second
+	var stdout, stderr bytes.Buffer; cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Start(); err != nil { res.Error = err.Error(); return res }
+	over := make(chan int64, 1); done := make(chan struct{})
+	if p.quota > 0 {
+		go func() {
+			for t := time.NewTicker(2 * time.Second); ; {
+				select {
+				case <-done: t.Stop(); return
+				case <-t.C: if n := dirSize(ws.dir); n > p.quota { over <- n; cmd.Process.Signal(syscall.SIGTERM); t.Stop(); return }
+				}
+			}
+		}()
+	}
+	err := cmd.Wait(); close(done)
+	os.WriteFile(filepath.Join(p.runs, id+".log"), stderr.Bytes(), 0644)
+	var exit *exec.ExitError
+	switch {
+	case err == nil: res.ExitCode = 0
+	case errors.As(err, &exit) && exit.ExitCode() >= 0: res.ExitCode = exit.ExitCode()
+	default: res.ExitCode = 130 // stopped by a signal
+	}
+	if json.Valid(stdout.Bytes()) && stdout.Len() > 0 { res.Report = json.RawMessage(bytes.TrimSpace(stdout.Bytes())) }
+	if data, err := os.ReadFile(changes); err == nil && json.Valid(data) { res.Changes = data }
+	select {
+	case n := <-over: res.Error = fmt.Sprintf("the workspace grew to %s, over its %s quota; the run was stopped", humanBytes(n), humanBytes(p.quota)); ws.ready = false
+	default:
+		if res.Report == nil && err != nil { res.Error = "nano: " + lastLine(stderr.String()); if res.Error == "nano: " { res.Error += err.Error() } }
+		if ctx.Err() != nil { ws.ready = false } // stopped midway; don't trust a reset to catch everything
+	}
+	return res
+}
+
+// janitor removes what nobody is using, every janitorEvery until ctx ends.
+func (p *pool) janitor(ctx context.Context) {
+	for t := time.NewTicker(janitorEvery); ; {
+		select {
+		case <-ctx.Done(): t.Stop(); return
+		case <-t.C: p.sweep()
+		}
+	}
+}
+
+// sweep removes free checkouts idle past p.idle, taking each from the channel so no run can
+// lease it meanwhile, and workspace directories under the base no live server holds.
+func (p *pool) sweep() {
+	for i, n := 0, len(p.free); i < n; i++ {
+		var ws *workspace
+		select { case ws = <-p.free: default: return } // all leased meanwhile
+		if ws.ready && time.Since(ws.used) > p.idle { os.RemoveAll(ws.dir); ws.ready = false; fmt.Fprintf(os.Stderr, "  janitor: removed %s, idle since %s\n", ws.name, ws.used.Format("15:04")) }
+		p.free <- ws
+	}
+	entries, _ := os.ReadDir(p.base)
+	for _, e := range entries {
+		k, err := strconv.Atoi(strings.TrimPrefix(e.Name(), "ws-"))
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), "ws-") || err != nil || k < p.size { continue }
+		lock := filepath.Join(p.base, e.Name()+".lock")
+		unlock, err := lockFile(lock, 0); if err != nil && !errors.Is(err, errNoLocking) { continue } // another server's
+		os.RemoveAll(filepath.Join(p.base, e.Name())); os.Remove(lock); unlock()
+		fmt.Fprintf(os.Stderr, "  janitor: removed abandoned %s\n", e.Name())
+	}
+}
+
+func dirSize(dir string) int64 {
+	var n int64
+	filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() { if info, err := d.Info(); err == nil { n += info.Size() } }
+		return nil
+	})
+	return n
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json"); w.WriteHeader(status)
+	data, _ := json.MarshalIndent(v, "", "  "); w.Write(append(data, '\n'))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// testRepo is a one-commit git repository to clone workspaces from.
+func testRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil { t.Skip("git not installed") }
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull); t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	for _, args := range [][]string{{"init", "-q"}, {"add", "."}, {"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-qm", "init"}} { runGit(t, dir, args...) }
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); if err != nil { t.Fatalf("git %v: %v: %s", args, err, out) }
+	return string(out)
+}
+
+// testPool is a one-workspace pool over repo, with that workspace leased.
+func testPool(t *testing.T, repo string) (*pool, *workspace) {
+	t.Helper()
+	p := &pool{base: t.TempDir(), repo: repo, size: 1}
+	release, err := p.open(); if err != nil { t.Fatal(err) }; t.Cleanup(release)
+	ws, err := p.lease(context.Background()); if err != nil || !ws.ready { t.Fatalf("lease: %v", err) }
+	return p, ws
+}
+
+func TestReleaseResetsTheTree(t *testing.T) {
+	p, ws := testPool(t, testRepo(t))
+	writeTestFile(t, filepath.Join(ws.dir, "main.go"), "package broken\n"); writeTestFile(t, filepath.Join(ws.dir, "new.txt"), "x")
+	p.release(ws); ws = <-p.free
+	if !ws.ready { t.Fatal("a tree-only change should leave the clone ready for reuse") }
+	if got := readTestFile(t, filepath.Join(ws.dir, "main.go")); got != "package main\n" { t.Errorf("main.go %q", got) }
+	if _, err := os.Stat(filepath.Join(ws.dir, "new.txt")); !os.IsNotExist(err) { t.Error("untracked file survived the reset") }
+	p.free <- ws; ws, _ = p.lease(context.Background())
+	p.release(ws); if ws = <-p.free; !ws.ready { t.Error("a second clean release provisioned afresh; the reset's own reflog entry should not count as a change") }
+}
+
+func TestReleaseReprovisionsWhenGitMetadataChanged(t *testing.T) {
+	repo := testRepo(t)
+	for name, change := range map[string]func(dir string){
+		"config": func(dir string) { runGit(t, dir, "config", "core.hooksPath", "hooks-elsewhere") },
+		"hook": func(dir string) { writeTestFile(t, filepath.Join(dir, ".git", "hooks", "post-checkout"), "#!/bin/sh\ntouch pwned\n") },
+		"ref": func(dir string) { runGit(t, dir, "branch", "left-behind") },
+		"stash": func(dir string) {
+			writeTestFile(t, filepath.Join(dir, "main.go"), "package stashed\n"); runGit(t, dir, "-c", "user.name=t", "-c", "user.email=t@example.com", "stash", "-q")
+		},
+		"alternates": func(dir string) { writeTestFile(t, filepath.Join(dir, ".git", "objects", "info", "alternates"), "/elsewhere\n") },
+	} {
+		p, ws := testPool(t, repo)
+		change(ws.dir); p.release(ws); ws = <-p.free
+		if ws.ready { t.Errorf("%s: a changed .git was kept for reuse", name); continue }
+		if _, err := os.Stat(ws.dir); !os.IsNotExist(err) { t.Errorf("%s: the workspace wasn't removed", name) }
+		p.free <- ws; ws, _ = p.lease(context.Background())
+		if !ws.ready { t.Fatalf("%s: not provisioned again", name) }
+		if out, _ := exec.Command("git", "-C", ws.dir, "config", "core.hooksPath").Output(); len(out) > 0 { t.Errorf("%s: core.hooksPath survived: %s", name, out) }
+		if out := runGit(t, ws.dir, "stash", "list"); out != "" { t.Errorf("%s: stash survived: %s", name, out) }
+		if out := runGit(t, ws.dir, "branch", "--list", "left-behind"); out != "" { t.Errorf("%s: branch survived", name) }
+	}
+}
+
+func TestHostGitIgnoresFsmonitorAndHooks(t *testing.T) {
+	dir := testRepo(t)
+	monitor := filepath.Join(t.TempDir(), "monitor"); marker := monitor + ".ran"
+	writeTestFile(t, monitor, "#!/bin/sh\ntouch "+marker+"\n"); os.Chmod(monitor, 0755)
+	runGit(t, dir, "config", "core.fsmonitor", monitor)
+	writeTestFile(t, filepath.Join(dir, ".git", "hooks", "post-checkout"), "#!/bin/sh\ntouch "+marker+"\n"); os.Chmod(filepath.Join(dir, ".git", "hooks", "post-checkout"), 0755)
+	exec.Command("git", "-C", dir, "status").Run()
+	if _, err := os.Stat(marker); err != nil { t.Skip("this git doesn't run core.fsmonitor; nothing to check") }
+	os.Remove(marker)
+	if out, err := hostGit(dir, "status").CombinedOutput(); err != nil { t.Fatalf("%v: %s", err, out) }
+	if out, err := hostGit(dir, "checkout", "-q", "-b", "other").CombinedOutput(); err != nil { t.Fatalf("%v: %s", err, out) }
+	if _, err := os.Stat(marker); err == nil { t.Error("hostGit ran the clone's fsmonitor or hook") }
+}
+
+func TestHealthReportsStatusAndVersion(t *testing.T) {
+	srv := httptest.NewServer((&pool{size: 1}).handler("nano", nil)); defer srv.Close()
+	resp, err := http.Get(srv.URL + "/health"); if err != nil { t.Fatal(err) }
+	defer resp.Body.Close()
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil { t.Fatal(err) }
+	v, _ := buildVersion()
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "application/json" || len(body) != 2 || body["status"] != "ok" || body["version"] != v {
+		t.Errorf("GET /health: %d %q %v", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+	resp, err = http.Post(srv.URL+"/health", "application/json", nil); if err != nil { t.Fatal(err) }
+	resp.Body.Close()
+	if resp.StatusCode != 405 { t.Errorf("POST /health: %d, want 405", resp.StatusCode) }
+}
@@ -0,0 +1,310 @@
+// Saved sessions: a conversation snapshot (model, message history, the files its tools wrote
+// and where it ran) stored as JSON under the data directory, ~/.local/share/nano/sessions/ by
+// default. Every run saves itself under its session ID; --resume continues one, and forks
+// record their parent so `nano sessions` can show the family tree. After the first turn a
+// session gets a short title from a cheap model ($NANO_TITLE_MODEL, "none" to skip), or else
+// the prompt's first words.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sessionVersion is the metadata schema: 1 (no version field) lacked title, prompt and cost.
+const sessionVersion = 2
+
+type savedSession struct {
+	Version  int       `json:"version"`
+	Nano     string    `json:"nano_version,omitempty"` // the build that last saved it
+	ID       string    `json:"id"`
+	Parent   string    `json:"parent,omitempty"`
+	Title    string    `json:"title"`
+	Prompt   string    `json:"prompt"` // the first one
+	Dir      string    `json:"dir"`
+	Model    string    `json:"model"`
+	Saved    time.Time `json:"saved"`
+	Usage    Usage     `json:"usage"`
+	CostUSD  *float64  `json:"cost_usd"` // null when the model isn't priced
+	Files    []string  `json:"files,omitempty"` // written by tools, relative to Dir
+	Pinned   int       `json:"pinned,omitempty"` // leading seed messages (--history) compaction keeps
+	Messages []Message `json:"messages"`
+}
+
+// dataDir is $XDG_DATA_HOME/nano, falling back to ~/.local/share/nano.
+func dataDir() string {
+	if d := os.Getenv("XDG_DATA_HOME"); d != "" { return filepath.Join(d, "nano") }
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share", "nano")
+}
+
+func sessionPath(id string) string { return filepath.Join(dataDir(), "sessions", id+".json") }
+
+func newSessionID() string { return time.Now().Format("20060102-150405-") + randHex(3) }
+
+func workDir() string { if sandboxRoot != "" { return sandboxRoot }; d, _ := os.Getwd(); return d }
+
+// snapshot captures the live conversation as a session; the messages are deep copies.
+func (a *Agent) snapshot(id string) (savedSession, error) {
+	msgs, err := cloneMessages(a.Messages); if err != nil { return savedSession{}, err }
+	nano, _ := buildVersion()
+	s := savedSession{Version: sessionVersion, Nano: nano, ID: id, Parent: a.parent, Title: a.title, Prompt: firstPrompt(msgs[a.pinned:]), Dir: workDir(), Model: a.Model, Saved: time.Now(), Pinned: a.pinned, Messages: msgs}
+	s.Usage = a.prior.Usage; s.Usage.add(a.Usage)
+	if c, ok := a.runCost(); ok && (a.prior.CostUSD != nil || a.prior.Usage == (Usage{})) { // earlier runs unpriced: the total is unknown
+		if a.prior.CostUSD != nil { c += *a.prior.CostUSD }
+		s.CostUSD = &c
+	}
+	for _, p := range a.touched { s.Files = append(s.Files, relTo(realPath(s.Dir), p)) }
+	return s, nil
+}
+
+// saveSession writes the conversation under id (the session ID when empty) and returns the path.
+func (a *Agent) saveSession(id string) (string, error) {
+	if id == "" { id = a.Session }
+	s, err := a.snapshot(id); if err != nil { return "", err }
+	return s.path(), s.write()
+}
+
+func (s savedSession) path() string { return sessionPath(s.ID) }
+
+func (s savedSession) write() error {
+	data, err := json.MarshalIndent(s, "", "  "); if err != nil { return err }
+	if err := os.MkdirAll(filepath.Dir(s.path()), 0700); err != nil { return err }
+	// Write and rename, so a run killed mid-save leaves the previous copy intact.
+	return withLock(filepath.Dir(s.path()), func() error {
+		tmp := s.path() + ".tmp"
+		if err := os.WriteFile(tmp, data, 0600); err != nil { return err }
+		return os.Rename(tmp, s.path())
+	})
+}
+
+func loadSession(id string) (savedSession, error) {
+	var s savedSession
+	data, err := os.ReadFile(sessionPath(id))
+	if os.IsNotExist(err) { return s, fmt.Errorf("no session %q (see nano sessions)", id) } else if err != nil { return s, err }
+	if err := json.Unmarshal(data, &s); err != nil { return s, fmt.Errorf("session %s: %w", id, err) }
+	if s.Version > sessionVersion { return s, fmt.Errorf("session %s was saved by a newer nano (format %d)", id, s.Version) }
+	if err := normalizeMessages(s.Messages); err != nil { return s, err }
+	if s.Version < 2 { s.Prompt = firstPrompt(s.Messages); s.Title = fallbackTitle(s.Prompt) }
+	return s, nil
+}
+
+// resume continues session s in a: same ID, history, model and file list.
+func (a *Agent) resume(s savedSession) {
+	a.Session, a.parent, a.Messages, a.Model, a.title, a.pinned = s.ID, s.Parent, s.Messages, s.Model, s.Title, s.Pinned
+	a.prior.Usage, a.prior.CostUSD = s.Usage, s.CostUSD
+	for _, f := range s.Files { a.touched = append(a.touched, filepath.Join(s.Dir, f)) }
+}
+
+// cloneMessages deep-copies a history by round-tripping it through JSON, so a fork never
+// shares maps or slices with the conversation it came from.
+func cloneMessages(msgs []Message) ([]Message, error) {
+	data, err := json.Marshal(msgs); if err != nil { return nil, err }
+	var out []Message
+	if err := json.Unmarshal(data, &out); err != nil { return nil, err }
+	return out, normalizeMessages(out)
+}
+
+// normalizeMessages turns decoded assistant content back into []Block, the shape Send builds.
+func normalizeMessages(msgs []Message) error {
+	for i, m := range msgs {
+		c, ok := m.Content.([]any); if !ok || m.Role != "assistant" { continue }
+		data, _ := json.Marshal(c); var bl []Block
+		if err := json.Unmarshal(data, &bl); err != nil { return err }
+		msgs[i].Content = bl
+	}
+	return nil
+}
+
+func relTo(dir, p string) string { if r, err := filepath.Rel(dir, p); err == nil && !strings.HasPrefix(r, "..") { return r }; return p }
+
+// autosave saves the session after a turn, titling it first if it's new; failing to save is
+// worth a warning, not the run.
+func (a *Agent) autosave() {
+	if a.title == "" { a.title = a.makeTitle(firstPrompt(a.Messages)) }
+	if _, err := a.saveSession(""); err != nil { fmt.Fprintln(os.Stderr, "warning: could not save session:", err); return }
+	if err := a.checkpointJournal(); err != nil { slog.Warn("crash journal checkpoint failed", "err", err) }
+}
+
+func firstPrompt(msgs []Message) string {
+	for _, m := range msgs { if s, ok := m.Content.(string); ok && m.Role == "user" { return unprefixed(s) } }
+	return ""
+}
+
+func fallbackTitle(prompt string) string {
+	w := strings.Fields(prompt); if len(w) > 8 { w = append(w[:8], "…") }
+	return strings.Join(w, " ")
+}
+
+// makeTitle asks a cheap model for a few-word title. Recorded, replayed and batch runs use
+// the fallback so titling never consumes or adds API exchanges there.
+func (a *Agent) makeTitle(prompt string) string {
+	model := env("NANO_TITLE_MODEL", "claude-haiku-4-5")
+	if prompt == "" || model == "none" || stopped() != nil || a.rec != nil || a.batch || a.Key == "" { return fallbackTitle(prompt) }
+	body, _ := json.Marshal(map[string]any{"model": model, "max_tokens": 30, "messages": []Message{{Role: "user", Content: "Write a title of at most 6 words for a coding session that starts with the request below. Reply with the title only, no quotes or punctuation at the end.\n\n" + truncate(prompt, 2000)}}})
+	raw, err := a.do("POST", a.URL, body)
+	var res Response
+	if err == nil { err = json.Unmarshal(raw, &res) }
+	if err != nil || len(res.Content) == 0 || strings.TrimSpace(res.Content[0].Text) == "" { slog.Debug("session title fell back to the prompt", "err", err); return fallbackTitle(prompt) }
+	a.countUsage(phaseTitle, model, false, res.Usage)
+	return strings.Trim(strings.TrimSpace(res.Content[0].Text), `"'`)
+}
+
+// fork copies s to a new ID whose parent is s, optionally in a fresh git worktree.
+func fork(s savedSession, worktree bool) (savedSession, error) {
+	child := s; child.ID, child.Parent, child.Saved = newSessionID(), s.ID, time.Now()
+	var err error
+	if child.Messages, err = cloneMessages(s.Messages); err != nil { return child, err }
+	child.Files = slices.Clone(s.Files)
+	if worktree {
+		top, err := exec.Command("git", "-C", s.Dir, "rev-parse", "--show-toplevel").Output(); if err != nil { return child, fmt.Errorf("--worktree: %s is not in a git repository", s.Dir) }
+		root := strings.TrimSpace(string(top))
+		wt := filepath.Join(filepath.Dir(root), filepath.Base(root)+"-"+child.ID)
+		if out, err := exec.Command("git", "-C", root, "worktree", "add", "--detach", wt, "HEAD").CombinedOutput(); err != nil { return child, fmt.Errorf("git worktree add: %s", strings.TrimSpace(string(out))) }
+		rel, _ := filepath.Rel(root, s.Dir); child.Dir = filepath.Join(wt, rel)
+		fmt.Fprintln(os.Stderr, "worktree", wt, "checked out at HEAD (uncommitted changes are not copied)")
+	}
+	return child, child.write()
+}
+
+func forkMain(fs *flag.FlagSet) func(args []string) int {
+	worktree := fs.Bool("worktree", false, "give the fork its own git worktree of the session's repository")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano fork [--worktree] <session-id>"); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		if fs.NArg() != 1 { fs.Usage(); return 1 }
+		s, err := loadSession(fs.Arg(0)); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		child, err := fork(s, *worktree); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		fmt.Println(child.ID)
+		fmt.Fprintf(os.Stderr, "continue it with: cd %s && nano --resume %s\n", child.Dir, child.ID)
+		return 0
+	}
+}
+
+func allSessions() ([]savedSession, error) {
+	files, _ := filepath.Glob(filepath.Join(dataDir(), "sessions", "*.json"))
+	var out []savedSession
+	for _, f := range files {
+		s, err := loadSession(strings.TrimSuffix(filepath.Base(f), ".json")); if err != nil { fmt.Fprintln(os.Stderr, "warning:", err); continue }
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Saved.Before(out[j].Saved) })
+	return out, nil
+}
+
+func sessionsMain(fs *flag.FlagSet) func(args []string) int {
+	grep := fs.String("grep", "", "only sessions whose title or prompts match `regexp` (case-insensitive)")
+	dir := fs.String("dir", "", "only sessions that ran in `dir` or below it")
+	prune := fs.String("prune", "", "delete sessions last saved more than `older-than=30d` ago")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano sessions [--grep re] [--dir path] [--prune older-than=30d] | nano sessions rm <id>..."); fs.PrintDefaults() }
+	return func(args []string) int {
+		if len(args) > 0 && args[0] == "rm" { return sessionsRm(args[1:]) }
+		fs.Parse(args)
+		all, err := allSessions(); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		if *prune != "" { return pruneSessions(all, *prune) }
+		var re *regexp.Regexp
+		if *grep != "" { if re, err = regexp.Compile("(?i)" + *grep); err != nil { fmt.Fprintln(os.Stderr, "Error: --grep:", err); return 1 } }
+		if *dir != "" { *dir, _ = filepath.Abs(*dir) }
+		all = slices.DeleteFunc(all, func(s savedSession) bool { return re != nil && !s.matches(re) || *dir != "" && !within(s.Dir, *dir) })
+		if len(all) == 0 { fmt.Fprintln(os.Stderr, "no saved sessions"); return 0 }
+		children := map[string][]savedSession{}; ids := map[string]bool{}
+		for _, s := range all { ids[s.ID] = true }
+		var roots []savedSession
+		for _, s := range all { if s.Parent != "" && ids[s.Parent] { children[s.Parent] = append(children[s.Parent], s) } else { roots = append(roots, s) } }
+		var show func(s savedSession, indent string)
+		show = func(s savedSession, indent string) {
+			cost := "cost ?"; if s.CostUSD != nil { cost = fmt.Sprintf("$%.4f", *s.CostUSD) }
+			fmt.Printf("%s%-24s %s  %-40s %3d msgs  %-9s %s\n", indent, s.ID, s.Saved.Format("2006-01-02 15:04"), truncate(s.Title, 40), len(s.Messages), cost, s.Dir)
+			for _, c := range children[s.ID] { show(c, indent+"  └─ ") }
+		}
+		for _, s := range roots { show(s, "") }
+		return 0
+	}
+}
+
+func (s savedSession) matches(re *regexp.Regexp) bool {
+	if re.MatchString(s.Title) { return true }
+	for _, m := range s.Messages { if p, ok := m.Content.(string); ok && m.Role == "user" && re.MatchString(p) { return true } }
+	return false
+}
+
+func sessionsRm(ids []string) int {
+	if len(ids) == 0 { fmt.Fprintln(os.Stderr, "Usage: nano sessions rm <id>..."); return 1 }
+	code := 0
+	for _, id := range ids {
+		if err := os.Remove(sessionPath(id)); err != nil { fmt.Fprintln(os.Stderr, "Error:", id+":", errors.Unwrap(err)); code = 1 } else { fmt.Println("removed", id) }
+	}
+	return code
+}
+
+func pruneSessions(all []savedSession, spec string) int {
+	v, ok := strings.CutPrefix(spec, "older-than="); if !ok { fmt.Fprintln(os.Stderr, "Error: --prune wants older-than=<age>, e.g. older-than=30d"); return 1 }
+	age, err := parseAge(v); if err != nil { fmt.Fprintln(os.Stderr, "Error: --prune:", err); return 1 }
+	n := 0
+	for _, s := range all {
+		if time.Since(s.Saved) <= age { continue }
+		if err := os.Remove(s.path()); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		n++
+	}
+	fmt.Printf("removed %d session(s) older than %s\n", n, v); return 0
+}
+
+// parseAge is time.ParseDuration plus a d (day) unit: "30d", "12h", "1d12h".
+func parseAge(v string) (time.Duration, error) {
+	days := time.Duration(0)
+	if d, rest, ok := strings.Cut(v, "d"); ok {
+		n, err := strconv.Atoi(d); if err != nil { return 0, fmt.Errorf("bad age %q", v) }
+		days, v = time.Duration(n)*24*time.Hour, rest
+		if v == "" { return days, nil }
+	}
+	d, err := time.ParseDuration(v); if err != nil { return 0, fmt.Errorf("bad age %q", v) }
+	return days + d, nil
+}
+
+// diffSessionsMain compares the files either session wrote as they stand in each session's
+// directory; sessions sharing a directory can only be told apart by which files they touched.
+func diffSessionsMain(fs *flag.FlagSet) func(args []string) int {
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano diff-sessions <session-a> <session-b>"); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		if fs.NArg() != 2 { fs.Usage(); return 1 }
+		a, err := loadSession(fs.Arg(0)); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		b, err := loadSession(fs.Arg(1)); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		files := append(slices.Clone(a.Files), b.Files...); sort.Strings(files); files = slices.Compact(files)
+		if len(files) == 0 { fmt.Println("neither session wrote any files"); return 0 }
+		if a.Dir == b.Dir {
+			fmt.Printf("both sessions ran in %s, so there is one tree; files written by each:\n", a.Dir)
+			for _, f := range files {
+				who := "both"; if !slices.Contains(b.Files, f) { who = a.ID } else if !slices.Contains(a.Files, f) { who = b.ID }
+				fmt.Printf("  %-40s %s\n", f, who)
+			}
+			return 0
+		}
+		same := 0
+		for _, f := range files {
+			x, errA := os.ReadFile(filepath.Join(a.Dir, f)); y, errB := os.ReadFile(filepath.Join(b.Dir, f))
+			switch {
+			case errA != nil && errB != nil: fmt.Printf("=== %s: missing in both\n", f)
+			case errA != nil: fmt.Printf("=== %s: only in %s\n", f, b.ID)
+			case errB != nil: fmt.Printf("=== %s: only in %s\n", f, a.ID)
+			case string(x) == string(y): same++
+			default: fmt.Printf("=== %s\n--- %s\n+++ %s\n%s", f, a.ID, b.ID, lineDiff(string(x), string(y)))
+			}
+		}
+		if same > 0 { fmt.Printf("(%d of %d written file(s) identical)\n", same, len(files)) }
+		return 0
+	}
+}
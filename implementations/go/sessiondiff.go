@@ -0,0 +1,46 @@
+// The net change to the files a session touched: /diff in interactive mode, and
+// --show-diff-on-exit for one-shot runs. Every file a tool wrote is compared from its content
+// before the first write (the snapshot undo takes) to what is on disk now, so edits made in an
+// editor meanwhile show too, and created and deleted files are marked as such. Files changed
+// only by bash commands aren't tracked, and a resumed session starts from the files as they
+// were when it resumed, since backups aren't saved with sessions. On a terminal the diff is
+// colored; one longer than diffPageLines is saved as an artifact instead, and a per-file
+// summary printed with its path. With --output json the report carries the diff as well.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const diffPageLines = 200
+
+func (a *Agent) showDiff(w io.Writer) {
+	d := a.runDiff()
+	if d == "" { fmt.Fprintln(w, "no file changes this session"); return }
+	if n := strings.Count(d, "\n"); n > diffPageLines {
+		if path, err := a.saveArtifact("session.diff", d); err == nil {
+			for _, st := range a.diffStats() { fmt.Fprintf(w, "  %s +%d -%d\n", st.path, st.added, st.removed) }
+			fmt.Fprintf(w, "the full diff (%d lines) is in %s\n", n, path); return
+		}
+	}
+	if f, ok := w.(*os.File); ok && isTTY(f) { d = colorDiff(d) }
+	fmt.Fprint(w, d)
+}
+
+// colorDiff colors runDiff's output for a terminal: headers bold, removals red, additions green.
+func colorDiff(d string) string {
+	lines := strings.SplitAfter(d, "\n")
+	for i, l := range lines {
+		switch {
+		case strings.HasPrefix(l, "--- ") || strings.HasPrefix(l, "+++ "): lines[i] = "\033[1m" + strings.TrimSuffix(l, "\n") + "\033[0m\n"
+		case strings.HasPrefix(l, "-"): lines[i] = "\033[31m" + strings.TrimSuffix(l, "\n") + "\033[0m\n"
+		case strings.HasPrefix(l, "+"): lines[i] = "\033[32m" + strings.TrimSuffix(l, "\n") + "\033[0m\n"
+		case strings.HasPrefix(l, "…"): lines[i] = "\033[2m" + strings.TrimSuffix(l, "\n") + "\033[0m\n"
+		}
+	}
+	return strings.Join(lines, "")
+}
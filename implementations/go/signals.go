@@ -0,0 +1,64 @@
+// Stopping on SIGTERM or SIGHUP (CI cancelling a job, a terminal going away). No new API call
+// or tool starts after the signal; the running tool gets stopGrace to finish before runCtx
+// kills it, and a wait for input gives up at once. The main goroutine, which owns the
+// conversation, then saves the session, prints how to resume and exits 128+signal. The
+// handler only records the signal and cancels; it never saves or exits itself, so nothing
+// races the main goroutine mid-save. A second signal cuts the grace period short. Platforms
+// without these signals (see signals_other.go) don't stop for them.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"time"
+)
+
+const stopGrace = 5 * time.Second
+
+var stopSignal atomic.Value // os.Signal
+
+// stopCtx is cancelled by the first stop signal.
+var stopCtx, stopRun = context.WithCancel(context.Background())
+
+func (a *Agent) watchSignals() {
+	if len(stopSignals) == 0 { return }
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, stopSignals...)
+	go func() {
+		sig := <-ch; stopSignal.Store(sig); stopRun()
+		fmt.Fprintf(os.Stderr, "\n⏹ %s: stopping after the current step\n", sig)
+		grace := time.AfterFunc(stopGrace, cancelRun)
+		<-ch; grace.Stop(); cancelRun()
+		fmt.Fprintln(os.Stderr, "⏹ stopping now")
+	}()
+}
+
+// stopped reports the signal nano is stopping for, as an error, or nil.
+func stopped() error {
+	if sig, _ := stopSignal.Load().(os.Signal); sig != nil { return fmt.Errorf("stopped by %s", sig) }
+	return nil
+}
+
+// readStopping is read, unless a stop signal comes first; then it returns stopped().
+func readStopping(read func() (string, error)) (string, error) {
+	type line struct{ s string; err error }
+	ch := make(chan line, 1)
+	go func() { s, err := read(); ch <- line{s, err} }()
+	select {
+	case l := <-ch: return l.s, l.err
+	case <-stopCtx.Done(): return "", stopped()
+	}
+}
+
+// exitIfStopped saves the session and recording and exits when a stop signal has arrived.
+func (a *Agent) exitIfStopped() {
+	sig, _ := stopSignal.Load().(os.Signal); if sig == nil { return }
+	a.autosave(); a.rec.flush(a.Messages)
+	if keys != nil { keys.stop() }
+	fmt.Fprintln(os.Stderr, "⏹ session saved; resume with nano --resume", a.Session)
+	exit(signalCode(sig))
+}
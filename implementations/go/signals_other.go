@@ -0,0 +1,9 @@
+//go:build plan9 || js || wasip1
+
+package main
+
+import "os"
+
+var stopSignals []os.Signal // none to stop for
+
+func signalCode(os.Signal) int { return 1 }
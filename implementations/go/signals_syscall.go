@@ -0,0 +1,12 @@
+//go:build !(plan9 || js || wasip1)
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+var stopSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP}
+
+func signalCode(sig os.Signal) int { if s, ok := sig.(syscall.Signal); ok { return 128 + int(s) }; return 1 }
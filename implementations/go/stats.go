@@ -0,0 +1,73 @@
+// Timing statistics collected from run events: model time versus tool time and the slowest
+// individual calls, for the end-of-run summary and --output json.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type timedCall struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Detail     string `json:"detail,omitempty"`
+	OffsetMS   int64  `json:"offset_ms"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+type timing struct {
+	start       time.Time
+	model, tool time.Duration
+	calls       []timedCall
+	durations   []time.Duration
+}
+
+func newTiming(a *Agent) *timing {
+	t := &timing{start: time.Now()}; a.On(t.record); return t
+}
+
+func (t *timing) record(e Event) {
+	if e.Kind != "api_call" && e.Kind != "tool_call" { return }
+	c := timedCall{Kind: e.Kind, Name: e.Name, Detail: e.Detail, OffsetMS: e.Start.Sub(t.start).Milliseconds(), DurationMS: e.Duration.Milliseconds()}
+	if e.Err != nil { c.Error = e.Err.Error() }
+	if e.Kind == "api_call" { t.model += e.Duration } else { t.tool += e.Duration }
+	t.calls = append(t.calls, c); t.durations = append(t.durations, e.Duration)
+}
+
+// slowest returns up to n calls ordered by duration, longest first.
+func (t *timing) slowest(n int) []int {
+	idx := make([]int, len(t.calls)); for i := range idx { idx[i] = i }
+	sort.SliceStable(idx, func(i, j int) bool { return t.durations[idx[i]] > t.durations[idx[j]] })
+	return idx[:min(n, len(idx))]
+}
+
+func (t *timing) summary() string {
+	total := time.Since(t.start)
+	pct := func(d time.Duration) float64 { if total == 0 { return 0 }; return 100 * float64(d) / float64(total) }
+	s := fmt.Sprintf("⏱ %s total · model %s (%.0f%%) · tools %s (%.0f%%)", round(total), round(t.model), pct(t.model), round(t.tool), pct(t.tool))
+	var slow []string
+	for _, i := range t.slowest(5) {
+		c := t.calls[i]; label := c.Name
+		if c.Detail != "" { label += " " + truncate(c.Detail, 40) }
+		slow = append(slow, fmt.Sprintf("%s %s", label, round(t.durations[i])))
+	}
+	if len(slow) > 0 { s += "\n  slowest: " + strings.Join(slow, " · ") }
+	return s
+}
+
+func (t *timing) report() map[string]any {
+	return map[string]any{"total_ms": time.Since(t.start).Milliseconds(), "model_ms": t.model.Milliseconds(), "tool_ms": t.tool.Milliseconds(), "calls": t.calls}
+}
+
+func round(d time.Duration) time.Duration {
+	if d < time.Second { return d.Round(time.Millisecond) }; return d.Round(100 * time.Millisecond)
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= n { return s }; return s[:n] + "…"
+}
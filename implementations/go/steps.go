@@ -0,0 +1,62 @@
+// Running the prompt, and with --then each further prompt as the next turn of the same
+// session, so later steps see what earlier ones did. A failed step skips the rest unless
+// --continue-on-error (running out of time skips them regardless); each step reports its own
+// status, and --output json prints an array with one report per step.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// step runs one prompt to completion, verifying and wrapping up at the deadline as the
+// flags ask; err is an earlier failure (planning) that stops it before it starts.
+func (a *Agent) step(prompt string, err error, verify bool, rounds int, deadline time.Duration) report {
+	began := time.Now(); var result string; run := &Result{}
+	if err == nil { run, err = a.Run(prompt); result = run.Text; a.exitIfStopped() }
+	var ver *verification
+	if err == nil && verify && rounds > 0 { result, ver, err = a.Verify(prompt, result, rounds); a.exitIfStopped() }
+	var check *gateRun
+	if err == nil && a.gate.cmd != "" && run.Outcome != "failure" { result, check, err = a.checkGate(result); a.exitIfStopped() }
+	timedOut := a.pastDeadline()
+	if timedOut {
+		if result, err = a.wrapUp(); err != nil { fmt.Fprintln(os.Stderr, "warning:", err) }
+		err = fmt.Errorf("deadline of %s reached; resume with nano --resume %s", deadline, a.Session)
+	}
+	rep := report{Prompt: prompt, Result: result, Status: "completed", Outcome: run.Outcome, FollowUp: run.FollowUp, Turns: len(run.Turns), Usage: run.Usage, CostUSD: run.CostUSD, Steps: run.Turns, FilesChanged: run.FilesChanged, Verify: ver, Check: check, DurationMS: time.Since(began).Milliseconds()}
+	if err != nil { rep.Status, rep.Error = "failed", err.Error(); rep.ErrorType, rep.Hint = a.errorHint(err) }
+	if timedOut { rep.Status = "deadline_exceeded" } else if errors.Is(err, errRefused) { rep.Status = "refused" }
+	return rep
+}
+
+// print shows a step's answer on stdout, and its error on stderr.
+func (r report) print() {
+	if r.Status == "deadline_exceeded" && r.Result != "" { fmt.Println(r.Result) }
+	if r.Error != "" { printError(r.Hint, r.Error); return }
+	if r.AnswerFile != "" { fmt.Printf("answer written to %s (%s)\n", r.AnswerFile, humanBytes(int64(r.AnswerBytes))); return }
+	fmt.Println(r.Result)
+	if len(r.FollowUp) > 0 { fmt.Println("\nFollow-up:\n- " + strings.Join(r.FollowUp, "\n- ")) }
+}
+
+// failed reports whether the step counts as failed for --then: an error, or the model's own
+// failure report.
+func (r report) failed() bool { return r.Error != "" || r.Outcome == "failure" }
+
+func (r report) failure() string { if r.Error != "" { return r.Error }; return "the model reported failure" }
+
+func (r report) exitCode() int {
+	switch r.Status {
+	case "deadline_exceeded": return exitDeadline
+	case "refused": return exitRefused
+	case "failed": return 1
+	}
+	switch r.Outcome {
+	case "failure": return 1
+	case "partial": return exitPartial
+	}
+	return 0
+}
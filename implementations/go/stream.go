@@ -0,0 +1,114 @@
+// Streaming responses (--stream, or NANO_STREAM=1). The request asks for server-sent events
+// and the reply is assembled from them into the same JSON a plain call returns, so the rest of
+// the agent, recordings included, doesn't know the difference. What it buys is feedback while
+// the model generates a long tool input: "tool_input" events carry the input as far as it has
+// arrived, which the CLI draws as a single updating line on a terminal ("writing
+// src/server.go… (3.2 KB so far)", or a bash command as it is written).
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const previewEvery = 100 * time.Millisecond
+
+// doStream posts body and reads the reply as an event stream; a server that answers with
+// plain JSON anyway (as gateways may, and summarize's unstreamed requests get) is read as such.
+func (a *Agent) doStream(body []byte) ([]byte, error) {
+	resp, err := http.DefaultClient.Do(a.newRequest("POST", a.URL, body)); if err != nil { return nil, err }; defer resp.Body.Close()
+	a.gwCost = gw.responseCost(resp.Header)
+	if resp.StatusCode != 200 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") { return a.readResponse(resp) }
+	return a.readStream(io.LimitReader(resp.Body, maxResponseBytes()))
+}
+
+// readStream assembles a message from its events.
+func (a *Agent) readStream(r io.Reader) ([]byte, error) {
+	var msg map[string]any; var blocks []map[string]any; var inputs []*strings.Builder; var scans []*inputScanner
+	var usage = map[string]any{}; var stop any; last := time.Time{}
+	sc := bufio.NewScanner(r); sc.Buffer(make([]byte, 64<<10), 16<<20)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data:"); if !ok { continue }
+		var ev struct {
+			Type         string
+			Index        int
+			Message      map[string]any
+			ContentBlock map[string]any `json:"content_block"`
+			Delta        map[string]any
+			Usage        map[string]any
+		}
+		if err := json.Unmarshal([]byte(data), &ev); err != nil { return nil, fmt.Errorf("decoding stream event: %w", err) }
+		if ev.Type != "message_start" && ev.Type != "error" && ev.Type != "ping" && msg == nil { return nil, fmt.Errorf("stream event %q before message_start", ev.Type) }
+		switch ev.Type {
+		case "message_start":
+			msg = ev.Message; if u, ok := msg["usage"].(map[string]any); ok { for k, v := range u { usage[k] = v } }
+		case "content_block_start":
+			for len(blocks) <= ev.Index { blocks = append(blocks, nil); inputs = append(inputs, &strings.Builder{}); scans = append(scans, nil) }
+			blocks[ev.Index] = ev.ContentBlock
+			if ev.ContentBlock["type"] == "tool_use" { scans[ev.Index] = newInputScanner() }
+		case "content_block_delta":
+			if ev.Index >= len(blocks) || blocks[ev.Index] == nil { return nil, fmt.Errorf("stream delta for unknown block %d", ev.Index) }
+			b := blocks[ev.Index]
+			switch ev.Delta["type"] {
+			case "input_json_delta":
+				frag, _ := ev.Delta["partial_json"].(string); inputs[ev.Index].WriteString(frag)
+				if s := scans[ev.Index]; s != nil { s.feed(frag); if time.Since(last) >= previewEvery { last = time.Now(); a.previewInput(b, s) } }
+			case "text_delta": appendField(b, "text", ev.Delta["text"])
+			case "thinking_delta": appendField(b, "thinking", ev.Delta["thinking"])
+			case "signature_delta": appendField(b, "signature", ev.Delta["signature"])
+			case "citations_delta": cs, _ := b["citations"].([]any); b["citations"] = append(cs, ev.Delta["citation"])
+			}
+		case "content_block_stop":
+			if ev.Index < len(scans) && scans[ev.Index] != nil { a.previewInput(blocks[ev.Index], scans[ev.Index]) }
+		case "message_delta":
+			if s, ok := ev.Delta["stop_reason"]; ok { stop = s }
+			for k, v := range ev.Usage { if v != nil { usage[k] = v } }
+		case "message_stop":
+			for i, b := range blocks {
+				if b == nil { continue }
+				if raw := inputs[i].String(); b["type"] == "tool_use" || raw != "" { if raw == "" { raw = "{}" }; b["input"] = json.RawMessage(raw) }
+			}
+			msg["content"], msg["stop_reason"], msg["usage"] = blocks, stop, usage
+			return json.Marshal(msg)
+		case "error":
+			var e struct{ Error struct{ Type string } }; json.Unmarshal([]byte(data), &e)
+			status := 500; if e.Error.Type == "overloaded_error" { status = 529 }
+			return nil, newAPIError(status, []byte(strings.TrimSpace(data)))
+		}
+	}
+	if err := sc.Err(); err != nil { return nil, fmt.Errorf("reading the response stream: %w", err) }
+	return nil, fmt.Errorf("the response stream ended before message_stop (config max_response_bytes is %s)", humanBytes(maxResponseBytes()))
+}
+
+func appendField(b map[string]any, key string, v any) { s, _ := b[key].(string); t, _ := v.(string); b[key] = s + t }
+
+// previewInput reports a tool input in progress: the path once it is known, or the command as
+// far as it has come, and the bytes received.
+func (a *Agent) previewInput(b map[string]any, s *inputScanner) {
+	name, _ := b["name"].(string); detail := ""
+	if cmd, _ := s.field("command"); cmd != "" { detail = cmd } else if p, done := s.field("path"); done { detail = p }
+	a.emit(Event{Kind: "tool_input", Name: name, Detail: detail, Bytes: s.n})
+}
+
+// showToolInput draws a tool_input event over the previous one, on a terminal only.
+func (a *Agent) showToolInput(e Event) {
+	if !isTTY(os.Stderr) || quiet || a.ci != nil { return }
+	verb := e.Name; if e.Name == "write_file" { verb = "writing" }
+	s := verb + "…"
+	switch {
+	case e.Name == "bash" && e.Detail != "": s = "$ " + e.Detail
+	case e.Detail != "": s = verb + " " + e.Detail + "…"
+	}
+	s = fmt.Sprintf("%s (%s so far)", truncate(s, 100), humanBytes(int64(e.Bytes)))
+	fmt.Fprint(os.Stderr, "\r\033[K"+s); a.previewing = true
+}
+
+// clearPreview removes the tool_input line before anything else is drawn.
+func (a *Agent) clearPreview() { if a.previewing { fmt.Fprint(os.Stderr, "\r\033[K"); a.previewing = false } }
@@ -0,0 +1,79 @@
+// Symbol reads: read_file with symbol returns one declaration of a Go file instead of the
+// whole file, found with go/parser, so the model needn't list symbols and then ask for line
+// numbers. A function or type is named as itself, a method as Type.Method (or just Method,
+// matching it on every type); consts and vars by their names. The declaration comes back
+// with its doc comment, a few lines of context either side and line numbers; several
+// matches all come back, each labeled with its lines. A name that matches nothing gets the
+// file's top-level symbols instead, to pick from.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+const symbolContext = 3 // lines shown before and after a declaration
+
+func readSymbol(path string, src []byte, name string) (string, error) {
+	if filepath.Ext(path) != ".go" { return "", fmt.Errorf("symbol reads support Go files only; read %s without symbol", relPath(path)) }
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments|parser.SkipObjectResolution)
+	if f == nil { return "", fmt.Errorf("parsing %s: %w", relPath(path), err) }
+	lines := strings.SplitAfter(strings.TrimSuffix(string(src), "\n"), "\n")
+	var parts []string
+	for _, d := range goDecls(f) {
+		if d.name != name && d.method != name { continue }
+		from, to := fset.Position(d.node.Pos()).Line, fset.Position(d.node.End()).Line
+		if d.doc != nil { from = fset.Position(d.doc.Pos()).Line }
+		var b strings.Builder
+		if from == to { fmt.Fprintf(&b, "%s (line %d):\n", d.name, from) } else { fmt.Fprintf(&b, "%s (lines %d-%d):\n", d.name, from, to) }
+		for i := max(from-symbolContext, 1); i <= min(to+symbolContext, len(lines)); i++ { fmt.Fprintf(&b, "%6d\t%s", i, strings.TrimSuffix(lines[i-1], "\n")+"\n") }
+		parts = append(parts, b.String())
+	}
+	if parts == nil {
+		var names []string; for _, d := range goDecls(f) { names = append(names, d.name) }
+		msg := fmt.Sprintf("symbol %q not found in %s; available top-level symbols: %s", name, relPath(path), strings.Join(names, ", "))
+		if err != nil { msg += " (the file has syntax errors, so some may be missing)" }
+		return "", fmt.Errorf("%s", msg)
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// goDecl is one top-level declaration: name is as the model asks for it (Type.Method for a
+// method), method the bare method name.
+type goDecl struct {
+	name, method string
+	node         ast.Node
+	doc          *ast.CommentGroup
+}
+
+func goDecls(f *ast.File) []goDecl {
+	var out []goDecl
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			g := goDecl{name: d.Name.Name, node: d, doc: d.Doc}
+			if d.Recv != nil && len(d.Recv.List) > 0 { g.name, g.method = receiverName(d.Recv.List[0].Type)+"."+d.Name.Name, d.Name.Name }
+			out = append(out, g)
+		case *ast.GenDecl:
+			for _, s := range d.Specs {
+				// a spec in a group is shown alone with its own comment; an ungrouped one with the decl's
+				var node ast.Node = s; doc := d.Doc; if d.Lparen.IsValid() { doc = nil } else { node = d }
+				switch s := s.(type) {
+				case *ast.TypeSpec:
+					if s.Doc != nil { doc = s.Doc }
+					out = append(out, goDecl{name: s.Name.Name, node: node, doc: doc})
+				case *ast.ValueSpec:
+					if s.Doc != nil { doc = s.Doc }
+					for _, n := range s.Names { if n.Name != "_" { out = append(out, goDecl{name: n.Name, node: node, doc: doc}) } }
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+// Telemetry seam: runs, API calls and tool executions report spans and counters through this
+// small interface. Without OTEL_EXPORTER_OTLP_ENDPOINT (or when built with -tags nano_nootel)
+// everything is a no-op.
+
+package main
+
+type span interface {
+	Child(name string) span
+	Set(key string, value any)
+	End(err error)
+}
+
+type tracer interface {
+	Start(name string) span
+	Add(metric string, value float64, attrs map[string]any)
+	Shutdown()
+}
+
+type noopSpan struct{}
+
+func (noopSpan) Child(string) span { return noopSpan{} }
+func (noopSpan) Set(string, any)   {}
+func (noopSpan) End(error)         {}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(string) span                  { return noopSpan{} }
+func (noopTracer) Add(string, float64, map[string]any) {}
+func (noopTracer) Shutdown()                           {}
+
+var telemetry tracer = noopTracer{}
@@ -0,0 +1,5 @@
+//go:build nano_nootel
+
+package main
+
+func newTracer() tracer { return noopTracer{} }
@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func isTTY(f *os.File) bool { fi, err := f.Stat(); return err == nil && fi.Mode()&os.ModeCharDevice != 0 }
+
+func makeRaw(*os.File) (func(), error) { return nil, errors.New("raw terminal mode is not supported on this platform") }
+
+func makePolling(*os.File) (func(), error) { return nil, errors.New("raw terminal mode is not supported on this platform") }
@@ -0,0 +1,37 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+// Terminal detection and raw mode via the termios ioctls; a character device (like
+// /dev/null) that isn't a terminal doesn't count.
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func isTTY(f *os.File) bool {
+	var t syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(ioctlGetTermios), uintptr(unsafe.Pointer(&t)))
+	return errno == 0
+}
+
+// makeRaw switches f to byte-at-a-time input without echo or signal keys, for the line
+// editor, and returns the function that restores the previous mode.
+func makeRaw(f *os.File) (func(), error) { return rawMode(f, 1, 0) }
+
+// makePolling is raw mode whose reads give up after a tenth of a second without input, so a
+// reader can notice it should stop instead of waiting for (and eating) the next key.
+func makePolling(f *os.File) (func(), error) { return rawMode(f, 0, 1) }
+
+func rawMode(f *os.File, vmin, vtime uint8) (func(), error) {
+	var old syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(ioctlGetTermios), uintptr(unsafe.Pointer(&old))); errno != 0 { return nil, errno }
+	raw := old
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.ISTRIP | syscall.INPCK
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN], raw.Cc[syscall.VTIME] = vmin, vtime
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(ioctlSetTermios), uintptr(unsafe.Pointer(&raw))); errno != 0 { return nil, errno }
+	return func() { syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(ioctlSetTermios), uintptr(unsafe.Pointer(&old))) }, nil
+}
@@ -0,0 +1,71 @@
+// Line-ending and encoding conventions of existing files. Models emit LF-only UTF-8, so edits
+// match against a normalized copy and the result is written back with the file's own CRLF,
+// BOM and trailing-newline conventions instead of leaving it half converted. A file with mixed
+// endings keeps each line's own ending outside the edited range; only the lines that changed
+// take the dominant one, so a one-line edit stays a one-line diff.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+var bom = []byte("\xef\xbb\xbf")
+
+type textFormat struct {
+	crlf, bom, finalNewline bool
+	lines                   []string // with mixed endings: the original LF text's lines,
+	crlfAt                  []bool   // and whether each of them ended in CRLF
+}
+
+// decodeText splits data into its conventions and LF-normalized text.
+func decodeText(data []byte) (textFormat, string, error) {
+	if !utf8.Valid(data) { return textFormat{}, "", errors.New("file is not valid UTF-8; refusing to edit it as text") }
+	var f textFormat
+	if bytes.HasPrefix(data, bom) { f.bom = true; data = data[len(bom):] }
+	crlf, lf := bytes.Count(data, []byte("\r\n")), bytes.Count(data, []byte("\n")); f.crlf = crlf > 0 && crlf >= lf-crlf
+	text := normalizeNewlines(string(data))
+	f.finalNewline = strings.HasSuffix(text, "\n")
+	if crlf > 0 && crlf < lf {
+		f.lines = strings.Split(text, "\n")
+		for i, c := range data { if c == '\n' { f.crlfAt = append(f.crlfAt, i > 0 && data[i-1] == '\r') } }
+	}
+	return f, text, nil
+}
+
+// encode serializes LF text with f's conventions. keepFinal also restores the original
+// presence or absence of a trailing newline, for edits that didn't mean to change it.
+func (f textFormat) encode(text string, keepFinal bool) []byte {
+	if keepFinal && text != "" {
+		if f.finalNewline && !strings.HasSuffix(text, "\n") { text += "\n" }
+		if !f.finalNewline { text = strings.TrimSuffix(text, "\n") }
+	}
+	if f.lines != nil { text = f.mixedEndings(text) } else if f.crlf { text = strings.ReplaceAll(text, "\n", "\r\n") }
+	if f.bom { return append(append([]byte{}, bom...), text...) }
+	return []byte(text)
+}
+
+// mixedEndings gives the lines text shares with the original, at its start and its end, their
+// original endings, and the lines in between the dominant one.
+func (f textFormat) mixedEndings(text string) string {
+	lines := strings.Split(text, "\n")
+	head := 0; for head < len(lines)-1 && head < len(f.lines)-1 && lines[head] == f.lines[head] { head++ }
+	tail := 0; for tail < len(lines)-1-head && tail < len(f.lines)-1-head && lines[len(lines)-2-tail] == f.lines[len(f.lines)-2-tail] { tail++ }
+	var b strings.Builder
+	for i, l := range lines {
+		b.WriteString(l)
+		if i == len(lines)-1 { break }
+		crlf := f.crlf
+		switch {
+		case i < head: crlf = f.crlfAt[i]
+		case i >= len(lines)-1-tail: crlf = f.crlfAt[len(f.crlfAt)-(len(lines)-1-i)]
+		}
+		if crlf { b.WriteString("\r\n") } else { b.WriteString("\n") }
+	}
+	return b.String()
+}
+
+func normalizeNewlines(s string) string { return strings.ReplaceAll(s, "\r\n", "\n") }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextFormatRoundTrip(t *testing.T) {
+	for _, c := range []struct{ name, data, text string }{
+		{"lf", "a\nb\n", "a\nb\n"},
+		{"crlf", "a\r\nb\r\n", "a\nb\n"},
+		{"bom", "\xef\xbb\xbfa\r\nb", "a\nb"},
+		{"no final newline", "a\nb", "a\nb"},
+		{"mixed", "a\r\nb\nc\r\n", "a\nb\nc\n"},
+		{"lone cr kept", "a\rb\n", "a\rb\n"},
+		{"empty", "", ""},
+	} {
+		f, text, err := decodeText([]byte(c.data)); if err != nil { t.Fatalf("%s: %v", c.name, err) }
+		if text != c.text { t.Errorf("%s: decoded %q, want %q", c.name, text, c.text) }
+		if got := string(f.encode(text, true)); got != c.data { t.Errorf("%s: re-encoded %q, want %q", c.name, got, c.data) }
+	}
+}
+
+func TestDecodeTextRejectsInvalidUTF8(t *testing.T) {
+	if _, _, err := decodeText([]byte("a\xffb")); err == nil || !strings.Contains(err.Error(), "not valid UTF-8") { t.Errorf("got %v, want a UTF-8 error", err) }
+}
+
+func TestEncodeKeepsFinalNewline(t *testing.T) {
+	f, _, _ := decodeText([]byte("a\r\nb"))
+	if got := string(f.encode("a\nc\n", true)); got != "a\r\nc" { t.Errorf("without a final newline: %q", got) }
+	f, _, _ = decodeText([]byte("a\r\nb\r\n"))
+	if got := string(f.encode("a\nc", true)); got != "a\r\nc\r\n" { t.Errorf("with a final newline: %q", got) }
+	if got := string(f.encode("a\nc", false)); got != "a\r\nc" { t.Errorf("keepFinal off: %q", got) }
+}
+
+func TestMixedEndingsOutsideTheEditAreKept(t *testing.T) {
+	data := "one\r\ntwo\nthree\r\nfour\nfive\r\n" // three CRLF, two LF: CRLF dominates
+	for _, c := range []struct{ name, from, to, want string }{
+		{"one line changed", "three", "THREE", "one\r\ntwo\nTHREE\r\nfour\nfive\r\n"},
+		{"line inserted", "two\n", "two\nnew\n", "one\r\ntwo\nnew\r\nthree\r\nfour\nfive\r\n"},
+		{"line removed", "four\n", "", "one\r\ntwo\nthree\r\nfive\r\n"},
+		{"lf line changed", "four", "FOUR", "one\r\ntwo\nthree\r\nFOUR\r\nfive\r\n"},
+	} {
+		f, text, _ := decodeText([]byte(data))
+		if got := string(f.encode(strings.Replace(text, c.from, c.to, 1), true)); got != c.want { t.Errorf("%s: %q, want %q", c.name, got, c.want) }
+	}
+}
+
+func TestEditFileKeepsFileConventions(t *testing.T) {
+	inTempDir(t); t.Cleanup(forgetReads)
+	for _, c := range []struct{ name, data, want string }{
+		{"crlf with bom", "\xef\xbb\xbfx := 1\r\ny := 2\r\n", "\xef\xbb\xbfx := 1\r\ny := 3\r\n"},
+		{"mixed", "w := 0\r\nx := 1\ny := 2\nz := 3\r\n", "w := 0\r\nx := 1\ny := 3\r\nz := 3\r\n"}, // the edited line takes the dominant CRLF
+		{"no final newline", "x := 1\ny := 2", "x := 1\ny := 3"},
+	} {
+		writeTestFile(t, "f.go", c.data)
+		in, _ := decodeInput([]byte(`{"path":"f.go","old_string":"y := 2","new_string":"y := 3"}`))
+		if _, err := editFile(in); err != nil { t.Fatalf("%s: %v", c.name, err) }
+		if got := readTestFile(t, "f.go"); got != c.want { t.Errorf("%s: %q, want %q", c.name, got, c.want) }
+		forgetReads()
+	}
+}
+
+func TestAppendToMixedFileKeepsItsEndings(t *testing.T) {
+	inTempDir(t); t.Cleanup(forgetReads)
+	writeTestFile(t, "notes.txt", "a\r\nb\nc\r\n")
+	in, _ := decodeInput([]byte(`{"path":"notes.txt","content":"d\n","append":true}`))
+	if _, err := writeFile(in); err != nil { t.Fatal(err) }
+	if got := readTestFile(t, "notes.txt"); got != "a\r\nb\nc\r\nd\r\n" { t.Errorf("got %q", got) }
+}
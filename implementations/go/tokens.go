@@ -0,0 +1,82 @@
+// Token counting for context budgeting. Counts are cached per conversation prefix (a hash chain
+// over the messages), seeded for free from each response's input_tokens, so only messages added
+// since the last known count need counting. With NANO_ACCURATE_TOKENS=1 those come from the
+// count_tokens endpoint; otherwise, or when the provider lacks it, a bytes/4 estimate is used.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const contextWindow, maxOutput = 200000, 8192 // contextWindow: for models windowFor doesn't know
+
+type tokenCounts struct {
+	prefix      map[string]int // prefix key -> input tokens of a request with exactly those messages
+	unavailable bool           // count_tokens failed once; stop asking
+}
+
+// keys returns the hash chain for msgs under the current model, system prompt and tools.
+func (a *Agent) tokenKeys(msgs []Message) []string {
+	h := sha256.New(); tools, _ := json.Marshal(schemas(a.Tools)); fmt.Fprintf(h, "%s\x00%s\x00%s", a.Model, a.System, tools)
+	keys := make([]string, len(msgs))
+	for i, m := range msgs { data, _ := json.Marshal(m); h.Write(data); keys[i] = hex.EncodeToString(h.Sum(nil)) }
+	return keys
+}
+
+func (a *Agent) storeCount(msgs []Message, n int) {
+	if len(msgs) == 0 || n <= 0 { return }
+	if a.counts.prefix == nil { a.counts.prefix = map[string]int{} }
+	keys := a.tokenKeys(msgs); a.counts.prefix[keys[len(keys)-1]] = n
+}
+
+// countTokens returns the input tokens a request with msgs would use, and whether the figure is
+// exact rather than estimated.
+func (a *Agent) countTokens(msgs []Message) (int, bool) {
+	keys := a.tokenKeys(msgs)
+	known, base := 0, -1
+	for i := len(keys) - 1; i >= 0; i-- { if n, ok := a.counts.prefix[keys[i]]; ok { known, base = n, i; break } }
+	if base == len(msgs)-1 && base >= 0 { return known, true }
+	if os.Getenv("NANO_ACCURATE_TOKENS") == "1" && !a.counts.unavailable && (a.rec == nil || !a.rec.replay) {
+		if n, err := a.countAPI(msgs); err == nil { a.storeCount(msgs, n); return n, true } else { slog.Info("count_tokens unavailable; estimating", "err", err); a.counts.unavailable = true }
+	}
+	if base < 0 { tools, _ := json.Marshal(schemas(a.Tools)); known = (len(a.System) + len(tools)) / 4 }
+	rest, _ := json.Marshal(msgs[base+1:]); return known + len(rest)/4, false
+}
+
+func (a *Agent) countAPI(msgs []Message) (int, error) {
+	req := map[string]any{"model": a.Model, "messages": msgs, "system": a.System}
+	if len(a.Tools) > 0 { req["tools"] = schemas(a.Tools) }
+	body, _ := json.Marshal(req)
+	if a.Key == "" { return 0, errNoKey }
+	raw, err := a.do("POST", a.URL+"/count_tokens", body); if err != nil { return 0, err }
+	var res struct{ InputTokens int `json:"input_tokens"` }
+	if err := json.Unmarshal(raw, &res); err != nil || res.InputTokens == 0 { return 0, fmt.Errorf("unexpected count_tokens response: %s", raw) }
+	return res.InputTokens, nil
+}
+
+// printCount prints the input tokens of the request prompt would start.
+func (a *Agent) printCount(prompt string) {
+	n, exact := a.countTokens(append(a.Messages, Message{Role: "user", Content: a.prefixed(prompt)}))
+	if exact { fmt.Println(n) } else { fmt.Printf("%d (estimated)\n", n) }
+}
+
+// tokensMain implements `nano tokens "prompt"`: count without running anything.
+func tokensMain(fs *flag.FlagSet) func(args []string) int {
+	enable, disable := toolFlags(fs)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano tokens [flags] \"prompt\"\n\nPrints the input tokens the request would use (exact with NANO_ACCURATE_TOKENS=1)."); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		if fs.NArg() == 0 { fs.Usage(); return 2 }
+		a, err := newAgent(); if err != nil { fmt.Fprintln(os.Stderr, err); return 1 }
+		if a.Tools, err = selectTools(*enable, *disable); err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		a.printCount(strings.Join(fs.Args(), " ")); return 0
+	}
+}
@@ -0,0 +1,216 @@
+// Tool registry. Each tool declares its schema next to its implementation; ReadOnly tools
+// are the ones safe to hand the model before the user has approved any changes.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Tool struct {
+	Name, Description, Schema string
+	ReadOnly                  bool
+	ReadOnlyFor               func(in Input) bool // for tools whose side effects depend on the call
+	Run                       func(in Input) (string, error)
+	Blocks                    func(in Input) ([]Block, error) // instead of Run, for results with images
+	Available                 func() error // nil, or why the tool is off unless explicitly enabled
+}
+
+// Input is a decoded tool_use input; accessors return zero values for absent or mistyped keys
+// (validateInput has already rejected mistyped declared fields before a tool runs).
+type Input map[string]any
+
+func (in Input) Str(key string) string { s, _ := in[key].(string); return s }
+func (in Input) Bool(key string) bool  { b, _ := in[key].(bool); return b }
+func (in Input) Int(key string, def int) int { if f, ok := in[key].(float64); ok { return int(f) }; return def }
+
+var registry = []Tool{
+	{Name: "read_file", Description: "Read file. Images (png, jpg, gif, webp) come back as images you can see; PDFs come back as their text, at most 50 pages at a time (choose with pages, e.g. \"3\" or \"10-20\"). Re-reading a file that hasn't changed since you last read it returns \"unchanged since your last read\" instead of the contents; pass force: true to get them anyway. For a Go file, symbol (a function, type, const or var, or a method as Type.Method) returns just that declaration with line numbers, instead of the whole file.", ReadOnly: true, Schema: `{"type":"object","properties":{"path":{"type":"string"},"force":{"type":"boolean"},"pages":{"type":"string"},"symbol":{"type":"string"}},"required":["path"]}`, Blocks: readFileBlocks},
+	{Name: "write_file", Description: "Write file. With append: true, content is added to the end instead (creating the file if needed), so a large file can be written in parts. Refused if the file changed on disk since you read it; pass force: true to overwrite anyway.", Schema: `{"type":"object","properties":{"path":{"type":"string"},"content":{"type":"string"},"append":{"type":"boolean"},"force":{"type":"boolean"}},"required":["path","content"]}`, Run: writeFile},
+	{Name: "edit_file", Description: "Edit file. Refused if the file changed on disk since you read it; pass force: true to edit anyway.", Schema: `{"type":"object","properties":{"path":{"type":"string"},"old_string":{"type":"string"},"new_string":{"type":"string"},"force":{"type":"boolean"}},"required":["path","old_string","new_string"]}`, Run: editFile},
+	{Name: "search_replace", Description: "Replace pattern (literal text, or a Go regexp with regex: true; $1 in replacement refers to a group) in every non-ignored text file of the project, or only those matching the include glob (e.g. \"*.go\" or \"src/**/*.ts\"). Call it with dry_run: true first to see the matches per file; the same call with dry_run false then applies every edit, or none if any file fails.", Schema: `{"type":"object","properties":{"pattern":{"type":"string"},"replacement":{"type":"string"},"regex":{"type":"boolean"},"include":{"type":"string"},"dry_run":{"type":"boolean"}},"required":["pattern","replacement"]}`, Run: searchReplace, ReadOnlyFor: func(in Input) bool { return in.Bool("dry_run") }},
+	{Name: "bash", Description: bashDescription, Schema: `{"type":"object","properties":{"command":{"type":"string"}},"required":["command"]}`, Run: bash},
+	{Name: "list_dir", Description: "List directory", ReadOnly: true, Schema: `{"type":"object","properties":{"path":{"type":"string"}},"required":["path"]}`, Run: listDir},
+	{Name: "file_info", Description: "Describe a path without reading it: existence, type (and symlink target), size, mode, mtime; line count and language for text files, entry count for directories", ReadOnly: true, Schema: `{"type":"object","properties":{"path":{"type":"string"}},"required":["path"]}`, Run: fileInfo},
+	{Name: "screenshot", Description: "Capture the screen, or with url a headless-browser render of that page, and return it as an image", Schema: `{"type":"object","properties":{"url":{"type":"string"}}}`, Blocks: screenshot, ReadOnlyFor: func(in Input) bool { return in.Str("url") != "" }},
+	{Name: "query_data", Description: "Query a JSON, YAML or CSV file without reading it whole. JSON/YAML: dotted path with * (any key or index), ** (any depth) and [N], optionally compared: \"services.*.image\", \"**.memory > 2Gi\". CSV: column conditions joined by and: \"status = failed and retries >= 3\", or \"row 12\". Operators: = != > < >= <= ~ (contains); sizes like 512Mi compare numerically.", ReadOnly: true, Schema: `{"type":"object","properties":{"path":{"type":"string"},"query":{"type":"string"}},"required":["path","query"]}`, Run: queryData},
+	{Name: "clipboard", Description: "Read the system clipboard (action read; secrets are redacted) or replace it with content (action write)", Schema: `{"type":"object","properties":{"action":{"type":"string","enum":["read","write"]},"content":{"type":"string"}},"required":["action"]}`, Run: clipboard, ReadOnlyFor: func(in Input) bool { return in.Str("action") == "read" }},
+	{Name: "web_search", Description: "Search the web; returns titles, URLs and snippets. Follow up on a result with fetch_url.", ReadOnly: true, Schema: `{"type":"object","properties":{"query":{"type":"string"},"max_results":{"type":"integer"}},"required":["query"]}`, Run: webSearch, Available: searchAvailable},
+	{Name: "fetch_url", Description: "Fetch a public web page or file over HTTP(S) and return it as text", ReadOnly: true, Schema: `{"type":"object","properties":{"url":{"type":"string"}},"required":["url"]}`, Run: fetchURL},
+	{Name: "download_file", Description: "Download a URL to a file (no extraction); reports bytes written and the content type. Redirects must stay on https.", Schema: `{"type":"object","properties":{"url":{"type":"string"},"path":{"type":"string"}},"required":["url","path"]}`, Run: downloadFile},
+	{Name: "archive", Description: "List or extract a .zip, .tar or .tar.gz/.tgz archive. extract writes into destination (created if missing); symlinks and entries escaping it are skipped.", Schema: `{"type":"object","properties":{"action":{"type":"string","enum":["list","extract"]},"path":{"type":"string"},"destination":{"type":"string"}},"required":["action","path"]}`, Run: archive, ReadOnlyFor: func(in Input) bool { return in.Str("action") == "list" }},
+	{Name: "finish", Description: "Report that the task is over and end the run: status success, partial or failure, a summary for the user, and follow_up_items for anything left to do.", ReadOnly: true, Schema: `{"type":"object","properties":{"status":{"type":"string","enum":["success","partial","failure"]},"summary":{"type":"string"},"follow_up_items":{"type":"array","items":{"type":"string"}}},"required":["status","summary"]}`, Run: finishTool},
+	{Name: "git_show", Description: "Show a file as it is at a git ref (branch, tag or commit), with line numbers, without checking anything out: to compare with main or an earlier release.", ReadOnly: true, Schema: `{"type":"object","properties":{"ref":{"type":"string"},"path":{"type":"string"}},"required":["ref","path"]}`, Run: gitShow, Available: inRepo},
+	{Name: "git_blame", Description: "Show who last changed each line of a file and how long ago: commit, author and age per line. Optionally a line range (start_line, end_line) and a ref to blame at.", ReadOnly: true, Schema: `{"type":"object","properties":{"path":{"type":"string"},"start_line":{"type":"integer"},"end_line":{"type":"integer"},"ref":{"type":"string"}},"required":["path"]}`, Run: gitBlame, Available: inRepo},
+	{Name: "http_request", Description: "Send an HTTP request to a local or private-network service (e.g. the dev server you started) and return the status, key headers and body. Redirects are returned, not followed.", Schema: `{"type":"object","properties":{"method":{"type":"string"},"url":{"type":"string"},"headers":{"type":"object"},"body":{"type":"string"}},"required":["url"]}`, Run: httpRequest},
+}
+
+// selectTools applies a whitelist (empty means all) and then a blacklist to the registry;
+// naming a tool that doesn't exist is an error rather than a silent no-op. Tools that need
+// setup (a search provider) are left out of "all" until configured, and listing one
+// explicitly before then is an error.
+func selectTools(enable, disable []string) ([]Tool, error) {
+	for _, name := range append(append([]string{}, enable...), disable...) {
+		if offline && slices.Contains(offlineTools, name) { return nil, fmt.Errorf("tool %s isn't available with --offline", name) }
+		if _, ok := registered(name); !ok { return nil, fmt.Errorf("unknown tool %q (available: %s)", name, strings.Join(toolNames(registry), ", ")) }
+	}
+	var out []Tool
+	for _, t := range registry {
+		if slices.Contains(disable, t.Name) { continue }
+		if len(enable) == 0 && t.Available != nil && t.Available() != nil { continue }
+		if len(enable) > 0 && !slices.Contains(enable, t.Name) { continue }
+		if t.Available != nil { if err := t.Available(); err != nil { return nil, fmt.Errorf("tool %s: %w", t.Name, err) } }
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func registered(name string) (Tool, bool) { for _, t := range registry { if t.Name == name { return t, true } }; return Tool{}, false }
+
+func toolNames(tools []Tool) (names []string) { for _, t := range tools { names = append(names, t.Name) }; return }
+
+// readOnlyCall reports whether this particular call is free of side effects.
+func (t Tool) readOnlyCall(in Input) bool { return t.ReadOnly || t.ReadOnlyFor != nil && t.ReadOnlyFor(in) }
+
+func readOnly(tools []Tool) (out []Tool) { for _, t := range tools { if t.ReadOnly { out = append(out, t) } }; return }
+
+func schemas(tools []Tool) []map[string]any {
+	out := []map[string]any{}
+	for _, t := range tools { out = append(out, map[string]any{"name": t.Name, "description": t.Description, "input_schema": json.RawMessage(t.Schema)}) }
+	return out
+}
+
+func (a *Agent) lookup(name string) (Tool, bool) {
+	for _, t := range a.Tools { if t.Name == name { return t, true } }
+	return Tool{}, false
+}
+
+// Tools return their output and an error; the dispatcher appends "Error: ..." to the output and
+// flags the result is_error. bash returns its output alongside a non-zero exit.
+
+func readFile(in Input) (string, error) {
+	path, err := resolvePath(in.Str("path")); if err != nil { return "", err }
+	fi, err := os.Stat(path); if err != nil { return "", err }
+	if sym := in.Str("symbol"); sym != "" {
+		data, err := os.ReadFile(path); if err != nil { return "", err }
+		recordWrite(path, data) // a later edit compares against this, but a full read isn't "unchanged"
+		return readSymbol(path, data, sym)
+	}
+	if e, ok := cachedRead(path, fi); ok && !in.Bool("force") { return fmt.Sprintf("unchanged since your last read (hash %s); contents omitted", e.hash), nil }
+	data, err := os.ReadFile(path); if err != nil { return "", err }
+	rememberRead(path, fi, data)
+	if ignored(path, false) { return string(data) + "\n[note: this path is ignored by .gitignore/.nanoignore]", nil }
+	return string(data), nil
+}
+
+// readFileBlocks is read_file: images become image blocks, PDFs their text (or a document
+// block), everything else is readFile's text.
+func readFileBlocks(in Input) ([]Block, error) {
+	path, err := resolvePath(in.Str("path")); if err != nil { return nil, err }
+	ext := strings.ToLower(filepath.Ext(path))
+	if _, ok := imageTypes[ext]; ok { return readImage(path) }
+	if ext == ".pdf" { return readPDF(path, in.Str("pages")) }
+	out, err := readFile(in); return []Block{{Type: "text", Text: out}}, err
+}
+
+func writeFile(in Input) (string, error) {
+	path, err := resolvePath(in.Str("path")); if err != nil { return "", err }
+	defer lockPath(path)()
+	current, _ := os.ReadFile(path)
+	if !in.Bool("force") { if err := checkUnmodified(path, current); err != nil { return "", err } }
+	content := []byte(in.Str("content")); if in.Bool("append") { content = append(current, content...) }
+	if f, text, err := decodeText(current); current != nil && err == nil {
+		if !in.Bool("append") { text = "" }
+		content = f.encode(text+normalizeNewlines(in.Str("content")), false)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil { return "", err }
+	recordWrite(path, content)
+	if in.Bool("append") { return fmt.Sprintf("OK, appended; the file now has %d bytes, %d lines", len(content), bytes.Count(content, []byte("\n"))), nil }
+	return "OK", nil
+}
+
+func editFile(in Input) (string, error) {
+	path, err := resolvePath(in.Str("path")); if err != nil { return "", err }
+	defer lockPath(path)()
+	data, err := os.ReadFile(path); if err != nil { return "", err }
+	if !in.Bool("force") { if err := checkUnmodified(path, data); err != nil { return "", err } }
+	f, text, err := decodeText(data); if err != nil { return "", err }
+	old := normalizeNewlines(in.Str("old_string"))
+	if !strings.Contains(text, old) { return "", errors.New("old_string not found") }
+	edited := f.encode(strings.Replace(text, old, normalizeNewlines(in.Str("new_string")), 1), true)
+	if err := os.WriteFile(path, edited, 0644); err != nil { return "", err }
+	recordWrite(path, edited); return "OK", nil
+}
+
+func bash(in Input) (string, error) {
+	ctx, cancel := context.WithCancel(runCtx); defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", in.Str("command")); cmd.Dir, cmd.Env = sandboxRoot, withRunEnv(bashEnv())
+	cmd.WaitDelay = 500 * time.Millisecond // a cancelled command's children may still hold its output open
+	ownProcessGroup(cmd)
+	start := time.Now(); stop := watchCancelKey(cancel)
+	out, err := cmd.Output()
+	if stop() { err = fmt.Errorf("command cancelled by user after %s", time.Since(start).Round(time.Second)) }
+	return scrubOutput(string(out)), err // cut to the limit, and the rest saved, by execLive
+}
+
+const maxToolOutput = 50000
+
+// clip is the shared limit on what a tool hands back to the model.
+func clip(s string) string {
+	if len(s) <= maxToolOutput { return s }
+	return s[:maxToolOutput] + fmt.Sprintf("\n[... truncated %d bytes]", len(s)-maxToolOutput)
+}
+
+func listDir(in Input) (string, error) {
+	path, err := resolvePath(in.Str("path")); if err != nil { return "", err }
+	entries, err := os.ReadDir(path); if err != nil { return "", err }
+	var lines []string; hidden := 0
+	for _, e := range entries {
+		if ignored(filepath.Join(path, e.Name()), e.IsDir()) { hidden++; continue }
+		t := "-"; if e.IsDir() { t = "d" }; lines = append(lines, t+" "+e.Name())
+	}
+	if hidden > 0 { lines = append(lines, fmt.Sprintf("(%d ignored entries hidden by .gitignore/.nanoignore)", hidden)) }
+	return strings.Join(lines, "\n"), nil
+}
+
+// toolsMain implements `nano tools`: every registered tool, whether this configuration
+// enables it, and its description.
+func toolsMain(fs *flag.FlagSet) func(args []string) int {
+	enable, disable := toolFlags(fs)
+	return func(args []string) int {
+		fs.Parse(args)
+		enabled, err := selectTools(*enable, *disable); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		for _, t := range registry {
+			state := "disabled"; if slices.ContainsFunc(enabled, func(e Tool) bool { return e.Name == t.Name }) { state = "enabled" }
+			desc := t.Description; if t.Available != nil { if err := t.Available(); err != nil { desc += " (" + err.Error() + ")" } }
+			fmt.Printf("%-12s %-9s %s\n", t.Name, state, desc)
+		}
+		return 0
+	}
+}
+
+// toolFlags registers --tools and --disable-tools on fs, defaulting to the config's lists.
+func toolFlags(fs *flag.FlagSet) (enable, disable *[]string) {
+	enable, disable = &[]string{}, &[]string{}
+	*enable, *disable = cfg.Tools, cfg.DisableTools
+	list := func(dst *[]string) func(string) error { return func(v string) error { *dst = splitList(v); return nil } }
+	fs.Func("tools", "comma-separated `list` of the only tools to enable (default: all, or config \"tools\")", list(enable))
+	fs.Func("disable-tools", "comma-separated `list` of tools to disable (config \"disable_tools\")", list(disable))
+	return
+}
+
+func splitList(v string) (out []string) {
+	for _, s := range strings.Split(v, ",") { if s = strings.TrimSpace(s); s != "" { out = append(out, s) } }
+	return
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestChunkedWriteWithReadsBetween(t *testing.T) {
+	f := newFakeAPI(t,
+		toolReply("t1", "write_file", `{"path":"big.txt","content":"one\ntwo\n"}`),
+		toolReply("t2", "read_file", `{"path":"big.txt"}`),
+		toolReply("t3", "write_file", `{"path":"big.txt","content":"three\n","append":true}`),
+		toolReply("t4", "read_file", `{"path":"big.txt"}`),
+		toolReply("t5", "write_file", `{"path":"big.txt","content":"four","append":true}`),
+		textReply("written"))
+	after := map[string]string{} // the file after each call
+	snapshot := func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, c ToolCall) (string, []Block, error) {
+			out, blocks, err := next(ctx, c); data, _ := os.ReadFile("big.txt"); after[c.ID] = string(data); return out, blocks, err
+		}
+	}
+	a := testAgent(t, f).WithToolMiddleware(snapshot)
+	if _, err := a.Run("write big.txt in parts"); err != nil { t.Fatal(err) }
+	for id, want := range map[string]string{"t1": "one\ntwo\n", "t2": "one\ntwo\n", "t3": "one\ntwo\nthree\n", "t4": "one\ntwo\nthree\n", "t5": "one\ntwo\nthree\nfour"} {
+		if after[id] != want { t.Errorf("after %s the file is %q, want %q", id, after[id], want) }
+	}
+	for i, c := range []struct{ id, want string }{{"t2", "two"}, {"t3", "OK, appended; the file now has 14 bytes, 3 lines"}, {"t4", "three"}, {"t5", "OK, appended; the file now has 18 bytes, 3 lines"}} {
+		r := toolResult(t, f.request(t, i+2), c.id)
+		if s, _ := r["content"].(string); r["is_error"] == true || !strings.Contains(s, c.want) { t.Errorf("%s: %v, want %q", c.id, r["content"], c.want) }
+	}
+}
+
+func TestAppendRefusedAfterAnOutsideChange(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "read_file", `{"path":"log.txt"}`), toolReply("t2", "write_file", `{"path":"log.txt","content":"b\n","append":true}`), textReply("done"))
+	a := testAgent(t, f); writeTestFile(t, "log.txt", "a\n")
+	changed := func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, c ToolCall) (string, []Block, error) {
+			if c.ID == "t2" { writeTestFile(t, "log.txt", "a\nsomeone else\n") }
+			return next(ctx, c)
+		}
+	}
+	a.WithToolMiddleware(changed)
+	if _, err := a.Run("append"); err != nil { t.Fatal(err) }
+	if r := toolResult(t, f.request(t, 2), "t2"); r["is_error"] != true { t.Errorf("append over an outside change went through: %v", r["content"]) }
+	if got := readTestFile(t, "log.txt"); got != "a\nsomeone else\n" { t.Errorf("log.txt %q", got) }
+}
@@ -0,0 +1,42 @@
+// Tool calls cut off by the output limit. A response that stops at max_tokens with a tool_use
+// as its last block, or a tool input whose JSON ends inside a string or before its closing
+// brace, was truncated mid-call: running it would write half a file, so it gets an error
+// result instead, telling the model to send large content in parts with write_file's append.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var errTruncatedInput = errors.New("tool input was cut off by the output token limit")
+
+// markTruncated flags the final tool_use of a max_tokens response; earlier calls in it are whole.
+func markTruncated(res *Response) {
+	if res.StopReason != "max_tokens" || len(res.Content) == 0 { return }
+	if b := &res.Content[len(res.Content)-1]; b.Type == "tool_use" { b.inputErr = errTruncatedInput }
+}
+
+// truncatedJSON reports whether raw is an object that stops before it is closed.
+func truncatedJSON(raw json.RawMessage) bool {
+	var s string; if json.Unmarshal(raw, &s) == nil { raw = json.RawMessage(s) }
+	depth, inStr, esc := 0, false, false
+	for _, c := range []byte(strings.TrimSpace(string(raw))) {
+		switch {
+		case inStr: if esc { esc = false } else if c == '\\' { esc = true } else if c == '"' { inStr = false }
+		case c == '"': inStr = true
+		case c == '{' || c == '[': depth++
+		case c == '}' || c == ']': depth--
+		}
+	}
+	return inStr || depth > 0
+}
+
+// truncatedAdvice is the tool result for a cut-off call.
+func truncatedAdvice(tool string) string {
+	s := "Error: not run: your " + tool + " input was cut off because the response reached the output token limit, so nothing was done."
+	if tool == "write_file" { return s + " Write the file in parts of at most about 300 lines: write_file with the first part, then write_file with append: true for each following part, in order." }
+	return s + " Re-issue the call with a shorter input, splitting the work into several calls if needed."
+}
@@ -0,0 +1,146 @@
+// Terminal input for interactive mode. One goroutine owns stdin and hands out keys over a
+// channel, so the line editor, approval prompts and typing during a turn never race for it.
+// While a turn runs, typeahead collects what the user types: each finished line is queued
+// and handed to the agent loop at its next boundary (see Agent.pending), Escape clears the
+// queue, and an unfinished line carries over to the next prompt.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// termInput is the terminal in raw mode plus the goroutine reading it.
+type termInput struct {
+	ch      chan rune
+	restore func()
+}
+
+var keys *termInput // set while interactive mode owns the terminal
+
+func startTermInput() (*termInput, error) {
+	restore, err := makeRaw(os.Stdin); if err != nil { return nil, err }
+	t := &termInput{ch: make(chan rune, 4096), restore: restore}
+	go func() {
+		for { r, _, err := stdin.ReadRune(); if err != nil { close(t.ch); return }; t.ch <- r }
+	}()
+	fmt.Fprint(os.Stderr, "\x1b[?2004h") // bracketed paste
+	return t, nil
+}
+
+func (t *termInput) stop() { fmt.Fprint(os.Stderr, "\x1b[?2004l"); t.restore() }
+
+func (t *termInput) next() (rune, error) {
+	select {
+	case r, ok := <-t.ch: if !ok { return 0, io.EOF }; return r, nil
+	case <-stopCtx.Done(): return 0, stopped()
+	}
+}
+
+// escape reads the rest of a CSI or SS3 key sequence after ESC; "" is a lone Escape press.
+func (t *termInput) escape() string {
+	var first rune
+	select {
+	case r, ok := <-t.ch: if !ok { return "" }; first = r
+	case <-time.After(50 * time.Millisecond): return ""
+	}
+	if first != '[' && first != 'O' { return "" }
+	seq := []rune{first}
+	for {
+		c, err := t.next(); if err != nil { return "" }
+		seq = append(seq, c)
+		if c >= 0x40 && c <= 0x7e { return string(seq) }
+	}
+}
+
+// typeahead collects input typed while a turn is running.
+type typeahead struct {
+	mu      sync.Mutex
+	queue   []string
+	partial []rune
+	answer  chan string // non-nil while a prompt (an approval) is waiting for a line
+	cancel  func()      // non-nil while a command runs; x at the start of a line calls it
+	nudging bool        // i at the start of a line: the line is an interjection (see nudge.go)
+	done    chan struct{}
+	exited  chan struct{}
+}
+
+var active *typeahead // the running turn's typeahead, for prompts that need a reply
+
+// startTypeahead reads keys until stop.
+func startTypeahead() *typeahead {
+	q := &typeahead{done: make(chan struct{}), exited: make(chan struct{})}
+	active = q
+	go q.run()
+	return q
+}
+
+func (q *typeahead) run() {
+	defer close(q.exited)
+	for {
+		var r rune
+		select {
+		case <-q.done: return
+		case k, ok := <-keys.ch: if !ok { return }; r = k
+		}
+		q.mu.Lock()
+		echo := q.answer != nil || q.nudging
+		switch r {
+		case '\r', '\n':
+			line := strings.TrimSpace(string(q.partial)); q.partial = nil
+			if q.answer != nil { fmt.Fprint(os.Stderr, "\r\n"); q.answer <- line; q.answer = nil } else if q.nudging { q.nudging = false; addNudge(line) } else if line != "" {
+				q.queue = append(q.queue, line); fmt.Fprintf(ui, "⏳ queued for the next step: %s (Esc clears)\n", line)
+			}
+		case 3: // Ctrl-C ends the session as it would outside raw mode
+			keys.stop(); fmt.Fprintln(os.Stderr, "^C"); exit(130)
+		case 27:
+			if keys.escape() == "" && q.nudging { q.nudging, q.partial = false, nil; fmt.Fprint(os.Stderr, "\r\033[K") } else if q.answer == nil {
+				if n := len(q.queue); n > 0 { fmt.Fprintf(ui, "🗑 cleared %d queued message(s)\n", n) }
+				q.queue, q.partial = nil, nil
+			}
+		case 127, 8:
+			if n := len(q.partial); n > 0 { q.partial = q.partial[:n-1]; if echo { fmt.Fprint(os.Stderr, "\b \b") } }
+		case 'x', 'X':
+			if q.cancel != nil && q.answer == nil && !q.nudging && len(q.partial) == 0 { cancel := q.cancel; q.cancel = nil; q.mu.Unlock(); cancel(); continue }
+			q.partial = append(q.partial, r); if echo { fmt.Fprint(os.Stderr, string(r)) }
+		case 'i', 'I':
+			if q.answer == nil && !q.nudging && len(q.partial) == 0 { q.nudging = true; fmt.Fprint(os.Stderr, nudgePrompt); break }
+			q.partial = append(q.partial, r); if echo { fmt.Fprint(os.Stderr, string(r)) }
+		default:
+			if r >= ' ' || r == '\t' { q.partial = append(q.partial, r); if echo { fmt.Fprint(os.Stderr, string(r)) } }
+		}
+		q.mu.Unlock()
+	}
+}
+
+// drain hands over the queued lines; the agent loop calls it between steps.
+func (q *typeahead) drain() []string {
+	q.mu.Lock(); defer q.mu.Unlock()
+	out := q.queue; q.queue = nil; return out
+}
+
+// ask reads one line for a prompt shown while the turn runs, echoing what is typed.
+func (q *typeahead) ask() string {
+	ch := make(chan string, 1)
+	q.mu.Lock(); q.answer = ch; q.partial = nil; q.mu.Unlock()
+	return <-ch
+}
+
+// stop ends collection and returns the still-queued lines and the unfinished one.
+func (q *typeahead) stop() ([]string, string) {
+	close(q.done); <-q.exited; active = nil
+	q.mu.Lock(); defer q.mu.Unlock()
+	return q.queue, string(q.partial)
+}
+
+// readAnswer reads the user's reply to a prompt: through the running typeahead when
+// interactive mode owns the terminal, otherwise straight from stdin.
+func readAnswer() string {
+	if q := active; q != nil { return q.ask() }
+	line, _ := readStopping(func() (string, error) { return stdin.ReadString('\n') }); return line
+}
@@ -0,0 +1,92 @@
+// Undo for interactive mode. Each exchange (a prompt and everything the model did for it)
+// remembers where it starts in the history and the original contents of every file its
+// tools wrote, so /undo can cut the history back to before the prompt and put those files
+// back. Commands run through bash and other side effects beyond files can't be reverted.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// exchange is one Send: its first message index, prompt and the files it changed.
+type exchange struct {
+	start, instr int // instr: directory instructions already seen
+	prompt       string
+	backups      []fileBackup
+	unsafe       []string // tools with side effects undo can't revert
+}
+
+type fileBackup struct {
+	path    string
+	data    []byte
+	mode    os.FileMode
+	existed bool
+}
+
+// backup snapshots the files a mutating call is about to write, once per exchange, and returns
+// their canonical paths.
+func (a *Agent) backup(tool string, in Input) []string {
+	var paths []string
+	if tool == "search_replace" { paths = replaceTargets(in) } else if p := in.Str("path"); p != "" && tool != "archive" { paths = []string{p} }
+	for i, p := range paths { paths[i] = canonPath(p) }
+	if len(a.exchanges) == 0 { return paths }
+	x := &a.exchanges[len(a.exchanges)-1]
+	if paths == nil && tool != "search_replace" {
+		if !slices.Contains(x.unsafe, tool) { x.unsafe = append(x.unsafe, tool) }
+		return nil
+	}
+	for _, p := range paths { a.backupFile(x, p) }
+	return paths
+}
+
+func (a *Agent) backupFile(x *exchange, p string) {
+	if _, err := resolvePath(p); err != nil { return }
+	path := canonPath(p)
+	if !slices.Contains(a.touched, path) { a.touched = append(a.touched, path) }
+	if slices.ContainsFunc(x.backups, func(b fileBackup) bool { return b.path == path }) { return }
+	fb := fileBackup{path: path}
+	if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+		if fb.data, err = os.ReadFile(path); err != nil { return }
+		fb.mode, fb.existed = fi.Mode().Perm(), true
+	}
+	x.backups = append(x.backups, fb)
+	if a.originals == nil { a.originals = map[string]fileBackup{} }
+	if _, ok := a.originals[path]; !ok { a.originals[path] = fb } // the run's starting point, for --verify
+}
+
+// undo drops the last exchange from the history, restores its files and returns a summary.
+func (a *Agent) undo() (exchange, string, error) {
+	if len(a.exchanges) == 0 { return exchange{}, "", errors.New("nothing to undo") }
+	x := a.exchanges[len(a.exchanges)-1]; a.exchanges = a.exchanges[:len(a.exchanges)-1]
+	dropped := a.Messages[x.start:]; a.Messages = a.Messages[:x.start]
+	calls := 0
+	for _, m := range dropped { if bl, ok := m.Content.([]Block); ok { for _, b := range bl { if b.Type == "tool_use" { calls++ } } } }
+	var restored, failed []string
+	for i := len(x.backups) - 1; i >= 0; i-- {
+		b := x.backups[i]; rel := relPath(b.path)
+		var err error
+		if b.existed { err = os.WriteFile(b.path, b.data, b.mode) } else if err = os.Remove(b.path); errors.Is(err, os.ErrNotExist) { err = nil }
+		if err != nil { failed = append(failed, rel+": "+err.Error()); continue }
+		restored = append(restored, rel)
+	}
+	forgetReads() // the model no longer has what it read during the exchange
+	a.instructions, a.seenDirs = a.instructions[:x.instr], nil
+	s := fmt.Sprintf("undid %q: %d message(s), %d tool call(s) removed", truncate(x.prompt, 40), len(dropped), calls)
+	if len(restored) > 0 { s += "; restored " + strings.Join(restored, ", ") }
+	if len(failed) > 0 { s += "; could not restore " + strings.Join(failed, ", ") }
+	if len(x.unsafe) > 0 { s += "; not reverted: effects of " + strings.Join(x.unsafe, ", ") }
+	return x, s, nil
+}
+
+// shiftExchanges keeps exchange starts valid after compaction replaced messages[from:cut]
+// with one summary message; exchanges that began inside the summarized part can't be undone.
+func (a *Agent) shiftExchanges(from, cut int) {
+	a.exchanges = slices.DeleteFunc(a.exchanges, func(x exchange) bool { return x.start < cut })
+	for i := range a.exchanges { a.exchanges[i].start -= cut - from - 1 }
+}
+
@@ -0,0 +1,120 @@
+// Cross-session usage ledger: every run appends one JSON line to usage.jsonl in the data
+// directory (also when it fails partway), and `nano usage` totals them by project, model or
+// day, or by phase from the per-phase breakdown each record carries (see phases.go). Costs come
+// from the pricing table; runs on unpriced models count tokens only.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type usageRecord struct {
+	Time       time.Time `json:"time"`
+	Project    string    `json:"project"`
+	Model      string    `json:"model"`
+	Usage      Usage     `json:"usage"`
+	CostUSD    *float64  `json:"cost_usd"`
+	DurationMS int64     `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+	Version    string       `json:"nano_version"`
+	Phases     []phaseUsage `json:"phases,omitempty"`
+}
+
+func usagePath() string { return filepath.Join(dataDir(), "usage.jsonl") }
+
+// project is the git top level of the working directory, or the directory itself.
+func project() string {
+	dir := workDir()
+	if out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output(); err == nil { return strings.TrimSpace(string(out)) }
+	return dir
+}
+
+// recordUsage appends this run to the ledger; a failure costs a warning, never the run.
+func (a *Agent) recordUsage(start time.Time, code int) {
+	r := usageRecord{Time: start, Project: project(), Model: a.Model, Usage: a.Usage, DurationMS: time.Since(start).Milliseconds(), ExitCode: code}
+	r.Version, _ = buildVersion(); r.Phases = a.phaseCosts()
+	if c, ok := a.runCost(); ok { r.CostUSD = &c }
+	data, _ := json.Marshal(r)
+	err := os.MkdirAll(dataDir(), 0700)
+	if err == nil {
+		err = withLock(usagePath(), func() error {
+			f, err := os.OpenFile(usagePath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); if err != nil { return err }
+			_, err = f.Write(append(data, '\n')); f.Close(); return err
+		})
+	}
+	if err != nil { fmt.Fprintln(os.Stderr, "warning: could not record usage:", err) }
+}
+
+// mergePhases sums a record's phases over models, so a run counts once per phase.
+func mergePhases(ps []phaseUsage) []phaseUsage {
+	var out []phaseUsage
+	for _, p := range ps {
+		i := slices.IndexFunc(out, func(q phaseUsage) bool { return q.Phase == p.Phase })
+		if i < 0 { p.CostUSD = clonePtr(p.CostUSD); out = append(out, p); continue }
+		out[i].Usage.add(p.Usage)
+		if out[i].CostUSD != nil && p.CostUSD != nil { *out[i].CostUSD += *p.CostUSD } else { out[i].CostUSD = nil }
+	}
+	return out
+}
+
+func clonePtr(f *float64) *float64 { if f == nil { return nil }; c := *f; return &c }
+
+func usageMain(fs *flag.FlagSet) func(args []string) int {
+	since := fs.String("since", "", "only runs newer than `age`, e.g. 7d or 12h")
+	by := fs.String("by", "day", "group by project, model, day or phase")
+	return func(args []string) int {
+		fs.Parse(args)
+		var cutoff time.Time
+		if *since != "" { age, err := parseAge(*since); if err != nil { fmt.Fprintln(os.Stderr, "Error: --since:", err); return 1 }; cutoff = time.Now().Add(-age) }
+		key := map[string]func(usageRecord) string{
+			"project": func(r usageRecord) string { return r.Project },
+			"model":   func(r usageRecord) string { return r.Model },
+			"day":     func(r usageRecord) string { return r.Time.Local().Format("2006-01-02") },
+			"phase":   nil, // per phase within each record, below
+		}[*by]
+		if key == nil && *by != "phase" { fmt.Fprintf(os.Stderr, "Error: --by must be project, model, day or phase, not %q\n", *by); return 1 }
+		f, err := os.Open(usagePath())
+		if os.IsNotExist(err) { fmt.Fprintln(os.Stderr, "no usage recorded yet"); return 0 } else if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }
+		defer f.Close()
+		type total struct{ runs, failed, in, out int; cost float64; unpriced int }
+		groups := map[string]*total{}; all := &total{}
+		sc := bufio.NewScanner(f); sc.Buffer(nil, 1<<20)
+		for sc.Scan() {
+			var r usageRecord
+			if json.Unmarshal(sc.Bytes(), &r) != nil || r.Time.Before(cutoff) { continue }
+			count := func(name string, u Usage, cost *float64) {
+				g := all // name "": the overall total
+				if name != "" { if g = groups[name]; g == nil { g = &total{}; groups[name] = g } }
+				g.runs++; g.in += u.InputTokens; g.out += u.OutputTokens
+				if r.ExitCode != 0 { g.failed++ }
+				if cost != nil { g.cost += *cost } else { g.unpriced++ }
+			}
+			count("", r.Usage, r.CostUSD)
+			if key != nil { count(key(r), r.Usage, r.CostUSD); continue }
+			if len(r.Phases) == 0 { count("(not recorded)", r.Usage, r.CostUSD) }
+			for _, ph := range mergePhases(r.Phases) { count(ph.Phase, ph.Usage, ph.CostUSD) }
+		}
+		names := sortedKeys(groups)
+		if *by != "day" { sort.SliceStable(names, func(i, j int) bool { return groups[names[i]].cost > groups[names[j]].cost }) }
+		row := func(name string, t *total) {
+			cost := fmt.Sprintf("$%.4f", t.cost)
+			if t.unpriced == t.runs { cost = "unknown" } else if t.unpriced > 0 { cost += fmt.Sprintf(" + %d unpriced", t.unpriced) }
+			fmt.Printf("%-40s %5d %6d %12d %12d  %s\n", truncate(name, 40), t.runs, t.failed, t.in, t.out, cost)
+		}
+		fmt.Printf("%-40s %5s %6s %12s %12s  %s\n", *by, "runs", "failed", "input", "output", "cost")
+		for _, n := range names { row(n, groups[n]) }
+		row("total", all)
+		return 0
+	}
+}
@@ -0,0 +1,49 @@
+// Tool input validation against the declared input_schema, deliberately small: required
+// fields must be present and declared properties must carry the declared JSON type. Fields
+// the schema doesn't mention are tolerated.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+type inputSchema struct {
+	Properties map[string]struct{ Type string `json:"type"` } `json:"properties"`
+	Required   []string                                      `json:"required"`
+}
+
+func validateInput(schema string, in Input) error {
+	var s inputSchema
+	if err := json.Unmarshal([]byte(schema), &s); err != nil { return fmt.Errorf("tool schema is invalid: %w", err) }
+	for _, name := range s.Required { if v, ok := in[name]; !ok || v == nil { return fmt.Errorf("missing required field %q", name) } }
+	for _, name := range sortedKeys(in) {
+		p, ok := s.Properties[name]; v := in[name]
+		if !ok || p.Type == "" || v == nil || jsonTypeMatches(p.Type, v) { continue }
+		return fmt.Errorf("field %q must be %s, got %s", name, withArticle(p.Type), withArticle(jsonTypeOf(v)))
+	}
+	return nil
+}
+
+func jsonTypeMatches(want string, v any) bool {
+	if f, ok := v.(float64); ok && want == "integer" { return f == math.Trunc(f) }
+	return jsonTypeOf(v) == want || want == "number" && jsonTypeOf(v) == "integer"
+}
+
+func jsonTypeOf(v any) string {
+	switch x := v.(type) {
+	case string: return "string"
+	case bool: return "boolean"
+	case float64: if x == math.Trunc(x) { return "integer" }; return "number"
+	case []any: return "array"
+	case map[string]any: return "object"
+	}
+	return "null"
+}
+
+func withArticle(t string) string {
+	if t == "integer" || t == "array" || t == "object" { return "an " + t }
+	return "a " + t
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSchema = `{"type":"object","properties":{"path":{"type":"string"},"line":{"type":"integer"},"ratio":{"type":"number"},"force":{"type":"boolean"},"tags":{"type":"array"}},"required":["path"]}`
+
+func TestValidateInput(t *testing.T) {
+	for _, c := range []struct {
+		name, input, want string // want: "" for valid, else a substring of the error
+	}{
+		{"valid", `{"path":"a.go","line":3,"ratio":0.5,"force":true,"tags":["x"]}`, ""},
+		{"missing path", `{"line":3}`, `missing required field "path"`},
+		{"null path", `{"path":null}`, `missing required field "path"`},
+		{"number for string", `{"path":7}`, `field "path" must be a string, got an integer`},
+		{"fraction for integer", `{"path":"a","line":1.5}`, `field "line" must be an integer, got a number`},
+		{"integer for number", `{"path":"a","ratio":2}`, ""},
+		{"string for boolean", `{"path":"a","force":"yes"}`, `field "force" must be a boolean, got a string`},
+		{"object for array", `{"path":"a","tags":{}}`, `field "tags" must be an array, got an object`},
+		{"unknown fields tolerated", `{"path":"a","extra":1,"more":{"x":true}}`, ""},
+	} {
+		in, err := decodeInput([]byte(c.input)); if err != nil { t.Fatalf("%s: %v", c.name, err) }
+		err = validateInput(testSchema, in)
+		switch {
+		case c.want == "" && err != nil: t.Errorf("%s: unexpected error %v", c.name, err)
+		case c.want != "" && (err == nil || !strings.Contains(err.Error(), c.want)): t.Errorf("%s: error %v, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestInvalidInputIsReportedToTheModel(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "write_file", `{"content":"x"}`), toolReply("t2", "read_file", `{"path":42}`), textReply("done"))
+	a := testAgent(t, f)
+	if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+	for i, c := range []struct{ id, want string }{{"t1", `missing required field "path"`}, {"t2", `field "path" must be a string`}} {
+		r := toolResult(t, f.request(t, i+1), c.id); content, _ := r["content"].(string)
+		if r["is_error"] != true || !strings.Contains(content, c.want) { t.Errorf("%s: %v %q, want an error quoting %q", c.id, r["is_error"], content, c.want) }
+	}
+}
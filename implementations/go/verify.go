@@ -0,0 +1,95 @@
+// --verify: a self-review pass before a run counts as done. Once the model stops calling
+// tools, a separate tool-free request shows it the task, its final answer and the cumulative
+// diff of every file the run's tools wrote, and asks for concrete problems or APPROVED.
+// Problems go back into the conversation as a marked user turn and the loop carries on, up
+// to --verify-rounds times. Everything spent from the first review on is tallied on its own
+// so the summary can show what verifying cost.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const reviewPrompt = "You are reviewing a coding agent's work before it is declared complete. Check the changes against the task and look for concrete problems: bugs, broken or missing pieces, parts of the task not done, leftover debugging code. Reply with a short list of the problems, each specific enough to act on, or with just APPROVED if there are none. Don't list style nits or speculative improvements."
+
+const maxReviewDiff = 100_000 // bytes of diff sent for review
+
+type verification struct {
+	Rounds   int      `json:"rounds"`
+	Approved bool     `json:"approved"`
+	Problems string   `json:"problems,omitempty"` // the last review's, when not approved
+	Turns    int      `json:"turns"`
+	Usage    Usage    `json:"usage"`
+	CostUSD  *float64 `json:"cost_usd"`
+}
+
+// Verify reviews the run's work and sends the problems found back for fixing until a review
+// approves or rounds run out. It returns the final answer.
+func (a *Agent) Verify(prompt, result string, rounds int) (string, *verification, error) {
+	v := &verification{}
+	turns, usage := a.Turns, a.Usage; cost, priced := a.runCost()
+	defer func() {
+		v.Turns, v.Usage = a.Turns-turns, a.Usage.minus(usage)
+		if c, ok := a.runCost(); ok && priced { c -= cost; v.CostUSD = &c }
+	}()
+	for v.Rounds < rounds {
+		v.Rounds++
+		a.notify(fmt.Sprintf("🔍 verifying (round %d/%d)", v.Rounds, rounds))
+		problems, err := a.review(prompt, result); if err != nil { return result, v, err }
+		if problems == "" { a.notify("✓ verification approved"); v.Approved, v.Problems = true, ""; return result, v, nil }
+		a.notify("✗ verification found problems:\n" + problems)
+		v.Problems = problems
+		if v.Rounds == rounds { break }
+		if result, err = a.Send(fmt.Sprintf("[verification round %d/%d] A review of your changes found these problems. Fix them, then reply with your final answer again.\n\n%s", v.Rounds, rounds, problems)); err != nil { return result, v, err }
+	}
+	fmt.Fprintf(os.Stderr, "warning: verification still found problems after %d round(s)\n", v.Rounds)
+	return result, v, nil
+}
+
+// review asks for a verdict on the run so far and returns the problems, or "" when approved.
+func (a *Agent) review(prompt, result string) (string, error) {
+	diff := a.runDiff(); if diff == "" { diff = "(no files were changed)\n" }
+	if len(diff) > maxReviewDiff { diff = diff[:maxReviewDiff] + fmt.Sprintf("\n… %d more bytes of diff not shown\n", len(diff)-maxReviewDiff) }
+	msg := fmt.Sprintf("%s\n\nThe task:\n%s\n\nThe agent's final answer:\n%s\n\nThe changes it made:\n```diff\n%s```", reviewPrompt, prompt, result, diff)
+	body, _ := json.Marshal(map[string]any{"model": a.Model, "max_tokens": 2048, "messages": []Message{{Role: "user", Content: msg}}})
+	raw, err := a.post(body); if err != nil { return "", fmt.Errorf("verification: %w", err) }
+	var res Response; if err := json.Unmarshal(raw, &res); err != nil { return "", fmt.Errorf("verification: %w", err) }
+	a.Turns++; a.addUsage(phaseVerify, res.Usage)
+	text := strings.TrimSpace(responseText(res.Content))
+	if path, err := a.saveArtifact("verification.md", fmt.Sprintf("# Verification review\n\n%s\n\n## The diff reviewed\n\n```diff\n%s```\n", text, diff)); err == nil { a.notify("📝 review saved to " + path) }
+	if text == "" || strings.HasPrefix(strings.ToUpper(text), "APPROVED") { return "", nil }
+	return text, nil
+}
+
+// runDiff is the diff of every file written this run, from its content before the first
+// write to now.
+func (a *Agent) runDiff() string {
+	paths := make([]string, 0, len(a.originals)); for p := range a.originals { paths = append(paths, p) }
+	sort.Strings(paths)
+	var sb strings.Builder
+	for _, p := range paths {
+		was := a.originals[p]; now, err := os.ReadFile(p); exists := err == nil
+		if !was.existed && !exists || was.existed && exists && string(now) == string(was.data) { continue }
+		rel := relPath(p)
+		switch {
+		case !exists: fmt.Fprintf(&sb, "--- %s (deleted)\n", rel)
+		case !was.existed: fmt.Fprintf(&sb, "+++ %s (new file)\n", rel)
+		default: fmt.Fprintf(&sb, "--- %s\n+++ %s\n", rel, rel)
+		}
+		sb.WriteString(lineDiff(string(was.data), string(now)))
+	}
+	return sb.String()
+}
+
+// summary is the verification part of the run summary line.
+func (v *verification) summary() string {
+	s := fmt.Sprintf("verify %d round(s)", v.Rounds)
+	if !v.Approved { s += ", not approved" }
+	if v.CostUSD != nil { s += fmt.Sprintf(" $%.4f", *v.CostUSD) }
+	return s
+}
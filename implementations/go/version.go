@@ -0,0 +1,39 @@
+// Build identification: --version, the User-Agent on every API request, and the version
+// stamped into recordings, sessions and the usage ledger. Release builds set version with
+//
+//	go build -ldflags "-X main.version=1.2.3"
+//
+// otherwise it comes from the module's build info (a tagged `go install`) or stays "dev".
+// The commit is the VCS revision Go embeds when building inside a checkout.
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+var version = ""
+
+// buildVersion returns the version and the commit (with "+dirty" for a modified tree).
+func buildVersion() (string, string) {
+	v, commit, dirty := version, "unknown", false
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "" && info.Main.Version != "(devel)" { v = info.Main.Version }
+		for _, s := range info.Settings {
+			switch s.Key { case "vcs.revision": commit = s.Value; case "vcs.modified": dirty = s.Value == "true" }
+		}
+	}
+	if v == "" { v = "dev" }
+	if len(commit) > 12 { commit = commit[:12] }
+	if dirty { commit += "+dirty" }
+	return v, commit
+}
+
+func userAgent() string { v, _ := buildVersion(); return fmt.Sprintf("nano-opencode/%s (%s; %s)", v, runtime.GOOS, runtime.GOARCH) }
+
+func printVersion() {
+	v, commit := buildVersion()
+	fmt.Printf("nano-opencode %s\ncommit %s\n%s %s/%s\n", v, commit, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
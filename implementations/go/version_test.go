@@ -0,0 +1,27 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUserAgentOnEveryRequest(t *testing.T) {
+	f := newFakeAPI(t, toolReply("t1", "bash", `{"command":"true"}`), textReply("done"))
+	a := testAgent(t, f)
+	if _, err := a.Run("go"); err != nil { t.Fatal(err) }
+	want := "nano-opencode/dev (" + runtime.GOOS + "; " + runtime.GOARCH + ")"
+	if f.count() != 2 { t.Fatalf("%d requests, want 2", f.count()) }
+	for i, h := range f.headers { if got := h.Get("User-Agent"); got != want { t.Errorf("request %d: User-Agent %q, want %q", i, got, want) } }
+}
+
+func TestVersionFromLdflags(t *testing.T) {
+	saved := version; t.Cleanup(func() { version = saved })
+	version = "1.2.3"
+	if v, _ := buildVersion(); v != "1.2.3" { t.Errorf("version %q, want 1.2.3", v) }
+	if ua := userAgent(); !strings.HasPrefix(ua, "nano-opencode/1.2.3 (") { t.Errorf("User-Agent %q", ua) }
+	out := captureStdout(t, printVersion)
+	for _, want := range []string{"nano-opencode 1.2.3\n", "commit ", runtime.Version()} {
+		if !strings.Contains(out, want) { t.Errorf("--version output %q lacks %q", out, want) }
+	}
+}
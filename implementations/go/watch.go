@@ -0,0 +1,168 @@
+// Watch mode: `nano watch --glob 'api/*.yaml' "regenerate the client"` re-sends the prompt
+// whenever a matching file changes, each time as a new turn of one saved session. The tree is
+// polled (the standard library has no file notification API); a burst of changes waits until
+// things have been quiet for --debounce. Files the agent's own tools wrote during a run are
+// remembered with their size and mtime, and a change that matches what the agent left behind
+// doesn't trigger another run. A failed run is reported and watching goes on; Ctrl-C saves
+// the session and exits.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type stamp struct {
+	mod  time.Time
+	size int64
+}
+
+// watcher polls the files under the working directory that match its globs.
+type watcher struct {
+	globs []*regexp.Regexp
+	seen  map[string]stamp // as of the last run, plus the agent's own writes since
+	ours  map[string]stamp // files as the agent's tools left them
+}
+
+func watchMain(fs *flag.FlagSet) func(args []string) int {
+	var globs []string
+	fs.Func("glob", "watch files matching `pattern` (gitignore-style, ** allowed); repeatable", func(v string) error { globs = append(globs, v); return nil })
+	interval := fs.Duration("interval", 500*time.Millisecond, "how often to check for changes")
+	debounce := fs.Duration("debounce", time.Second, "wait until files have been quiet this long before running")
+	resume := fs.String("resume", "", "continue saved session `id` instead of starting a new one")
+	model := fs.String("model", "", "model to use, or an alias: opus, sonnet, haiku")
+	fs.BoolVar(&autoApprove, "yes", false, "approve every write and command without asking")
+	noLock := fs.Bool("no-lock", false, "run even if another nano is running in this project")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, "Usage: nano watch --glob pattern [--glob pattern...] \"prompt\""); fs.PrintDefaults() }
+	return func(args []string) int {
+		fs.Parse(args)
+		prompt := strings.Join(fs.Args(), " "); if prompt == "" || len(globs) == 0 { fs.Usage(); return 1 }
+		w := &watcher{ours: map[string]stamp{}}
+		for _, g := range globs {
+			re, err := regexp.Compile("^" + globRegexp(strings.TrimPrefix(filepath.ToSlash(g), "./")) + "$"); if err != nil { fmt.Fprintf(os.Stderr, "Error: --glob %q: %v\n", g, err); return 1 }
+			w.globs = append(w.globs, re)
+		}
+		a, err := newAgent(); if err != nil { fmt.Fprintln(os.Stderr, err); return 1 }
+		a.renderProgress()
+		if *model != "" { a.Model = resolveModel(*model) }
+		if *resume != "" { s, err := loadSession(*resume); if err != nil { fmt.Fprintln(os.Stderr, "Error:", err); return 1 }; a.resume(s) }
+		if !*noLock && !acquireRunLock("nano watch: " + prompt) { return 1 }
+		a.watchSignals(); a.openJournal(); defer a.closeJournal()
+		a.span = telemetry.Start("nano.watch")
+		w.track(a)
+		var interrupted atomic.Bool
+		sigs := make(chan os.Signal, 1); signal.Notify(sigs, os.Interrupt)
+		go func() {
+			<-sigs; interrupted.Store(true); fmt.Fprintln(os.Stderr, "\n⏹ stopping")
+			cancelRun() // abandon the run in progress, if any
+			<-sigs; a.autosave(); exit(130)
+		}()
+
+		w.seen = w.scan()
+		fmt.Fprintf(os.Stderr, "👀 watching %d file(s) matching %s · session %s · Ctrl-C to stop\n", len(w.seen), strings.Join(globs, ", "), a.Session)
+		for n := 1; ; n++ {
+			changed := w.wait(*interval, *debounce, func() bool { return interrupted.Load() || stopped() != nil })
+			a.exitIfStopped()
+			if interrupted.Load() { break }
+			cost0, _ := a.runCost(); start := time.Now()
+			fmt.Fprintf(os.Stderr, "▶ #%d: %s changed\n", n, summarizePaths(changed))
+			result, err := a.Send(fmt.Sprintf("%s\n\n(Files changed since the last run: %s)", prompt, strings.Join(changed, ", ")))
+			a.exitIfStopped()
+			if interrupted.Load() { break }
+			a.autosave()
+			var wrote []string; for _, b := range a.exchanges[len(a.exchanges)-1].backups { wrote = append(wrote, relPath(b.path)) }
+			w.settle()
+			status := fmt.Sprintf("#%d %s · %s changed", n, round(time.Since(start)), summarizePaths(changed))
+			if len(wrote) > 0 { status += " · wrote " + summarizePaths(wrote) }
+			if c, ok := a.runCost(); ok { status += fmt.Sprintf(" · $%.4f", c-cost0) }
+			if err != nil { fmt.Fprintf(os.Stderr, "✗ %s · Error: %v\n", status, err); continue }
+			fmt.Println(result)
+			fmt.Fprintln(os.Stderr, "✓", status)
+		}
+		a.autosave(); a.span.End(nil)
+		fmt.Fprintln(os.Stderr, "⏹ session saved; resume with nano watch --resume", a.Session)
+		return 0
+	}
+}
+
+// scan stamps every matching file, skipping ignored directories.
+func (w *watcher) scan() map[string]stamp {
+	out := map[string]stamp{}
+	filepath.WalkDir(".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == "." { return nil }
+		if ignored(p, d.IsDir()) { if d.IsDir() { return filepath.SkipDir }; return nil }
+		if d.IsDir() || !w.matches(filepath.ToSlash(p)) { return nil }
+		if fi, err := d.Info(); err == nil { out[filepath.ToSlash(p)] = stamp{fi.ModTime(), fi.Size()} }
+		return nil
+	})
+	return out
+}
+
+func (w *watcher) matches(rel string) bool {
+	for _, re := range w.globs { if re.MatchString(rel) { return true } }
+	return false
+}
+
+// changedFiles lists the files that differ between two scans: changed, added or removed.
+func changedFiles(old, cur map[string]stamp) []string {
+	var out []string
+	for p, s := range cur { if o, ok := old[p]; !ok || !o.same(s) { out = append(out, p) } }
+	for p := range old { if _, ok := cur[p]; !ok { out = append(out, p) } }
+	sort.Strings(out)
+	return out
+}
+
+func (s stamp) same(o stamp) bool { return s.mod.Equal(o.mod) && s.size == o.size }
+
+// wait polls until some files have changed and then stayed put for debounce, and returns them.
+func (w *watcher) wait(interval, debounce time.Duration, stop func() bool) []string {
+	var last map[string]stamp; var quietSince time.Time
+	for !stop() {
+		cur := w.scan()
+		if changed := changedFiles(w.seen, cur); len(changed) > 0 {
+			if last == nil || len(changedFiles(last, cur)) > 0 { last, quietSince = cur, time.Now() }
+			if time.Since(quietSince) >= debounce { w.seen = cur; return changed }
+		}
+		time.Sleep(interval)
+	}
+	return nil
+}
+
+// track notes the files a's tools write.
+func (w *watcher) track(a *Agent) {
+	a.On(func(e Event) { if e.Kind == "tool_call" && e.Err == nil { for _, p := range e.Paths { w.noteWrite(p) } } })
+}
+
+// noteWrite stamps a watched file right after one of the agent's tools wrote it (path is
+// canonical, from the tool_call event); a zero stamp records that the tool deleted it.
+func (w *watcher) noteWrite(path string) {
+	rel := filepath.ToSlash(relPath(path)); if !w.matches(rel) { return }
+	s := stamp{}; if fi, err := os.Stat(path); err == nil { s = stamp{fi.ModTime(), fi.Size()} }
+	w.ours[rel] = s
+}
+
+// settle accepts the changes that leave files as the agent's tools did, so they don't set off
+// another run; anything else stays a change for the next wait.
+func (w *watcher) settle() {
+	cur := w.scan()
+	for _, p := range changedFiles(w.seen, cur) {
+		mine, ok := w.ours[p]; if !ok || !mine.same(cur[p]) { continue }
+		if s, exists := cur[p]; exists { w.seen[p] = s } else { delete(w.seen, p) }
+	}
+}
+
+// summarizePaths names up to three paths and counts the rest.
+func summarizePaths(paths []string) string {
+	if len(paths) <= 3 { return strings.Join(paths, ", ") }
+	return fmt.Sprintf("%s and %d more", strings.Join(paths[:3], ", "), len(paths)-3)
+}
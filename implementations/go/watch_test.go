@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWatchIgnoresTheAgentsOwnWrites(t *testing.T) {
+	f := newFakeAPI(t,
+		reply("tool_use", toolBlock("t1", "write_file", `{"path":"./src/../a.go","content":"package a\n"}`), toolBlock("t2", "search_replace", `{"pattern":"old","replacement":"new","dry_run":true}`)),
+		reply("tool_use", toolBlock("t3", "search_replace", `{"pattern":"old","replacement":"new"}`), toolBlock("t4", "bash", `{"command":"echo package c > c.go"}`)),
+		textReply("done"))
+	a := testAgent(t, f)
+	os.Mkdir("src", 0755); writeTestFile(t, "a.go", "package a // old\n"); writeTestFile(t, "src/b.go", "package b // old\n")
+	w := &watcher{ours: map[string]stamp{}, globs: []*regexp.Regexp{regexp.MustCompile("^" + globRegexp("**/*.go") + "$")}}
+	w.seen = w.scan(); w.track(a)
+	if _, err := a.Send("edit"); err != nil { t.Fatal(err) }
+	if len(w.ours) != 2 { t.Errorf("stamped %v, want a.go and src/b.go", w.ours) }
+	w.settle()
+	// c.go was written by a command, which isn't one of the agent's file tools, so it stays a change.
+	if changed := changedFiles(w.seen, w.scan()); strings.Join(changed, ",") != "c.go" { t.Errorf("changes after settling: %v, want only c.go", changed) }
+	writeTestFile(t, "a.go", "package a // edited by hand\n")
+	if changed := changedFiles(w.seen, w.scan()); strings.Join(changed, ",") != "a.go,c.go" { t.Errorf("an edit after the agent's should count: %v", changed) }
+}
@@ -0,0 +1,184 @@
+// Network tools: web_search (SearxNG or Brave, per the config's "search" block), fetch_url,
+// download_file and http_request. The last three go through guardedClient, which checks every
+// address actually dialed, so redirects and DNS tricks can't steer them across the line:
+// fetch_url and download_file are for the public internet (unless fetch_allow_private),
+// http_request for local and private services (unless http_allow_public).
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func isPrivate(ip net.IP) bool { return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() }
+
+// guardedClient refuses connections to addresses allow rejects, explaining why.
+func guardedClient(allow func(net.IP) bool, why string) *http.Client {
+	d := &net.Dialer{Timeout: 10 * time.Second, Control: func(_, address string, _ syscall.RawConn) error {
+		host, _, _ := net.SplitHostPort(address)
+		if ip := net.ParseIP(host); ip == nil || !allow(ip) { return fmt.Errorf("%s refused: %s", host, why) }
+		if offline && !net.ParseIP(host).IsLoopback() { return fmt.Errorf("%s refused: %w", host, errOffline) }
+		return nil
+	}}
+	if offline { d.Resolver = hostsOnly }
+	return &http.Client{Timeout: 30 * time.Second, Transport: &http.Transport{DialContext: d.DialContext, TLSHandshakeTimeout: 10 * time.Second}}
+}
+
+// netError makes transport failures readable for the model.
+func netError(err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) { if ue.Timeout() { return fmt.Errorf("request to %s timed out", ue.URL) }; return fmt.Errorf("request to %s failed: %v", ue.URL, ue.Err) }
+	return err
+}
+
+func searchAvailable() error {
+	switch cfg.Search.Provider {
+	case "": return errors.New(`needs a search provider: set "search": {"provider": "searxng", "url": ...} or {"provider": "brave"} in config`)
+	case "searxng": if cfg.Search.URL == "" { return errors.New(`search provider searxng needs "url"`) }
+	case "brave": if searchKey() == "" { return errors.New("search provider brave needs a key (config search.key or $BRAVE_API_KEY)") }
+	default: return fmt.Errorf("unknown search provider %q (want searxng or brave)", cfg.Search.Provider)
+	}
+	return nil
+}
+
+func searchKey() string { if cfg.Search.Key != "" { return cfg.Search.Key }; return os.Getenv("BRAVE_API_KEY") }
+
+func webSearch(in Input) (string, error) {
+	if err := searchAvailable(); err != nil { return "", err }
+	n := in.Int("max_results", 5); if n < 1 || n > 20 { n = 5 }
+	q := url.QueryEscape(in.Str("query"))
+	var req *http.Request
+	if cfg.Search.Provider == "searxng" {
+		req, _ = http.NewRequestWithContext(runCtx, "GET", strings.TrimSuffix(cfg.Search.URL, "/")+"/search?format=json&q="+q, nil)
+	} else {
+		endpoint := cfg.Search.URL; if endpoint == "" { endpoint = "https://api.search.brave.com/res/v1/web/search" }
+		req, _ = http.NewRequestWithContext(runCtx, "GET", fmt.Sprintf("%s?q=%s&count=%d", endpoint, q, n), nil)
+		req.Header.Set("X-Subscription-Token", searchKey()); req.Header.Set("Accept", "application/json")
+	}
+	// The search endpoint is configured by the user, so it may well be a local SearxNG.
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req); if err != nil { return "", netError(err) }; defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20)); if err != nil { return "", netError(err) }
+	if resp.StatusCode != 200 { return "", fmt.Errorf("search provider returned %s: %s", resp.Status, truncate(string(raw), 200)) }
+	type hit struct{ Title, URL, Snippet string }
+	var hits []hit
+	if cfg.Search.Provider == "searxng" {
+		var v struct{ Results []struct{ Title, URL, Content string } }
+		if err := json.Unmarshal(raw, &v); err != nil { return "", fmt.Errorf("unexpected search response: %w", err) }
+		for _, r := range v.Results { hits = append(hits, hit{r.Title, r.URL, r.Content}) }
+	} else {
+		var v struct{ Web struct{ Results []struct{ Title, URL, Description string } } }
+		if err := json.Unmarshal(raw, &v); err != nil { return "", fmt.Errorf("unexpected search response: %w", err) }
+		for _, r := range v.Web.Results { hits = append(hits, hit{r.Title, r.URL, r.Description}) }
+	}
+	if len(hits) == 0 { return "no results", nil }
+	var sb strings.Builder
+	for i, h := range hits[:min(len(hits), n)] { fmt.Fprintf(&sb, "%d. %s\n   %s\n   %s\n", i+1, stripTags(h.Title), h.URL, truncate(stripTags(h.Snippet), 300)) }
+	return sb.String(), nil
+}
+
+func fetchURL(in Input) (string, error) {
+	u, err := url.Parse(in.Str("url")); if err != nil || (u.Scheme != "http" && u.Scheme != "https") { return "", fmt.Errorf("not an http(s) URL: %q", in.Str("url")) }
+	allow := func(ip net.IP) bool { return cfg.FetchAllowPrivate || !isPrivate(ip) }
+	resp, err := guardedClient(allow, "fetch_url only reaches public addresses (set fetch_allow_private in config to change)").Do(getRequest(u)); if err != nil { return "", netError(err) }; defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20)); if err != nil { return "", netError(err) }
+	ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	body := string(raw)
+	switch {
+	case ct == "text/html" || ct == "application/xhtml+xml": body = htmlText(body)
+	case strings.HasPrefix(ct, "text/") || strings.Contains(ct, "json") || strings.Contains(ct, "xml") || ct == "": // as is
+	default: return "", fmt.Errorf("%s returned binary content (%s, %d bytes); use download_file or bash to fetch it", u, ct, len(raw))
+	}
+	out := clip(body)
+	if resp.StatusCode != 200 { return out, fmt.Errorf("%s returned %s", u, resp.Status) }
+	return out, nil
+}
+
+// getRequest is a GET for u under runCtx, so --deadline cuts it short.
+func getRequest(u *url.URL) *http.Request { req, _ := http.NewRequestWithContext(runCtx, "GET", u.String(), nil); return req }
+
+// downloadFile streams url into a temporary file next to path and renames it into place, so
+// an interrupted or oversized download never leaves a truncated file behind.
+func downloadFile(in Input) (string, error) {
+	u, err := url.Parse(in.Str("url")); if err != nil || (u.Scheme != "http" && u.Scheme != "https") { return "", fmt.Errorf("not an http(s) URL: %q", in.Str("url")) }
+	path, err := resolvePath(in.Str("path")); if err != nil { return "", err }
+	defer lockPath(path)()
+	limit := cfg.DownloadMaxBytes; if limit <= 0 { limit = 100 << 20 }
+	c := guardedClient(func(ip net.IP) bool { return cfg.FetchAllowPrivate || !isPrivate(ip) }, "download_file only reaches public addresses (set fetch_allow_private in config to change)")
+	c.Timeout = 10 * time.Minute
+	c.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+		if r.URL.Scheme != "https" { return fmt.Errorf("refusing redirect to non-https %s", r.URL) }
+		if len(via) >= 10 { return errors.New("too many redirects") }
+		return nil
+	}
+	resp, err := c.Do(getRequest(u)); if err != nil { return "", netError(err) }; defer resp.Body.Close()
+	if resp.StatusCode != 200 { return "", fmt.Errorf("%s returned %s", u, resp.Status) }
+	if resp.ContentLength > limit { return "", fmt.Errorf("%s is %d bytes, over the %d byte download limit", u, resp.ContentLength, limit) }
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".nano-download-*"); if err != nil { return "", err }
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(resp.Body, limit+1))
+	if cerr := tmp.Close(); err == nil { err = cerr }
+	if err != nil { return "", netError(err) }
+	if n > limit { return "", fmt.Errorf("%s exceeded the %d byte download limit", u, limit) }
+	if err := os.Chmod(tmp.Name(), 0644); err != nil { return "", err }
+	if err := os.Rename(tmp.Name(), path); err != nil { return "", err }
+	recordWriteHash(path, hashSum(h.Sum(nil)))
+	ct := resp.Header.Get("Content-Type"); if ct == "" { ct = "unknown type" }
+	return fmt.Sprintf("wrote %d bytes (%s) to %s", n, ct, in.Str("path")), nil
+}
+
+// shownHeaders are the response headers http_request reports; the rest are noise.
+var shownHeaders = []string{"Content-Type", "Content-Length", "Location", "Set-Cookie", "WWW-Authenticate", "Retry-After", "Cache-Control"}
+
+func httpRequest(in Input) (string, error) {
+	u, err := url.Parse(in.Str("url")); if err != nil || (u.Scheme != "http" && u.Scheme != "https") { return "", fmt.Errorf("not an http(s) URL: %q", in.Str("url")) }
+	method := strings.ToUpper(in.Str("method")); if method == "" { method = "GET" }
+	req, err := http.NewRequestWithContext(runCtx, method, u.String(), strings.NewReader(in.Str("body"))); if err != nil { return "", err }
+	if h, ok := in["headers"].(map[string]any); ok { for k, v := range h { req.Header.Set(k, fmt.Sprint(v)) } }
+	c := guardedClient(func(ip net.IP) bool { return cfg.HTTPAllowPublic || isPrivate(ip) }, "http_request only reaches local and private addresses (set http_allow_public in config to change)")
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	start := time.Now()
+	resp, err := c.Do(req); if err != nil { return "", netError(err) }; defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20)); if err != nil { return "", netError(err) }
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s (%s)\n", resp.Proto, resp.Status, time.Since(start).Round(time.Millisecond))
+	for _, k := range shownHeaders { for _, v := range resp.Header.Values(k) { fmt.Fprintf(&sb, "%s: %s\n", k, v) } }
+	sb.WriteString("\n")
+	var pretty bytes.Buffer
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") && json.Indent(&pretty, raw, "", "  ") == nil { raw = pretty.Bytes() }
+	sb.Write(raw)
+	return clip(sb.String()), nil
+}
+
+var (
+	htmlDrop   = regexp.MustCompile(`(?is)<(script|style|noscript|svg|head)\b.*?</(script|style|noscript|svg|head)>|<!--.*?-->`)
+	htmlBreak  = regexp.MustCompile(`(?i)<(br|/p|/div|/li|/h[1-6]|/tr|/pre|hr)\b[^>]*>`)
+	htmlTag    = regexp.MustCompile(`<[^>]*>`)
+	blankLines = regexp.MustCompile(`\n\s*\n\s*\n+`)
+)
+
+// htmlText is a crude readable rendering of a page: markup and scripts gone, blocks on lines.
+func htmlText(s string) string {
+	s = htmlDrop.ReplaceAllString(s, ""); s = htmlBreak.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+	lines := strings.Split(s, "\n"); for i, l := range lines { lines[i] = strings.Join(strings.Fields(l), " ") }
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+func stripTags(s string) string { return html.UnescapeString(htmlTag.ReplaceAllString(s, "")) }
@@ -0,0 +1,41 @@
+// -C/--dir: run as if nano had been started in another directory, as with git -C. The chdir
+// happens before anything looks at the working directory, so the project's .nano.json, the
+// ignore rules, AGENTS.md files, the sandbox root, the session's recorded directory and the
+// run lock all belong to that directory. Paths the user typed for their own files (--record,
+// --replay, --log-file, --audit-log, $NANO_AUDIT_LOG) keep meaning what they meant where the
+// command was typed.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// invocationDir is where nano was started, before any -C.
+var invocationDir, _ = os.Getwd()
+
+// leadingDir takes -C dir (or --dir dir, -C=dir, ...) off the front of args, so it works
+// before a subcommand too: nano -C ~/src/app fix -- go test ./...
+func leadingDir(args []string) (dir string, rest []string) {
+	if len(args) == 0 { return "", args }
+	for _, name := range []string{"-C", "--C", "-dir", "--dir"} {
+		if args[0] == name && len(args) > 1 { return args[1], args[2:] }
+		if v, ok := strings.CutPrefix(args[0], name+"="); ok { return v, args[1:] }
+	}
+	return "", args
+}
+
+// changeDir validates dir and makes it the working directory.
+func changeDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if err != nil { return fmt.Errorf("-C: %w", err) } else if !fi.IsDir() { return fmt.Errorf("-C: %s is not a directory", dir) }
+	return os.Chdir(dir)
+}
+
+// fromInvocation makes relative paths absolute against the invocation directory.
+func fromInvocation(paths ...*string) {
+	for _, p := range paths { if *p != "" && !filepath.IsAbs(*p) { *p = filepath.Join(invocationDir, *p) } }
+}
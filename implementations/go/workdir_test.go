@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLeadingDir(t *testing.T) {
+	for _, c := range []struct{ args []string; dir string; rest []string }{
+		{[]string{"-C", "app", "fix", "--", "go", "test"}, "app", []string{"fix", "--", "go", "test"}},
+		{[]string{"--dir=app", "prompt"}, "app", []string{"prompt"}},
+		{[]string{"-C=app"}, "app", []string{}},
+		{[]string{"--dir", "app"}, "app", []string{}},
+		{[]string{"fix", "-C", "app"}, "", []string{"fix", "-C", "app"}}, // only in front
+		{[]string{"-C"}, "", []string{"-C"}},
+		{nil, "", nil},
+	} {
+		dir, rest := leadingDir(c.args)
+		if dir != c.dir || !slices.Equal(rest, c.rest) { t.Errorf("%q: %q %q, want %q %q", c.args, dir, rest, c.dir, c.rest) }
+	}
+}
+
+func TestChangeDirRefusesNonDirectories(t *testing.T) {
+	inTempDir(t); writeTestFile(t, "file", "")
+	if err := changeDir("missing"); err == nil || !strings.HasPrefix(err.Error(), "-C: ") { t.Errorf("missing dir: %v", err) }
+	if err := changeDir("file"); err == nil || !strings.Contains(err.Error(), "is not a directory") { t.Errorf("a file: %v", err) }
+}
+
+func TestFlagPathsResolveAgainstTheInvocationDir(t *testing.T) {
+	typed := inTempDir(t); typed, _ = filepath.EvalSymlinks(typed)
+	saved := invocationDir; invocationDir = typed; t.Cleanup(func() { invocationDir = saved })
+	if err := os.Mkdir("project", 0755); err != nil { t.Fatal(err) }
+	if err := changeDir("project"); err != nil { t.Fatal(err) }
+	answer, history, record, abs, unset := "out/answer.md", "seed.json", "../rec.json", "/tmp/changes.json", ""
+	fromInvocation(&answer, &history, &record, &abs, &unset)
+	for _, c := range []struct{ got, want string }{
+		{answer, filepath.Join(typed, "out", "answer.md")}, {history, filepath.Join(typed, "seed.json")},
+		{record, filepath.Join(filepath.Dir(typed), "rec.json")}, {abs, "/tmp/changes.json"}, {unset, ""},
+	} {
+		if c.got != c.want { t.Errorf("got %q, want %q", c.got, c.want) }
+	}
+	if wd, _ := os.Getwd(); filepath.Base(wd) != "project" { t.Errorf("working directory %s, want the -C one", wd) }
+	// and a file named that way lands where the command was typed
+	os.Mkdir(filepath.Join(typed, "out"), 0755)
+	if err := os.WriteFile(answer, []byte("ok"), 0644); err != nil { t.Fatal(err) }
+	if _, err := os.Stat(filepath.Join(typed, "out", "answer.md")); err != nil { t.Error(err) }
+	if _, err := os.Stat(filepath.Join("out", "answer.md")); err == nil { t.Error("written under the -C directory") }
+}
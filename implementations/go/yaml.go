@@ -0,0 +1,144 @@
+// A small YAML subset parser: block mappings and sequences, plain/quoted scalars, flow
+// lists of scalars ([a, b]), | and > block scalars, and comments. Values decode to the same
+// shapes encoding/json produces (map[string]any, []any, string, float64, bool, nil) so
+// callers can treat YAML and JSON documents alike. Anchors, tags and flow maps are not
+// supported.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var yamlNumber = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+type yamlLine struct{ indent, num int; text string }
+
+func parseYAML(data string) (any, error) {
+	var lines []yamlLine
+	for n, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		text := strings.TrimRight(stripYAMLComment(raw), " \t")
+		if t := strings.TrimSpace(text); t == "" || t == "---" || t == "..." { lines = append(lines, yamlLine{-1, n + 1, raw}); continue }
+		lines = append(lines, yamlLine{len(text) - len(strings.TrimLeft(text, " ")), n + 1, strings.TrimLeft(text, " ")})
+	}
+	p := &yamlParser{lines: lines}
+	p.skipBlank()
+	if p.i >= len(lines) { return nil, nil }
+	v, err := p.node(lines[p.i].indent); if err != nil { return nil, err }
+	if p.skipBlank(); p.i < len(lines) { return nil, fmt.Errorf("yaml line %d: unexpected indentation", lines[p.i].num) }
+	return v, nil
+}
+
+type yamlParser struct{ lines []yamlLine; i int }
+
+func (p *yamlParser) skipBlank() { for p.i < len(p.lines) && p.lines[p.i].indent < 0 { p.i++ } }
+
+func (p *yamlParser) node(indent int) (any, error) {
+	if l := p.lines[p.i]; l.text == "-" || strings.HasPrefix(l.text, "- ") { return p.seq(indent) }
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) seq(indent int) (any, error) {
+	out := []any{}
+	for p.skipBlank(); p.i < len(p.lines) && p.lines[p.i].indent == indent; p.skipBlank() {
+		l := &p.lines[p.i]
+		if l.text != "-" && !strings.HasPrefix(l.text, "- ") { return nil, fmt.Errorf("yaml line %d: expected a list item", l.num) }
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" {
+			p.i++; p.skipBlank()
+			if p.i >= len(p.lines) || p.lines[p.i].indent <= indent { out = append(out, nil); continue }
+			v, err := p.node(p.lines[p.i].indent); if err != nil { return nil, err }; out = append(out, v); continue
+		}
+		// "- key: value" starts a mapping whose keys line up with "key"; re-read the line as such.
+		if _, _, ok := splitYAMLKey(rest); ok {
+			l.indent, l.text = l.indent+len(l.text)-len(rest), rest
+			v, err := p.mapping(l.indent); if err != nil { return nil, err }; out = append(out, v); continue
+		}
+		v, err := p.scalar(rest, indent); if err != nil { return nil, err }; out = append(out, v)
+	}
+	return out, nil
+}
+
+func (p *yamlParser) mapping(indent int) (any, error) {
+	out := map[string]any{}
+	for p.skipBlank(); p.i < len(p.lines) && p.lines[p.i].indent == indent; p.skipBlank() {
+		l := p.lines[p.i]
+		key, val, ok := splitYAMLKey(l.text); if !ok { return nil, fmt.Errorf("yaml line %d: expected key: value", l.num) }
+		if val != "" { v, err := p.scalar(val, indent); if err != nil { return nil, err }; out[key] = v; continue }
+		p.i++; p.skipBlank()
+		if p.i < len(p.lines) && (p.lines[p.i].indent > indent || p.lines[p.i].indent == indent && strings.HasPrefix(p.lines[p.i].text, "-")) {
+			v, err := p.node(p.lines[p.i].indent); if err != nil { return nil, err }; out[key] = v
+		} else { out[key] = nil }
+	}
+	return out, nil
+}
+
+// scalar parses the value on the current line (consuming it) plus any block-scalar body.
+func (p *yamlParser) scalar(s string, indent int) (any, error) {
+	num := p.lines[p.i].num; p.i++
+	switch {
+	case s == "|" || s == ">" || s == "|-" || s == ">-":
+		var body []string; base := -1
+		for ; p.i < len(p.lines); p.i++ {
+			l := p.lines[p.i]
+			if l.indent < 0 { body = append(body, ""); continue }
+			if l.indent <= indent { break }
+			if base < 0 { base = l.indent }
+			body = append(body, strings.Repeat(" ", max(l.indent-base, 0))+l.text)
+		}
+		for len(body) > 0 && body[len(body)-1] == "" { body = body[:len(body)-1] }
+		sep := "\n"; if s[0] == '>' { sep = " " }
+		text := strings.Join(body, sep); if !strings.HasSuffix(s, "-") { text += "\n" }
+		return text, nil
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s); if err != nil { return nil, fmt.Errorf("yaml line %d: bad quoted string", num) }; return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") { return nil, fmt.Errorf("yaml line %d: bad quoted string", num) }
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]"):
+		out := []any{}
+		for _, item := range strings.Split(s[1:len(s)-1], ",") {
+			if item = strings.TrimSpace(item); item != "" { out = append(out, yamlPlain(strings.Trim(item, `"'`))) }
+		}
+		return out, nil
+	}
+	return yamlPlain(s), nil
+}
+
+func yamlPlain(s string) any {
+	switch s {
+	case "~", "null", "Null", "NULL": return nil
+	case "true", "True", "TRUE": return true
+	case "false", "False", "FALSE": return false
+	}
+	if yamlNumber.MatchString(s) { f, _ := strconv.ParseFloat(s, 64); return f }
+	return s
+}
+
+// splitYAMLKey splits "key: value" (or "key:"), honouring a quoted key.
+func splitYAMLKey(s string) (string, string, bool) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		end := strings.IndexByte(s[1:], s[0]); if end < 0 { return "", "", false }
+		rest := s[end+2:]; if !strings.HasPrefix(rest, ":") { return "", "", false }
+		return s[1 : end+1], strings.TrimSpace(rest[1:]), true
+	}
+	if i := strings.Index(s, ": "); i > 0 { return s[:i], strings.TrimSpace(s[i+2:]), true }
+	if strings.HasSuffix(s, ":") && len(s) > 1 { return s[:len(s)-1], "", true }
+	return "", "", false
+}
+
+// stripYAMLComment drops a trailing "# comment" that isn't inside quotes.
+func stripYAMLComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0: if c == quote { quote = 0 }
+		case c == '"' || c == '\'': quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'): return s[:i]
+		}
+	}
+	return s
+}